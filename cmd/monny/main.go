@@ -10,8 +10,35 @@ import (
 )
 
 func main() {
+	sub, args := monny.Subcommand(os.Args[1:])
+	switch sub {
+	case monny.SubcommandCheck:
+		exit(sub, monny.Check(args, os.Stdout))
+	case monny.SubcommandFlush:
+		exit(sub, monny.Flush(args, os.Stdout))
+	case monny.SubcommandVersion:
+		exit(sub, monny.PrintVersion(args, os.Stdout))
+	case monny.SubcommandParallel:
+		run(args, monny.Parallel())
+	default:
+		run(args)
+	}
+}
+
+// exit exits with the result of a subcommand other than run
+func exit(sub string, err error) {
+	if err != nil {
+		if !errors.Is(err, pflag.ErrHelp) {
+			fmt.Printf("Error: %s\n\nUse monny %s --help for options\n", err, sub)
+		}
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
 
-	usercmd, opts, err := monny.ParseCommandLine()
+// run runs the command with the options parsed from args, followed by extra
+func run(args []string, extra ...monny.ConfigOption) {
+	usercmd, opts, err := monny.ParseRun(args)
 	if err != nil {
 		if !errors.Is(err, pflag.ErrHelp) {
 			fmt.Printf("Could not parse configuration: %s\n\nUse monny --help for options\n", err)
@@ -19,7 +46,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	cmd, errs := monny.New(usercmd, opts...)
+	cmd, errs := monny.New(usercmd, append(opts, extra...)...)
 	if len(errs) > 0 {
 		fmt.Println("Error in config:")
 		for _, e := range errs {
@@ -27,15 +54,72 @@ func main() {
 		}
 		os.Exit(1)
 	}
+	for _, w := range cmd.Config.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+	if len(cmd.Config.Schedule) > 0 {
+		schedule(usercmd, append(opts, extra...))
+	}
 
-	if err := cmd.Exec(); err != nil {
+	err = cmd.Exec()
+	code, ok := exitCode(err)
+	if !ok {
 		fmt.Println("Process error:", err)
+		cleanup(cmd)
 		os.Exit(1)
 	}
 	if err := cmd.Wait(); err != nil {
 		fmt.Printf("Not all reports sent: %s\n", err)
+		cleanup(cmd)
 		os.Exit(1)
 	}
+	cleanup(cmd)
+	os.Exit(code)
+}
 
+// schedule runs the command on its schedule until monny is stopped
+func schedule(usercmd []string, opts []monny.ConfigOption) {
+	s, errs := monny.NewScheduler(usercmd, opts...)
+	if len(errs) > 0 {
+		fmt.Println("Error in config:")
+		for _, e := range errs {
+			fmt.Println(e)
+		}
+		os.Exit(1)
+	}
+	if err := s.Run(); err != nil {
+		fmt.Println("Schedule error:", err)
+		os.Exit(1)
+	}
 	os.Exit(0)
 }
+
+// exitCode returns the exit code of monny for how the process ended.  A failed process passes through its exit status,
+// and a killed process exits with the same status as timeout(1) or a process killed with SIGKILL.  Returns false for
+// an error in monny itself.
+func exitCode(err error) (int, bool) {
+	var failed monny.ErrChildFailed
+	var timeout monny.ErrKilledOnTimeout
+	var memory monny.ErrKilledOnMemory
+	switch {
+	case err == nil:
+		return 0, true
+	case errors.As(err, &failed) && failed.Code > 0:
+		return failed.Code, true
+	case errors.As(err, &failed):
+		return 1, true
+	case errors.As(err, &timeout):
+		return 124, true
+	case errors.As(err, &memory):
+		return 137, true
+	default:
+		return 1, false
+	}
+}
+
+// cleanup removes temporary files created to run the command, since os.Exit does not run deferred calls
+func cleanup(cmd *monny.Command) {
+	for _, err := range cmd.Cleanup() {
+		fmt.Fprintf(os.Stderr, "Could not clean up: %s\n", err)
+	}
+}
@@ -2,6 +2,7 @@ package eventbus
 
 import (
 	"context"
+	"fmt"
 	"sync"
 )
 
@@ -27,14 +28,23 @@ type EventBus struct {
 	done        []chan struct{}
 	mutex       sync.RWMutex
 	sdStarted   bool
+
+	// forwarders deliver events in order to each subscriber channel when configured WithOrderedDelivery
+	forwarders map[chan Event]*forwarder
+	queueSize  int
 }
 
 // New returns a new event bus.  A default topic is created, but subscribers may create other topics
-// when they register.
-func New() *EventBus {
-	return &EventBus{
+// when they register.  Events may be delivered to a subscriber out of order unless the bus is created
+// WithOrderedDelivery.
+func New(opts ...Option) *EventBus {
+	e := &EventBus{
 		subscribers: make(map[Topic][]chan Event),
 	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
 }
 
 // ShutdownFunc tells the event bus that this subscriber has finished the shutdown process and it is safe to exit
@@ -72,6 +82,9 @@ func (e *EventBus) subscribe(topics ...Topic) (chan Event, chan struct{}) {
 	c := make(chan Event, 1)
 	done := make(chan struct{})
 	e.done = append(e.done, done)
+	if e.forwarders != nil {
+		e.forwarders[c] = newForwarder(c, e.queueSize)
+	}
 
 	// subscribe to the default topic if no topics defined
 	if len(topics) == 0 {
@@ -98,13 +111,14 @@ func (e *EventBus) Unsubscribe(c chan Event, done chan struct{}) {
 	for topic, chs := range e.subscribers {
 		for i, ch := range chs {
 			if ch == c {
-				close(ch)
-				recover()
+				e.closeSubscriber(ch)
 				e.subscribers[topic] = append(e.subscribers[topic][0:i], e.subscribers[topic][i+1:]...)
 			}
 		}
 	}
 
+	delete(e.forwarders, c)
+
 	for i, d := range e.done {
 		if d == done {
 			close(d)
@@ -136,6 +150,13 @@ func (e *EventBus) Dispatch(event Event, topics ...Topic) {
 			continue
 		}
 
+		if e.forwarders != nil {
+			for _, ch := range channels {
+				e.forwarders[ch].enqueue(event)
+			}
+			continue
+		}
+
 		// make a copy of the channels to preserve locking
 		chs := append([]chan Event{}, channels...)
 
@@ -176,12 +197,9 @@ func (e *EventBus) Shutdown(ctx context.Context) error {
 
 	for _, chs := range e.subscribers {
 		for _, ch := range chs {
-			// close all subscriber channels to signal shutdown, recover above in case
+			// close all subscriber channels to signal shutdown, closeSubscriber recovers in case
 			// one of the channels is closed improperly by subscriber which would cause a panic
-			close(ch)
-			if r := recover(); r != nil {
-				continue
-			}
+			e.closeSubscriber(ch)
 		}
 	}
 
@@ -193,6 +211,62 @@ func (e *EventBus) Shutdown(ctx context.Context) error {
 	}
 }
 
+// Reset closes the event channel of every subscriber without waiting for them to exit, removes all subscribers, and
+// clears the shutdown state so that the bus can be reused.  It is intended for tests that reuse a bus across subtests
+// and is not safe to call concurrently with Dispatch.  Returns an error if a subscriber closed its own event channel.
+func (e *EventBus) Reset() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	var closed int
+	// channels were already closed if shutdown started
+	if !e.sdStarted {
+		// a subscriber to multiple topics has the same channel in each
+		seen := make(map[chan Event]bool)
+		for _, chs := range e.subscribers {
+			for _, ch := range chs {
+				if seen[ch] {
+					continue
+				}
+				seen[ch] = true
+				if !e.closeSubscriber(ch) {
+					closed++
+				}
+			}
+		}
+	}
+	e.subscribers = make(map[Topic][]chan Event)
+	if e.forwarders != nil {
+		e.forwarders = make(map[chan Event]*forwarder)
+	}
+	e.done = nil
+	e.sdStarted = false
+	if closed > 0 {
+		return fmt.Errorf("eventbus: %d subscriber channels were already closed before reset", closed)
+	}
+	return nil
+}
+
+// closeSubscriber closes a subscriber channel, stopping its forwarder first when events are delivered in order, and
+// returns false if it was already closed
+func (e *EventBus) closeSubscriber(ch chan Event) bool {
+	if f, ok := e.forwarders[ch]; ok {
+		return f.close()
+	}
+	return closeEvents(ch)
+}
+
+// closeEvents closes a subscriber channel and returns false if it was already closed
+func closeEvents(ch chan Event) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+		}
+	}()
+	close(ch)
+	return true
+}
+
 // shutdownNotify will watch each channel for it to be closed on the subscriber end and sends the notification on the done
 // channel.  This should be called on the eventbus list of done channels. Subscribers should detect a closed send channel,
 // do cleanup, then close their done channel when all go routines have exited.
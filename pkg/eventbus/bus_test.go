@@ -205,3 +205,115 @@ func TestShutdown(t *testing.T) {
 	}
 
 }
+
+func TestReset(t *testing.T) {
+	isClosed := func(c chan Event) bool {
+		select {
+		case _, ok := <-c:
+			return !ok
+		case <-time.After(time.Second):
+			return false
+		}
+	}
+
+	e := New()
+	c1, _ := e.Subscribe()
+	c2, _ := e.Subscribe(Topic("test1"), Topic("test2"))
+	assert.NoError(t, e.Reset())
+	assert.True(t, isClosed(c1))
+	assert.True(t, isClosed(c2))
+	assert.Len(t, e.subscribers, 0)
+	assert.Len(t, e.done, 0)
+
+	// the bus is reusable after a shutdown and reset
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	e.Subscribe()
+	assert.Error(t, e.Shutdown(ctx))
+	assert.NoError(t, e.Reset())
+	assert.False(t, e.sdStarted)
+
+	c3, _ := e.Subscribe()
+	event := Event{t: EventType("test")}
+	e.Dispatch(event)
+	select {
+	case received := <-c3:
+		assert.Equal(t, event, received)
+	case <-time.After(time.Second):
+		t.Fatal("event not received after reset")
+	}
+
+	// a subscriber that closes its own channel is an error
+	c4, _ := e.Subscribe(Topic("closed"))
+	close(c4)
+	assert.Error(t, e.Reset())
+	assert.Len(t, e.subscribers, 0)
+}
+
+func TestOrderedDelivery(t *testing.T) {
+	decode := func(e Event) int {
+		var i int
+		if err := e.Decode(&i); err != nil {
+			t.Fatalf("unexpected error decoding event: %v", err)
+		}
+		return i
+	}
+
+	t.Run("in order", func(t *testing.T) {
+		e := New(WithOrderedDelivery(100))
+		c, _ := e.Subscribe(Topic("test"))
+		cd, _ := e.Subscribe()
+		for i := 0; i < 100; i++ {
+			evt, _ := NewEvent(EventType("test"), i)
+			e.Dispatch(evt, Topic("test"))
+		}
+		for _, ch := range []chan Event{c, cd} {
+			for i := 0; i < 100; i++ {
+				select {
+				case evt := <-ch:
+					assert.Equal(t, i, decode(evt))
+				case <-time.After(time.Second):
+					t.Fatalf("event %d not received", i)
+				}
+			}
+		}
+	})
+
+	t.Run("drop when full", func(t *testing.T) {
+		e := New(WithOrderedDelivery(5))
+		c, sd := e.Subscribe()
+		// dispatch does not block on a subscriber that is not receiving
+		for i := 0; i < 100; i++ {
+			evt, _ := NewEvent(EventType("test"), i)
+			e.Dispatch(evt)
+		}
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			e.Shutdown(ctx)
+		}()
+		var received []int
+		for evt := range c {
+			received = append(received, decode(evt))
+		}
+		sd()
+		assert.NotEmpty(t, received)
+		assert.True(t, len(received) < 100, "received %d events", len(received))
+		for i := 1; i < len(received); i++ {
+			assert.True(t, received[i-1] < received[i], "events out of order: %v", received)
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		e := New(WithOrderedDelivery(1))
+		c1, _ := e.Subscribe(Topic("test1"), Topic("test2"))
+		assert.NoError(t, e.Reset())
+		_, ok := <-c1
+		assert.False(t, ok)
+
+		c2, _ := e.Subscribe()
+		close(c2)
+		assert.Error(t, e.Reset())
+	})
+}
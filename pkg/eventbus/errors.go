@@ -10,6 +10,25 @@ const (
 // have exited
 var ErrShutdownTimeout error = fmt.Errorf("eventbus: context timeout or cancelled before all subscribers exited")
 
+// ErrNoPayload is returned by MustPayload if the event was created without data
+var ErrNoPayload error = fmt.Errorf("eventbus: event has no payload")
+
+// ErrPayloadType is returned by MustPayload if the payload can not be decoded into the receiver, usually because the
+// receiver is not a pointer to the type the event was created with
+type ErrPayloadType struct {
+	Event    EventType
+	Receiver string
+	Err      error
+}
+
+func (e ErrPayloadType) Error() string {
+	return fmt.Sprintf("eventbus: payload of event %s can not be decoded into %s: %v", e.Event, e.Receiver, e.Err)
+}
+
+func (e ErrPayloadType) Unwrap() error {
+	return e.Err
+}
+
 func OnErrorTopic() Topic {
 	return errorTopic
 }
@@ -5,6 +5,7 @@ import (
 	"encoding/gob"
 	"fmt"
 	"log"
+	"reflect"
 )
 
 // EventType represents the type of event being passed on the bus.  It allows handlers receiving the event to
@@ -37,6 +38,29 @@ func (e Event) Decode(receiver interface{}) error {
 	return nil
 }
 
+// MustPayload decodes the payload of e into out, which must be a non-nil pointer to the type the event was created
+// with.  It replaces a type check in each subscriber:
+//
+//	var le LogEvent
+//	if err := eventbus.MustPayload(e, &le); err != nil {
+//		return err
+//	}
+//
+// Returns ErrNoPayload if the event has no data and ErrPayloadType if out is not a pointer or is the wrong type.
+func MustPayload(e Event, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if out == nil || rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return ErrPayloadType{Event: e.t, Receiver: fmt.Sprintf("%T", out), Err: fmt.Errorf("receiver must be a non-nil pointer")}
+	}
+	if len(e.d) == 0 {
+		return ErrNoPayload
+	}
+	if err := gob.NewDecoder(bytes.NewReader(e.d)).Decode(out); err != nil {
+		return ErrPayloadType{Event: e.t, Receiver: rv.Elem().Type().String(), Err: err}
+	}
+	return nil
+}
+
 func NewEvent(t EventType, data interface{}) (Event, error) {
 	var b bytes.Buffer
 	if data != nil {
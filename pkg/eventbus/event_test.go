@@ -1,6 +1,7 @@
 package eventbus
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 
@@ -47,6 +48,28 @@ func TestPrimitiveTypes(t *testing.T) {
 	assert.Equal(t, []byte("test string"), b)
 }
 
+func TestMustPayload(t *testing.T) {
+	evt, err := NewEvent(EventType("test_event"), Tester{A: 1, B: "test"})
+	assert.NoError(t, err)
+
+	var r Tester
+	assert.NoError(t, MustPayload(evt, &r))
+	assert.Equal(t, Tester{A: 1, B: "test"}, r)
+
+	var typeErr ErrPayloadType
+	var wrong []string
+	assert.True(t, errors.As(MustPayload(evt, &wrong), &typeErr))
+	assert.Equal(t, "[]string", typeErr.Receiver)
+	assert.True(t, errors.As(MustPayload(evt, r), &typeErr))
+	assert.True(t, errors.As(MustPayload(evt, nil), &typeErr))
+	var nilPtr *Tester
+	assert.True(t, errors.As(MustPayload(evt, nilPtr), &typeErr))
+
+	empty, err := NewEvent(EventType("test_event"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ErrNoPayload, MustPayload(empty, &r))
+}
+
 func TestErrorEvent(t *testing.T) {
 	e := fmt.Errorf("test error")
 	evt, err := NewErrorEvent(EventType("test_error"), e)
@@ -0,0 +1,85 @@
+package eventbus
+
+// Option configures the event bus
+type Option func(*EventBus)
+
+// WithOrderedDelivery delivers events to each subscriber in the order they were dispatched.  Each subscriber has its own
+// queue of up to size events and a go routine that delivers them.  Dispatch does not block when a subscriber falls
+// behind; events are dropped when its queue is full.  A size less than 1 uses a queue of 1 event.
+func WithOrderedDelivery(size int) Option {
+	return func(e *EventBus) {
+		if size < 1 {
+			size = 1
+		}
+		e.queueSize = size
+		e.forwarders = make(map[chan Event]*forwarder)
+	}
+}
+
+// forwarder delivers the events queued for one subscriber in order
+type forwarder struct {
+	queue  chan Event
+	stop   *doneCloser
+	exited chan struct{}
+	// closed is false if the subscriber closed its own event channel
+	closed bool
+}
+
+func newForwarder(c chan Event, size int) *forwarder {
+	f := &forwarder{
+		queue:  make(chan Event, size),
+		stop:   &doneCloser{d: make(chan struct{})},
+		exited: make(chan struct{}),
+	}
+	go f.run(c)
+	return f
+}
+
+// enqueue adds the event to the queue without blocking and drops it if the queue is full
+func (f *forwarder) enqueue(evt Event) {
+	select {
+	case f.queue <- evt:
+	default:
+	}
+}
+
+// run delivers queued events until stopped, then closes the subscriber channel.  Events still queued when the forwarder
+// is stopped are dropped.
+func (f *forwarder) run(c chan Event) {
+	defer close(f.exited)
+	defer func() { f.closed = closeEvents(c) }()
+	for {
+		select {
+		case <-f.stop.d:
+			return
+		case evt := <-f.queue:
+			if !f.send(c, evt) {
+				return
+			}
+		}
+	}
+}
+
+// send blocks until the event is received or the forwarder is stopped.  Returns false if the forwarder should exit.
+func (f *forwarder) send(c chan Event, evt Event) (ok bool) {
+	// a subscriber that closes its own channel causes a panic on send
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+		}
+	}()
+	select {
+	case c <- evt:
+		return true
+	case <-f.stop.d:
+		return false
+	}
+}
+
+// close stops the forwarder and waits for it to close the subscriber channel.  Returns false if the subscriber closed
+// its own event channel.  It is safe to call more than once.
+func (f *forwarder) close() bool {
+	f.stop.close()
+	<-f.exited
+	return f.closed
+}
@@ -25,6 +25,8 @@ type Machine struct {
 	initial   State
 	allowable map[State][]State
 	stoppable stoppable
+	history   *history
+	hooks     []func(from, to State)
 }
 
 // NewMachine returns a new basic Machine with configured options.  If you do not utilize any
@@ -34,6 +36,7 @@ func NewMachine(initial State, opts ...MachineOption) (*Machine, error) {
 		current:   initial,
 		initial:   initial,
 		allowable: map[State][]State{},
+		history:   newHistory(defaultHistorySize),
 	}
 	for _, opt := range opts {
 		if err := opt(machine); err != nil {
@@ -58,6 +61,12 @@ func (m *Machine) Transition(to State) error {
 	return m.transition(to, m.stoppable)
 }
 
+// History returns the most recent state transitions from oldest to newest.  A reset is recorded as a transition to
+// the initial state.  The number of transitions retained is set with WithHistorySize.
+func (m *Machine) History() []StateTransition {
+	return m.history.values()
+}
+
 // Reset will reset the machine to its initial state and remove any stop condition if it
 // exists
 func (m *Machine) Reset() {
@@ -65,6 +74,7 @@ func (m *Machine) Reset() {
 }
 
 func (m *Machine) reset() error {
+	m.history.add(m.current, m.initial)
 	m.current = m.initial
 	m.stoppable.stopped = false
 	return nil
@@ -80,7 +90,12 @@ func (m *Machine) transition(to State, guards ...transitionGuard) error {
 
 	switch m.Allowable(m.current, to) {
 	case true:
+		from := m.current
+		m.history.add(from, to)
 		m.current = to
+		for _, hook := range m.hooks {
+			hook(from, to)
+		}
 		return nil
 	default:
 		m.stoppable.stopped = true
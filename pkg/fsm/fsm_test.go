@@ -95,3 +95,71 @@ func TestMachineStop(t *testing.T) {
 	assert.Equal(t, m.current, m.initial)
 	assert.True(t, m.stoppable.stopOnError)
 }
+
+func TestMachineHistory(t *testing.T) {
+	m, err := NewMachine(State("initial"), WithHistorySize(3), WithTransitions(
+		T(State("initial"), State("processing")),
+		T(State("processing"), State("error"), State("finished")),
+		T(State("error"), State("processing")),
+	))
+	assert.NoError(t, err)
+	assert.Len(t, m.History(), 0)
+	assert.NoError(t, m.Transition(State("processing")))
+	assert.Error(t, m.Transition(State("initial")))
+	assert.Equal(t, []State{"initial"}, from(m.History()))
+	assert.Equal(t, []State{"processing"}, to(m.History()))
+
+	assert.NoError(t, m.Transition(State("error")))
+	assert.NoError(t, m.Transition(State("processing")))
+	m.Reset()
+	// oldest transition is discarded when history is full
+	assert.Equal(t, []State{"processing", "error", "processing"}, from(m.History()))
+	assert.Equal(t, []State{"error", "processing", "initial"}, to(m.History()))
+	for _, h := range m.History() {
+		assert.False(t, h.At.IsZero())
+	}
+
+	_, err = NewMachine(State("initial"), WithHistorySize(0))
+	assert.Error(t, err)
+}
+
+func from(history []StateTransition) []State {
+	var out []State
+	for _, h := range history {
+		out = append(out, h.From)
+	}
+	return out
+}
+
+func to(history []StateTransition) []State {
+	var out []State
+	for _, h := range history {
+		out = append(out, h.To)
+	}
+	return out
+}
+
+func TestMachineOnTransition(t *testing.T) {
+	var calls []StateTransition
+	hook := func(from, to State) {
+		calls = append(calls, StateTransition{From: from, To: to})
+	}
+	var order []string
+	m, err := NewMachine(State("initial"), WithOnTransition(hook),
+		WithOnTransition(func(from, to State) { order = append(order, "first") }),
+		WithOnTransition(func(from, to State) { order = append(order, "second") }),
+		WithTransitions(
+			T(State("initial"), State("processing")),
+			T(State("processing"), State("error"), State("finished")),
+		))
+	assert.NoError(t, err)
+	assert.NoError(t, m.Transition(State("processing")))
+	// hooks are not called on a failed transition or reset
+	assert.Error(t, m.Transition(State("initial")))
+	m.Reset()
+	assert.Equal(t, []StateTransition{{From: "initial", To: "processing"}}, calls)
+	assert.Equal(t, []string{"first", "second"}, order)
+
+	_, err = NewMachine(State("initial"), WithOnTransition(nil))
+	assert.Error(t, err)
+}
@@ -0,0 +1,47 @@
+package fsm
+
+import "time"
+
+// defaultHistorySize is the number of transitions retained by a machine unless set with WithHistorySize
+const defaultHistorySize = 100
+
+// StateTransition records a change of state of the machine
+type StateTransition struct {
+	From State
+	To   State
+	At   time.Time
+}
+
+// history is a ring buffer that retains the most recent transitions
+type history struct {
+	transitions []StateTransition
+	next        int
+	full        bool
+}
+
+func newHistory(size int) *history {
+	return &history{transitions: make([]StateTransition, size)}
+}
+
+func (h *history) add(from, to State) {
+	if len(h.transitions) == 0 {
+		return
+	}
+	h.transitions[h.next] = StateTransition{From: from, To: to, At: time.Now()}
+	h.next = (h.next + 1) % len(h.transitions)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// values returns the retained transitions from oldest to newest
+func (h *history) values() []StateTransition {
+	if !h.full {
+		out := make([]StateTransition, h.next)
+		copy(out, h.transitions[0:h.next])
+		return out
+	}
+	out := make([]StateTransition, 0, len(h.transitions))
+	out = append(out, h.transitions[h.next:]...)
+	return append(out, h.transitions[0:h.next]...)
+}
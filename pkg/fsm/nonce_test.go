@@ -103,3 +103,14 @@ func TestMachineBadNonceStoppable(t *testing.T) {
 	assert.NotEqual(t, n2, n3)
 	assert.NoError(t, m.Transition(State("processing"), m.nonce.current))
 }
+
+func TestMachineNonceOnTransition(t *testing.T) {
+	var calls []State
+	m, err := NewMachineNonce(State("initial"), WithOnTransition(func(from, to State) { calls = append(calls, from, to) }),
+		WithTransitions(T(State("initial"), State("processing"))))
+	assert.NoError(t, err)
+	assert.Error(t, m.Transition(State("processing"), []byte{}))
+	assert.Len(t, calls, 0)
+	assert.NoError(t, m.Transition(State("processing"), m.Nonce()))
+	assert.Equal(t, []State{"initial", "processing"}, calls)
+}
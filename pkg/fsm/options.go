@@ -1,5 +1,7 @@
 package fsm
 
+import "fmt"
+
 // MachineOption represents options to initially set up a machine
 type MachineOption func(m *Machine) error
 
@@ -33,6 +35,31 @@ func WithStoppable() MachineOption {
 	}
 }
 
+// WithHistorySize sets the number of state transitions retained by the machine, which defaults to 100.  Older
+// transitions are discarded.
+func WithHistorySize(n int) MachineOption {
+	return func(m *Machine) error {
+		if n <= 0 {
+			return fmt.Errorf("history size must be greater than 0, got %d", n)
+		}
+		m.history = newHistory(n)
+		return nil
+	}
+}
+
+// WithOnTransition registers a hook that is called with the previous and new state after each successful transition.
+// Hooks run synchronously in the order they were registered, so Transition does not return until all hooks have
+// returned.  Hooks are not called on a failed transition or on Reset.
+func WithOnTransition(hook func(from, to State)) MachineOption {
+	return func(m *Machine) error {
+		if hook == nil {
+			return fmt.Errorf("transition hook must not be nil")
+		}
+		m.hooks = append(m.hooks, hook)
+		return nil
+	}
+}
+
 type stoppable struct {
 	stopOnError bool
 	stopped     bool
@@ -117,6 +117,32 @@ func (c *WindowedCounter) HistoryInclusive() []Counter {
 	return newHistory(append(c.hist, *c.current), c.MaxHistory, c.MaxHistoryDuration)
 }
 
+// At returns the count of the window containing t, including the current window.  Returns false if the window is not
+// retained in the history, either because it is older than MaxHistory or MaxHistoryDuration allow or because no
+// observations were added during the window.
+func (c *WindowedCounter) At(t time.Time) (int, bool) {
+	for _, h := range c.HistoryInclusive() {
+		if t.Before(h.start) {
+			continue
+		}
+		if h.duration == 0 || t.Before(h.start.Add(h.duration)) {
+			return h.Value(), true
+		}
+	}
+	return 0, false
+}
+
+// Nth returns the nth most recent window, where 0 is the current window and 1 is the window before it.  Returns false
+// if fewer than n+1 windows are retained.  Windows with no observations are not retained, so the nth window may be
+// more than n intervals ago.  Use At to look up a window by time.
+func (c *WindowedCounter) Nth(n int) (Counter, bool) {
+	hist := c.HistoryInclusive()
+	if n < 0 || n >= len(hist) {
+		return Counter{}, false
+	}
+	return hist[len(hist)-1-n], true
+}
+
 // filters the history based on both MaxHistoryDuration and MaxHistory
 func newHistory(hist []Counter, max int, maxduration time.Duration) []Counter {
 	if max == 0 && maxduration == 0 {
@@ -217,6 +243,18 @@ func (c *ConcurrentWindowedCounter) HistoryInclusive() []Counter {
 
 }
 
+func (c *ConcurrentWindowedCounter) At(t time.Time) (int, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.c.At(t)
+}
+
+func (c *ConcurrentWindowedCounter) Nth(n int) (Counter, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.c.Nth(n)
+}
+
 func NewConcurrentWindowedCounter(duration time.Duration) *ConcurrentWindowedCounter {
 	return &ConcurrentWindowedCounter{
 		c: NewWindowedCounter(duration),
@@ -117,3 +117,46 @@ func TestConcurrentCounters(t *testing.T) {
 	wg2.Wait()
 	assert.Equal(t, 50, w.Value())
 }
+
+func TestWindowedCounterLookup(t *testing.T) {
+	c := NewWindowedCounter(50 * time.Millisecond)
+	var starts []time.Time
+	for _, v := range []uint{1, 2, 3} {
+		c.Add(v)
+		starts = append(starts, c.current.start)
+		time.Sleep(60 * time.Millisecond)
+	}
+	c.Add(4)
+	starts = append(starts, c.current.start)
+
+	for i, expect := range []int{1, 2, 3, 4} {
+		count, ok := c.At(starts[i].Add(10 * time.Millisecond))
+		assert.True(t, ok)
+		assert.Equal(t, expect, count)
+	}
+	_, ok := c.At(starts[0].Add(-time.Second))
+	assert.False(t, ok)
+	_, ok = c.At(time.Now().Add(time.Hour))
+	assert.False(t, ok)
+
+	for n, expect := range []int{4, 3, 2, 1} {
+		counter, ok := c.Nth(n)
+		assert.True(t, ok)
+		assert.Equal(t, expect, counter.Value())
+		assert.Equal(t, starts[3-n], counter.Start())
+	}
+	_, ok = c.Nth(4)
+	assert.False(t, ok)
+	_, ok = c.Nth(-1)
+	assert.False(t, ok)
+
+	// windows dropped from the history are not found
+	c.MaxHistory = 2
+	_, ok = c.At(starts[0].Add(10 * time.Millisecond))
+	assert.False(t, ok)
+	count, ok := c.At(starts[3].Add(10 * time.Millisecond))
+	assert.True(t, ok)
+	assert.Equal(t, 4, count)
+	_, ok = c.Nth(2)
+	assert.False(t, ok)
+}
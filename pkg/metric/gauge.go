@@ -1 +1,265 @@
 package metric
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+var _ GaugeI = &Gauge{}
+var _ GaugeI = &WindowedGauge{}
+var _ GaugeI = &ConcurrentGauge{}
+
+// GaugeI is the basic interface for a gauge that returns its current value and can be set to any value
+type GaugeI interface {
+	Value() float64
+	Set(v float64)
+	Reset()
+}
+
+// GaugeOption is a function for setting options on a gauge
+type GaugeOption func(g *Gauge) error
+
+// Gauge holds the most recent value of a measurement that can increase or decrease, such as current memory or
+// queue depth.  The minimum and maximum values set since the last reset are also tracked.
+type Gauge struct {
+	name     Name
+	start    time.Time
+	duration time.Duration
+	value    float64
+	min      float64
+	max      float64
+	set      bool
+}
+
+// Value returns the most recent value of the gauge
+func (g *Gauge) Value() float64 {
+	return g.value
+}
+
+// Set sets the current value of the gauge to v
+func (g *Gauge) Set(v float64) {
+	switch {
+	case !g.set:
+		g.min, g.max = v, v
+	case v < g.min:
+		g.min = v
+	case v > g.max:
+		g.max = v
+	}
+	g.value = v
+	g.set = true
+}
+
+// Reset sets the value of the gauge to zero and clears the minimum and maximum
+func (g *Gauge) Reset() {
+	g.value = 0.0
+	g.min = 0.0
+	g.max = 0.0
+	g.set = false
+}
+
+// Min returns the minimum value set since the last reset, or zero if no value has been set
+func (g Gauge) Min() float64 {
+	return g.min
+}
+
+// Max returns the maximum value set since the last reset, or zero if no value has been set
+func (g Gauge) Max() float64 {
+	return g.max
+}
+
+// Name returns the name of the gauge with its metadata, such as memory_gauge[host=pod1]
+func (g Gauge) Name() string {
+	return g.name.String()
+}
+
+// Start returns the time this gauge was started, which may be the time.Time null value for non-windowed gauges.  This
+// function is only useful when operating on a history of gauges returned by the History() function on windowed gauges.
+func (g Gauge) Start() time.Time {
+	return g.start
+}
+
+// Duration returns the duration of the gauge, which will be zero for non-windowed gauges. This function is only useful
+// when operating on a history of gauges returned by the History() function on windowed gauges.
+func (g Gauge) Duration() time.Duration {
+	return g.duration
+}
+
+// NewGauge returns a new gauge with a value of zero
+func NewGauge(opts ...GaugeOption) (*Gauge, error) {
+	g := &Gauge{}
+	for _, opt := range opts {
+		if err := opt(g); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// WithGaugeName sets the name and metadata of the gauge
+func WithGaugeName(name string, md map[string]string) GaugeOption {
+	return func(g *Gauge) error {
+		if name == "" {
+			return fmt.Errorf("gauge name must be the non-empty string")
+		}
+		g.name = NewName(name, md)
+		return nil
+	}
+}
+
+// WindowedGauge keeps track of the last, minimum, and maximum value of a gauge within a set duration.  A new gauge is
+// allocated on the first value set after the duration has elapsed and the previous window is kept in the history.  As with
+// WindowedCounter, windows with no values set are not kept in the history and MaxHistory and MaxHistoryDuration limit the
+// number and age of the previous windows that are retained.  Use NewWindowedGauge to initialize a windowed gauge.
+type WindowedGauge struct {
+	hist               []Gauge
+	current            *Gauge
+	MaxHistory         int
+	MaxHistoryDuration time.Duration
+}
+
+// Value returns the most recent value of the gauge.  Unlike a windowed counter, the value is retained after the window
+// closes until a new value is set.
+func (g *WindowedGauge) Value() float64 {
+	return g.current.Value()
+}
+
+// Min returns the minimum value set in the current window
+func (g *WindowedGauge) Min() float64 {
+	return g.current.Min()
+}
+
+// Max returns the maximum value set in the current window
+func (g *WindowedGauge) Max() float64 {
+	return g.current.Max()
+}
+
+// Name returns the name of the gauge with its metadata
+func (g *WindowedGauge) Name() string {
+	return g.current.Name()
+}
+
+// Set sets the current value of the gauge within the window to v
+func (g *WindowedGauge) Set(v float64) {
+	now := time.Now().UTC()
+	end := g.current.start.Add(g.current.duration)
+	switch {
+	case now.Before(end) || g.current.duration == 0:
+		g.current.Set(v)
+	default:
+		if g.current.set {
+			g.hist = newGaugeHistory(append(g.hist, *g.current), g.MaxHistory, g.MaxHistoryDuration)
+		}
+		g.current = &Gauge{name: g.current.name, start: now, duration: g.current.duration}
+		g.current.Set(v)
+	}
+}
+
+// History will return the history of gauges not including the current gauge if the window is still open
+func (g *WindowedGauge) History() []Gauge {
+	now := time.Now().UTC()
+	end := g.current.start.Add(g.current.duration)
+	switch {
+	case now.After(end) || g.current.duration == 0:
+		return newGaugeHistory(append(g.hist, *g.current), g.MaxHistory, g.MaxHistoryDuration)
+	default:
+		return newGaugeHistory(g.hist, g.MaxHistory, g.MaxHistoryDuration)
+	}
+}
+
+// HistoryInclusive will return the history of all gauges, including the current gauge even if the window is still open
+func (g *WindowedGauge) HistoryInclusive() []Gauge {
+	return newGaugeHistory(append(g.hist, *g.current), g.MaxHistory, g.MaxHistoryDuration)
+}
+
+// Reset will clear the history and start a new gauge with the same window duration
+func (g *WindowedGauge) Reset() {
+	g.hist = []Gauge{}
+	g.current = &Gauge{name: g.current.name, start: time.Now().UTC(), duration: g.current.duration}
+}
+
+// filters the history based on both MaxHistoryDuration and MaxHistory
+func newGaugeHistory(hist []Gauge, max int, maxduration time.Duration) []Gauge {
+	if max == 0 && maxduration == 0 {
+		return hist
+	}
+	if max > 0 && len(hist) > max {
+		hist = hist[len(hist)-max:]
+	}
+	if maxduration > 0 {
+		g := []Gauge{}
+		for _, h := range hist {
+			if time.Now().UTC().Sub(h.start) <= maxduration {
+				g = append(g, h)
+			}
+		}
+		return g
+	}
+	return hist
+}
+
+// NewWindowedGauge creates a new windowed gauge with a window size of duration
+func NewWindowedGauge(duration time.Duration, opts ...GaugeOption) (*WindowedGauge, error) {
+	g, err := NewGauge(opts...)
+	if err != nil {
+		return nil, err
+	}
+	g.start = time.Now().UTC()
+	g.duration = duration
+	return &WindowedGauge{
+		current: g,
+	}, nil
+}
+
+// ConcurrentGauge is a Gauge that is safe for concurrent use
+type ConcurrentGauge struct {
+	mu sync.RWMutex
+	g  *Gauge
+}
+
+func (g *ConcurrentGauge) Value() float64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.g.Value()
+}
+
+func (g *ConcurrentGauge) Set(v float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.g.Set(v)
+}
+
+func (g *ConcurrentGauge) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.g.Reset()
+}
+
+func (g *ConcurrentGauge) Min() float64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.g.Min()
+}
+
+func (g *ConcurrentGauge) Max() float64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.g.Max()
+}
+
+func (g *ConcurrentGauge) Name() string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.g.Name()
+}
+
+func NewConcurrentGauge(opts ...GaugeOption) (*ConcurrentGauge, error) {
+	g, err := NewGauge(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &ConcurrentGauge{
+		g: g,
+	}, nil
+}
@@ -0,0 +1,106 @@
+package metric
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGauge(t *testing.T) {
+	tt := []struct {
+		name   string
+		values []float64
+		expect float64
+		min    float64
+		max    float64
+	}{
+		{name: "increasing", values: []float64{1, 2, 3}, expect: 3, min: 1, max: 3},
+		{name: "decreasing", values: []float64{3, 2, 1}, expect: 1, min: 1, max: 3},
+		{name: "mixed", values: []float64{5, -2.5, 10, 4}, expect: 4, min: -2.5, max: 10},
+		{name: "single", values: []float64{7}, expect: 7, min: 7, max: 7},
+		{name: "none", values: []float64{}, expect: 0, min: 0, max: 0},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g, err := NewGauge(WithGaugeName("memory_gauge", map[string]string{"host": "pod1"}))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, v := range tc.values {
+				g.Set(v)
+			}
+			assert.Equal(t, tc.expect, g.Value())
+			assert.Equal(t, tc.min, g.Min())
+			assert.Equal(t, tc.max, g.Max())
+			assert.Equal(t, "memory_gauge[host=pod1]", g.Name())
+			g.Reset()
+			assert.Equal(t, 0.0, g.Value())
+			g.Set(-1)
+			assert.Equal(t, -1.0, g.Min())
+			assert.Equal(t, -1.0, g.Max())
+		})
+	}
+
+	_, err := NewGauge(WithGaugeName("", nil))
+	assert.Error(t, err)
+}
+
+func TestWindowedGauge(t *testing.T) {
+	g, err := NewWindowedGauge(50*time.Millisecond, WithGaugeName("queue_gauge", nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	g.Set(2)
+	g.Set(8)
+	g.Set(4)
+	assert.Equal(t, 4.0, g.Value())
+	assert.Len(t, g.History(), 0)
+	assert.Len(t, g.HistoryInclusive(), 1)
+
+	time.Sleep(60 * time.Millisecond)
+	// the last value is retained after the window closes
+	assert.Equal(t, 4.0, g.Value())
+	assert.Len(t, g.History(), 1)
+
+	g.Set(1)
+	assert.Equal(t, 1.0, g.Min())
+	assert.Equal(t, 1.0, g.Max())
+	hist := g.History()
+	if assert.Len(t, hist, 1) {
+		assert.Equal(t, 4.0, hist[0].Value())
+		assert.Equal(t, 2.0, hist[0].Min())
+		assert.Equal(t, 8.0, hist[0].Max())
+		assert.Equal(t, "queue_gauge", hist[0].Name())
+		assert.Equal(t, 50*time.Millisecond, hist[0].Duration())
+	}
+
+	g.MaxHistory = 1
+	time.Sleep(60 * time.Millisecond)
+	g.Set(3)
+	assert.Len(t, g.History(), 1)
+	assert.Equal(t, 1.0, g.History()[0].Value())
+
+	g.Reset()
+	assert.Len(t, g.History(), 0)
+	assert.Equal(t, "queue_gauge", g.Name())
+}
+
+func TestConcurrentGauge(t *testing.T) {
+	g, err := NewConcurrentGauge()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 1; i <= 100; i++ {
+		wg.Add(1)
+		go func(v float64) {
+			defer wg.Done()
+			g.Set(v)
+		}(float64(i))
+	}
+	wg.Wait()
+	assert.Equal(t, 1.0, g.Min())
+	assert.Equal(t, 100.0, g.Max())
+}
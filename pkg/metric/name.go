@@ -86,6 +86,61 @@ func MarshalText(m metadata) ([]byte, error) {
 	return b.Bytes(), nil
 }
 
+// ParseName parses the string representation of a name, such as requests_count[host=pod1 loc=us-west1 @mean], that is
+// returned by String
+func ParseName(s string) (Name, error) {
+	idx := strings.Index(s, "[")
+	if idx < 0 {
+		if len(s) == 0 || strings.Contains(s, "]") {
+			return Name{}, fmt.Errorf("invalid metric name %q", s)
+		}
+		return NewName(s, nil), nil
+	}
+	name := s[:idx]
+	if len(name) == 0 {
+		return Name{}, fmt.Errorf("invalid metric name %q, name is empty", s)
+	}
+	md, err := UnmarshalText([]byte(s[idx:]))
+	if err != nil {
+		return Name{}, fmt.Errorf("invalid metric name %q: %v", s, err)
+	}
+	return NewName(name, md), nil
+}
+
+// UnmarshalText decodes metadata encoded by MarshalText, such as [host=pod1 loc=us-west-1 @mean @summary]
+func UnmarshalText(text []byte) (metadata, error) {
+	if len(text) < 2 || text[0] != '[' || text[len(text)-1] != ']' {
+		return nil, fmt.Errorf("metadata must be enclosed in [ ]")
+	}
+	m := make(metadata)
+	d := logfmt.NewDecoder(bytes.NewReader(text[1 : len(text)-1]))
+	for records := 0; d.ScanRecord(); records++ {
+		if records > 0 {
+			return nil, fmt.Errorf("metadata must be on a single line")
+		}
+		for d.ScanKeyval() {
+			key := string(d.Key())
+			switch {
+			case strings.HasPrefix(key, "@") && d.Value() == nil:
+				if len(key) == 1 {
+					return nil, fmt.Errorf("annotation is empty")
+				}
+				m[key[1:]] = ""
+			case strings.HasPrefix(key, "@"):
+				return nil, fmt.Errorf("annotation %s can not have a value", key)
+			case len(d.Value()) == 0:
+				return nil, fmt.Errorf("metadata %s has no value, annotations start with @", key)
+			default:
+				m[key] = string(d.Value())
+			}
+		}
+	}
+	if err := d.Err(); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata: %v", err)
+	}
+	return m, nil
+}
+
 func NewNameFrom(n Name) Name {
 	copiedMD := make(map[string]string)
 	for k, v := range n.md {
@@ -6,20 +6,23 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// nameCases are names and their string representation
+var nameCases = []struct {
+	name string
+	n    string
+	md   map[string]string
+	exp  string
+}{
+	{name: "no metadata", n: "test_counter", exp: "test_counter"},
+	{name: "metadata", n: "test_counter", md: map[string]string{"host": "pod", "loc": "us-west-1"}, exp: "test_counter[host=pod loc=us-west-1]"},
+	{name: "metadata spaces", n: "test_counter", md: map[string]string{"loc": "us west 1", "host": "pod"}, exp: "test_counter[host=pod loc=\"us west 1\"]"},
+	{name: "metadata with annotations", n: "test_counter", md: map[string]string{"loc": "us-west-1", "host": "pod", "mean": ""}, exp: "test_counter[host=pod loc=us-west-1 @mean]"},
+	{name: "annotations only", n: "test_counter", md: map[string]string{"mean": "", "sampled": ""}, exp: "test_counter[@mean @sampled]"},
+	{name: "metadata quotes", n: "test_counter", md: map[string]string{"msg": `say "hi"`}, exp: `test_counter[msg="say \"hi\""]`},
+}
+
 func TestNameMarshal(t *testing.T) {
-	tt := []struct {
-		name string
-		n    string
-		md   map[string]string
-		exp  string
-	}{
-		{name: "no metadata", n: "test_counter", exp: "test_counter"},
-		{name: "metadata", n: "test_counter", md: map[string]string{"host": "pod", "loc": "us-west-1"}, exp: "test_counter[host=pod loc=us-west-1]"},
-		{name: "metadata spaces", n: "test_counter", md: map[string]string{"loc": "us west 1", "host": "pod"}, exp: "test_counter[host=pod loc=\"us west 1\"]"},
-		{name: "metadata with annotations", n: "test_counter", md: map[string]string{"loc": "us-west-1", "host": "pod", "mean": ""}, exp: "test_counter[host=pod loc=us-west-1 @mean]"},
-		{name: "annotations only", n: "test_counter", md: map[string]string{"mean": "", "sampled": ""}, exp: "test_counter[@mean @sampled]"},
-	}
-	for _, tc := range tt {
+	for _, tc := range nameCases {
 		t.Run(tc.name, func(t *testing.T) {
 			n := NewName(tc.n, tc.md)
 			assert.Equal(t, tc.exp, n.String())
@@ -27,6 +30,23 @@ func TestNameMarshal(t *testing.T) {
 	}
 }
 
+func TestParseName(t *testing.T) {
+	for _, tc := range nameCases {
+		t.Run(tc.name, func(t *testing.T) {
+			n := NewName(tc.n, tc.md)
+			parsed, err := ParseName(n.String())
+			assert.NoError(t, err)
+			assert.Equal(t, n, parsed)
+		})
+	}
+
+	invalid := []string{"", "[host=pod]", "test[host=pod", "test]", "test[host]", "test[@mean=yes]", "test[@]", "test[host=pod\nloc=us]"}
+	for _, s := range invalid {
+		_, err := ParseName(s)
+		assert.Error(t, err, s)
+	}
+}
+
 func TestAddMetadata(t *testing.T) {
 	tt := []struct {
 		name string
@@ -12,7 +12,7 @@ var _ SeriesRecorder = &SampledSeries{}
 type SampledSeries struct {
 	s         *Series
 	mu        sync.RWMutex
-	t         *time.Ticker
+	t         Ticker
 	obs       []float64
 	transform func([]float64) float64
 	done      chan bool
@@ -20,53 +20,129 @@ type SampledSeries struct {
 	direct    bool
 }
 
+// Ticker signals the end of each sample window.  NewSampledSeries uses a time.Ticker.  Use NewSampledSeriesWithTicker and
+// a ManualTicker to close windows deterministically in tests.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// timeTicker adapts time.Ticker to the Ticker interface
+type timeTicker struct {
+	t *time.Ticker
+}
+
+func (t timeTicker) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t timeTicker) Stop() {
+	t.t.Stop()
+}
+
+// acknowledger is implemented by tickers that wait until the sample window is recorded
+type acknowledger interface {
+	ack()
+}
+
+// ManualTicker is a Ticker that closes a sample window only when Tick is called
+type ManualTicker struct {
+	c    chan time.Time
+	ackC chan struct{}
+}
+
+// NewManualTicker returns a ticker that closes sample windows when Tick is called
+func NewManualTicker() *ManualTicker {
+	return &ManualTicker{
+		c:    make(chan time.Time),
+		ackC: make(chan struct{}),
+	}
+}
+
+func (m *ManualTicker) C() <-chan time.Time {
+	return m.c
+}
+
+func (m *ManualTicker) Stop() {}
+
+// Tick closes the current sample window and blocks until it is recorded in the series
+func (m *ManualTicker) Tick() {
+	m.c <- time.Now()
+	<-m.ackC
+}
+
+func (m *ManualTicker) ack() {
+	m.ackC <- struct{}{}
+}
+
+// NewSampledSeries returns a series that combines the observations within each sample window into a single value using
+// transform.  The returned function stops sampling and records any observations in the last partial window.  If
+// sampleWindow is zero, observations are recorded directly.
 func NewSampledSeries(capacity int, sampleWindow time.Duration, transform func([]float64) float64, opts ...SeriesOption) (*SampledSeries, func(), error) {
+	if sampleWindow > 0 {
+		return NewSampledSeriesWithTicker(capacity, timeTicker{time.NewTicker(sampleWindow)}, transform, opts...)
+	}
 	s, err := NewSeries(capacity, opts...)
 	if err != nil {
 		return nil, nil, fmt.Errorf("could not create sampled series: %v", err)
 	}
+	// if duration is zero, use direct mode where observations are immediately written to the underlying
+	// series storage.  The transform in this case is a no op.
+	ss := &SampledSeries{
+		s:         s,
+		t:         nil,
+		transform: nil,
+		done:      nil,
+		direct:    true,
+	}
+	return ss, func() {}, nil
+}
 
-	if sampleWindow > 0 {
-		ss := &SampledSeries{
-			s:         s,
-			t:         time.NewTicker(sampleWindow),
-			obs:       make([]float64, 0),
-			transform: transform,
-			done:      make(chan bool),
-		}
-		ss.wg.Add(1)
-		go func(s *SampledSeries) {
-			defer s.wg.Done()
-			for {
-				select {
-				case <-s.t.C:
-					s.mu.Lock()
-					if len(s.obs) == 0 {
-						s.s.Record(0.0)
-					} else {
-						s.s.Record(s.transform(s.obs))
-						s.obs = make([]float64, 0)
-					}
-					s.mu.Unlock()
-				case <-s.done:
-					s.t.Stop()
-					return
+// NewSampledSeriesWithTicker is like NewSampledSeries except each sample window ends when ticker fires
+func NewSampledSeriesWithTicker(capacity int, ticker Ticker, transform func([]float64) float64, opts ...SeriesOption) (*SampledSeries, func(), error) {
+	s, err := NewSeries(capacity, opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create sampled series: %v", err)
+	}
+
+	ss := &SampledSeries{
+		s:         s,
+		t:         ticker,
+		obs:       make([]float64, 0),
+		transform: transform,
+		done:      make(chan bool),
+	}
+	ss.wg.Add(1)
+	go func(s *SampledSeries) {
+		defer s.wg.Done()
+		for {
+			select {
+			case <-s.t.C():
+				s.mu.Lock()
+				if len(s.obs) == 0 {
+					s.s.Record(0.0)
+				} else {
+					s.s.Record(s.transform(s.obs))
+					s.obs = make([]float64, 0)
+				}
+				s.mu.Unlock()
+				if a, ok := s.t.(acknowledger); ok {
+					a.ack()
+				}
+			case <-s.done:
+				// flush observations in the last partial window so they contribute to the series
+				s.mu.Lock()
+				if len(s.obs) > 0 {
+					s.s.Record(s.transform(s.obs))
+					s.obs = make([]float64, 0)
 				}
+				s.mu.Unlock()
+				s.t.Stop()
+				return
 			}
-		}(ss)
-		return ss, func() { ss.done <- true; ss.wg.Wait() }, nil
-	} else {
-		// if duration is zero, use direct mode where observations are immediately written to the underlying
-		// series storage.  The transform in this case is a no op.
-		ss := &SampledSeries{
-			s:         s,
-			t:         nil,
-			transform: nil,
-			done:      nil,
-			direct:    true,
 		}
-		return ss, func() {}, nil
-	}
+	}(ss)
+	return ss, func() { ss.done <- true; ss.wg.Wait() }, nil
 }
 
 // Reset clears all previous recorded values and the count to zero.  This reuses the same backing slice to reduce
@@ -113,6 +189,14 @@ func (s *SampledSeries) Count() int {
 	return s.s.Count()
 }
 
+// Merge appends the observations in other to the underlying series.  Observations pending in the current sample
+// window are not affected.  See Series.Merge.
+func (s *SampledSeries) Merge(other *Series, opts ...MergeOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.Merge(other, opts...)
+}
+
 func SampleAverage(obs []float64) float64 {
 	if len(obs) == 0 {
 		return 0.0
@@ -142,6 +226,11 @@ func SampleMax(obs []float64) float64 {
 	return max
 }
 
+// SampleCount returns the number of observations in the sample, ignoring their values
+func SampleCount(obs []float64) float64 {
+	return float64(len(obs))
+}
+
 func SampleSum(obs []float64) float64 {
 	sum := 0.0
 	for _, o := range obs {
@@ -49,3 +49,47 @@ func TestSampledObservations(t *testing.T) {
 		})
 	}
 }
+
+func TestSampledFlushOnDone(t *testing.T) {
+	tt := []struct {
+		name  string
+		ops   []op
+		exp   []float64
+		count int
+	}{
+		{name: "partial window", ops: []op{r(1.0), r(3.0)}, exp: []float64{4.0, 0.0}, count: 1},
+		{name: "complete and partial window", ops: []op{r(1.0), d("130ms"), r(3.0)}, exp: []float64{1.0, 3.0}, count: 2},
+		{name: "empty partial window", ops: []op{r(1.0), d("130ms")}, exp: []float64{1.0, 0.0}, count: 1},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s, done, err := NewSampledSeries(2, 100*time.Millisecond, SampleSum)
+			assert.NoError(t, err)
+			for _, f := range tc.ops {
+				f(s)
+			}
+			done()
+			assert.Equal(t, tc.exp, s.Values())
+			assert.Equal(t, tc.count, s.Count())
+		})
+	}
+}
+
+func TestSampledManualTicker(t *testing.T) {
+	ticker := NewManualTicker()
+	s, done, err := NewSampledSeriesWithTicker(3, ticker, SampleSum)
+	assert.NoError(t, err)
+	defer done()
+
+	s.Record(1.0)
+	s.Record(3.0)
+	assert.Equal(t, 0, s.Count())
+	ticker.Tick()
+	assert.Equal(t, 1, s.Count())
+	// empty windows are recorded as zero
+	ticker.Tick()
+	s.Record(2.0)
+	ticker.Tick()
+	assert.Equal(t, []float64{4.0, 0.0, 2.0}, s.Values())
+	assert.Equal(t, 3, s.Count())
+}
@@ -1,11 +1,14 @@
 package metric
 
 import (
+	"errors"
 	"fmt"
 	"math"
+	"sync"
 )
 
 var _ SeriesRecorder = &Series{}
+var _ SeriesRecorder = &ConcurrentSeries{}
 
 type SeriesRecorder interface {
 	Values() []float64
@@ -16,10 +19,20 @@ type SeriesRecorder interface {
 	Reset()
 }
 
+// ErrZeroCapacity is returned for observations recorded into a series with no capacity, such as the zero value of
+// Series, which can not retain any observations
+var ErrZeroCapacity = errors.New("series has zero capacity, create it with NewSeries")
+
 type Series struct {
 	name   Name
 	count  int
 	values []float64
+	// dropped is the number of observations recorded into a series with no capacity
+	dropped int
+
+	// running mean and sum of squared differences from the mean of the values in the series (Welford's algorithm)
+	mean float64
+	m2   float64
 }
 
 type SeriesOption func(s *Series) error
@@ -28,15 +41,27 @@ type SeriesOption func(s *Series) error
 // to reduce allocations
 func (s *Series) Reset() {
 	s.count = 0
+	s.mean = 0.0
+	s.m2 = 0.0
 	for i := range s.values {
 		s.values[i] = 0.0
 	}
 }
 
+// Capacity returns the number of observations retained by the series.  It is zero for the zero value of Series.
 func (s *Series) Capacity() int {
 	return len(s.values)
 }
 
+// Err returns an error wrapping ErrZeroCapacity when observations were recorded into a series with no capacity and
+// discarded, such as when the zero value of Series is used instead of NewSeries
+func (s *Series) Err() error {
+	if s.dropped > 0 {
+		return fmt.Errorf("%w: %d observations discarded", ErrZeroCapacity, s.dropped)
+	}
+	return nil
+}
+
 // Values returns a copy of the current values in the series in temporal order from oldest to most recent
 func (s *Series) Values() []float64 {
 	switch {
@@ -51,14 +76,71 @@ func (s *Series) Values() []float64 {
 	}
 }
 
-// Record adds a new observation to the series
+// Record adds a new observation to the series.  Observations recorded into a series with no capacity are discarded
+// and reported by Err.
 func (s *Series) Record(p float64) {
 	if len(s.values) == 0 {
+		s.dropped++
 		return
 	}
 
-	s.values[s.nextIndex()] = p
+	i := s.nextIndex()
+	old := s.values[i]
+	full := s.count >= len(s.values)
+	s.values[i] = p
 	s.count++
+
+	if !full {
+		delta := p - s.mean
+		s.mean += delta / float64(s.count)
+		s.m2 += delta * (p - s.mean)
+		return
+	}
+	// the oldest observation is replaced, so the number of values is unchanged
+	delta := p - old
+	mean := s.mean + delta/float64(len(s.values))
+	m2 := s.m2 + delta*(p-mean+old-s.mean)
+	// removing an outlier cancels most of the sum, so recalculate from the remaining values to restore precision
+	if s.m2 > 0.0 && m2 <= s.m2*cancellation {
+		s.recalculate()
+		return
+	}
+	s.mean = mean
+	s.m2 = m2
+}
+
+// cancellation is the fraction of the sum of squared differences remaining after replacing an observation below
+// which the running variance is recalculated
+const cancellation = 1e-6
+
+// recalculate computes the running mean and variance from the values in the series
+func (s *Series) recalculate() {
+	s.mean = 0.0
+	s.m2 = 0.0
+	for i, v := range s.observed() {
+		delta := v - s.mean
+		s.mean += delta / float64(i+1)
+		s.m2 += delta * (v - s.mean)
+	}
+}
+
+// Mean returns the mean of the values in the series
+func (s *Series) Mean() float64 {
+	return s.mean
+}
+
+// Variance returns the sample variance of the values in the series.  It is maintained as each observation is recorded
+// using Welford's algorithm, which avoids the loss of precision of summing squares for large series or values of very
+// different magnitudes.  Returns 0 for fewer than two values.
+func (s *Series) Variance() float64 {
+	n := s.count
+	if n > len(s.values) {
+		n = len(s.values)
+	}
+	if n < 2 {
+		return 0.0
+	}
+	return s.m2 / float64(n-1)
 }
 
 // nextIndex returns the index of the oldest observation in the series to be overwritten by new data
@@ -70,6 +152,96 @@ func (s *Series) nextIndex() int {
 	return int(math.Mod(float64(s.count), float64(cap)))
 }
 
+// MergeOption modifies the behavior of Merge
+type MergeOption func(m *mergeOpt)
+
+type mergeOpt struct {
+	forceCapacity bool
+}
+
+// MergeForceCapacity allows series of different capacities to be merged.  The merged series is re-allocated to
+// the larger of the two capacities.
+func MergeForceCapacity() MergeOption {
+	return func(m *mergeOpt) {
+		m.forceCapacity = true
+	}
+}
+
+// Merge appends the observations in other to this series in temporal order, overwriting the oldest values once the
+// series is filled to capacity.  This is useful for combining series recorded by different processes into a single
+// baseline.  Series with different capacities return an error unless MergeForceCapacity is used, in which case the
+// series is re-allocated to the larger capacity and the count is reset to the number of observations retained.
+func (s *Series) Merge(other *Series, opts ...MergeOption) error {
+	if other == nil {
+		return fmt.Errorf("cannot merge a nil series")
+	}
+	o := &mergeOpt{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	incoming := other.observed()
+	switch {
+	case s.Capacity() == other.Capacity():
+	case o.forceCapacity:
+		cap := s.Capacity()
+		if other.Capacity() > cap {
+			cap = other.Capacity()
+		}
+		existing := s.observed()
+		s.values = make([]float64, cap)
+		s.count = 0
+		s.mean = 0.0
+		s.m2 = 0.0
+		for _, v := range existing {
+			s.Record(v)
+		}
+	default:
+		return fmt.Errorf("cannot merge series with capacity %d into series with capacity %d, use MergeForceCapacity to re-allocate", other.Capacity(), s.Capacity())
+	}
+
+	for _, v := range incoming {
+		s.Record(v)
+	}
+	return nil
+}
+
+// Downsample returns a new series that reduces each consecutive group of factor observations to a single value with
+// strategy, such as SampleAverage, for long-term retention of a trend at a lower resolution.  The new series has the
+// same name and a capacity of the capacity of this series divided by factor, rounded up.  When the number of
+// observations is not a multiple of factor, the most recent group is reduced from the observations it has.  A factor
+// less than 1 is treated as 1, and a nil strategy uses SampleAverage.
+func (s *Series) Downsample(factor int, strategy func([]float64) float64) *Series {
+	if factor < 1 {
+		factor = 1
+	}
+	if strategy == nil {
+		strategy = SampleAverage
+	}
+	out := &Series{
+		name:   s.name,
+		values: make([]float64, (len(s.values)+factor-1)/factor),
+	}
+	obs := s.observed()
+	for start := 0; start < len(obs); start += factor {
+		end := start + factor
+		if end > len(obs) {
+			end = len(obs)
+		}
+		out.Record(strategy(obs[start:end]))
+	}
+	return out
+}
+
+// observed returns only the recorded values in temporal order, without the zero values of an underfilled series
+func (s *Series) observed() []float64 {
+	values := s.Values()
+	if s.count < len(values) {
+		return values[0:s.count]
+	}
+	return values
+}
+
 // Count returns the total number of observations for this series
 func (s *Series) Count() int {
 	return s.count
@@ -118,3 +290,88 @@ func WithValues(values []float64) SeriesOption {
 		return nil
 	}
 }
+
+// ConcurrentSeries is a Series that is safe for concurrent use from multiple goroutines
+type ConcurrentSeries struct {
+	mu sync.RWMutex
+	s  *Series
+}
+
+func (c *ConcurrentSeries) Values() []float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.s.Values()
+}
+
+func (c *ConcurrentSeries) Record(observation float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.s.Record(observation)
+}
+
+func (c *ConcurrentSeries) Count() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.s.Count()
+}
+
+func (c *ConcurrentSeries) Name() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.s.Name()
+}
+
+func (c *ConcurrentSeries) Capacity() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.s.Capacity()
+}
+
+func (c *ConcurrentSeries) Mean() float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.s.Mean()
+}
+
+func (c *ConcurrentSeries) Variance() float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.s.Variance()
+}
+
+func (c *ConcurrentSeries) Err() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.s.Err()
+}
+
+func (c *ConcurrentSeries) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.s.Reset()
+}
+
+func (c *ConcurrentSeries) Merge(other *Series, opts ...MergeOption) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.s.Merge(other, opts...)
+}
+
+// Downsample returns a new series that reduces each consecutive group of factor observations with strategy.  The
+// returned series is not safe for concurrent use.
+func (c *ConcurrentSeries) Downsample(factor int, strategy func([]float64) float64) *Series {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.s.Downsample(factor, strategy)
+}
+
+// NewConcurrentSeries creates a new series safe for concurrent use with a capacity of cap
+func NewConcurrentSeries(cap int, opts ...SeriesOption) (*ConcurrentSeries, error) {
+	s, err := NewSeries(cap, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &ConcurrentSeries{
+		s: s,
+	}, nil
+}
@@ -1,6 +1,8 @@
 package metric
 
 import (
+	"errors"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -28,8 +30,147 @@ func TestSeriesRecord(t *testing.T) {
 	}
 }
 
+func TestSeriesVariance(t *testing.T) {
+	tt := []struct {
+		name     string
+		capacity int
+		obs      []float64
+		mean     float64
+		variance float64
+	}{
+		{name: "empty", capacity: 5, obs: []float64{}, mean: 0, variance: 0},
+		{name: "single", capacity: 5, obs: []float64{3}, mean: 3, variance: 0},
+		{name: "underfill", capacity: 5, obs: []float64{1, 2, 3}, mean: 2, variance: 1},
+		{name: "fill", capacity: 4, obs: []float64{1, 1, 2, 2}, mean: 1.5, variance: 1.0 / 3.0},
+		{name: "overfill", capacity: 3, obs: []float64{10, -4, 1, 2, 3}, mean: 2, variance: 1},
+		{name: "large magnitude", capacity: 3, obs: []float64{1e12, 1e9 + 1, 1e9 + 2, 1e9 + 3}, mean: 1e9 + 2, variance: 1},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _ := NewSeries(tc.capacity)
+			for _, o := range tc.obs {
+				s.Record(o)
+			}
+			assert.InDelta(t, tc.mean, s.Mean(), 1e-6)
+			assert.InDelta(t, tc.variance, s.Variance(), 1e-6)
+
+			s.Reset()
+			assert.Equal(t, 0.0, s.Mean())
+			assert.Equal(t, 0.0, s.Variance())
+		})
+	}
+}
+
+func TestZeroCapacity(t *testing.T) {
+	var s Series
+	assert.Equal(t, 0, s.Capacity())
+	assert.NoError(t, s.Err())
+
+	s.Record(1.0)
+	s.Record(2.0)
+	err := s.Err()
+	if assert.Error(t, err) {
+		assert.True(t, errors.Is(err, ErrZeroCapacity))
+		assert.Contains(t, err.Error(), "2 observations discarded")
+	}
+	assert.Equal(t, 0, s.Count())
+	assert.Empty(t, s.Values())
+
+	_, err = NewSeries(0)
+	assert.Error(t, err)
+	n, _ := NewSeries(2, WithValues([]float64{1, 2, 3}))
+	assert.NoError(t, n.Err())
+}
+
 func TestWithValues(t *testing.T) {
 	s, err := NewSeries(6, WithValues([]float64{1, 2, 3, 4}))
 	assert.NoError(t, err)
 	assert.Equal(t, []float64{1, 2, 3, 4, 0, 0}, s.Values())
 }
+
+func TestMerge(t *testing.T) {
+	tt := []struct {
+		name  string
+		s     []float64
+		sCap  int
+		o     []float64
+		oCap  int
+		opts  []MergeOption
+		exp   []float64
+		count int
+		err   bool
+	}{
+		{name: "underfill", s: []float64{1, 2}, sCap: 5, o: []float64{3, 4}, oCap: 5, exp: []float64{1, 2, 3, 4, 0}, count: 4},
+		{name: "overwrite oldest", s: []float64{1, 2, 3}, sCap: 3, o: []float64{4, 5}, oCap: 3, exp: []float64{3, 4, 5}, count: 5},
+		{name: "other overfilled", s: []float64{1}, sCap: 3, o: []float64{2, 3, 4, 5}, oCap: 3, exp: []float64{3, 4, 5}, count: 4},
+		{name: "capacity mismatch", s: []float64{1}, sCap: 3, o: []float64{2}, oCap: 4, err: true},
+		{name: "force larger capacity", s: []float64{1, 2, 3, 4}, sCap: 3, o: []float64{5}, oCap: 5, opts: []MergeOption{MergeForceCapacity()}, exp: []float64{2, 3, 4, 5, 0}, count: 4},
+		{name: "force smaller other", s: []float64{1, 2}, sCap: 4, o: []float64{3, 4, 5}, oCap: 2, opts: []MergeOption{MergeForceCapacity()}, exp: []float64{1, 2, 4, 5}, count: 4},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _ := NewSeries(tc.sCap, WithValues(tc.s))
+			o, _ := NewSeries(tc.oCap, WithValues(tc.o))
+			err := s.Merge(o, tc.opts...)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.exp, s.Values())
+			assert.Equal(t, tc.count, s.Count())
+		})
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	tt := []struct {
+		name     string
+		capacity int
+		obs      []float64
+		factor   int
+		strategy func([]float64) float64
+		exp      []float64
+		count    int
+	}{
+		{name: "mean", capacity: 6, obs: []float64{1, 2, 3, 4, 5, 6}, factor: 2, strategy: SampleAverage, exp: []float64{1.5, 3.5, 5.5}, count: 3},
+		{name: "max", capacity: 6, obs: []float64{1, 5, 3, 4, 2, 6}, factor: 3, strategy: SampleMax, exp: []float64{5, 6}, count: 2},
+		{name: "partial group", capacity: 10, obs: []float64{1, 2, 3, 4, 5, 6, 7}, factor: 3, strategy: SampleSum, exp: []float64{6, 15, 7, 0}, count: 3},
+		{name: "overfilled", capacity: 4, obs: []float64{9, 9, 1, 2, 3, 4}, factor: 2, strategy: SampleMin, exp: []float64{1, 3}, count: 2},
+		{name: "default strategy", capacity: 4, obs: []float64{2, 4, 6, 8}, factor: 4, exp: []float64{5}, count: 1},
+		{name: "factor less than one", capacity: 3, obs: []float64{1, 2, 3}, factor: 0, strategy: SampleAverage, exp: []float64{1, 2, 3}, count: 3},
+		{name: "empty", capacity: 5, factor: 2, strategy: SampleAverage, exp: []float64{0, 0, 0}, count: 0},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _ := NewSeries(tc.capacity, WithName("latency", nil), WithValues(tc.obs))
+			d := s.Downsample(tc.factor, tc.strategy)
+			assert.Equal(t, tc.exp, d.Values())
+			assert.Equal(t, tc.count, d.Count())
+			assert.Equal(t, s.Name(), d.Name())
+			assert.InDelta(t, SampleAverage(d.observed()), d.Mean(), 1e-9)
+		})
+	}
+}
+
+func TestConcurrentSeries(t *testing.T) {
+	s, err := NewConcurrentSeries(100)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				s.Record(1.0)
+				_ = s.Values()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 100, s.Count())
+	for _, v := range s.Values() {
+		assert.Equal(t, 1.0, v)
+	}
+}
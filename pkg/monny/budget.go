@@ -0,0 +1,176 @@
+package monny
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/metric"
+	"github.com/BTBurke/monny/pkg/proto"
+	"github.com/BTBurke/monny/pkg/trace"
+)
+
+// errorBudgetBuckets is the number of windows the error budget window is divided into, so that counts expire a
+// bucket at a time as the window rolls forward
+const errorBudgetBuckets = 30
+
+// errorBudgetMinRequests is the default number of requests in the window before the error budget can be exhausted,
+// otherwise a single error in the first request is an error rate of 100%
+const errorBudgetMinRequests = 20
+
+// budgetTolerance allows for rounding in the burn rate so that an error rate of exactly the budget is within it
+const budgetTolerance = 1e-9
+
+// errorBudget counts requests and errors over a rolling window and tracks the error rate against the error budget of
+// an SLO.  Requests are lines matching the total rule, or every line when there is none, and errors are lines
+// matching the error rule.
+type errorBudget struct {
+	slo         float64
+	window      time.Duration
+	minRequests int
+	totalRule   *regexp.Regexp
+	errorRule   *regexp.Regexp
+
+	mutex     sync.Mutex
+	total     *metric.WindowedCounter
+	errors    *metric.WindowedCounter
+	exhausted bool
+}
+
+// newErrorBudget returns the error budget set with the ErrorBudget option, or nil when there is none
+func newErrorBudget(cfg Config) *errorBudget {
+	if cfg.ErrorBudgetSLO == 0 {
+		return nil
+	}
+	counter := func() *metric.WindowedCounter {
+		c := metric.NewWindowedCounter(cfg.ErrorBudgetWindow / errorBudgetBuckets)
+		c.MaxHistoryDuration = cfg.ErrorBudgetWindow
+		return c
+	}
+	return &errorBudget{
+		slo:         cfg.ErrorBudgetSLO,
+		window:      cfg.ErrorBudgetWindow,
+		minRequests: budgetMinRequests(cfg),
+		totalRule:   cfg.budgetTotal,
+		errorRule:   cfg.budgetError,
+		total:       counter(),
+		errors:      counter(),
+	}
+}
+
+// budgetMinRequests returns the number of requests set with ErrorBudgetMinRequests, or the default
+func budgetMinRequests(cfg Config) int {
+	if cfg.budgetMinRequests > 0 {
+		return cfg.budgetMinRequests
+	}
+	return errorBudgetMinRequests
+}
+
+// IncrementTotal counts a request.  The budget is no longer exhausted once enough requests succeed to bring the error
+// rate back within the budget.
+func (b *errorBudget) IncrementTotal() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.total.Add(1)
+	if b.exhausted && !b.exceeded() {
+		b.exhausted = false
+	}
+}
+
+// IncrementError counts an error.  Returns true when the error rate exceeds the budget and it was not already
+// exceeded.
+func (b *errorBudget) IncrementError() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.errors.Add(1)
+	if b.exhausted || !b.exceeded() {
+		return false
+	}
+	b.exhausted = true
+	return true
+}
+
+// ErrorRate returns the fraction of requests in the window that were errors
+func (b *errorBudget) ErrorRate() float64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.errorRate()
+}
+
+// BurnRate returns the error rate in the window as a multiple of the error budget.  The budget is exhausted when the
+// burn rate is greater than 1.
+func (b *errorBudget) BurnRate() float64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.burnRate()
+}
+
+func (b *errorBudget) errorRate() float64 {
+	total := windowTotal(b.total.HistoryInclusive())
+	if total == 0 {
+		return 0.0
+	}
+	return float64(windowTotal(b.errors.HistoryInclusive())) / float64(total)
+}
+
+func (b *errorBudget) burnRate() float64 {
+	return b.errorRate() / (1.0 - b.slo/100.0)
+}
+
+// exceeded returns true if the error rate is greater than the budget once the minimum number of requests are counted
+// in the window
+func (b *errorBudget) exceeded() bool {
+	if windowTotal(b.total.HistoryInclusive()) < b.minRequests {
+		return false
+	}
+	return b.burnRate() > 1.0+budgetTolerance
+}
+
+// windowTotal returns the total count of the windows
+func windowTotal(counters []metric.Counter) int {
+	var n int
+	for _, c := range counters {
+		n += c.Value()
+	}
+	return n
+}
+
+// Metric returns the current error rate and burn rate of the budget, identified by value=(error_rate|burn_rate)
+//
+// Example: error_budget[slo=99.9 value=burn_rate] 1.5
+func (b *errorBudget) Metric() map[string]float64 {
+	if b == nil {
+		return nil
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	slo := strconv.FormatFloat(b.slo, 'g', -1, 64)
+	errorRate := metric.NewName("error_budget", map[string]string{"slo": slo, "value": "error_rate"})
+	burnRate := metric.NewName("error_budget", map[string]string{"slo": slo, "value": "burn_rate"})
+	return map[string]float64{
+		errorRate.String(): b.errorRate(),
+		burnRate.String():  b.burnRate(),
+	}
+}
+
+// countBudget counts a line as a request, an error, or both, and sends a report when the error budget is exhausted
+func (c *Command) countBudget(line []byte) {
+	b := c.budget
+	if b == nil {
+		return
+	}
+	if b.totalRule == nil || b.totalRule.Match(line) {
+		b.IncrementTotal()
+	}
+	if !b.errorRule.Match(line) || !b.IncrementError() {
+		return
+	}
+	burnRate := b.BurnRate()
+	c.mutex.Lock()
+	c.Messages = append(c.Messages, fmt.Sprintf("error budget of SLO %g%% over %s is exhausted, errors are %.1f times the budget", b.slo, b.window, burnRate))
+	c.mutex.Unlock()
+	c.traceEvent("slo_violation", trace.Float64("monny.burn_rate", burnRate))
+	c.send(proto.SLOViolation)
+}
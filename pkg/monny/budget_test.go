@@ -0,0 +1,107 @@
+package monny
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorBudget(t *testing.T) {
+	b := newErrorBudget(Config{ErrorBudgetSLO: 90, ErrorBudgetWindow: time.Hour, budgetError: regexp.MustCompile("ERROR"), budgetMinRequests: 10})
+	for i := 0; i < 9; i++ {
+		b.IncrementTotal()
+	}
+	assert.Equal(t, 0.0, b.BurnRate())
+
+	// 1 error in 10 requests uses the whole budget of an SLO of 90%
+	b.IncrementTotal()
+	assert.False(t, b.IncrementError())
+	assert.InDelta(t, 0.1, b.ErrorRate(), 1e-9)
+	assert.InDelta(t, 1.0, b.BurnRate(), 1e-9)
+
+	b.IncrementTotal()
+	assert.True(t, b.IncrementError(), "budget should be exhausted at 2 errors in 11 requests")
+	b.IncrementTotal()
+	assert.False(t, b.IncrementError(), "exhausted budget should only be reported once")
+	assert.InDelta(t, 0.25, b.ErrorRate(), 1e-9)
+	assert.InDelta(t, 2.5, b.BurnRate(), 1e-9)
+
+	// successful requests bring the error rate back within the budget so that it can be reported again
+	for i := 0; i < 18; i++ {
+		b.IncrementTotal()
+	}
+	assert.InDelta(t, 0.1, b.ErrorRate(), 1e-9)
+	b.IncrementTotal()
+	assert.True(t, b.IncrementError())
+
+	assert.Equal(t, map[string]float64{
+		"error_budget[slo=90 value=error_rate]": b.ErrorRate(),
+		"error_budget[slo=90 value=burn_rate]":  b.BurnRate(),
+	}, b.Metric())
+
+	assert.Nil(t, newErrorBudget(Config{}))
+	var none *errorBudget
+	assert.Nil(t, none.Metric())
+
+	_, errs := New([]string{"echo"}, ID("test"), ErrorBudget(99, time.Hour))
+	assert.NotEmpty(t, errs, "an error rule is required")
+}
+
+func TestErrorBudgetMinRequests(t *testing.T) {
+	b := newErrorBudget(Config{ErrorBudgetSLO: 99, ErrorBudgetWindow: time.Hour, budgetError: regexp.MustCompile("ERROR")})
+	assert.Equal(t, errorBudgetMinRequests, b.minRequests)
+
+	// an error in the first request is an error rate of 100%, but the budget is not exhausted until enough requests
+	// are counted
+	b.IncrementTotal()
+	assert.False(t, b.IncrementError(), "budget should not be exhausted before the minimum requests")
+	assert.InDelta(t, 100.0, b.BurnRate(), 1e-9)
+	for i := 0; i < errorBudgetMinRequests-2; i++ {
+		b.IncrementTotal()
+	}
+	b.IncrementTotal()
+	assert.True(t, b.IncrementError(), "budget should be exhausted at the minimum requests")
+
+	b = newErrorBudget(Config{ErrorBudgetSLO: 99, ErrorBudgetWindow: time.Hour, budgetError: regexp.MustCompile("ERROR"), budgetMinRequests: 1})
+	b.IncrementTotal()
+	assert.True(t, b.IncrementError())
+}
+
+func TestErrorBudgetViolation(t *testing.T) {
+	tt := []struct {
+		Name      string
+		Script    string
+		Violation bool
+	}{
+		{Name: "within budget", Script: `i=0; while [ $i -lt 29 ]; do echo "GET / 200"; i=$((i+1)); done; echo "GET / 503"; echo "not a request"`},
+		{Name: "budget exhausted", Script: `i=0; while [ $i -lt 19 ]; do echo "GET / 200"; i=$((i+1)); done; echo "GET / 500"; echo "GET / 500"; echo "GET / 502"`, Violation: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			c, errs := New([]string{"sh", "-c", tc.Script}, ID("test"), ErrorBudget(95, time.Hour), ErrorBudgetTotal("^GET "), ErrorBudgetError(` 5\d\d$`), logOut(&closeBuffer{}), logErr(&closeBuffer{}))
+			if len(errs) > 0 {
+				t.Fatalf("unexpected error in config: %s", errs)
+			}
+			recorder := new(reasonRecorder)
+			c.report = recorder
+			if err := c.Exec(); err != nil {
+				t.Fatalf("unexpected error running: %s", err)
+			}
+			assert.NoError(t, c.Wait())
+
+			reasons := recorder.Reasons()
+			switch tc.Violation {
+			case true:
+				assert.Equal(t, 1, countReasons(reasons, proto.SLOViolation))
+				assert.Contains(t, c.Messages, "error budget of SLO 95% over 1h0m0s is exhausted, errors are 1.9 times the budget")
+				assert.InDelta(t, 3.0/22.0/0.05, c.metricsSnapshot().Metrics["error_budget[slo=95 value=burn_rate]"], 1e-9)
+			default:
+				assert.Equal(t, 0, countReasons(reasons, proto.SLOViolation))
+				assert.InDelta(t, 1.0/30.0/0.05, c.metricsSnapshot().Metrics["error_budget[slo=95 value=burn_rate]"], 1e-9)
+			}
+		})
+	}
+}
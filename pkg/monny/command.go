@@ -2,49 +2,79 @@ package monny
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math"
 	"os"
 	"os/exec"
 	"os/signal"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 
 	"time"
 
 	"github.com/BTBurke/monny/pkg/proto"
+	"github.com/BTBurke/monny/pkg/trace"
 )
 
 // Command represents the current state of process execution
 type Command struct {
-	Config        Config
-	UserCommand   []string
-	Stdout        []string
-	Stderr        []string
-	Success       bool
-	RuleMatches   []RuleMatch
-	Killed        bool
-	KillReason    proto.KillReason
-	Created       []File
-	MaxMemory     uint64
-	ReportReason  proto.ReportReason
-	Start         time.Time
-	Finish        time.Time
-	Duration      time.Duration
-	ExitCode      int32
-	ExitCodeValid bool
-	Messages      []string
+	Config          Config
+	UserCommand     []string
+	CommandTemplate string
+	Stdout          []string
+	Stderr          []string
+	Success         bool
+	RuleMatches     []RuleMatch
+	Killed          bool
+	KillReason      proto.KillReason
+	Created         []File
+	MaxMemory       uint64
+	ReportReason    proto.ReportReason
+	Start           time.Time
+	Finish          time.Time
+	Duration        time.Duration
+	ExitCode        int32
+	ExitCodeValid   bool
+	Messages        []string
+	Env             map[string]string
+	Steps           []StepResult
 
 	mutex        sync.Mutex
 	pid          int
+	memory       uint64
 	memWarnSent  bool
 	timeWarnSent bool
+	memFailures  int
+	memDegraded  bool
+	filesMissing map[string]bool
 	handler      ProcessHandlers
+	span         trace.Span
+	metrics      []*metricMonitor
+	budget       *errorBudget
 	report       ReportSender
-	errors       ErrorReporter
+	dryRunOut    io.Writer
+	sending      sync.WaitGroup
+	errors       *errorCollector
+	env          []string
+	log          *logger
 	cleanup      []func() error
+	steps        []step
+	stdoutLines  int
+	stderrLines  int
+	outputClosed chan struct{}
+	closeOutput  sync.Once
+	brokenPipe   bool
+	echoMutex    sync.Mutex
+	signals      chan os.Signal
+	in           io.Reader
 	out          io.WriteCloser
 	err          io.WriteCloser
 }
@@ -60,9 +90,16 @@ type File struct {
 
 // RuleMatch holds a single regex match in the log output
 type RuleMatch struct {
-	Time  time.Time
-	Line  string
-	Index [][]int
+	Time     time.Time
+	Line     string
+	Index    [][]int
+	Rule     string `json:",omitempty"`
+	Severity string `json:",omitempty"`
+	// Rules lists the names of all rules that matched the line when matches are merged
+	Rules []string `json:",omitempty"`
+
+	// rule is the index of the matching rule in the configuration
+	rule int
 }
 
 // New prepares the user's command to execute as a forked process
@@ -71,115 +108,307 @@ func New(usercmd []string, options ...ConfigOption) (*Command, []error) {
 	if len(err) > 0 {
 		return nil, err
 	}
+	if len(cfg.CommandTemplate) > 0 {
+		if len(usercmd) > 0 {
+			return nil, []error{ErrTemplateAndCommand{}}
+		}
+		usercmd = cfg.cmd
+	}
+	steps, serr := newSteps(cfg, usercmd)
+	if serr != nil {
+		return nil, []error{serr}
+	}
+	metrics, merr := newMetricMonitors(cfg.MetricRules, cfg.StatLambda, cfg.SampleStrategy)
+	if merr != nil {
+		return nil, []error{merr}
+	}
+	errors := newErrorCollector(errorService{})
+	log := newLogger(cfg)
+	report := newReport(cfg, errors, log)
 	return &Command{
-		Config:      cfg,
-		UserCommand: usercmd,
-		handler:     handler{},
-		report: &Report{
-			sender: &senderService{
-				host:   cfg.host,
-				port:   cfg.port,
-				errors: errorService{},
-			},
-		},
-		out: cfg.out,
-		err: cfg.err,
+		Config:          cfg,
+		UserCommand:     usercmd,
+		CommandTemplate: cfg.CommandTemplate,
+		Messages:        append([]string(nil), cfg.Warnings...),
+		handler:         handler{},
+		steps:           steps,
+		metrics:         metrics,
+		budget:          newErrorBudget(cfg),
+		report:          report,
+		dryRunOut:       report.dryRunOut(),
+		errors:          errors,
+		log:             log,
+		in:              cfg.in,
+		out:             cfg.out,
+		err:             cfg.err,
 	}, nil
 }
 
 // Wait blocks program termination until the user's command finishes and all potential
-// reports and metrics are transmitted to the server
+// reports and metrics are transmitted to the server.  When verbose, unexpected errors in the
+// client are printed to Stderr.  Temporary files created to run the command are removed.
 func (c *Command) Wait() error {
-	return c.report.Wait()
+	defer c.Cleanup()
+	// reports waiting in a batch are sent now, otherwise reports in flight wait for the batch interval
+	if b, ok := c.report.(batchCloser); ok {
+		b.closeBatch()
+	}
+	c.sending.Wait()
+	err := c.report.Wait()
+	c.shutdownTracer()
+	if c.Config.Verbose {
+		c.printErrors()
+	}
+	return err
 }
 
 // Exec will execute the user's command in a forked process and monitor log output and process
-// metrics
+// metrics.  When no command is given, monny monitors the log lines it receives on Stdin.  In a
+// dry run, the resolved configuration is printed and the command is not run.  When printing
+// reports, the configuration is printed before the command is run.  Output is no longer echoed
+// once its reader closes the pipe.
 func (c *Command) Exec() error {
-	var cmd *exec.Cmd
-	wrappedCmd, cleanup, err := wrapComplexCommand(c.Config.Shell, c.UserCommand)
-	if err != nil {
-		return err
+	if c.Config.DryRun || c.Config.PrintReports {
+		if err := c.printDryRun(); err != nil {
+			return err
+		}
+	}
+	if c.Config.DryRun {
+		return nil
 	}
-	c.cleanup = append(c.cleanup, cleanup)
+	stopNotify := notifyBrokenPipe()
+	defer stopNotify()
+	c.watchBrokenPipe()
+	c.startSpan()
+	c.checkLateRun()
+	stopMetrics := c.startMetrics()
+	stopPrometheus := c.startPrometheus()
+	err := c.exec()
+	stopPrometheus()
+	stopMetrics()
+	c.recordSuccess()
+	c.endSpan(err)
+	return err
+}
 
-	switch len(wrappedCmd) {
-	case 1:
-		cmd = exec.Command(wrappedCmd[0])
-	default:
-		cmd = exec.Command(wrappedCmd[0], wrappedCmd[1:]...)
+func (c *Command) exec() error {
+	if len(c.Config.PreRun) > 0 {
+		if err := c.preRun(); err != nil {
+			c.preRunFailed(err)
+			return nil
+		}
 	}
-	stdinWriter, err := cmd.StdinPipe()
-	if err != nil {
-		return err
+	switch {
+	case len(c.steps) > 0 && c.Config.Parallel:
+		return c.execParallel()
+	case len(c.steps) > 0:
+		return c.execPipeline()
 	}
-	stdoutReader, err := cmd.StdoutPipe()
-	if err != nil {
-		return err
+	if len(c.UserCommand) == 0 {
+		return c.execStdin()
 	}
-	stderrReader, err := cmd.StderrPipe()
+
+	c.Start = time.Now()
+	cmd, runFinished, err := c.startProcess(c.UserCommand, "", true)
 	if err != nil {
 		return err
 	}
-	stdoutScanner := bufio.NewScanner(stdoutReader)
-	stderrScanner := bufio.NewScanner(stderrReader)
+	return c.monitor(cmd, runFinished, func(err error) error {
+		if c.stoppedOnBrokenPipe() {
+			err = nil
+		}
+		return c.handler.Finished(c, cmd, err)
+	})
+}
 
-	c.Start = time.Now()
-	if err := cmd.Start(); err != nil {
-		return err
+// startProcess starts args as a forked process whose output is scanned for rules.  The result of waiting for the
+// process is sent on the returned channel once its output is scanned.  When last is true, the output of monny is
+// closed and temporary files are removed after the process exits.
+func (c *Command) startProcess(args []string, tag string, last bool) (*exec.Cmd, <-chan error, error) {
+	var cmd *exec.Cmd
+	wrappedCmd := args
+	if !c.Config.NoShell {
+		var cleanup func() error
+		var err error
+		wrappedCmd, cleanup, err = wrapComplexCommand(c.Config.Shell, args, c.Config.ShellStrict)
+		if err != nil {
+			return nil, nil, err
+		}
+		c.cleanup = append(c.cleanup, cleanup)
 	}
-	c.pid = os.Getpid()
 
+	switch len(wrappedCmd) {
+	case 1:
+		cmd = exec.Command(wrappedCmd[0])
+	default:
+		cmd = exec.Command(wrappedCmd[0], wrappedCmd[1:]...)
+	}
+	cmd.Dir = c.Config.WorkingDir
+	cmd.Env = c.traceEnv()
+	c.env = cmd.Env
+	c.Env = c.snapshotEnv()
 	var wg sync.WaitGroup
-	wg.Add(3)
-	go func() {
-		defer wg.Done()
-		defer stdinWriter.Close()
-		// determine if a monny is after a previous piped process, copy to forked process stdin if necessary
-		fi, err := os.Stdin.Stat()
+	switch c.Config.PTY {
+	case true:
+		// a pseudo-terminal combines stdout and stderr of the process into a single stream
+		terminal, err := startPTY(cmd)
 		if err != nil {
-			c.errors.ReportError(fmt.Errorf("failed to get stdin properties: %+v", err))
+			return nil, nil, err
 		}
-		if fi.Mode()&os.ModeNamedPipe != 0 {
-			_, err := io.Copy(stdinWriter, os.Stdin)
-			if err != nil {
-				c.errors.ReportError(fmt.Errorf("error writing to stdin: %+v", err))
-			}
+		c.pid = os.Getpid()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer terminal.Close()
+			c.scanStdout(terminal, tag)
+		}()
+	default:
+		// the process reads from the stdin of monny, such as monny -- mysql < dump.sql, unless it is disconnected or
+		// shared with other processes running in parallel
+		if !c.Config.NoStdin && len(tag) == 0 {
+			cmd.Stdin = c.in
 		}
-	}()
+		stdoutReader, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		stderrReader, err := cmd.StderrPipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, nil, err
+		}
+		c.pid = os.Getpid()
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			c.scanStdout(stdoutReader, tag)
+		}()
+		go func() {
+			defer wg.Done()
+			c.scanStderr(stderrReader, tag)
+		}()
+	}
+
+	// the result of waiting for the process is captured once and passed to the handler
+	runFinished := make(chan error, 1)
 	go func() {
-		defer wg.Done()
-		for stdoutScanner.Scan() {
-			if _, err := c.out.Write(stdoutScanner.Bytes()); err != nil {
-				c.errors.ReportError(fmt.Errorf("error writing log line to stdout: %+v", err))
-			}
-			c.out.Write([]byte{'\n'})
-			c.processStdout(stdoutScanner.Bytes())
+		wg.Wait()
+		err := cmd.Wait()
+		if last {
+			c.out.Close()
+			c.err.Close()
+			c.Cleanup()
 		}
+		runFinished <- err
 	}()
+	return cmd, runFinished, nil
+}
+
+// monitor watches the running process until it exits, when the result of waiting for it is passed to finished, or
+// until it is killed.  Timeouts are measured from the start of the command.
+func (c *Command) monitor(cmd *exec.Cmd, runFinished <-chan error, finished func(error) error) error {
+	g := newProcessGroup(1)
+	g.add(0, cmd, runFinished)
+	return c.monitorGroup(g, func(i int, err error) (bool, error) {
+		return true, finished(err)
+	})
+}
+
+// processGroup holds the processes that are monitored together, such as the commands run in parallel
+type processGroup struct {
+	mutex   sync.Mutex
+	running map[int]*exec.Cmd
+	exits   chan processExit
+}
+
+// processExit is the result of waiting for process i of a group
+type processExit struct {
+	i   int
+	err error
+}
+
+// newProcessGroup returns a group for up to size processes.  Exits are buffered so that a process that exits after
+// monitoring stops does not block.
+func newProcessGroup(size int) *processGroup {
+	return &processGroup{running: make(map[int]*exec.Cmd), exits: make(chan processExit, size)}
+}
+
+// add monitors cmd as process i until the result of waiting for it is sent on runFinished
+func (g *processGroup) add(i int, cmd *exec.Cmd, runFinished <-chan error) {
+	g.mutex.Lock()
+	g.running[i] = cmd
+	g.mutex.Unlock()
 	go func() {
-		defer wg.Done()
-		for stderrScanner.Scan() {
-			if _, err := c.err.Write(stderrScanner.Bytes()); err != nil {
-				c.errors.ReportError(fmt.Errorf("error writing log line to stderr: %+v", err))
-			}
-			c.err.Write([]byte{'\n'})
-			c.processStderr(stderrScanner.Bytes())
-		}
+		g.exits <- processExit{i: i, err: <-runFinished}
 	}()
+}
 
-	runFinished := make(chan bool, 1)
+// remove stops monitoring process i once it has exited
+func (g *processGroup) remove(i int) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	delete(g.running, i)
+}
+
+// ids returns the index of each running process in order
+func (g *processGroup) ids() []int {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	ids := make([]int, 0, len(g.running))
+	for i := range g.running {
+		ids = append(ids, i)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// processes returns the running processes in order
+func (g *processGroup) processes() []*exec.Cmd {
+	var cmds []*exec.Cmd
+	for _, i := range g.ids() {
+		g.mutex.Lock()
+		cmds = append(cmds, g.running[i])
+		g.mutex.Unlock()
+	}
+	return cmds
+}
+
+// kill kills the running processes other than except, or all of them when except is nil, without sending a report
+func (g *processGroup) kill(except *exec.Cmd) {
+	for _, cmd := range g.processes() {
+		if cmd != except {
+			cmd.Process.Kill()
+		}
+	}
+}
+
+// monitorGroup watches the processes of the group until exited returns true for a process that exits, or until they
+// are killed.  A report is sent once for the group when it is killed, by the handler for the first running process.
+// Memory limits apply to each process.  Timeouts are measured from the start of the command.
+func (c *Command) monitorGroup(g *processGroup, exited func(i int, err error) (bool, error)) error {
 	timeout := make(<-chan time.Time, 1)
 	timenotify := make(<-chan time.Time, 1)
-	signals := make(chan os.Signal, 1)
 	profileMemory := make(<-chan time.Time, 1)
-	signal.Notify(signals, os.Interrupt, os.Kill)
+	watchFiles := make(<-chan time.Time, 1)
+	heartbeat := make(<-chan time.Time, 1)
+	outputClosed := c.outputClosed
+	// a scheduled run receives the signals passed on by the scheduler instead
+	signals := c.signals
+	if signals == nil {
+		signals = make(chan os.Signal, 1)
+		signal.Notify(signals, forwardedSignals...)
+		defer signal.Stop(signals)
+	}
 
 	if c.Config.KillTimeout > 0 {
-		timeout = time.After(c.Config.KillTimeout)
+		timeout = time.After(c.Config.KillTimeout - time.Since(c.Start))
 	}
-	if c.Config.NotifyTimeout > 0 {
-		timenotify = time.After(c.Config.NotifyTimeout)
+	if c.Config.NotifyTimeout > 0 && !c.timeWarnSent {
+		timenotify = time.After(c.Config.NotifyTimeout - time.Since(c.Start))
 	}
 	if runtime.GOOS == "linux" {
 		switch c.Config.Daemon {
@@ -190,41 +419,239 @@ func (c *Command) Exec() error {
 		}
 	}
 
-	go func() {
-		wg.Wait()
-		cmd.Wait()
-		c.out.Close()
-		c.err.Close()
-		c.Cleanup()
-		runFinished <- true
-	}()
+	if len(c.Config.CreatesWatch) > 0 {
+		if c.filesMissing == nil {
+			c.filesMissing = make(map[string]bool)
+		}
+		watchFiles = time.Tick(watchInterval(c.Config.CreatesWatch))
+	}
+	if c.Config.Daemon && c.Config.HeartbeatInterval > 0 {
+		ticker := time.NewTicker(c.Config.HeartbeatInterval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
 
 	for {
 		select {
-		case <-runFinished:
-			return c.handler.Finished(c, cmd)
+		case e := <-g.exits:
+			g.remove(e.i)
+			if done, err := exited(e.i, e.err); done {
+				return err
+			}
 		case sig := <-signals:
-			return c.handler.Signal(c, cmd, sig)
+			cmds := g.processes()
+			if len(cmds) == 0 {
+				continue
+			}
+			if !killSignal(sig) {
+				for _, cmd := range cmds {
+					if err := c.handler.Signal(c, cmd, sig); err != nil {
+						c.reportError(errorInternal, fmt.Errorf("could not pass signal %s to process: %v", sig, err))
+					}
+				}
+				continue
+			}
+			err := c.handler.Signal(c, cmds[0], sig)
+			for _, cmd := range cmds[1:] {
+				cmd.Process.Signal(sig)
+			}
+			// monny may exit before the process, so the script is removed now instead of when the process exits
+			c.Cleanup()
+			return err
 		case <-timeout:
-			return c.handler.Timeout(c, cmd)
+			cmds := g.processes()
+			if len(cmds) == 0 {
+				continue
+			}
+			err := c.handler.Timeout(c, cmds[0])
+			g.kill(cmds[0])
+			return err
 		case <-timenotify:
 			c.handler.TimeWarning(c)
 		case <-profileMemory:
-			if err := c.handler.CheckMemory(c, cmd); err != nil {
-				return c.handler.KillOnHighMemory(c, cmd)
+			for _, cmd := range g.processes() {
+				if err := c.handler.CheckMemory(c, cmd); err != nil {
+					err := c.handler.KillOnHighMemory(c, cmd)
+					g.kill(cmd)
+					return err
+				}
+			}
+		case <-watchFiles:
+			c.handler.CheckFiles(c)
+		case <-heartbeat:
+			if cmds := g.processes(); len(cmds) > 0 {
+				c.handler.Heartbeat(c, cmds[0])
+			}
+		case <-outputClosed:
+			outputClosed = nil
+			if c.Config.KillOnBrokenPipe {
+				c.stopOnBrokenPipe(g.processes()...)
 			}
 		}
 	}
 }
 
-// checkRule finds a regular expression match to a line from either Stdout or Stderr.
-func checkRule(line []byte, rules []rule) []RuleMatch {
+// preRun runs the pre-run command with the shell, or split on spaces when there is no shell.  Its output is passed
+// through to the output of monny without being checked for rules.
+func (c *Command) preRun() error {
+	var cmd *exec.Cmd
+	switch {
+	case c.Config.NoShell:
+		args := strings.Fields(c.Config.PreRun)
+		cmd = exec.Command(args[0], args[1:]...)
+	default:
+		cmd = exec.Command(c.Config.Shell, "-c", c.Config.PreRun)
+	}
+	cmd.Dir = c.Config.WorkingDir
+	c.log.debugf("running pre-run command: %s", c.Config.PreRun)
+	var output bytes.Buffer
+	cmd.Stdout = io.MultiWriter(c.out, &output)
+	cmd.Stderr = io.MultiWriter(c.err, &output)
+	c.Start = time.Now()
+	err := cmd.Run()
+	if err != nil && output.Len() > 0 {
+		return fmt.Errorf("%v: %s", err, lastLines(output.String(), c.Config.StderrHistory))
+	}
+	return err
+}
+
+// preRunFailed sends a failure report when the pre-run command fails and the command is not run
+func (c *Command) preRunFailed(err error) {
+	c.log.warnf("pre-run command failed, not running the command: %v", err)
+	c.mutex.Lock()
+	c.computeDuration()
+	c.Success = false
+	c.ReportReason = proto.Failure
+	c.Messages = append(c.Messages, fmt.Sprintf("pre-run command %s failed and the command was not run: %v", c.Config.PreRun, err))
+	c.mutex.Unlock()
+	c.send(proto.Failure)
+}
+
+// lastLines returns up to n of the last lines of s
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// execStdin monitors log lines piped to monny when it is the final stage of a pipe
+// (e.g., journalctl -f | monny -i id --rule ERROR).  Lines are processed the same as Stdout
+// of a forked process.  A report is sent when the input is closed or a signal is received.
+func (c *Command) execStdin() error {
+	if f, ok := c.in.(*os.File); ok {
+		fi, err := f.Stat()
+		if err != nil {
+			return fmt.Errorf("failed to get stdin properties: %+v", err)
+		}
+		if fi.Mode()&os.ModeCharDevice != 0 {
+			return fmt.Errorf("no command to run, use monny -i <id> mycommand or pipe logs to monny")
+		}
+	}
+
+	finished := make(chan bool, 1)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	c.Env = c.snapshotEnv()
+	c.Start = time.Now()
+	go func() {
+		c.scanStdout(c.in, "")
+		finished <- true
+	}()
+
+	select {
+	case <-finished:
+		c.mutex.Lock()
+		c.computeDuration()
+		c.Success = true
+		c.ReportReason = proto.Success
+		c.mutex.Unlock()
+		c.out.Close()
+		c.err.Close()
+		c.send(proto.Success)
+	case sig := <-signals:
+		c.mutex.Lock()
+		c.computeDuration()
+		c.Killed = true
+		c.KillReason = proto.Signal
+		c.ReportReason = proto.Killed
+		c.Messages = append(c.Messages, fmt.Sprintf("monny received signal %s", sig))
+		c.mutex.Unlock()
+		c.send(proto.Killed)
+	}
+	return nil
+}
+
+// scanStdout echoes each line of stdout and processes it for rule matches and history.  Lines are echoed and kept in
+// the history with tag, which identifies the process when several run in parallel.
+func (c *Command) scanStdout(r io.Reader, tag string) {
+	scanner := c.newScanner(r)
+	for scanner.Scan() {
+		if err := c.echo(c.out, tag, scanner.Bytes()); err != nil {
+			c.reportError(errorSink, fmt.Errorf("error writing log line to stdout: %+v", err))
+		}
+		c.processStdout(scanner.Bytes(), tag)
+	}
+	c.checkScan("stdout", scanner, r, c.out)
+}
+
+// scanStderr echoes each line of stderr and processes it for rule matches and history
+func (c *Command) scanStderr(r io.Reader, tag string) {
+	scanner := c.newScanner(r)
+	for scanner.Scan() {
+		if err := c.echo(c.err, tag, scanner.Bytes()); err != nil {
+			c.reportError(errorSink, fmt.Errorf("error writing log line to stderr: %+v", err))
+		}
+		c.processStderr(scanner.Bytes(), tag)
+	}
+	c.checkScan("stderr", scanner, r, c.err)
+}
+
+// echo writes a line of output after its tag.  Lines are written one at a time so that the output of processes
+// running in parallel is not interleaved within a line.
+func (c *Command) echo(w io.Writer, tag string, line []byte) error {
+	c.echoMutex.Lock()
+	defer c.echoMutex.Unlock()
+	if len(tag) > 0 {
+		w.Write([]byte(tag))
+	}
+	_, err := w.Write(line)
+	w.Write([]byte{'\n'})
+	return err
+}
+
+// checkScan reports an error that stopped the scanner before the end of the stream, such as a line longer than
+// MaxLineSize.  The rest of the stream is echoed to w without processing so that the process is not blocked writing
+// output.
+func (c *Command) checkScan(stream string, scanner *bufio.Scanner, r io.Reader, w io.Writer) {
+	err := scanner.Err()
+	switch {
+	case err == nil:
+		return
+	case err == bufio.ErrTooLong:
+		c.reportError(errorScan, fmt.Errorf("stopped processing %s: line exceeds max line size of %d bytes, set with --max-line-size", stream, c.Config.lineSize()))
+	default:
+		c.reportError(errorScan, fmt.Errorf("stopped processing %s: %v", stream, err))
+	}
+	c.log.warnf("output on %s after this point is not checked for rule matches: %v", stream, err)
+	io.Copy(w, r)
+}
+
+// checkRule finds a regular expression match to a line from either Stdout or Stderr.  When coerce is true, string
+// values of JSON fields that contain a number are matched in the same format as JSON numbers.
+func checkRule(line []byte, stream streamSelector, rules []rule, coerce bool) []RuleMatch {
 	var matches []RuleMatch
-	for _, rule := range rules {
+	for i, rule := range rules {
+		if !rule.selects(stream) {
+			continue
+		}
 		var text []byte
 		switch {
 		case len(rule.Field) > 0:
-			text = extractTextFromJSON(line, rule.Field)
+			text = extractTextFromJSON(line, rule.Field, coerce)
 		default:
 			text = line
 		}
@@ -232,16 +659,57 @@ func checkRule(line []byte, rules []rule) []RuleMatch {
 		found := rule.Regex.FindAllIndex(text, -1)
 		if found != nil {
 			matches = append(matches, RuleMatch{
-				Time:  time.Now(),
-				Line:  string(line),
-				Index: found,
+				Time:     time.Now(),
+				Line:     string(line),
+				Index:    found,
+				Rule:     rule.Name,
+				Severity: rule.Severity,
+				rule:     i,
 			})
 		}
 	}
 	return matches
 }
 
-func extractTextFromJSON(raw []byte, field string) []byte {
+// inWindow removes matches to rules that do not apply at the time elapsed since the process started
+func (c *Command) inWindow(matches []RuleMatch) []RuleMatch {
+	if len(matches) == 0 {
+		return matches
+	}
+	var elapsed time.Duration
+	if !c.Start.IsZero() {
+		elapsed = time.Since(c.Start)
+	}
+	active := matches[:0]
+	for _, m := range matches {
+		if c.Config.Rules[m.rule].activeAt(elapsed) {
+			active = append(active, m)
+		}
+	}
+	return active
+}
+
+// mergeMatches combines matches to several rules on the same line into the first match.  The indexes of all matches
+// are kept in rule order.
+func mergeMatches(matches []RuleMatch) []RuleMatch {
+	if len(matches) < 2 {
+		return matches
+	}
+	merged := matches[0]
+	merged.Index = nil
+	for _, m := range matches {
+		merged.Index = append(merged.Index, m.Index...)
+		if len(m.Rule) > 0 {
+			merged.Rules = append(merged.Rules, m.Rule)
+		}
+	}
+	return []RuleMatch{merged}
+}
+
+// extractTextFromJSON returns the text of the value of field.  Numbers are formatted as floats (e.g., 404.000000) and
+// arrays are returned with one value per line.  When coerce is true, strings that contain a number (e.g., "123.4") are
+// formatted the same as numbers.
+func extractTextFromJSON(raw []byte, field string, coerce bool) []byte {
 	fieldPath := strings.Split(field, ".")
 	switch {
 	case len(fieldPath) > 1:
@@ -249,7 +717,7 @@ func extractTextFromJSON(raw []byte, field string) []byte {
 		if err := json.Unmarshal(raw, &res); err != nil {
 			return []byte{}
 		}
-		return extractTextFromJSON(res[fieldPath[0]], strings.Join(fieldPath[1:], "."))
+		return extractTextFromJSON(res[fieldPath[0]], strings.Join(fieldPath[1:], "."), coerce)
 	default:
 		res := make(map[string]interface{})
 		if err := json.Unmarshal(raw, &res); err != nil {
@@ -258,128 +726,216 @@ func extractTextFromJSON(raw []byte, field string) []byte {
 		value := res[field]
 
 		switch value.(type) {
-		case string:
-			return []byte(value.(string))
-		case float32:
-			return []byte(fmt.Sprintf("%f", value.(float32)))
-		case float64:
-			return []byte(fmt.Sprintf("%f", value.(float64)))
-		case bool:
-			return []byte(fmt.Sprintf("%v", value.(bool)))
 		case []interface{}:
 			var out []string
-			vals, ok := value.([]interface{})
-			if !ok {
-				return []byte{}
-			}
-			for _, val := range vals {
-				switch val.(type) {
-				case string:
-					out = append(out, val.(string))
-				case float32:
-					out = append(out, fmt.Sprintf("%f", value.(float32)))
-				case float64:
-					out = append(out, fmt.Sprintf("%f", value.(float64)))
-				case bool:
-					out = append(out, fmt.Sprintf("%v", val.(bool)))
-				default:
+			for _, val := range value.([]interface{}) {
+				if text, ok := formatJSONValue(val, coerce); ok {
+					out = append(out, text)
 				}
 			}
 			return []byte(strings.Join(out, "\n"))
 		default:
-			return []byte{}
+			text, _ := formatJSONValue(value, coerce)
+			return []byte(text)
 		}
 	}
 }
 
-func (c *Command) processStdout(line []byte) {
-	matches := checkRule(line, c.Config.Rules)
+// formatJSONValue returns the text of a JSON string, number, or bool.  Returns false for other types.
+func formatJSONValue(value interface{}, coerce bool) (string, bool) {
+	if n, ok := jsonNumber(value, coerce); ok {
+		return fmt.Sprintf("%f", n), true
+	}
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case bool:
+		return fmt.Sprintf("%v", v), true
+	default:
+		return "", false
+	}
+}
+
+// jsonNumber returns the value of a JSON number.  When coerce is true, strings that contain a finite number are also
+// returned as numbers.
+func jsonNumber(value interface{}, coerce bool) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		if !coerce {
+			return 0, false
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return 0, false
+		}
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+func (c *Command) processStdout(line []byte, tag string) {
+	c.countMetrics(line, streamStdout)
+	c.countBudget(line)
+	matches := c.inWindow(checkRule(line, streamStdout, c.Config.Rules, c.Config.CoerceJSONNumbers))
+	if c.Config.MergeMatches {
+		matches = mergeMatches(matches)
+	}
+	if len(matches) > 0 {
+		c.log.debugf("rule matched line on stdout: %s", line)
+		c.traceMatches(streamStdout, matches)
+	}
 	c.mutex.Lock()
 	c.RuleMatches = append(c.RuleMatches, matches...)
 	c.mutex.Unlock()
-	if len(c.RuleMatches) > 0 {
+	if len(matches) > 0 {
 		switch {
-		case c.Config.RuleQuantity > 0:
-			go c.report.Send(c, proto.AlertRate)
+		case c.Config.rateLimited():
+			c.send(proto.AlertRate)
 		default:
-			go c.report.Send(c, proto.Alert)
+			c.send(proto.Alert)
 		}
 	}
 	history := len(c.Stdout)
 	c.mutex.Lock()
+	c.stdoutLines++
 	switch {
 	case history >= c.Config.StdoutHistory:
-		c.Stdout = append(c.Stdout[2:], string(line))
+		c.Stdout = append(c.Stdout[2:], tag+string(line))
 	default:
-		c.Stdout = append(c.Stdout, string(line))
+		c.Stdout = append(c.Stdout, tag+string(line))
 	}
 	c.mutex.Unlock()
 	return
 }
 
-func (c *Command) processStderr(line []byte) {
-	matches := checkRule(line, c.Config.Rules)
+func (c *Command) processStderr(line []byte, tag string) {
+	c.countMetrics(line, streamStderr)
+	c.countBudget(line)
+	matches := c.inWindow(checkRule(line, streamStderr, c.Config.Rules, c.Config.CoerceJSONNumbers))
+	if c.Config.MergeMatches {
+		matches = mergeMatches(matches)
+	}
+	if len(matches) > 0 {
+		c.log.debugf("rule matched line on stderr: %s", line)
+		c.traceMatches(streamStderr, matches)
+	}
 	c.mutex.Lock()
 	c.RuleMatches = append(c.RuleMatches, matches...)
 	c.mutex.Unlock()
-	if len(c.RuleMatches) > 0 {
+	if len(matches) > 0 {
 		switch {
-		case c.Config.RuleQuantity > 0:
-			go c.report.Send(c, proto.AlertRate)
+		case c.Config.rateLimited():
+			c.send(proto.AlertRate)
 		default:
-			go c.report.Send(c, proto.Alert)
+			c.send(proto.Alert)
 		}
 	}
 	history := len(c.Stderr)
 	c.mutex.Lock()
+	c.stderrLines++
 	switch {
 	case history >= c.Config.StderrHistory:
-		c.Stderr = append(c.Stderr[2:], string(line))
+		c.Stderr = append(c.Stderr[2:], tag+string(line))
 	default:
-		c.Stderr = append(c.Stderr, string(line))
+		c.Stderr = append(c.Stderr, tag+string(line))
 	}
 	c.mutex.Unlock()
 	return
 }
 
-func wrapComplexCommand(shell string, args []string) ([]string, func() error, error) {
-	return args, nil, nil
-	// r := regexp.MustCompile(`(&|\x7C|<|>)`)
-
-	// var match bool
-	// for _, arg := range args {
-	// 	match = r.MatchString(arg)
-	// 	if match {
-	// 		break
-	// 	}
-	// }
-
-	// switch match {
-	// case false:
-	// 	return args, nil, nil
-	// default:
-	// 	wd, err := os.Getwd()
-	// 	if err != nil {
-	// 		return args, nil, err
-	// 	}
-	// 	f, err := ioutil.TempFile(wd, "monny")
-	// 	if err != nil {
-	// 		return args, nil, err
-	// 	}
-	// 	if _, err := f.Write([]byte(strings.Join(args, " "))); err != nil {
-	// 		return args, nil, err
-	// 	}
-	// 	if err := f.Chmod(os.ModePerm); err != nil {
-	// 		return args, nil, err
-	// 	}
-	// 	if err := f.Close(); err != nil {
-	// 		return args, nil, err
-	// 	}
-	// 	return []string{shell, f.Name()}, func() error { return os.Remove(f.Name()) }, nil
-	// }
-}
-
-// Cleanup executes all callbacks registered to clean up monitoring of the process
+// shellOperators are arguments that must be interpreted by a shell, such as when a command is passed as
+// monny make "&&" make install.  Operators are only recognized as standalone arguments so that arguments that contain
+// them as data, such as grep "a|b", are passed to the command unchanged.
+var shellOperators = map[string]bool{"&&": true, "||": true, "|": true, "&": true, ";": true, "<": true, ">": true, ">>": true, "2>": true, "2>&1": true}
+
+// strictShellOptions is the first line of the shell script when strict mode is enabled, so that the script exits on the
+// first failing command, unset variable, or failure in a pipeline
+const strictShellOptions = "set -euo pipefail"
+
+// wrapComplexCommand writes commands that contain shell operators to a script in the temporary directory that is run by
+// shell.  Arguments other than operators are quoted so that the shell passes them to each command unchanged.  When
+// strict is true, the script starts with set -euo pipefail.  The returned callback removes the script.  Other commands
+// are returned unchanged.
+func wrapComplexCommand(shell string, args []string, strict bool) ([]string, func() error, error) {
+	var match bool
+	for _, arg := range args {
+		if shellOperators[arg] {
+			match = true
+			break
+		}
+	}
+	if !match {
+		return args, nil, nil
+	}
+
+	f, err := ioutil.TempFile(os.TempDir(), "monny")
+	if err != nil {
+		return args, nil, fmt.Errorf("could not create shell script for command: %v", err)
+	}
+	cleanup := func() error {
+		if err := os.Remove(f.Name()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := f.Chmod(0700); err != nil {
+		f.Close()
+		cleanup()
+		return args, nil, fmt.Errorf("could not set permissions of shell script for command: %v", err)
+	}
+	if _, err := f.WriteString(shellScript(args, strict)); err != nil {
+		f.Close()
+		cleanup()
+		return args, nil, fmt.Errorf("could not write shell script for command: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		cleanup()
+		return args, nil, fmt.Errorf("could not write shell script for command: %v", err)
+	}
+	return []string{shell, f.Name()}, cleanup, nil
+}
+
+// shellScript returns the script that runs args, quoting each argument that is not a shell operator
+func shellScript(args []string, strict bool) string {
+	words := make([]string, 0, len(args))
+	for _, arg := range args {
+		switch {
+		case shellOperators[arg]:
+			words = append(words, arg)
+		default:
+			words = append(words, shellQuote(arg))
+		}
+	}
+	script := strings.Join(words, " ") + "\n"
+	if strict {
+		script = strictShellOptions + "\n" + script
+	}
+	return script
+}
+
+// shellQuote returns arg in single quotes when it contains characters that are special to the shell
+func shellQuote(arg string) string {
+	if len(arg) == 0 {
+		return "''"
+	}
+	safe := true
+	for _, r := range arg {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./,:=+@%", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return arg
+	}
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
+
+// Cleanup executes all callbacks registered to clean up monitoring of the process, such as removing the script of a
+// command with shell operators.  It is safe to call more than once.
 func (c *Command) Cleanup() []error {
 	var errs []error
 	if len(c.cleanup) == 0 {
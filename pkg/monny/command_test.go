@@ -2,16 +2,21 @@ package monny
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/BTBurke/monny/pkg/pb"
 	"github.com/BTBurke/monny/pkg/proto"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -21,7 +26,7 @@ type mockHandlers struct {
 	mock.Mock
 }
 
-func (m mockHandlers) Finished(c *Command, cmd *exec.Cmd) error {
+func (m mockHandlers) Finished(c *Command, cmd *exec.Cmd, waitErr error) error {
 	args := m.Called()
 	return args.Error(0)
 }
@@ -47,6 +52,16 @@ func (m mockHandlers) CheckMemory(c *Command, cmd *exec.Cmd) error {
 	return args.Error(0)
 }
 
+func (m mockHandlers) CheckFiles(c *Command) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m mockHandlers) Heartbeat(c *Command, cmd *exec.Cmd) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
 func (m mockHandlers) KillOnHighMemory(c *Command, cmd *exec.Cmd) error {
 	cmd.Process.Kill()
 	args := m.Called()
@@ -59,10 +74,45 @@ func (m *mockReport) Send(c *Command, reason proto.ReportReason) {
 	return
 }
 
+func (m *mockReport) SendBatch(reports []*pb.Report) error {
+	return nil
+}
+
 func (m *mockReport) Wait() error {
 	return nil
 }
 
+// reasonRecorder records the reason of each report sent
+type reasonRecorder struct {
+	mutex   sync.Mutex
+	reasons []proto.ReportReason
+}
+
+func (r *reasonRecorder) Send(c *Command, reason proto.ReportReason) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.reasons = append(r.reasons, reason)
+}
+
+func (r *reasonRecorder) SendBatch(reports []*pb.Report) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, report := range reports {
+		r.reasons = append(r.reasons, proto.ReportReason(report.ReportReason))
+	}
+	return nil
+}
+
+func (r *reasonRecorder) Wait() error {
+	return nil
+}
+
+func (r *reasonRecorder) Reasons() []proto.ReportReason {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]proto.ReportReason(nil), r.reasons...)
+}
+
 func TestHandlerCalls(t *testing.T) {
 	tt := []struct {
 		Name     string
@@ -77,6 +127,7 @@ func TestHandlerCalls(t *testing.T) {
 		{Name: "mem kill", Cmd: "sleep 5", Options: []ConfigOption{MemoryKill("1K")}, Handlers: []string{"CheckMemory", "KillOnHighMemory"}, Error: []error{fmt.Errorf("high mem kill"), nil}},
 		{Name: "time warning", Cmd: "sleep 1", Options: []ConfigOption{NotifyTimeout("200ms")}, Handlers: []string{"CheckMemory", "Finished", "TimeWarning"}, Error: []error{nil, nil, nil}},
 		{Name: "time kill", Cmd: "sleep 1", Options: []ConfigOption{KillTimeout("200ms")}, Handlers: []string{"Timeout"}, Error: []error{nil}},
+		{Name: "heartbeat", Cmd: "sleep 1", Options: []ConfigOption{Daemon(), Heartbeat("300ms")}, Handlers: []string{"Heartbeat", "Finished"}, Error: []error{nil, nil}},
 	}
 
 	for _, tc := range tt {
@@ -132,11 +183,13 @@ func TestIntegration(t *testing.T) {
 		KillReason   proto.KillReason
 		Duration     time.Duration
 		Cleanup      func()
+		// As is the type of error returned by Exec, or nil for no error
+		As interface{}
 	}{
 		{Name: "capture stdout", Cmd: "echo start", Stdout: []string{"start"}, ReportReason: proto.Success},
-		{Name: "get failure exit code", Cmd: "sh -c 'exit 1'", ReportReason: proto.Failure},
-		{Name: "kill on timeout", Cmd: "sleep 3", Options: []ConfigOption{KillTimeout("200ms")}, ReportReason: proto.Killed, KillReason: proto.Timeout, Duration: time.Duration(200 * time.Millisecond)},
-		{Name: "kill on memory", Cmd: "sleep 3", Options: []ConfigOption{MemoryKill("1K")}, ReportReason: proto.Killed, KillReason: proto.Memory},
+		{Name: "get failure exit code", Cmd: "sh -c 'exit 1'", ReportReason: proto.Failure, As: &ErrChildFailed{}},
+		{Name: "kill on timeout", Cmd: "sleep 3", Options: []ConfigOption{KillTimeout("200ms")}, ReportReason: proto.Killed, KillReason: proto.Timeout, Duration: time.Duration(200 * time.Millisecond), As: &ErrKilledOnTimeout{}},
+		{Name: "kill on memory", Cmd: "sleep 3", Options: []ConfigOption{MemoryKill("1K")}, ReportReason: proto.Killed, KillReason: proto.Memory, As: &ErrKilledOnMemory{}},
 		{Name: "file creation success", Cmd: "touch testfile.test", Options: []ConfigOption{Creates("testfile.test")}, ReportReason: proto.Success, Cleanup: func() { os.Remove("testfile.test") }},
 		{Name: "file creation failed", Cmd: "touch testfile1.test", Options: []ConfigOption{Creates("testfile.test")}, ReportReason: proto.FileNotCreated, Cleanup: func() { os.Remove("testfile1.test") }},
 	}
@@ -155,8 +208,12 @@ func TestIntegration(t *testing.T) {
 				t.Fatalf("unexpected error setting config: %s", err)
 			}
 			c.report = new(mockReport)
-			if err := c.Exec(); err != nil {
-				t.Fatalf("unexpected error execing command: %s", err)
+			execErr := c.Exec()
+			switch {
+			case tc.As == nil && execErr != nil:
+				t.Fatalf("unexpected error execing command: %s", execErr)
+			case tc.As != nil:
+				assert.True(t, errors.As(execErr, tc.As), "expected %T, got %v", tc.As, execErr)
 			}
 			if err := c.Cleanup(); err != nil {
 				t.Fatalf("unexpected cleanup error: %s", err)
@@ -182,12 +239,262 @@ func duration(expected time.Duration, actual time.Duration, deltaMillis float64)
 	}
 }
 
-const testJSON string = `{"code": 404,"msg": "test message","array": ["test1", "test2", "test3"],"nested": {"nest1": "test"},"bool": true}`
+const testJSON string = `{"code": 404,"msg": "test message","array": ["test1", "test2", "test3"],"nested": {"nest1": "test"},"bool": true,"latency": "123.4","numbers": [1, "2.5"]}`
+
+func TestWrapComplexCommand(t *testing.T) {
+	tt := []struct {
+		Name   string
+		Args   []string
+		Strict bool
+		Script string
+	}{
+		{Name: "no operators", Args: []string{"sh", "-c", "echo a && echo b"}},
+		{Name: "pipe in argument", Args: []string{"grep", "a|b", "file.txt"}},
+		{Name: "redirect in argument", Args: []string{"echo", "a>b"}},
+		{Name: "and", Args: []string{"echo", "a", "&&", "echo", "b"}, Script: "echo a && echo b\n"},
+		{Name: "quoted arguments", Args: []string{"grep", "a|b", "my file.txt", "|", "wc", "-l"}, Script: "grep 'a|b' 'my file.txt' | wc -l\n"},
+		{Name: "single quote", Args: []string{"echo", "it's", ";", "echo", ""}, Script: "echo 'it'\\''s' ; echo ''\n"},
+		{Name: "strict", Args: []string{"echo", "a", "&&", "echo", "b"}, Strict: true, Script: "set -euo pipefail\necho a && echo b\n"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			args, cleanup, err := wrapComplexCommand("/bin/sh", tc.Args, tc.Strict)
+			if err != nil {
+				t.Fatalf("unexpected error wrapping command: %v", err)
+			}
+			if len(tc.Script) == 0 {
+				assert.Nil(t, cleanup)
+				assert.Equal(t, tc.Args, args)
+				return
+			}
+			if assert.Len(t, args, 2) {
+				assert.Equal(t, "/bin/sh", args[0])
+				assert.Equal(t, filepath.Clean(os.TempDir()), filepath.Dir(args[1]))
+				script, err := ioutil.ReadFile(args[1])
+				assert.NoError(t, err)
+				assert.Equal(t, tc.Script, string(script))
+			}
+			assert.NoError(t, cleanup())
+			assert.NoError(t, cleanup())
+		})
+	}
+}
+
+func TestShellQuoting(t *testing.T) {
+	tt := []struct {
+		Name   string
+		Args   []string
+		Stdout []string
+	}{
+		{Name: "pipe in argument", Args: []string{"echo", "a|b", "&&", "echo", "c"}, Stdout: []string{"a|b", "c"}},
+		{Name: "spaces and variables", Args: []string{"echo", "$HOME  x", "&&", "echo", "`id`"}, Stdout: []string{"$HOME  x", "`id`"}},
+		{Name: "single quote", Args: []string{"echo", "it's", "|", "cat"}, Stdout: []string{"it's"}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			c, errs := New(tc.Args, ID("test"), logOut(&closeBuffer{}), logErr(&closeBuffer{}))
+			if len(errs) > 0 {
+				t.Fatalf("unexpected error in config: %s", errs)
+			}
+			c.report = new(mockReport)
+			if err := c.Exec(); err != nil {
+				t.Fatalf("unexpected error execing command: %s", err)
+			}
+			assert.Nil(t, c.Cleanup())
+			assert.Equal(t, tc.Stdout, c.Stdout)
+		})
+	}
+}
+
+func TestNoShell(t *testing.T) {
+	c, errs := New([]string{"echo", "a|b", "&&", "echo", "c"}, ID("test"), NoShell(), logOut(&closeBuffer{}), logErr(&closeBuffer{}))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	c.report = new(mockReport)
+	if err := c.Exec(); err != nil {
+		t.Fatalf("unexpected error execing command: %s", err)
+	}
+	assert.Empty(t, c.Cleanup())
+	// operators are passed to echo as arguments
+	assert.Equal(t, []string{"a|b && echo c"}, c.Stdout)
+	assert.True(t, c.Success)
+}
+
+func TestProcessStdin(t *testing.T) {
+	tt := []struct {
+		Name    string
+		Options []ConfigOption
+		Stdout  []string
+	}{
+		{Name: "connected", Stdout: []string{"first", "second"}},
+		{Name: "no stdin", Options: []ConfigOption{NoStdin()}, Stdout: nil},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			opts := append([]ConfigOption{ID("test"), logIn(strings.NewReader("first\nsecond\n")), logOut(&closeBuffer{}), logErr(&closeBuffer{})}, tc.Options...)
+			c, errs := New([]string{"cat"}, opts...)
+			if len(errs) > 0 {
+				t.Fatalf("unexpected error in config: %s", errs)
+			}
+			c.report = new(mockReport)
+			if err := c.Exec(); err != nil {
+				t.Fatalf("unexpected error execing command: %s", err)
+			}
+			assert.Equal(t, tc.Stdout, c.Stdout)
+			assert.True(t, c.Success)
+		})
+	}
+}
+
+func TestShellStrict(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is required for pipefail")
+	}
+	tt := []struct {
+		Name    string
+		Options []ConfigOption
+		Success bool
+	}{
+		{Name: "default", Success: true},
+		{Name: "strict", Options: []ConfigOption{ShellStrict()}, Success: false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			opts := append([]ConfigOption{ID("test"), Shell(bash), logOut(&closeBuffer{}), logErr(&closeBuffer{})}, tc.Options...)
+			c, errs := New([]string{"false", "|", "cat"}, opts...)
+			if len(errs) > 0 {
+				t.Fatalf("unexpected error in config: %s", errs)
+			}
+			c.report = new(mockReport)
+			c.Exec()
+			assert.Nil(t, c.Cleanup())
+			assert.Equal(t, tc.Success, c.Success)
+		})
+	}
+}
+
+func TestComplexCommandReadOnlyDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monny-readonly")
+	if err != nil {
+		t.Fatalf("unexpected error creating directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("unexpected error getting working directory: %v", err)
+	}
+	if err := os.Chmod(dir, 0555); err != nil {
+		t.Fatalf("unexpected error making directory read only: %v", err)
+	}
+	defer os.Chmod(dir, 0755)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("unexpected error changing directory: %v", err)
+	}
+	defer os.Chdir(wd)
+
+	c, errs := New([]string{"echo", "one", "&&", "echo", "two"}, ID("test"), logOut(&closeBuffer{}), logErr(&closeBuffer{}))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	c.report = new(mockReport)
+	if err := c.Exec(); err != nil {
+		t.Fatalf("unexpected error execing command: %s", err)
+	}
+	assert.Nil(t, c.Cleanup())
+	assert.Equal(t, []string{"one", "two"}, c.Stdout)
+	assert.True(t, c.Success)
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 0)
+}
+
+func TestComplexCommandSignal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monny-tmp")
+	if err != nil {
+		t.Fatalf("unexpected error creating directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	tmp := os.Getenv("TMPDIR")
+	os.Setenv("TMPDIR", dir)
+	defer os.Setenv("TMPDIR", tmp)
+
+	c, errs := New([]string{"sleep", "2", "&&", "echo", "done"}, ID("test"), logOut(&closeBuffer{}), logErr(&closeBuffer{}))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	c.report = new(mockReport)
+	done := make(chan error, 1)
+	go func() { done <- c.Exec() }()
+
+	// wait for the script to be written and the signal handler to be registered
+	var scripts []os.FileInfo
+	for i := 0; i < 50 && len(scripts) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		scripts, _ = ioutil.ReadDir(dir)
+	}
+	if assert.Len(t, scripts, 1) {
+		assert.Equal(t, os.FileMode(0700), scripts[0].Mode().Perm())
+	}
+	time.Sleep(100 * time.Millisecond)
+	self, _ := os.FindProcess(os.Getpid())
+	if err := self.Signal(os.Interrupt); err != nil {
+		t.Fatalf("unexpected error sending signal: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("command did not return after signal")
+	}
+	assert.True(t, c.Killed)
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 0)
+}
+
+func TestLongLines(t *testing.T) {
+	// a single 200KB line is longer than the default buffer of bufio.Scanner
+	script := `head -c 200000 /dev/zero | tr '\0' 'a'; echo; echo ERROR after`
+	tt := []struct {
+		Name    string
+		Options []ConfigOption
+		Matches int
+		Message string
+	}{
+		{Name: "default max line size", Matches: 1},
+		{Name: "line too long", Options: []ConfigOption{MaxLineSize("64KiB")}, Message: "scan error: stopped processing stdout: line exceeds max line size of 65536 bytes"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			out := &closeBuffer{}
+			c, errs := New([]string{"sh", "-c", script}, append(tc.Options, ID("test"), Rule("ERROR"), logOut(out), logErr(&closeBuffer{}))...)
+			if len(errs) > 0 {
+				t.Fatalf("unexpected error in config: %s", errs)
+			}
+			c.report = new(mockReport)
+			if err := c.Exec(); err != nil {
+				t.Fatalf("unexpected error execing command: %s", err)
+			}
+			assert.True(t, c.Success)
+			assert.Len(t, c.RuleMatches, tc.Matches)
+			// output after a line that is too long is still echoed
+			assert.Contains(t, out.String(), "ERROR after")
+			if len(tc.Message) > 0 {
+				assert.Equal(t, 1, countContaining(c.Messages, tc.Message), "expected one message in %v", c.Messages)
+			}
+		})
+	}
+}
 
 func TestExtractJSON(t *testing.T) {
 	tt := []struct {
 		Name   string
 		Field  string
+		Coerce bool
 		Expect string
 	}{
 		{Name: "string", Field: "msg", Expect: "test message"},
@@ -195,11 +502,16 @@ func TestExtractJSON(t *testing.T) {
 		{Name: "nested string", Field: "nested.nest1", Expect: "test"},
 		{Name: "nested array", Field: "array", Expect: "test1\ntest2\ntest3"},
 		{Name: "bool", Field: "bool", Expect: "true"},
+		{Name: "string number", Field: "latency", Expect: "123.4"},
+		{Name: "string number coerced", Field: "latency", Coerce: true, Expect: fmt.Sprintf("%f", 123.4)},
+		{Name: "string coerced not a number", Field: "msg", Coerce: true, Expect: "test message"},
+		{Name: "number array", Field: "numbers", Expect: fmt.Sprintf("%f\n2.5", float64(1))},
+		{Name: "number array coerced", Field: "numbers", Coerce: true, Expect: fmt.Sprintf("%f\n%f", float64(1), 2.5)},
 	}
 
 	for _, tc := range tt {
 		t.Run(tc.Name, func(t *testing.T) {
-			ext := extractTextFromJSON([]byte(testJSON), tc.Field)
+			ext := extractTextFromJSON([]byte(testJSON), tc.Field, tc.Coerce)
 			assert.Equal(t, tc.Expect, string(ext))
 		})
 	}
@@ -207,11 +519,12 @@ func TestExtractJSON(t *testing.T) {
 
 func TestCheckRule(t *testing.T) {
 	tt := []struct {
-		Name  string
-		Line  string
-		Field string
-		Regex string
-		Match bool
+		Name   string
+		Line   string
+		Field  string
+		Regex  string
+		Coerce bool
+		Match  bool
 	}{
 		{Name: "text", Line: "this is a test line", Field: "", Regex: "te.*", Match: true},
 		{Name: "text with capture", Line: "this is a test line", Field: "", Regex: "(te.*) line", Match: true},
@@ -221,6 +534,8 @@ func TestCheckRule(t *testing.T) {
 		{Name: "json text nested", Line: testJSON, Field: "nested.nest1", Regex: "te.*", Match: true},
 		{Name: "json array", Line: testJSON, Field: "array", Regex: "te.*", Match: true},
 		{Name: "json number", Line: testJSON, Field: "code", Regex: "404", Match: true},
+		{Name: "json string number", Line: testJSON, Field: "latency", Regex: `^123\.400000$`, Match: false},
+		{Name: "json string number coerced", Line: testJSON, Field: "latency", Regex: `^123\.400000$`, Coerce: true, Match: true},
 	}
 	for _, tc := range tt {
 		t.Run(tc.Name, func(t *testing.T) {
@@ -230,7 +545,7 @@ func TestCheckRule(t *testing.T) {
 				Regex: reg,
 			}
 
-			matches := checkRule([]byte(tc.Line), []rule{r})
+			matches := checkRule([]byte(tc.Line), streamStdout, []rule{r}, tc.Coerce)
 			switch tc.Match {
 			case true:
 				assert.Len(t, matches, 1)
@@ -241,6 +556,229 @@ func TestCheckRule(t *testing.T) {
 	}
 }
 
+func TestCheckRuleStream(t *testing.T) {
+	rules := []rule{
+		{Regex: regexp.MustCompile("ERROR"), Name: "any"},
+		{Regex: regexp.MustCompile("ERROR"), Name: "stderr", Severity: "critical", Stream: streamStderr},
+		{Regex: regexp.MustCompile("ERROR"), Name: "stdout", Stream: streamStdout},
+	}
+	matches := checkRule([]byte("ERROR"), streamStderr, rules, false)
+	if assert.Len(t, matches, 2) {
+		assert.Equal(t, "any", matches[0].Rule)
+		assert.Equal(t, "stderr", matches[1].Rule)
+		assert.Equal(t, "critical", matches[1].Severity)
+		assert.Equal(t, 1, matches[1].rule)
+	}
+	matches = checkRule([]byte("ERROR"), streamStdout, rules, false)
+	if assert.Len(t, matches, 2) {
+		assert.Equal(t, "stdout", matches[1].Rule)
+	}
+}
+
+func TestMergeMatches(t *testing.T) {
+	rules := []rule{
+		{Regex: regexp.MustCompile("ERROR"), Name: "error", Severity: "critical"},
+		{Regex: regexp.MustCompile("disk"), Name: "disk"},
+		{Regex: regexp.MustCompile("timeout")},
+	}
+	matches := mergeMatches(checkRule([]byte("ERROR: disk full"), streamStdout, rules, false))
+	if assert.Len(t, matches, 1) {
+		assert.Equal(t, "error", matches[0].Rule)
+		assert.Equal(t, "critical", matches[0].Severity)
+		assert.Equal(t, []string{"error", "disk"}, matches[0].Rules)
+		assert.Equal(t, [][]int{{0, 5}, {7, 11}}, matches[0].Index)
+	}
+	// a single match is not changed
+	matches = mergeMatches(checkRule([]byte("ERROR"), streamStdout, rules, false))
+	if assert.Len(t, matches, 1) {
+		assert.Nil(t, matches[0].Rules)
+	}
+
+	opts := []ConfigOption{
+		ID("test"),
+		StructuredRule(RuleSpec{Pattern: "ERROR", Name: "error"}),
+		StructuredRule(RuleSpec{Pattern: "disk", Name: "disk"}),
+		logOut(&closeBuffer{}),
+		logErr(&closeBuffer{}),
+	}
+	for _, merge := range []bool{false, true} {
+		options := opts
+		if merge {
+			options = append(options, MergeMatches())
+		}
+		c, errs := New([]string{"echo", "ERROR: disk full"}, options...)
+		if len(errs) > 0 {
+			t.Fatalf("unexpected error in config: %s", errs)
+		}
+		c.report = new(mockReport)
+		if err := c.Exec(); err != nil {
+			t.Fatalf("unexpected error execing command: %s", err)
+		}
+		switch {
+		case merge:
+			if assert.Len(t, c.RuleMatches, 1) {
+				assert.Equal(t, []string{"error", "disk"}, c.RuleMatches[0].Rules)
+			}
+		default:
+			assert.Len(t, c.RuleMatches, 2)
+		}
+	}
+}
+
+func TestRuleWindow(t *testing.T) {
+	assert.True(t, rule{}.activeAt(0))
+	assert.False(t, rule{After: 2 * time.Second}.activeAt(time.Second))
+	assert.True(t, rule{After: 2 * time.Second}.activeAt(2*time.Second))
+	assert.True(t, rule{Before: 5 * time.Minute}.activeAt(time.Minute))
+	assert.False(t, rule{Before: 5 * time.Minute}.activeAt(5*time.Minute))
+
+	c, errs := New([]string{"sh", "-c", "echo ERROR early; sleep 2.2; echo ERROR late"}, ID("test"), RuleWindow("ERROR", 2*time.Second, 0), logOut(&closeBuffer{}), logErr(&closeBuffer{}))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	c.report = new(mockReport)
+	if err := c.Exec(); err != nil {
+		t.Fatalf("unexpected error execing command: %s", err)
+	}
+	if assert.Len(t, c.RuleMatches, 1) {
+		assert.Equal(t, "ERROR late", c.RuleMatches[0].Line)
+	}
+}
+
+func TestCommandTemplate(t *testing.T) {
+	r, w := io.Pipe()
+	go func() {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(r)
+		r.Close()
+	}()
+	c, errs := New([]string{}, ID("test"), CommandTemplate("echo {{.greeting}} {{.name}}", map[string]string{"greeting": "hello"}), TemplateVar("name", "world"), logErr(w), logOut(w))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	c.report = new(mockReport)
+	assert.Equal(t, []string{"echo", "hello", "world"}, c.UserCommand)
+	assert.Equal(t, "echo {{.greeting}} {{.name}}", c.CommandTemplate)
+
+	if err := c.Exec(); err != nil {
+		t.Fatalf("unexpected error running: %s", err)
+	}
+	assert.Equal(t, []string{"hello world"}, c.Stdout)
+
+	_, errs = New([]string{"echo"}, ID("test"), CommandTemplate("echo {{.greeting}}", map[string]string{"greeting": "hello"}))
+	if assert.Len(t, errs, 1) {
+		assert.True(t, errors.As(errs[0], &ErrTemplateAndCommand{}))
+		assert.Equal(t, "use either a command template or a command, not both", errs[0].Error())
+	}
+}
+
+func TestPreRun(t *testing.T) {
+	tt := []struct {
+		Name    string
+		PreRun  string
+		Run     bool
+		Reasons []proto.ReportReason
+		Message string
+	}{
+		{Name: "success", PreRun: "echo ERROR from pre-run", Run: true, Reasons: []proto.ReportReason{proto.Success}},
+		{Name: "failure", PreRun: "echo lock held >&2; exit 3", Reasons: []proto.ReportReason{proto.Failure}, Message: "pre-run command echo lock held >&2; exit 3 failed and the command was not run: exit status 3: lock held"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "monny")
+			if err != nil {
+				t.Fatalf("unexpected error creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			marker := filepath.Join(dir, "ran")
+
+			c, errs := New([]string{"touch", marker}, ID("test"), Rule("ERROR"), PreRun(tc.PreRun), logOut(&closeBuffer{}), logErr(&closeBuffer{}))
+			if len(errs) > 0 {
+				t.Fatalf("unexpected error in config: %s", errs)
+			}
+			report := new(reasonRecorder)
+			c.report = report
+			assert.NoError(t, c.Exec())
+			assert.NoError(t, c.Wait())
+
+			_, err = os.Stat(marker)
+			assert.Equal(t, tc.Run, err == nil)
+			assert.Equal(t, tc.Run, c.Success)
+			// output of the pre-run command is not checked for rules
+			assert.Empty(t, c.RuleMatches)
+			assert.Equal(t, tc.Reasons, report.Reasons())
+			if len(tc.Message) > 0 {
+				assert.Contains(t, c.Messages, tc.Message)
+			}
+		})
+	}
+}
+
+func TestWorkingDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monny")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	// the temp dir may be a symlink, such as on macOS
+	dir, err = filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatalf("unexpected error resolving temp dir: %v", err)
+	}
+
+	c, errs := New([]string{"pwd"}, ID("test"), WorkingDir(dir), PreRun("touch ran"), logOut(&closeBuffer{}), logErr(&closeBuffer{}))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	c.report = new(mockReport)
+	assert.NoError(t, c.Exec())
+	assert.Equal(t, []string{dir}, c.Stdout)
+	assert.Equal(t, dir, c.Config.Sanitized().WorkingDir)
+	_, err = os.Stat(filepath.Join(dir, "ran"))
+	assert.NoError(t, err, "pre-run command should run in the working directory")
+}
+
+func TestStdin(t *testing.T) {
+	tt := []struct {
+		Name    string
+		Stdin   string
+		Options []ConfigOption
+		Stdout  []string
+		Matches []string
+	}{
+		{Name: "capture stdin", Stdin: "start\nfinish\n", Stdout: []string{"start", "finish"}},
+		{Name: "rule match", Stdin: "start\nERROR this is a test\nfinish\n", Options: []ConfigOption{Rule("ERROR")}, Stdout: []string{"start", "ERROR this is a test", "finish"}, Matches: []string{"ERROR this is a test"}},
+		{Name: "json rule match", Stdin: testJSON + "\n", Options: []ConfigOption{JSONRule("msg", "te.*")}, Stdout: []string{testJSON}, Matches: []string{testJSON}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			r, w := io.Pipe()
+			go func() {
+				buf := new(bytes.Buffer)
+				buf.ReadFrom(r)
+				r.Close()
+			}()
+			opts := append(tc.Options, ID("test"), logIn(strings.NewReader(tc.Stdin)), logErr(w), logOut(w))
+			c, err := New([]string{}, opts...)
+			if err != nil {
+				t.Fatalf("unexpected error in config: %s", err)
+			}
+			c.report = new(mockReport)
+
+			if err := c.Exec(); err != nil {
+				t.Fatalf("unexpected error running: %s", err)
+			}
+			assert.Equal(t, tc.Stdout, c.Stdout)
+			assert.Equal(t, proto.Success, c.ReportReason)
+			assert.Len(t, c.RuleMatches, len(tc.Matches))
+			for idx, line := range tc.Matches {
+				assert.Equal(t, line, c.RuleMatches[idx].Line)
+			}
+		})
+	}
+}
+
 func TestRules(t *testing.T) {
 	tt := []struct {
 		Name        string
@@ -293,3 +831,52 @@ func TestRules(t *testing.T) {
 	}
 
 }
+
+func TestQuiet(t *testing.T) {
+	c, err := New([]string{"sh", "-c", "echo out; echo ERROR >&2"}, ID("test"), Rule("ERROR"), Quiet())
+	if err != nil {
+		t.Fatalf("unexpected error in config: %s", err)
+	}
+	c.report = new(mockReport)
+	assert.Equal(t, discard{}, c.out)
+	assert.Equal(t, discard{}, c.err)
+
+	if err := c.Exec(); err != nil {
+		t.Fatalf("unexpected error running: %s", err)
+	}
+	// output is still captured and processed for rules
+	assert.Equal(t, []string{"out"}, c.Stdout)
+	assert.Equal(t, []string{"ERROR"}, c.Stderr)
+	if assert.Len(t, c.RuleMatches, 1) {
+		assert.Equal(t, "ERROR", c.RuleMatches[0].Line)
+	}
+}
+
+// failWriter fails every write to simulate a broken output sink
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) {
+	return 0, fmt.Errorf("sink closed")
+}
+
+func (failWriter) Close() error {
+	return nil
+}
+
+func TestSinkWriteError(t *testing.T) {
+	c, err := New([]string{"sh", "-c", "echo out; echo more; echo ERROR >&2"}, ID("test"), logOut(failWriter{}), logErr(failWriter{}))
+	if err != nil {
+		t.Fatalf("unexpected error in config: %s", err)
+	}
+	c.report = new(mockReport)
+
+	if err := c.Exec(); err != nil {
+		t.Fatalf("unexpected error running: %s", err)
+	}
+	assert.Equal(t, []string{"out", "more"}, c.Stdout)
+	assert.Equal(t, []string{"ERROR"}, c.Stderr)
+	// repeated write errors are only recorded once
+	assert.Len(t, c.Messages, 2)
+	assert.Equal(t, 1, countContaining(c.Messages, "sink error: error writing log line to stdout: sink closed"))
+	assert.Equal(t, 1, countContaining(c.Messages, "sink error: error writing log line to stderr: sink closed"))
+}
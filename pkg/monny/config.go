@@ -1,100 +1,458 @@
 package monny
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
+	"unicode"
+
+	"github.com/BTBurke/monny/pkg/trace"
+	"github.com/BTBurke/monny/pkg/units"
 )
 
 const api string = "https://report.lmkwtf.com"
 const port string = "443"
 
+// metricWindow is the default window over which metric rule matches are counted
+const metricWindow = 15 * time.Second
+
+// statLambda is the default weight of each observation in the EWMA estimator of metric rules
+const statLambda = 0.25
+
+// maxReportBytes is the default limit on the size of a report, leaving room below the default 4MB gRPC message limit
+const maxReportBytes int = 3 * 1024 * 1024
+
+// maxLineSize is the default size of the longest line of output that is processed
+const maxLineSize int = 1024 * 1024
+
 // Config stores configuration data for the monitoring service.  Functional options are
 // used to modify the configuration based on command-line flags or optional YAML configuration.
 // See documentation of individual functional options for descriptions.
 type Config struct {
-	ID              string
-	Rules           []rule
-	RuleQuantity    int
-	RulePeriod      time.Duration
-	Hostname        string
-	NotifyTimeout   time.Duration
-	KillTimeout     time.Duration
-	MemoryWarn      uint64
-	MemoryKill      uint64
-	Daemon          bool
-	Creates         []string
-	StdoutHistory   int
-	StderrHistory   int
-	NotifyOnSuccess bool
-	NotifyOnFailure bool
-	Shell           string
-
-	host   string
-	port   string
-	useTLS bool
-	out    io.WriteCloser
-	err    io.WriteCloser
+	ID                string
+	Presets           []string
+	Rules             []rule
+	RuleQuantity      int
+	RulePeriod        time.Duration
+	MetricRules       []metricRule
+	MetricWindow      time.Duration
+	StatLambda        float64
+	SampleStrategy    string
+	ErrorBudgetSLO    float64
+	ErrorBudgetWindow time.Duration
+	Hostname          string
+	NotifyTimeout     time.Duration
+	KillTimeout       time.Duration
+	MemoryWarn        uint64
+	MemoryKill        uint64
+	Daemon            bool
+	HeartbeatInterval time.Duration
+	Schedule          string
+	MaxConcurrent     int
+	ExpectedEvery     time.Duration
+	Creates           []string
+	CreatesWatch      []fileWatch
+	StdoutHistory     int
+	StderrHistory     int
+	NotifyOnSuccess   bool
+	NotifyOnFailure   bool
+	Shell             string
+	NoShell           bool
+	NoStdin           bool
+	ShellStrict       bool
+	PTY               bool
+	BatchInterval     time.Duration
+	BatchSize         int
+	BatchWindow       time.Duration
+	BatchReports      time.Duration
+	MultiLineJSON     bool
+	CommandTemplate   string
+	PreRun            string
+	WorkingDir        string
+	Steps             []string
+	Pipeline          bool
+	ContinueOnError   bool
+	Parallel          bool
+	FailFast          bool
+	KillOnBrokenPipe  bool
+	MaxReportBytes    int
+	MaxLineSize       int
+	Compress          bool
+	ReportFile        string
+	MetricsSnapshot   string
+	PrometheusAddr    string
+	OTelEndpoint      string
+	EnvDumpFile       string
+	IncludeEnv        []string
+	OmitConfig        bool
+	JSONLMatches      bool
+	MergeMatches      bool
+	CoerceJSONNumbers bool
+	DryRun            bool
+	PrintReports      bool
+	Verbose           bool
+	Debug             bool
+	LogFormat         string
+	// Warnings describes combinations of options where an option has no effect.  Warnings are added to the messages
+	// of each report.
+	Warnings []string
+
+	host      string
+	port      string
+	useTLS    bool
+	tls       *tls.Config
+	schedule  *schedule
+	stateDir  string
+	token     string
+	vars      map[string]string
+	cmd       []string
+	redactEnv []*regexp.Regexp
+	tracer    trace.TracerProvider
+	log       io.Writer
+	in        io.Reader
+	out       io.WriteCloser
+	err       io.WriteCloser
+	// budgetTotal and budgetError match the lines counted as requests and errors by the error budget
+	budgetTotal *regexp.Regexp
+	budgetError *regexp.Regexp
+	// metricSampling and metricRateLimit are set on the metric rule of the same name when the configuration is
+	// validated, so that they do not depend on the order of the options
+	metricSampling  map[string]int
+	metricRateLimit map[string]metricRateLimit
+	// dryRunFile is the path the dry run is appended to instead of Stderr
+	dryRunFile string
+	// budgetMinRequests is the number of requests in the window before the error budget can be exhausted, or the
+	// default when zero
+	budgetMinRequests int
+}
+
+// Sanitized returns a copy of the configuration with only the fields that are safe to send to the reporting
+// server.  Fields that may contain secrets or internal details, such as rule regexes, the shell, and the command
+// template, are left empty.  New fields must be added here explicitly to be included in reports.
+func (c Config) Sanitized() Config {
+	return Config{
+		ID:                c.ID,
+		Presets:           c.Presets,
+		RuleQuantity:      c.RuleQuantity,
+		RulePeriod:        c.RulePeriod,
+		MetricWindow:      c.MetricWindow,
+		StatLambda:        c.StatLambda,
+		SampleStrategy:    c.SampleStrategy,
+		ErrorBudgetSLO:    c.ErrorBudgetSLO,
+		ErrorBudgetWindow: c.ErrorBudgetWindow,
+		Hostname:          c.Hostname,
+		NotifyTimeout:     c.NotifyTimeout,
+		KillTimeout:       c.KillTimeout,
+		MemoryWarn:        c.MemoryWarn,
+		MemoryKill:        c.MemoryKill,
+		Daemon:            c.Daemon,
+		HeartbeatInterval: c.HeartbeatInterval,
+		Schedule:          c.Schedule,
+		MaxConcurrent:     c.MaxConcurrent,
+		ExpectedEvery:     c.ExpectedEvery,
+		Creates:           c.Creates,
+		CreatesWatch:      c.CreatesWatch,
+		StdoutHistory:     c.StdoutHistory,
+		StderrHistory:     c.StderrHistory,
+		NotifyOnSuccess:   c.NotifyOnSuccess,
+		NotifyOnFailure:   c.NotifyOnFailure,
+		NoShell:           c.NoShell,
+		NoStdin:           c.NoStdin,
+		ShellStrict:       c.ShellStrict,
+		PTY:               c.PTY,
+		WorkingDir:        c.WorkingDir,
+		Pipeline:          c.Pipeline,
+		ContinueOnError:   c.ContinueOnError,
+		Parallel:          c.Parallel,
+		FailFast:          c.FailFast,
+		KillOnBrokenPipe:  c.KillOnBrokenPipe,
+		BatchInterval:     c.BatchInterval,
+		BatchSize:         c.BatchSize,
+		BatchWindow:       c.BatchWindow,
+		BatchReports:      c.BatchReports,
+		MultiLineJSON:     c.MultiLineJSON,
+		MaxReportBytes:    c.MaxReportBytes,
+		MaxLineSize:       c.MaxLineSize,
+		Compress:          c.Compress,
+		JSONLMatches:      c.JSONLMatches,
+		MergeMatches:      c.MergeMatches,
+		CoerceJSONNumbers: c.CoerceJSONNumbers,
+	}
 }
 
 type rule struct {
-	Field string
-	Regex *regexp.Regexp
+	Field    string
+	Regex    *regexp.Regexp
+	Name     string
+	Severity string
+	Stream   streamSelector
+	Quantity int
+	Period   time.Duration
+	After    time.Duration
+	Before   time.Duration
+}
+
+// selects returns true if the rule applies to lines from stream.  Rules apply to both streams by default.
+func (r rule) selects(stream streamSelector) bool {
+	return len(r.Stream) == 0 || r.Stream == streamBoth || r.Stream == stream
+}
+
+// activeAt returns true if the rule applies to lines emitted at elapsed time after the process started.  Rules apply
+// for the whole run by default.
+func (r rule) activeAt(elapsed time.Duration) bool {
+	return elapsed >= r.After && (r.Before == 0 || elapsed < r.Before)
+}
+
+// rateLimited returns true if reports for rule matches are sent only when a quantity of matches is reached
+func (c Config) rateLimited() bool {
+	if c.RuleQuantity > 0 {
+		return true
+	}
+	for _, r := range c.Rules {
+		if r.Quantity > 0 {
+			return true
+		}
+	}
+	return false
 }
 
 // ConfigOption is a function for validating and setting configuration values
 type ConfigOption func(c *Config) error
 
 func newConfig(options ...ConfigOption) (Config, []error) {
+	c := defaultConfig()
+
+	var errors []error
+	for _, option := range options {
+		err := option(&c)
+		if err != nil {
+			errors = append(errors, err)
+		}
+	}
+
+	shell, err := findDefaultShell()
+	if err != nil {
+		errors = append(errors, err)
+	}
+	c.Shell = shell
+	if len(c.CommandTemplate) > 0 {
+		cmd, err := renderCommand(c.CommandTemplate, c.vars)
+		if err != nil {
+			errors = append(errors, err)
+		}
+		c.cmd = cmd
+	}
+	if len(c.ID) == 0 {
+		errors = append(errors, ErrMissingID{})
+	}
+	errors = append(errors, c.validate()...)
+
+	if len(errors) > 0 {
+		return Config{}, errors
+	}
+	return c, nil
+}
+
+// defaultConfig returns the configuration before any options are applied
+func defaultConfig() Config {
 	host, err := os.Hostname()
 	if err != nil {
 		host = ""
 	}
-	c := Config{
+	return Config{
 		StdoutHistory:   30,
 		StderrHistory:   30,
 		NotifyOnSuccess: true,
 		NotifyOnFailure: true,
+		MaxConcurrent:   1,
+		BatchSize:       1,
+		MetricWindow:    metricWindow,
+		StatLambda:      statLambda,
+		SampleStrategy:  "sum",
+		MaxReportBytes:  maxReportBytes,
+		MaxLineSize:     maxLineSize,
+		Compress:        true,
 		Hostname:        host,
 		host:            api,
 		port:            port,
 		useTLS:          true,
-		out:             os.Stdout,
-		err:             os.Stderr,
+		in:              os.Stdin,
+		out:             standardStream{os.Stdout},
+		err:             standardStream{os.Stderr},
 	}
+}
 
+// validate checks combinations of options after all options are applied.  Combinations that can not work are returned
+// as errors.  Combinations where an option has no effect are added to Warnings.
+func (c *Config) validate() []error {
 	var errors []error
-	for _, option := range options {
-		err := option(&c)
-		if err != nil {
-			errors = append(errors, err)
-		}
+	if c.RulePeriod > 0 && c.RuleQuantity == 0 {
+		errors = append(errors, ErrInvalidValue{Option: "rule-period", Value: c.RulePeriod.String(), Reason: "rule period has no effect without a number of matches, set with --rule-quantity"})
+	}
+	if c.MemoryKill > 0 && c.MemoryWarn > 0 && c.MemoryKill <= c.MemoryWarn {
+		c.Warnings = append(c.Warnings, fmt.Sprintf("memory-warn %dK has no effect because the process is killed at memory-kill %dK", c.MemoryWarn, c.MemoryKill))
+	}
+	if c.KillTimeout > 0 && c.NotifyTimeout > 0 && c.KillTimeout <= c.NotifyTimeout {
+		c.Warnings = append(c.Warnings, fmt.Sprintf("timeout-warn %s has no effect because the process is killed at timeout-kill %s", c.NotifyTimeout, c.KillTimeout))
+	}
+	if c.HeartbeatInterval > 0 && !c.Daemon {
+		c.Warnings = append(c.Warnings, "heartbeat has no effect because heartbeats are only sent for daemons")
+	}
+	if c.BatchWindow > 0 && c.BatchSize <= 1 {
+		c.Warnings = append(c.Warnings, "batch-window has no effect because each report is sent as soon as it is created with a batch-size of 1")
+	}
+	if c.BatchWindow > 0 && c.BatchInterval > 0 {
+		c.Warnings = append(c.Warnings, "batch-interval has no effect because batches are sent after batch-window")
+	}
+	if len(c.Steps) > 0 && len(c.CommandTemplate) > 0 {
+		errors = append(errors, ErrInvalidValue{Option: "step", Reason: "use either steps or a command template, not both"})
+	}
+	if len(c.Steps) > 0 && c.Pipeline {
+		errors = append(errors, ErrInvalidValue{Option: "pipeline", Reason: "use either steps or a pipeline of commands separated by --, not both"})
+	}
+	if c.Pipeline && c.Parallel {
+		errors = append(errors, ErrInvalidValue{Option: "parallel", Reason: "use either a pipeline or parallel commands, not both"})
+	}
+	if c.ContinueOnError && len(c.Steps) == 0 && !c.Pipeline && !c.Parallel {
+		c.Warnings = append(c.Warnings, "continue-on-error has no effect because the command is not a pipeline")
+	}
+	if c.ContinueOnError && c.Parallel {
+		c.Warnings = append(c.Warnings, "continue-on-error has no effect because parallel commands all run unless fail-fast is set")
+	}
+	if c.FailFast && !c.Parallel {
+		c.Warnings = append(c.Warnings, "fail-fast has no effect because the commands are not run in parallel")
+	}
+	if len(c.Schedule) > 0 && c.Daemon {
+		errors = append(errors, ErrInvalidValue{Option: "schedule", Value: c.Schedule, Reason: "a daemon runs until it exits and can not be run on a schedule"})
+	}
+	if c.MaxConcurrent > 1 && len(c.Schedule) == 0 {
+		c.Warnings = append(c.Warnings, "max-concurrent has no effect because the command is not run on a schedule")
 	}
+	if c.ErrorBudgetSLO > 0 && c.budgetError == nil {
+		errors = append(errors, ErrInvalidValue{Option: "error-budget", Reason: "an error budget needs a rule for errors, set with --error-budget-error"})
+	}
+	if c.ErrorBudgetSLO == 0 && (c.budgetError != nil || c.budgetTotal != nil) {
+		c.Warnings = append(c.Warnings, "error-budget-error and error-budget-total have no effect without an SLO, set with --error-budget")
+	}
+	errors = append(errors, c.applyMetricLimits()...)
+	if len(c.dryRunFile) > 0 && !c.PrintReports {
+		c.DryRun = true
+	}
+	if c.DryRun && c.PrintReports {
+		c.Warnings = append(c.Warnings, "print-reports has no effect because the command is not run in a dry run")
+	}
+	if c.ExpectedEvery > 0 && c.Daemon {
+		c.Warnings = append(c.Warnings, "expected-every has no effect because a daemon runs until it exits, use heartbeat to detect a daemon that stops")
+	}
+	if c.Daemon && len(c.Creates) > 0 {
+		c.Warnings = append(c.Warnings, "creates is only checked when a daemon exits, use creates-watch to check for files while it is running")
+	}
+	return errors
+}
 
-	shell, err := findDefaultShell()
+// renderCommand splits the command template into words with the quoting rules of the shell, then executes each word
+// with vars so that a value containing spaces stays a single argument
+func renderCommand(tmpl string, vars map[string]string) ([]string, error) {
+	words, err := splitWords(tmpl)
 	if err != nil {
-		errors = append(errors, err)
+		return nil, ErrInvalidTemplate{Template: tmpl, Err: err}
 	}
-	c.Shell = shell
-	if len(c.ID) == 0 {
-		errors = append(errors, fmt.Errorf("id is required, use monny -i <id>; new ids are created with monctl create or pass your email address to get a notifications via email without an account"))
+	var cmd []string
+	for _, word := range words {
+		t, err := template.New("command").Option("missingkey=error").Parse(word)
+		if err != nil {
+			return nil, ErrInvalidTemplate{Template: tmpl, Err: err}
+		}
+		var out strings.Builder
+		if err := t.Execute(&out, vars); err != nil {
+			return nil, ErrInvalidTemplate{Template: tmpl, Err: err}
+		}
+		cmd = append(cmd, out.String())
 	}
+	if len(cmd) == 0 || len(cmd[0]) == 0 {
+		return nil, ErrInvalidTemplate{Template: tmpl, Err: fmt.Errorf("rendered an empty command")}
+	}
+	return cmd, nil
+}
 
-	if len(errors) > 0 {
-		return Config{}, errors
+// splitWords splits s on whitespace outside of quotes.  Single quotes keep everything up to the closing quote,
+// double quotes and a backslash outside of quotes escape the next character.  Template actions between {{ and }}
+// are kept whole so that their arguments are not split.
+func splitWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '{' && i+1 < len(runes) && runes[i+1] == '{':
+			end := strings.Index(string(runes[i:]), "}}")
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed action")
+			}
+			action := string(runes[i:])[:end+2]
+			word.WriteString(action)
+			i += len([]rune(action)) - 1
+			inWord = true
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+				continue
+			}
+			word.WriteRune(r)
+		case r == '\\' && quote != '\'':
+			if i+1 < len(runes) {
+				i++
+				word.WriteRune(runes[i])
+			}
+			inWord = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+				continue
+			}
+			word.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
 	}
-	return c, nil
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
 }
 
 func findDefaultShell() (string, error) {
 	shell := os.Getenv("SHELL")
 	if len(shell) == 0 {
-		return shell, fmt.Errorf("could not determine default shell, set with --shell=<full path to shell>")
+		return shell, ErrShellNotFound{}
 	}
 	return shell, nil
 }
@@ -114,8 +472,11 @@ func ID(id string) ConfigOption {
 func Rule(regex string) ConfigOption {
 	return func(c *Config) error {
 		reg, err := regexp.Compile(regex)
+		if err != nil {
+			return ErrInvalidRegex{Pattern: regex, Err: err}
+		}
 		c.Rules = append(c.Rules, rule{Regex: reg})
-		return err
+		return nil
 	}
 }
 
@@ -125,11 +486,319 @@ func Rule(regex string) ConfigOption {
 func JSONRule(field string, regex string) ConfigOption {
 	return func(c *Config) error {
 		reg, err := regexp.Compile(regex)
+		if err != nil {
+			return ErrInvalidRegex{Pattern: regex, Err: err}
+		}
 		c.Rules = append(c.Rules, rule{
 			Field: field,
 			Regex: reg,
 		})
-		return err
+		return nil
+	}
+}
+
+// RuleWindow is like Rule except that the rule only matches lines emitted within a window of time relative to the start
+// of the process, such as during startup or after the process reaches a steady state.  A zero after matches from the
+// start of the process and a zero before matches until it exits.
+func RuleWindow(regex string, after time.Duration, before time.Duration) ConfigOption {
+	return func(c *Config) error {
+		reg, err := regexp.Compile(regex)
+		if err != nil {
+			return ErrInvalidRegex{Pattern: regex, Err: err}
+		}
+		if err := validateRuleWindow(regex, after, before); err != nil {
+			return err
+		}
+		c.Rules = append(c.Rules, rule{Regex: reg, After: after, Before: before})
+		return nil
+	}
+}
+
+func validateRuleWindow(pattern string, after time.Duration, before time.Duration) error {
+	switch {
+	case after < 0:
+		return ErrInvalidValue{Option: "rule-window", Value: after.String(), Reason: fmt.Sprintf("start of the window for rule %s must not be negative", pattern)}
+	case before < 0:
+		return ErrInvalidValue{Option: "rule-window", Value: before.String(), Reason: fmt.Sprintf("end of the window for rule %s must not be negative", pattern)}
+	case before > 0 && before <= after:
+		return ErrInvalidValue{Option: "rule-window", Value: fmt.Sprintf("%s,%s", after, before), Reason: fmt.Sprintf("end of the window for rule %s must be after the start", pattern)}
+	}
+	return nil
+}
+
+// RuleSpec describes a rule with settings that are not available with Rule or JSONRule.  Each entry of the rules list
+// in the YAML configuration file is a RuleSpec:
+//
+//	rules:
+//	  - name: disk-full
+//	    pattern: "No space left"
+//	    severity: critical
+//	    stream: stderr
+//	  - name: slow-request
+//	    field: duration
+//	    pattern: "^[0-9]{4,}"
+//	    quantity: 10
+//	    period: 5m
+type RuleSpec struct {
+	// Pattern is the regex matched against each line, or against the value of Field in JSON lines
+	Pattern string `yaml:"pattern"`
+	Field   string `yaml:"field"`
+	// Name and Severity are included in each match of the rule in the report
+	Name     string `yaml:"name"`
+	Severity string `yaml:"severity"`
+	// Quantity and Period send a report when the rule matches Quantity times within Period, counted separately from
+	// other rules and instead of RuleQuantity and RulePeriod
+	Quantity int           `yaml:"quantity"`
+	Period   time.Duration `yaml:"period"`
+	// Stream is stdout, stderr, or both, which is the default.  When using PTY all lines are treated as stdout.
+	Stream string `yaml:"stream"`
+	// After and Before limit the rule to lines emitted within a window of time after the process starts.  See RuleWindow.
+	After  time.Duration `yaml:"after"`
+	Before time.Duration `yaml:"before"`
+}
+
+// StructuredRule adds a rule described by spec.  A rule with only a pattern, or a pattern and field, is the same as
+// Rule or JSONRule.
+func StructuredRule(spec RuleSpec) ConfigOption {
+	return func(c *Config) error {
+		if len(spec.Pattern) == 0 {
+			return ErrInvalidValue{Option: "rules", Value: spec.Name, Reason: "rule pattern is required"}
+		}
+		reg, err := regexp.Compile(spec.Pattern)
+		if err != nil {
+			return ErrInvalidRegex{Pattern: spec.Pattern, Err: err}
+		}
+		s := streamSelector(spec.Stream)
+		switch s {
+		case "", streamStdout, streamStderr, streamBoth:
+		default:
+			return ErrInvalidValue{Option: "rules", Value: spec.Stream, Reason: fmt.Sprintf("unknown stream for rule %s, should be stdout, stderr, or both: %s", spec.Pattern, spec.Stream)}
+		}
+		switch {
+		case spec.Quantity < 0:
+			return ErrInvalidValue{Option: "rules", Value: strconv.Itoa(spec.Quantity), Reason: fmt.Sprintf("quantity for rule %s must not be negative", spec.Pattern)}
+		case spec.Period < 0:
+			return ErrInvalidValue{Option: "rules", Value: spec.Period.String(), Reason: fmt.Sprintf("period for rule %s must not be negative", spec.Pattern)}
+		case spec.Period > 0 && spec.Quantity == 0:
+			return ErrInvalidValue{Option: "rules", Value: spec.Period.String(), Reason: fmt.Sprintf("period for rule %s has no effect without a quantity", spec.Pattern)}
+		}
+		if err := validateRuleWindow(spec.Pattern, spec.After, spec.Before); err != nil {
+			return err
+		}
+		c.Rules = append(c.Rules, rule{
+			Field:    spec.Field,
+			Regex:    reg,
+			Name:     spec.Name,
+			Severity: spec.Severity,
+			Stream:   s,
+			Quantity: spec.Quantity,
+			Period:   spec.Period,
+			After:    spec.After,
+			Before:   spec.Before,
+		})
+		return nil
+	}
+}
+
+// MetricRule counts lines matching regex in each MetricWindow and sends a report when the rate of matches increases,
+// as detected by a Poisson estimator.  The stream selects whether lines from stdout, stderr, or both are counted, which
+// is useful to track an error rate from only stderr.  An empty stream counts both.  When using PTY, stdout and stderr
+// are combined and all lines are treated as stdout.
+func MetricRule(name string, stream string, regex string) ConfigOption {
+	return func(c *Config) error {
+		if len(name) == 0 {
+			return ErrInvalidValue{Option: "metric-rule", Value: name, Reason: "metric rule name is required"}
+		}
+		reg, err := regexp.Compile(regex)
+		if err != nil {
+			return ErrInvalidRegex{Pattern: regex, Err: err}
+		}
+		s := streamSelector(stream)
+		switch s {
+		case "":
+			s = streamBoth
+		case streamStdout, streamStderr, streamBoth:
+		default:
+			return ErrInvalidValue{Option: "metric-rule", Value: stream, Reason: fmt.Sprintf("unknown stream for metric rule %s, should be stdout, stderr, or both: %s", name, stream)}
+		}
+		c.MetricRules = append(c.MetricRules, metricRule{
+			Name:   name,
+			Stream: s,
+			Regex:  reg,
+		})
+		return nil
+	}
+}
+
+// MetricRuleSampling records on average 1 in every k windows of the metric rule with this name in its estimator, to
+// protect the estimator from a high rate of windows.  Each window is recorded with probability 1/k so that the sampled
+// series has the same distribution.
+func MetricRuleSampling(name string, k int) ConfigOption {
+	return func(c *Config) error {
+		if len(name) == 0 {
+			return ErrInvalidValue{Option: "metric-rule-sampling", Value: name, Reason: "metric rule name is required"}
+		}
+		if k < 1 {
+			return ErrInvalidValue{Option: "metric-rule-sampling", Value: strconv.Itoa(k), Reason: fmt.Sprintf("sampling of metric rule %s must be at least 1", name)}
+		}
+		if c.metricSampling == nil {
+			c.metricSampling = make(map[string]int)
+		}
+		c.metricSampling[name] = k
+		return nil
+	}
+}
+
+// MetricRuleRateLimit records at most n windows of the metric rule with this name in its estimator in each period.
+// Windows in excess of the limit are dropped until the next period begins.
+func MetricRuleRateLimit(name string, n int, period time.Duration) ConfigOption {
+	return func(c *Config) error {
+		if len(name) == 0 {
+			return ErrInvalidValue{Option: "metric-rule-rate-limit", Value: name, Reason: "metric rule name is required"}
+		}
+		if n < 1 || period <= 0 {
+			return ErrInvalidValue{Option: "metric-rule-rate-limit", Value: fmt.Sprintf("%d:%s", n, period), Reason: fmt.Sprintf("rate limit of metric rule %s must be at least 1 window over a positive period", name)}
+		}
+		if c.metricRateLimit == nil {
+			c.metricRateLimit = make(map[string]metricRateLimit)
+		}
+		c.metricRateLimit[name] = metricRateLimit{n: n, period: period}
+		return nil
+	}
+}
+
+// applyMetricLimits sets the sampling and rate limit of each metric rule.  Returns an error for limits set on a metric
+// rule that does not exist.
+func (c *Config) applyMetricLimits() []error {
+	var errors []error
+	apply := func(option string, name string, set func(r *metricRule)) {
+		found := false
+		for i := range c.MetricRules {
+			if c.MetricRules[i].Name == name {
+				set(&c.MetricRules[i])
+				found = true
+			}
+		}
+		if !found {
+			errors = append(errors, ErrInvalidValue{Option: option, Value: name, Reason: fmt.Sprintf("no metric rule named %s, set with --metric-rule", name)})
+		}
+	}
+	var names []string
+	for name := range c.metricSampling {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		k := c.metricSampling[name]
+		apply("metric-rule-sampling", name, func(r *metricRule) { r.Sampling = k })
+	}
+	names = nil
+	for name := range c.metricRateLimit {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		l := c.metricRateLimit[name]
+		apply("metric-rule-rate-limit", name, func(r *metricRule) { r.RateLimit, r.RatePeriod = l.n, l.period })
+	}
+	return errors
+}
+
+// SampleStrategy sets how the lines matching a metric rule in each window are combined into one observation: sum,
+// avg, max, min, or count.  The value of a line is the number in the capture group named value, such as
+// latency=(?P<value>\d+), or 1 when the rule has none. (default sum)
+func SampleStrategy(strategy string) ConfigOption {
+	return func(c *Config) error {
+		if _, ok := sampleStrategies[strategy]; !ok {
+			return ErrInvalidValue{Option: "sample-strategy", Value: strategy, Reason: fmt.Sprintf("unknown sample strategy %s, should be one of %s", strategy, strings.Join(sampleStrategyNames, ", "))}
+		}
+		c.SampleStrategy = strategy
+		return nil
+	}
+}
+
+// MetricWindow is the window over which matches to metric rules are counted.  Each window is an observation of the rate
+// of matches.  Expects a time.Duration in string format (e.g. 10s, 1m). (default 15s)
+func MetricWindow(window string) ConfigOption {
+	return func(c *Config) error {
+		duration, err := units.ParseDuration(window)
+		if err != nil || duration <= 0 {
+			return ErrInvalidDuration{Option: "metric-window", Value: window}
+		}
+		c.MetricWindow = duration
+		return nil
+	}
+}
+
+// StatLambda sets the weight of each observation in the EWMA estimator of metric rules, between 0 and 1.  Larger values
+// respond to a change in the rate of matches more quickly, but alarm falsely more often.  At 1, the EWMA estimator is the
+// same as the Shewart estimator. (default 0.25)
+func StatLambda(lambda float64) ConfigOption {
+	return func(c *Config) error {
+		if !(lambda > 0.0 && lambda <= 1.0) {
+			return ErrInvalidValue{Option: "stat-lambda", Value: strconv.FormatFloat(lambda, 'g', -1, 64), Reason: "lambda must be greater than 0 and at most 1"}
+		}
+		c.StatLambda = lambda
+		return nil
+	}
+}
+
+// ErrorBudget tracks the error rate against an SLO of sloPercent successful requests, such as 99.9, over a rolling
+// window.  An SLO violation report is sent when more than 100 - sloPercent percent of requests in the window are
+// errors.  Errors are lines matching ErrorBudgetError and requests are lines matching ErrorBudgetTotal, or every line
+// of output when it is not set.  The window is rolled forward in thirtieths, so counts expire gradually.
+func ErrorBudget(sloPercent float64, window time.Duration) ConfigOption {
+	return func(c *Config) error {
+		if !(sloPercent > 0.0 && sloPercent < 100.0) {
+			return ErrInvalidValue{Option: "error-budget", Value: strconv.FormatFloat(sloPercent, 'g', -1, 64), Reason: "SLO must be a percentage greater than 0 and less than 100"}
+		}
+		if window <= 0 {
+			return ErrInvalidValue{Option: "error-budget", Value: window.String(), Reason: "error budget window must be greater than zero"}
+		}
+		c.ErrorBudgetSLO = sloPercent
+		c.ErrorBudgetWindow = window
+		return nil
+	}
+}
+
+// ErrorBudgetMinRequests is the number of requests that must be counted in the window before the error budget can be
+// exhausted, so that a few errors in the first requests do not send a report (default: 20).  See ErrorBudget.
+func ErrorBudgetMinRequests(requests string) ConfigOption {
+	return func(c *Config) error {
+		n, err := strconv.Atoi(requests)
+		if err != nil {
+			return ErrInvalidNumber{Option: "error-budget-min-requests", Value: requests}
+		}
+		if n < 1 {
+			return ErrInvalidValue{Option: "error-budget-min-requests", Value: requests, Reason: "minimum requests must be at least 1"}
+		}
+		c.budgetMinRequests = n
+		return nil
+	}
+}
+
+// ErrorBudgetError counts lines of output matching regex as errors against the error budget.  See ErrorBudget.
+func ErrorBudgetError(regex string) ConfigOption {
+	return func(c *Config) error {
+		reg, err := regexp.Compile(regex)
+		if err != nil {
+			return ErrInvalidRegex{Pattern: regex, Err: err}
+		}
+		c.budgetError = reg
+		return nil
+	}
+}
+
+// ErrorBudgetTotal counts lines of output matching regex as requests in the error budget.  Lines counted as errors
+// should also match, such as a regex matching every access log line.  (default every line)
+func ErrorBudgetTotal(regex string) ConfigOption {
+	return func(c *Config) error {
+		reg, err := regexp.Compile(regex)
+		if err != nil {
+			return ErrInvalidRegex{Pattern: regex, Err: err}
+		}
+		c.budgetTotal = reg
+		return nil
 	}
 }
 
@@ -140,7 +809,7 @@ func RuleQuantity(quantity string) ConfigOption {
 	return func(c *Config) error {
 		qty, err := strconv.Atoi(quantity)
 		if err != nil {
-			return fmt.Errorf("could not convert rule-quantity to integer")
+			return ErrInvalidNumber{Option: "rule-quantity", Value: quantity}
 		}
 		c.RuleQuantity = qty
 		return nil
@@ -151,9 +820,9 @@ func RuleQuantity(quantity string) ConfigOption {
 // exceceds RuleQuantity/RulePeriod. Expects a time.Duration in string format (e.g. 10m, 1h)
 func RulePeriod(period string) ConfigOption {
 	return func(c *Config) error {
-		duration, err := time.ParseDuration(period)
+		duration, err := units.ParseDuration(period)
 		if err != nil {
-			return fmt.Errorf("could not convert rule-period to time")
+			return ErrInvalidDuration{Option: "rule-period", Value: period}
 		}
 		c.RulePeriod = duration
 		return nil
@@ -165,7 +834,7 @@ func StdoutHistory(h string) ConfigOption {
 	return func(c *Config) error {
 		hist, err := strconv.Atoi(h)
 		if err != nil {
-			return err
+			return ErrInvalidNumber{Option: "stdout-history", Value: h}
 		}
 		c.StdoutHistory = hist
 		return nil
@@ -177,7 +846,7 @@ func StderrHistory(h string) ConfigOption {
 	return func(c *Config) error {
 		hist, err := strconv.Atoi(h)
 		if err != nil {
-			return err
+			return ErrInvalidNumber{Option: "stderr-history", Value: h}
 		}
 		c.StderrHistory = hist
 		return nil
@@ -211,113 +880,247 @@ func Daemon() ConfigOption {
 	}
 }
 
-// MemoryWarn sends a report when process memory exceeds this value.  Expects a string with
-// units in K, M, or G.  (Linux only, memory measurements on Darwin or Windows is a no-op)
-func MemoryWarn(mem string) ConfigOption {
+// Heartbeat sends a heartbeat report with the uptime and current memory of a daemon every interval while it runs, so
+// that the server can alert when heartbeats stop (a deadman's switch).  Duration is expressed as a string with unit
+// ns, us, ms, s, m, h.  Heartbeats are only sent in daemon mode.
+func Heartbeat(interval string) ConfigOption {
 	return func(c *Config) error {
-		var err error
-		var warn int
-		switch {
-		case strings.HasSuffix(mem, "K"):
-			warn, err = strconv.Atoi(mem[0 : len(mem)-1])
-		case strings.HasSuffix(mem, "M"):
-			warn, err = strconv.Atoi(mem[0 : len(mem)-1])
-			warn = warn * 1000
-		case strings.HasSuffix(mem, "G"):
-			warn, err = strconv.Atoi(mem[0 : len(mem)-1])
-			warn = warn * 1000000
-		default:
-			warn, err = strconv.Atoi(mem)
-		}
+		duration, err := units.ParseDuration(interval)
 		if err != nil {
-			return fmt.Errorf("could not parse memory warning limit: %s", mem)
+			return ErrInvalidDuration{Option: "heartbeat", Value: interval}
 		}
-		c.MemoryWarn = uint64(warn)
+		if duration <= 0 {
+			return ErrInvalidValue{Option: "heartbeat", Value: interval, Reason: "heartbeat interval must be greater than zero"}
+		}
+		c.HeartbeatInterval = duration
 		return nil
 	}
 }
 
-// MemoryKill kills the process and sends a report when process memory exceeds this value.  Expects a string with
-// units in K, M, or G.  (Linux only, memory measurements on Darwin or Windows is a no-op)
-func MemoryKill(mem string) ConfigOption {
+// Schedule runs the command each time the cron expression matches until monny is stopped, for containers without
+// cron.  Expressions have five fields (minute hour day-of-month month day-of-week), or six with seconds first, and can
+// also be a descriptor such as @hourly or @every 10m.  Each run is monitored separately and sends its own reports.
+// Times are in the local time zone.
+func Schedule(expr string) ConfigOption {
 	return func(c *Config) error {
-		var err error
-		var kill int
-		switch {
-		case strings.HasSuffix(mem, "K"):
-			kill, err = strconv.Atoi(mem[0 : len(mem)-1])
-		case strings.HasSuffix(mem, "M"):
-			kill, err = strconv.Atoi(mem[0 : len(mem)-1])
-			kill = kill * 1000
-		case strings.HasSuffix(mem, "G"):
-			kill, err = strconv.Atoi(mem[0 : len(mem)-1])
-			kill = kill * 1000000
-		default:
-			kill, err = strconv.Atoi(mem)
-		}
+		s, err := parseSchedule(expr)
 		if err != nil {
-			return fmt.Errorf("could not parse memory warning limit: %s", mem)
+			return ErrInvalidValue{Option: "schedule", Value: expr, Reason: err.Error()}
 		}
-		c.MemoryKill = uint64(kill)
+		c.Schedule = expr
+		c.schedule = s
 		return nil
 	}
 }
 
-// KillTimeout kills the process and sends a report when process run time exceeds the duration set.  Duration
-// is expressed as a string with unit ns, us, ms, s, m, h.
-func KillTimeout(timeout string) ConfigOption {
+// MaxConcurrent is the number of scheduled runs of the command that can run at the same time.  When a run is due and
+// this many runs are still running, it is skipped and a report is sent that it was skipped.  (default 1)
+func MaxConcurrent(n string) ConfigOption {
 	return func(c *Config) error {
-		duration, err := time.ParseDuration(timeout)
+		max, err := strconv.Atoi(n)
 		if err != nil {
-			return fmt.Errorf("unrecognized kill timeout duration: %s", timeout)
+			return ErrInvalidNumber{Option: "max-concurrent", Value: n}
 		}
-		c.KillTimeout = duration
+		if max < 1 {
+			return ErrInvalidValue{Option: "max-concurrent", Value: n, Reason: "at least one run must be allowed"}
+		}
+		c.MaxConcurrent = max
 		return nil
 	}
 }
 
-// NotifyTimeout sends a report when process run time exceeds the duration set.  Duration
-// is expressed as a string with unit ns, us, ms, s, m, h.
-func NotifyTimeout(timeout string) ConfigOption {
+// ExpectedEvery sends a late run report when a run starts and the last successful run was longer ago than interval, a
+// deadman's switch for reports that are not sent to a server which can notice the missing runs.  The time of the last
+// successful run is kept for the ID in ~/.monny/state/<id>.json.  Nothing is reported before the first successful run.
+// Duration is expressed as a string with unit ns, us, ms, s, m, h.
+func ExpectedEvery(interval string) ConfigOption {
 	return func(c *Config) error {
-		duration, err := time.ParseDuration(timeout)
+		duration, err := units.ParseDuration(interval)
 		if err != nil {
-			return fmt.Errorf("unrecognized notify timeout duration: %s", timeout)
+			return ErrInvalidDuration{Option: "expected-every", Value: interval}
 		}
-		c.NotifyTimeout = duration
+		if duration <= 0 {
+			return ErrInvalidValue{Option: "expected-every", Value: interval, Reason: "expected interval between runs must be greater than zero"}
+		}
+		c.ExpectedEvery = duration
 		return nil
 	}
 }
 
-// Creates generates a report when an expected file is not created as a result of the process.
-// Expects a filepath that will be checked on process completion.
-func Creates(filepath string) ConfigOption {
+// MemoryWarn sends a report when process memory exceeds this value.  Expects a size such as 100M, 1.5G, or 512MiB, or a
+// number of kilobytes without a unit.  (Linux only, memory measurements on Darwin or Windows is a no-op)
+func MemoryWarn(mem string) ConfigOption {
 	return func(c *Config) error {
-		c.Creates = append(c.Creates, filepath)
+		warn, err := parseMemory("memory-warn", mem)
+		if err != nil {
+			return err
+		}
+		c.MemoryWarn = warn
 		return nil
 	}
 }
 
-// Host sets the url and port when using a private reporting server.  Expects host:port.
-func Host(pathWithPort string) ConfigOption {
+// MemoryKill kills the process and sends a report when process memory exceeds this value.  Expects a size such as 100M,
+// 1.5G, or 512MiB, or a number of kilobytes without a unit.  (Linux only, memory measurements on Darwin or Windows is a no-op)
+func MemoryKill(mem string) ConfigOption {
 	return func(c *Config) error {
-		h := strings.Split(pathWithPort, ":")
-		if len(h) != 2 {
-			return fmt.Errorf("unknown host, use host:port")
+		kill, err := parseMemory("memory-kill", mem)
+		if err != nil {
+			return err
 		}
-		c.host = h[0]
-		c.port = h[1]
+		c.MemoryKill = kill
 		return nil
 	}
 }
 
-// Insecure allows a non-TLS connection to a private reporting server.  This option should only
-// be used when the reporting server and the monitor communicate over a private internal network.
-func Insecure() ConfigOption {
-	return func(c *Config) error {
-		c.useTLS = false
-		return nil
+// parseMemory returns the memory in kilobytes of a size such as 100M or 1.5GiB.  A number without a unit is a number of
+// kilobytes.
+func parseMemory(option string, mem string) (uint64, error) {
+	if kb, err := strconv.ParseUint(mem, 10, 64); err == nil {
+		return kb, nil
+	}
+	b, err := units.ParseBytes(mem)
+	if err != nil {
+		return 0, ErrInvalidMemory{Option: option, Value: mem}
 	}
+	return b / 1000, nil
+}
+
+// KillTimeout kills the process and sends a report when process run time exceeds the duration set.  Duration
+// is expressed as a string with unit ns, us, ms, s, m, h, or as a number of seconds.
+func KillTimeout(timeout string) ConfigOption {
+	return func(c *Config) error {
+		duration, err := units.ParseDuration(timeout)
+		if err != nil {
+			return ErrInvalidDuration{Option: "timeout-kill", Value: timeout}
+		}
+		c.KillTimeout = duration
+		return nil
+	}
+}
+
+// NotifyTimeout sends a report when process run time exceeds the duration set.  Duration
+// is expressed as a string with unit ns, us, ms, s, m, h, or as a number of seconds.
+func NotifyTimeout(timeout string) ConfigOption {
+	return func(c *Config) error {
+		duration, err := units.ParseDuration(timeout)
+		if err != nil {
+			return ErrInvalidDuration{Option: "timeout-warn", Value: timeout}
+		}
+		c.NotifyTimeout = duration
+		return nil
+	}
+}
+
+// Creates generates a report when an expected file is not created as a result of the process.
+// Expects a filepath that will be checked on process completion.
+func Creates(filepath string) ConfigOption {
+	return func(c *Config) error {
+		c.Creates = append(c.Creates, filepath)
+		return nil
+	}
+}
+
+// CreatesWatch generates a report while the process is running when no file matching glob is created or modified
+// within interval, such as hourly partition files written by a long-running job.  The process continues to run and
+// the report is sent once until a matching file appears again.
+func CreatesWatch(glob string, interval time.Duration) ConfigOption {
+	return func(c *Config) error {
+		if _, err := filepath.Match(glob, ""); err != nil || len(glob) == 0 {
+			return ErrInvalidValue{Option: "creates-watch", Value: glob, Reason: fmt.Sprintf("invalid glob for watched files: %s", glob)}
+		}
+		if interval <= 0 {
+			return ErrInvalidValue{Option: "creates-watch", Value: interval.String(), Reason: fmt.Sprintf("interval to watch for %s must be greater than 0", glob)}
+		}
+		c.CreatesWatch = append(c.CreatesWatch, fileWatch{Glob: glob, Interval: interval})
+		return nil
+	}
+}
+
+// Host sets the url and port when using a private reporting server.  Expects host:port.
+func Host(pathWithPort string) ConfigOption {
+	return func(c *Config) error {
+		h := strings.Split(pathWithPort, ":")
+		if len(h) != 2 {
+			return ErrInvalidHost{Value: pathWithPort}
+		}
+		c.host = h[0]
+		c.port = h[1]
+		return nil
+	}
+}
+
+// Insecure allows a non-TLS connection to a private reporting server.  This option should only
+// be used when the reporting server and the monitor communicate over a private internal network.
+func Insecure() ConfigOption {
+	return func(c *Config) error {
+		c.useTLS = false
+		return nil
+	}
+}
+
+// APIToken authenticates reports to the reporting server with a bearer token sent in the authorization
+// header of each call.  The token is never included in the configuration sent with the report.  It can
+// also be set with the MONNY_TOKEN environment variable.
+func APIToken(token string) ConfigOption {
+	return func(c *Config) error {
+		if len(token) == 0 {
+			return ErrInvalidValue{Option: "token", Reason: "api token must not be empty"}
+		}
+		c.token = token
+		return nil
+	}
+}
+
+// CACert trusts the PEM encoded certificate authority at path when verifying the certificate of a private reporting
+// server, in addition to the system certificate pool.
+func CACert(path string) ConfigOption {
+	return func(c *Config) error {
+		pem, err := ioutil.ReadFile(path)
+		if err != nil {
+			return ErrInvalidCertificate{Path: path, Err: err}
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if ok := pool.AppendCertsFromPEM(pem); !ok {
+			return ErrInvalidCertificate{Path: path, Err: fmt.Errorf("no valid PEM certificates found")}
+		}
+		c.tlsConfig().RootCAs = pool
+		return nil
+	}
+}
+
+// ClientCert presents a client certificate to a private reporting server that requires mutual TLS.  Expects the paths
+// to a PEM encoded certificate and private key.
+func ClientCert(certPath string, keyPath string) ConfigOption {
+	return func(c *Config) error {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return ErrInvalidCertificate{Path: certPath, Err: err}
+		}
+		cfg := c.tlsConfig()
+		cfg.Certificates = append(cfg.Certificates, cert)
+		return nil
+	}
+}
+
+// TLSSkipVerify does not verify the certificate of the reporting server.  This option should only be used for testing
+// a private reporting server with a self-signed certificate.
+func TLSSkipVerify() ConfigOption {
+	return func(c *Config) error {
+		c.tlsConfig().InsecureSkipVerify = true
+		return nil
+	}
+}
+
+// tlsConfig returns the TLS configuration for connections to the reporting server, creating it if necessary
+func (c *Config) tlsConfig() *tls.Config {
+	if c.tls == nil {
+		c.tls = &tls.Config{}
+	}
+	return c.tls
 }
 
 // NoErrorReports prevents unhandled errors from being reported to monny.dev to improve the quality
@@ -336,13 +1139,424 @@ func Shell(shell string) ConfigOption {
 	return func(c *Config) error {
 		path, err := exec.LookPath(shell)
 		if err != nil {
-			return err
+			return ErrShellNotFound{Path: shell, Err: err}
 		}
 		c.Shell = path
 		return nil
 	}
 }
 
+// NoShell executes the command directly without a shell, even when its arguments contain shell operators such as &&
+// or |, which are then passed to the command as arguments.  No script is written to the temporary directory.
+func NoShell() ConfigOption {
+	return func(c *Config) error {
+		c.NoShell = true
+		return nil
+	}
+}
+
+// NoStdin disconnects the process from the stdin of monny so that it reads no input, for commands that should not
+// consume input intended for a later command or wait on an interactive terminal.  By default, the process reads from the
+// stdin of monny (e.g., monny -- mysql < dump.sql).
+func NoStdin() ConfigOption {
+	return func(c *Config) error {
+		c.NoStdin = true
+		return nil
+	}
+}
+
+// ShellStrict starts the script that runs commands containing shell operators with set -euo pipefail, so that the
+// command fails at the first failing step, unset variable, or failure in a pipeline.  The shell must support
+// pipefail (e.g., bash, zsh, or ksh).
+func ShellStrict() ConfigOption {
+	return func(c *Config) error {
+		c.ShellStrict = true
+		return nil
+	}
+}
+
+// PTY runs the process attached to a pseudo-terminal for tools that change their output when they do not detect
+// a TTY (e.g., progress bars, colorized output).  Stdout and Stderr are combined by the terminal into a single
+// stream which is treated as Stdout for rule matching and history.  (Linux only)
+func PTY() ConfigOption {
+	return func(c *Config) error {
+		c.PTY = true
+		return nil
+	}
+}
+
+// BatchInterval collects reports generated within the interval and sends them to the reporting server in a
+// single call.  This reduces the number of calls for daemons that generate frequent alerts.  Duration is
+// expressed as a string with unit ns, us, ms, s, m, h. (default 0, reports are sent immediately)
+func BatchInterval(interval string) ConfigOption {
+	return func(c *Config) error {
+		duration, err := units.ParseDuration(interval)
+		if err != nil {
+			return ErrInvalidDuration{Option: "batch-interval", Value: interval}
+		}
+		c.BatchInterval = duration
+		return nil
+	}
+}
+
+// BatchSize holds reports until size reports are waiting and sends them to the reporting server in a single call.
+// A batch that is not full is sent when the BatchWindow or BatchInterval elapses, or when the process exits.  With a
+// BatchInterval, the batch is sent as soon as it is full instead of waiting for the interval to elapse. (default 1,
+// reports are sent immediately)
+func BatchSize(size string) ConfigOption {
+	return func(c *Config) error {
+		n, err := strconv.Atoi(size)
+		if err != nil {
+			return ErrInvalidNumber{Option: "batch-size", Value: size}
+		}
+		if n < 1 {
+			return ErrInvalidValue{Option: "batch-size", Value: size, Reason: "batch size must be at least 1"}
+		}
+		c.BatchSize = n
+		return nil
+	}
+}
+
+// BatchWindow is used with BatchSize to send a batch that is not full when the window has elapsed since the first
+// report in the batch.  Duration is expressed as a string with unit ns, us, ms, s, m, h. (default 0, the batch is
+// held until it is full or the process exits)
+func BatchWindow(window string) ConfigOption {
+	return func(c *Config) error {
+		duration, err := units.ParseDuration(window)
+		if err != nil || duration < 0 {
+			return ErrInvalidDuration{Option: "batch-window", Value: window}
+		}
+		c.BatchWindow = duration
+		return nil
+	}
+}
+
+// BatchReports coalesces the alerts triggered by rule matches within the window into a single report with all of
+// the accumulated matches.  The window starts at the first alert and the report is sent when it elapses, or when the
+// process exits.  Unlike BatchInterval, which sends separate reports in one call, this reduces the number of reports
+// for daemons with frequent rule matches.  Duration is expressed as a string with unit ns, us, ms, s, m, h.
+// (default 0, alerts are sent immediately)
+func BatchReports(window string) ConfigOption {
+	return func(c *Config) error {
+		duration, err := units.ParseDuration(window)
+		if err != nil || duration < 0 {
+			return ErrInvalidDuration{Option: "batch-reports", Value: window}
+		}
+		c.BatchReports = duration
+		return nil
+	}
+}
+
+// MultiLineJSON parses JSON log entries that are pretty-printed across multiple lines.  Lines are accumulated
+// until the root JSON object is closed and the full object is processed as a single log entry for rule matching
+// and history.  Lines that do not start a JSON object are processed normally.
+func MultiLineJSON() ConfigOption {
+	return func(c *Config) error {
+		c.MultiLineJSON = true
+		return nil
+	}
+}
+
+// CommandTemplate renders the user command from a text/template using vars before execution (e.g., backup --date {{.date}}).
+// Additional variables can be added with TemplateVar.  When set, the command should not also be passed as arguments.
+// The template is split into words with the quoting rules of the shell before each word is rendered, so a variable
+// containing spaces is passed as a single argument.
+func CommandTemplate(tmpl string, vars map[string]string) ConfigOption {
+	return func(c *Config) error {
+		c.CommandTemplate = tmpl
+		for k, v := range vars {
+			if err := TemplateVar(k, v)(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// PreRun runs a command before the monitored command, such as to acquire a lock or prime a cache.  Its output is not
+// checked for rules.  When it fails, the monitored command is not run and a failure report is sent.
+func PreRun(command string) ConfigOption {
+	return func(c *Config) error {
+		if len(strings.TrimSpace(command)) == 0 {
+			return ErrInvalidValue{Option: "pre-run", Reason: "pre-run command must not be empty"}
+		}
+		c.PreRun = command
+		return nil
+	}
+}
+
+// WorkingDir runs the process and the pre-run command in the directory at path instead of the working directory of
+// monny, such as when monny is started from / by cron.  The directory must exist.
+func WorkingDir(path string) ConfigOption {
+	return func(c *Config) error {
+		info, err := os.Stat(path)
+		switch {
+		case err != nil:
+			return ErrInvalidValue{Option: "working-dir", Value: path, Reason: fmt.Sprintf("working directory %s does not exist", path)}
+		case !info.IsDir():
+			return ErrInvalidValue{Option: "working-dir", Value: path, Reason: fmt.Sprintf("working directory %s is not a directory", path)}
+		}
+		c.WorkingDir = path
+		return nil
+	}
+}
+
+// Step adds a command to run as a step of a pipeline.  Steps run in order in the shell, or split on spaces when there
+// is no shell, and one report covers the whole pipeline.  Steps can not be combined with a command.
+func Step(command string) ConfigOption {
+	return func(c *Config) error {
+		if len(strings.TrimSpace(command)) == 0 {
+			return ErrInvalidValue{Option: "step", Reason: "step command must not be empty"}
+		}
+		c.Steps = append(c.Steps, command)
+		return nil
+	}
+}
+
+// Pipeline runs the command as a pipeline where the commands separated by -- are steps that run in order, such as
+// monny --pipeline -- extract.sh -- transform.sh -- load.sh
+func Pipeline() ConfigOption {
+	return func(c *Config) error {
+		c.Pipeline = true
+		return nil
+	}
+}
+
+// ContinueOnError runs the remaining steps of a pipeline after a step fails.  The pipeline still fails.  By default
+// the pipeline stops at the first failing step.
+func ContinueOnError() ConfigOption {
+	return func(c *Config) error {
+		c.ContinueOnError = true
+		return nil
+	}
+}
+
+// Parallel runs the steps at the same time instead of in order, either the configured steps or the commands separated
+// by --, such as monny parallel -- cmd1 -- cmd2.  Each line of output is tagged with the number of its step and rules
+// apply to the output of every step.  One report covers all steps and is successful only if every step succeeds.
+func Parallel() ConfigOption {
+	return func(c *Config) error {
+		c.Parallel = true
+		return nil
+	}
+}
+
+// FailFast kills the other parallel steps when one fails.  By default, every step runs to completion.
+func FailFast() ConfigOption {
+	return func(c *Config) error {
+		c.FailFast = true
+		return nil
+	}
+}
+
+// KillOnBrokenPipe stops the process with SIGPIPE when the reader of the output of monny closes it, such as head in
+// monny -- producer | head, and the process is reported as successful.  By default, monny stops echoing the output
+// and the process runs to completion.
+func KillOnBrokenPipe() ConfigOption {
+	return func(c *Config) error {
+		c.KillOnBrokenPipe = true
+		return nil
+	}
+}
+
+// TemplateVar sets a variable used to render the CommandTemplate
+func TemplateVar(key string, value string) ConfigOption {
+	return func(c *Config) error {
+		if len(key) == 0 {
+			return ErrInvalidValue{Option: "var", Value: value, Reason: "template variable name is required"}
+		}
+		if c.vars == nil {
+			c.vars = make(map[string]string)
+		}
+		c.vars[key] = value
+		return nil
+	}
+}
+
+// MaxReportBytes limits the size of a report.  When a report exceeds the limit, the oldest lines of stdout, stderr, and rule
+// matches are dropped until it fits and a message is added to the report with the amount dropped.  Expects a size in bytes
+// with optional decimal (K, M) or binary (KiB, MiB) units.  (default 3MiB)
+func MaxReportBytes(size string) ConfigOption {
+	return func(c *Config) error {
+		max, err := units.ParseBytes(size)
+		if err != nil || max == 0 || max > uint64(^uint(0)>>1) {
+			return ErrInvalidSize{Option: "max-report-size", Value: size}
+		}
+		c.MaxReportBytes = int(max)
+		return nil
+	}
+}
+
+// MaxLineSize sets the longest line of output, or multi-line JSON object, that is processed.  Expects a size in bytes
+// with optional decimal (K, M) or binary (KiB, MiB) units.  When a longer line is written, monny stops reading the
+// output and reports an error.  (default 1MiB)
+func MaxLineSize(size string) ConfigOption {
+	return func(c *Config) error {
+		max, err := units.ParseBytes(size)
+		if err != nil || max == 0 || max > uint64(^uint(0)>>1) {
+			return ErrInvalidSize{Option: "max-line-size", Value: size}
+		}
+		c.MaxLineSize = int(max)
+		return nil
+	}
+}
+
+// NoCompression sends reports without gzip compression.  This may be necessary for private reporting servers that do not
+// support compressed messages.
+func NoCompression() ConfigOption {
+	return func(c *Config) error {
+		c.Compress = false
+		return nil
+	}
+}
+
+// ReportFile appends each report to the file at path as a single line of JSON instead of sending it to the reporting
+// server.  This is useful in air-gapped environments.  The file is created if it does not exist and can be shared by
+// multiple monitors.
+func ReportFile(path string) ConfigOption {
+	return func(c *Config) error {
+		if len(path) == 0 {
+			return ErrInvalidValue{Option: "report-file", Reason: "report file path must not be empty"}
+		}
+		c.ReportFile = path
+		return nil
+	}
+}
+
+// MetricsSnapshotFile writes the final metrics of the estimators for each metric rule to the file at path when the
+// process finishes.  Files ending in .csv are written as metric,value rows.  Otherwise, the metrics and control chart
+// data are written as JSON.
+func MetricsSnapshotFile(path string) ConfigOption {
+	return func(c *Config) error {
+		if len(path) == 0 {
+			return ErrInvalidValue{Option: "metrics-snapshot", Reason: "metrics snapshot file path must not be empty"}
+		}
+		c.MetricsSnapshot = path
+		return nil
+	}
+}
+
+// WithPrometheusExport serves the current values and control limits of the estimators for each metric rule at
+// http://addr/metrics in the Prometheus text format while the process runs, such as WithPrometheusExport(":9464").
+func WithPrometheusExport(addr string) ConfigOption {
+	return func(c *Config) error {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return ErrInvalidValue{Option: "prometheus-export", Value: addr, Reason: "address must be host:port or :port"}
+		}
+		c.PrometheusAddr = addr
+		return nil
+	}
+}
+
+// OTelTracing starts a trace span covering the execution of the command, as with WithTracer, and exports it to an
+// OpenTelemetry collector using OTLP over HTTP when the command finishes.  The endpoint is a host:port, such as
+// localhost:4318, or the URL of the collector.  Rule matches and metric alarms are recorded as events on the span.
+func OTelTracing(endpoint string) ConfigOption {
+	return func(c *Config) error {
+		exporter, err := trace.NewOTLPExporter(endpoint, "monny")
+		if err != nil {
+			return ErrInvalidValue{Option: "otel-endpoint", Value: endpoint, Reason: "endpoint must be host:port or an http or https URL"}
+		}
+		c.OTelEndpoint = exporter.URL()
+		c.tracer = trace.NewProvider(exporter)
+		return nil
+	}
+}
+
+// DumpEnvOnFailure writes the environment of the process to the file at path when it fails, so that the failure can
+// be reproduced locally.  The environment is not sent in the report.  Values of variables with names that commonly
+// hold secrets, such as those containing TOKEN, SECRET, PASSWORD, or KEY, are redacted, as are variables matching
+// any pattern added with RedactEnv.
+func DumpEnvOnFailure(path string) ConfigOption {
+	return func(c *Config) error {
+		if len(path) == 0 {
+			return ErrInvalidValue{Option: "dump-env-on-failure", Reason: "environment dump file path must not be empty"}
+		}
+		c.EnvDumpFile = path
+		return nil
+	}
+}
+
+// RedactEnv redacts the values of environment variables with names matching the regex in the environment dump
+// written by DumpEnvOnFailure.  Use multiple times to add more patterns.
+func RedactEnv(regex string) ConfigOption {
+	return func(c *Config) error {
+		reg, err := regexp.Compile(regex)
+		if err != nil {
+			return ErrInvalidRegex{Pattern: regex, Err: err}
+		}
+		c.redactEnv = append(c.redactEnv, reg)
+		return nil
+	}
+}
+
+// IncludeEnv includes the names and values of the environment variables in the comma-separated allowlist in each
+// report, as they were when the process was started.  No environment variables are included by default.  Variables
+// with names that commonly hold secrets, such as those containing TOKEN, SECRET, PASSWORD, or KEY, or matching a
+// pattern added with RedactEnv are never included, even when allowlisted.  A warning is logged instead.
+func IncludeEnv(allowlist string) ConfigOption {
+	return func(c *Config) error {
+		var names []string
+		for _, name := range strings.Split(allowlist, ",") {
+			if name = strings.TrimSpace(name); len(name) > 0 {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			return ErrInvalidValue{Option: "include-env", Reason: "environment variable allowlist must not be empty"}
+		}
+		c.IncludeEnv = append(c.IncludeEnv, names...)
+		return nil
+	}
+}
+
+// UseJSONLMatches encodes rule matches in the report as JSON Lines, one match per line, instead of a single JSON
+// array.  This allows the reporting server to process large numbers of matches without decoding them all at once.
+func UseJSONLMatches() ConfigOption {
+	return func(c *Config) error {
+		c.JSONLMatches = true
+		return nil
+	}
+}
+
+// MergeMatches records a single match for a line that matches several rules, listing the names of all matched rules,
+// instead of one match for each rule.  The merged match takes the rule and severity of the first matched rule in
+// configuration order and counts once towards the rule quantity.
+func MergeMatches() ConfigOption {
+	return func(c *Config) error {
+		c.MergeMatches = true
+		return nil
+	}
+}
+
+// CoerceJSONNumbers matches JSON rules against string fields that contain a number, such as "latency": "123.4", in
+// the same format as JSON numbers.  Use this when a process logs numbers as strings so that the same rule matches
+// both encodings.
+func CoerceJSONNumbers() ConfigOption {
+	return func(c *Config) error {
+		c.CoerceJSONNumbers = true
+		return nil
+	}
+}
+
+// NoConfigInReport omits the configuration from reports.  An empty JSON object is sent instead.
+func NoConfigInReport() ConfigOption {
+	return func(c *Config) error {
+		c.OmitConfig = true
+		return nil
+	}
+}
+
+// WithTracer starts a trace span covering the execution of the command using tp.  Reports are recorded as events on
+// the span and the span status is set from the exit code.  A trace context passed to monny in the TRACEPARENT environment
+// variable is used as the parent span and the command span is passed to the process in TRACEPARENT.
+func WithTracer(tp trace.TracerProvider) ConfigOption {
+	return func(c *Config) error {
+		c.tracer = tp
+		return nil
+	}
+}
+
 // LogFile sends Stdout and Stderr to log rotated files in the given directory.  It will create the
 // directory if it does not exist.  An error will be returned if the user does not have write permission
 // to create (if the directory does not already exist) or write to the directory.
@@ -353,6 +1567,126 @@ func LogFile(dir string) ConfigOption {
 	}
 }
 
+// DryRun validates the configuration and prints the resolved configuration and what would trigger reports to Stderr.
+// The command is not run and no reports are sent.  Useful to test the precedence of flags and YAML configuration in CI
+// before deploying.  To run the command and print its reports, use PrintReports.
+func DryRun() ConfigOption {
+	return func(c *Config) error {
+		c.DryRun = true
+		return nil
+	}
+}
+
+// PrintReports prints the resolved configuration to Stderr, then runs the command and prints each report that would
+// be sent instead of sending it.  Useful to test rules against the output of the command before deploying.  A DryRun
+// takes precedence, so the command is never run when both are set.
+func PrintReports() ConfigOption {
+	return func(c *Config) error {
+		c.PrintReports = true
+		return nil
+	}
+}
+
+// DryRunFile prints the output of a dry run, or the reports printed with PrintReports, to the file at path instead of
+// Stderr.  The output is appended to an existing file.  When PrintReports is not set, it enables a dry run.
+func DryRunFile(path string) ConfigOption {
+	return func(c *Config) error {
+		if info, err := os.Stat(filepath.Dir(path)); err != nil || !info.IsDir() {
+			return ErrInvalidValue{Option: "dry-run-file", Value: path, Reason: "the directory of the dry run file does not exist"}
+		}
+		c.dryRunFile = path
+		return nil
+	}
+}
+
+// Verbose prints unexpected errors in the client to Stderr when Wait returns.  The errors are also sent in the
+// messages of the next report.  Report sends and kill decisions are logged to Stderr, or to the writer set with
+// Logger.
+func Verbose() ConfigOption {
+	return func(c *Config) error {
+		c.Verbose = true
+		return nil
+	}
+}
+
+// Debug logs each decision made while monitoring the process, including rule matches, reports that are not sent
+// because of the configuration, and calls to process handlers.  Messages are written to Stderr, or to the writer
+// set with Logger.
+func Debug() ConfigOption {
+	return func(c *Config) error {
+		c.Debug = true
+		return nil
+	}
+}
+
+// Logger writes log messages about monny itself to w.  By default, only warnings are logged, such as failed
+// report sends.  Use Verbose or Debug to log more detail.  Without a logger, monny is silent unless verbose or
+// debug logging is enabled.
+func Logger(w io.Writer) ConfigOption {
+	return func(c *Config) error {
+		c.log = w
+		return nil
+	}
+}
+
+// StructuredLogging writes log messages about monny itself, such as rule matches, report sends, and errors, to out in
+// format, which is text (the default), json, or logfmt.  The json and logfmt formats write one object per line with
+// the time, level, logger, and msg so that the messages can be parsed when monny runs inside a log pipeline.  When out
+// is nil, messages are written to the writer set with Logger, or to Stderr when verbose or debug logging is enabled.
+func StructuredLogging(out io.Writer, format string) ConfigOption {
+	return func(c *Config) error {
+		switch format {
+		case logFormatText, logFormatJSON, logFormatLogfmt:
+		default:
+			return ErrInvalidValue{Option: "log-format", Value: format, Reason: "log format must be text, json, or logfmt"}
+		}
+		c.LogFormat = format
+		if out != nil {
+			c.log = out
+		}
+		return nil
+	}
+}
+
+// Quiet stops echoing the output of the process to Stdout and Stderr.  Output is still processed for rule matches
+// and history.
+func Quiet() ConfigOption {
+	return func(c *Config) error {
+		c.out = discard{}
+		c.err = discard{}
+		return nil
+	}
+}
+
+// standardStream wraps Stdout or Stderr so that closing the output of the process does not close the standard
+// streams of monny, which are still used to print errors and dry run reports
+type standardStream struct {
+	*os.File
+}
+
+func (standardStream) Close() error {
+	return nil
+}
+
+// discard is a WriteCloser that discards all writes
+type discard struct{}
+
+func (discard) Write(p []byte) (int, error) {
+	return ioutil.Discard.Write(p)
+}
+
+func (discard) Close() error {
+	return nil
+}
+
+// logIn reads log lines from in instead of Stdin when no command is given
+func logIn(in io.Reader) ConfigOption {
+	return func(c *Config) error {
+		c.in = in
+		return nil
+	}
+}
+
 // logOut redirects Stdout to out
 func logOut(out io.WriteCloser) ConfigOption {
 	return func(c *Config) error {
@@ -361,6 +1695,14 @@ func logOut(out io.WriteCloser) ConfigOption {
 	}
 }
 
+// stateDirectory keeps the state of runs in dir instead of ~/.monny/state
+func stateDirectory(dir string) ConfigOption {
+	return func(c *Config) error {
+		c.stateDir = dir
+		return nil
+	}
+}
+
 // logErr redirects Stderr to err
 func logErr(err io.WriteCloser) ConfigOption {
 	return func(c *Config) error {
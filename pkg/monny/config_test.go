@@ -1,11 +1,14 @@
 package monny
 
 import (
+	"errors"
+	"math"
 	"os"
 	"regexp"
 	"testing"
 	"time"
 
+	"github.com/BTBurke/monny/pkg/proto"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,39 +20,159 @@ func TestConfigOptions(t *testing.T) {
 		Option ConfigOption
 		Expect Config
 		Error  bool
+		As     interface{}
 	}{
 		{Name: "ID", Option: ID("test"), Expect: Config{ID: "test"}},
 		{Name: "rule valid regex", Option: Rule(".*"), Expect: Config{Rules: []rule{rule{Regex: regexp.MustCompile(".*")}}}},
-		{Name: "rule invalid regex", Option: Rule("("), Error: true},
+		{Name: "rule invalid regex", Option: Rule("("), Error: true, As: &ErrInvalidRegex{}},
 		{Name: "JSON rule valid regex", Option: JSONRule("test", ".*"), Expect: Config{Rules: []rule{rule{Field: "test", Regex: regexp.MustCompile(".*")}}}},
-		{Name: "JSON rule invalid regex", Option: JSONRule("test", "("), Error: true},
+		{Name: "JSON rule invalid regex", Option: JSONRule("test", "("), Error: true, As: &ErrInvalidRegex{}},
+		{Name: "rule window", Option: RuleWindow("ERROR", time.Minute, 0), Expect: Config{Rules: []rule{rule{Regex: regexp.MustCompile("ERROR"), After: time.Minute}}}},
+		{Name: "rule window negative", Option: RuleWindow("ERROR", -time.Minute, 0), Error: true, As: &ErrInvalidValue{}},
+		{Name: "rule window ends before start", Option: RuleWindow("ERROR", time.Minute, time.Second), Error: true, As: &ErrInvalidValue{}},
 		{Name: "rule quantity", Option: RuleQuantity("5"), Expect: Config{RuleQuantity: 5}},
-		{Name: "rule quantity non-numeric", Option: RuleQuantity("A"), Error: true},
+		{Name: "rule quantity non-numeric", Option: RuleQuantity("A"), Error: true, As: &ErrInvalidNumber{}},
 		{Name: "rule period", Option: RulePeriod("2h"), Expect: Config{RulePeriod: time.Duration(2 * time.Hour)}},
-		{Name: "rule period non-duration", Option: RulePeriod("2a"), Error: true},
+		{Name: "rule period non-duration", Option: RulePeriod("2a"), Error: true, As: &ErrInvalidDuration{}},
+		{Name: "metric rule", Option: MetricRule("errors", "stderr", "ERROR"), Expect: Config{MetricRules: []metricRule{{Name: "errors", Stream: streamStderr, Regex: regexp.MustCompile("ERROR")}}}},
+		{Name: "metric rule default stream", Option: MetricRule("errors", "", "ERROR"), Expect: Config{MetricRules: []metricRule{{Name: "errors", Stream: streamBoth, Regex: regexp.MustCompile("ERROR")}}}},
+		{Name: "metric rule invalid stream", Option: MetricRule("errors", "stdin", "ERROR"), Error: true, As: &ErrInvalidValue{}},
+		{Name: "metric rule invalid regex", Option: MetricRule("errors", "stderr", "("), Error: true, As: &ErrInvalidRegex{}},
+		{Name: "metric rule no name", Option: MetricRule("", "stderr", "ERROR"), Error: true, As: &ErrInvalidValue{}},
+		{Name: "metric rule sampling", Option: MetricRuleSampling("errors", 10), Expect: Config{metricSampling: map[string]int{"errors": 10}}},
+		{Name: "metric rule sampling below 1", Option: MetricRuleSampling("errors", 0), Error: true, As: &ErrInvalidValue{}},
+		{Name: "metric rule rate limit", Option: MetricRuleRateLimit("errors", 100, time.Hour), Expect: Config{metricRateLimit: map[string]metricRateLimit{"errors": {n: 100, period: time.Hour}}}},
+		{Name: "metric rule rate limit no period", Option: MetricRuleRateLimit("errors", 100, 0), Error: true, As: &ErrInvalidValue{}},
+		{Name: "metric window", Option: MetricWindow("30s"), Expect: Config{MetricWindow: time.Duration(30 * time.Second)}},
+		{Name: "metric window invalid", Option: MetricWindow("30T"), Error: true, As: &ErrInvalidDuration{}},
+		{Name: "metrics snapshot", Option: MetricsSnapshotFile("metrics.json"), Expect: Config{MetricsSnapshot: "metrics.json"}},
+		{Name: "metrics snapshot empty", Option: MetricsSnapshotFile(""), Error: true, As: &ErrInvalidValue{}},
+		{Name: "prometheus export", Option: WithPrometheusExport(":9464"), Expect: Config{PrometheusAddr: ":9464"}},
+		{Name: "otel endpoint invalid", Option: OTelTracing("grpc://localhost:4317"), Error: true, As: &ErrInvalidValue{}},
+		{Name: "stat lambda", Option: StatLambda(0.1), Expect: Config{StatLambda: 0.1}},
+		{Name: "stat lambda one", Option: StatLambda(1.0), Expect: Config{StatLambda: 1.0}},
+		{Name: "stat lambda zero", Option: StatLambda(0), Error: true, As: &ErrInvalidValue{}},
+		{Name: "stat lambda too large", Option: StatLambda(1.5), Error: true, As: &ErrInvalidValue{}},
+		{Name: "stat lambda NaN", Option: StatLambda(math.NaN()), Error: true, As: &ErrInvalidValue{}},
+		{Name: "sample strategy", Option: SampleStrategy("max"), Expect: Config{SampleStrategy: "max"}},
+		{Name: "unknown sample strategy", Option: SampleStrategy("median"), Error: true, As: &ErrInvalidValue{}},
+		{Name: "prometheus export no port", Option: WithPrometheusExport("localhost"), Error: true, As: &ErrInvalidValue{}},
 		{Name: "stdout history", Option: StdoutHistory("50"), Expect: Config{StdoutHistory: 50}},
-		{Name: "stdout history non-numeric", Option: StdoutHistory("2a"), Error: true},
+		{Name: "stdout history non-numeric", Option: StdoutHistory("2a"), Error: true, As: &ErrInvalidNumber{}},
 		{Name: "stderr history", Option: StderrHistory("50"), Expect: Config{StderrHistory: 50}},
-		{Name: "stderr history non-numeric", Option: StderrHistory("2a"), Error: true},
+		{Name: "stderr history non-numeric", Option: StderrHistory("2a"), Error: true, As: &ErrInvalidNumber{}},
 		{Name: "no notify on success", Option: NoNotifyOnSuccess(), Expect: Config{NotifyOnSuccess: false}},
 		{Name: "no notify on failure", Option: NoNotifyOnFailure(), Expect: Config{NotifyOnFailure: false}},
 		{Name: "daemon", Option: Daemon(), Expect: Config{Daemon: true}},
+		{Name: "heartbeat", Option: Heartbeat("5m"), Expect: Config{HeartbeatInterval: 5 * time.Minute}},
+		{Name: "heartbeat invalid", Option: Heartbeat("5x"), Error: true, As: &ErrInvalidDuration{}},
+		{Name: "heartbeat zero", Option: Heartbeat("0"), Error: true, As: &ErrInvalidValue{}},
+		{Name: "schedule", Option: Schedule("@every 5m"), Expect: Config{Schedule: "@every 5m", schedule: &schedule{every: 5 * time.Minute}}},
+		{Name: "schedule invalid", Option: Schedule("* * *"), Error: true, As: &ErrInvalidValue{}},
+		{Name: "max concurrent", Option: MaxConcurrent("2"), Expect: Config{MaxConcurrent: 2}},
+		{Name: "max concurrent non-numeric", Option: MaxConcurrent("two"), Error: true, As: &ErrInvalidNumber{}},
+		{Name: "max concurrent zero", Option: MaxConcurrent("0"), Error: true, As: &ErrInvalidValue{}},
+		{Name: "error budget", Option: ErrorBudget(99.9, 720*time.Hour), Expect: Config{ErrorBudgetSLO: 99.9, ErrorBudgetWindow: 720 * time.Hour}},
+		{Name: "error budget slo too high", Option: ErrorBudget(100, time.Hour), Error: true, As: &ErrInvalidValue{}},
+		{Name: "error budget zero window", Option: ErrorBudget(99, 0), Error: true, As: &ErrInvalidValue{}},
+		{Name: "error budget error rule", Option: ErrorBudgetError(" 5\\d\\d "), Expect: Config{budgetError: regexp.MustCompile(" 5\\d\\d ")}},
+		{Name: "error budget error rule invalid", Option: ErrorBudgetError("("), Error: true, As: &ErrInvalidRegex{}},
+		{Name: "error budget total rule", Option: ErrorBudgetTotal("^GET"), Expect: Config{budgetTotal: regexp.MustCompile("^GET")}},
+		{Name: "error budget total rule invalid", Option: ErrorBudgetTotal("("), Error: true, As: &ErrInvalidRegex{}},
+		{Name: "error budget min requests", Option: ErrorBudgetMinRequests("100"), Expect: Config{budgetMinRequests: 100}},
+		{Name: "error budget min requests zero", Option: ErrorBudgetMinRequests("0"), Error: true, As: &ErrInvalidValue{}},
+		{Name: "error budget min requests invalid", Option: ErrorBudgetMinRequests("many"), Error: true, As: &ErrInvalidNumber{}},
+		{Name: "expected every", Option: ExpectedEvery("24h"), Expect: Config{ExpectedEvery: 24 * time.Hour}},
+		{Name: "expected every invalid", Option: ExpectedEvery("1d"), Error: true, As: &ErrInvalidDuration{}},
+		{Name: "expected every zero", Option: ExpectedEvery("0s"), Error: true, As: &ErrInvalidValue{}},
 		{Name: "memory warn GB", Option: MemoryWarn("2G"), Expect: Config{MemoryWarn: 2000000}},
 		{Name: "memory warn MB", Option: MemoryWarn("2M"), Expect: Config{MemoryWarn: 2000}},
 		{Name: "memory warn KB", Option: MemoryWarn("2K"), Expect: Config{MemoryWarn: 2}},
-		{Name: "memory warn invalid", Option: MemoryWarn("2T"), Error: true},
+		{Name: "memory warn fractional lower case", Option: MemoryWarn("1.5g"), Expect: Config{MemoryWarn: 1500000}},
+		{Name: "memory warn MiB", Option: MemoryWarn("100MiB"), Expect: Config{MemoryWarn: 104857}},
+		{Name: "memory warn kilobytes without unit", Option: MemoryWarn("2048"), Expect: Config{MemoryWarn: 2048}},
+		{Name: "memory warn invalid", Option: MemoryWarn("2X"), Error: true, As: &ErrInvalidMemory{}},
 		{Name: "memory kill GB", Option: MemoryKill("2G"), Expect: Config{MemoryKill: 2000000}},
 		{Name: "memory kill MB", Option: MemoryKill("2M"), Expect: Config{MemoryKill: 2000}},
 		{Name: "memory kill KB", Option: MemoryKill("2K"), Expect: Config{MemoryKill: 2}},
-		{Name: "memory kill invalid", Option: MemoryKill("2T"), Error: true},
+		{Name: "memory kill GiB", Option: MemoryKill("1GiB"), Expect: Config{MemoryKill: 1073741}},
+		{Name: "memory kill invalid", Option: MemoryKill("2X"), Error: true, As: &ErrInvalidMemory{}},
 		{Name: "timeout kill", Option: KillTimeout("2h"), Expect: Config{KillTimeout: time.Duration(2 * time.Hour)}},
-		{Name: "timeout kill invalid", Option: KillTimeout("2T"), Error: true},
+		{Name: "timeout kill seconds", Option: KillTimeout("90"), Expect: Config{KillTimeout: 90 * time.Second}},
+		{Name: "timeout kill invalid", Option: KillTimeout("2T"), Error: true, As: &ErrInvalidDuration{}},
 		{Name: "timeout warn", Option: NotifyTimeout("2h"), Expect: Config{NotifyTimeout: time.Duration(2 * time.Hour)}},
-		{Name: "timeout warrn invalid", Option: NotifyTimeout("2T"), Error: true},
+		{Name: "timeout warn seconds", Option: NotifyTimeout("30"), Expect: Config{NotifyTimeout: 30 * time.Second}},
+		{Name: "timeout warrn invalid", Option: NotifyTimeout("2T"), Error: true, As: &ErrInvalidDuration{}},
 		{Name: "creates", Option: Creates("/path/to/something"), Expect: Config{Creates: []string{"/path/to/something"}}},
 		{Name: "host", Option: Host("test.com:443"), Expect: Config{host: "test.com", port: "443"}},
-		{Name: "host invalid", Option: Host("test.com"), Error: true},
+		{Name: "host invalid", Option: Host("test.com"), Error: true, As: &ErrInvalidHost{}},
 		{Name: "insecure", Option: Insecure(), Expect: Config{useTLS: false}},
+		{Name: "api token", Option: APIToken("abc123"), Expect: Config{token: "abc123"}},
+		{Name: "api token empty", Option: APIToken(""), Error: true, As: &ErrInvalidValue{}},
+		{Name: "dry run", Option: DryRun(), Expect: Config{DryRun: true}},
+		{Name: "print reports", Option: PrintReports(), Expect: Config{PrintReports: true}},
+		{Name: "dry run file invalid", Option: DryRunFile("/does/not/exist/dryrun.txt"), Error: true, As: &ErrInvalidValue{}},
+		{Name: "verbose", Option: Verbose(), Expect: Config{Verbose: true}},
+		{Name: "debug", Option: Debug(), Expect: Config{Debug: true}},
+		{Name: "quiet", Option: Quiet(), Expect: Config{out: discard{}, err: discard{}}},
+		{Name: "shell not found", Option: Shell("/does/not/exist/sh"), Error: true, As: &ErrShellNotFound{}},
+		{Name: "pty", Option: PTY(), Expect: Config{PTY: true}},
+		{Name: "no shell", Option: NoShell(), Expect: Config{NoShell: true}},
+		{Name: "no stdin", Option: NoStdin(), Expect: Config{NoStdin: true}},
+		{Name: "log format json", Option: StructuredLogging(nil, "json"), Expect: Config{LogFormat: "json"}},
+		{Name: "log format logfmt", Option: StructuredLogging(nil, "logfmt"), Expect: Config{LogFormat: "logfmt"}},
+		{Name: "log format unknown", Option: StructuredLogging(nil, "xml"), Error: true, As: &ErrInvalidValue{}},
+		{Name: "shell strict", Option: ShellStrict(), Expect: Config{ShellStrict: true}},
+		{Name: "multiline json", Option: MultiLineJSON(), Expect: Config{MultiLineJSON: true}},
+		{Name: "command template", Option: CommandTemplate("echo {{.x}}", map[string]string{"x": "1"}), Expect: Config{CommandTemplate: "echo {{.x}}", vars: map[string]string{"x": "1"}}},
+		{Name: "template var", Option: TemplateVar("x", "1"), Expect: Config{vars: map[string]string{"x": "1"}}},
+		{Name: "template var no name", Option: TemplateVar("", "1"), Error: true, As: &ErrInvalidValue{}},
+		{Name: "max report bytes", Option: MaxReportBytes("1000"), Expect: Config{MaxReportBytes: 1000}},
+		{Name: "max report bytes KB", Option: MaxReportBytes("512K"), Expect: Config{MaxReportBytes: 512 * 1000}},
+		{Name: "max report bytes MB", Option: MaxReportBytes("2M"), Expect: Config{MaxReportBytes: 2 * 1000 * 1000}},
+		{Name: "max report bytes KiB", Option: MaxReportBytes("512KiB"), Expect: Config{MaxReportBytes: 512 * 1024}},
+		{Name: "max report bytes MiB", Option: MaxReportBytes("2mib"), Expect: Config{MaxReportBytes: 2 * 1024 * 1024}},
+		{Name: "max report bytes invalid", Option: MaxReportBytes("2X"), Error: true, As: &ErrInvalidSize{}},
+		{Name: "max report bytes zero", Option: MaxReportBytes("0"), Error: true, As: &ErrInvalidSize{}},
+		{Name: "max line size", Option: MaxLineSize("4MiB"), Expect: Config{MaxLineSize: 4 * 1024 * 1024}},
+		{Name: "max line size invalid", Option: MaxLineSize("4X"), Error: true, As: &ErrInvalidSize{}},
+		{Name: "report file", Option: ReportFile("/var/log/monny.jsonl"), Expect: Config{ReportFile: "/var/log/monny.jsonl"}},
+		{Name: "report file empty", Option: ReportFile(""), Error: true, As: &ErrInvalidValue{}},
+		{Name: "jsonl matches", Option: UseJSONLMatches(), Expect: Config{JSONLMatches: true}},
+		{Name: "merge matches", Option: MergeMatches(), Expect: Config{MergeMatches: true}},
+		{Name: "pre-run", Option: PreRun("flock /tmp/job.lock true"), Expect: Config{PreRun: "flock /tmp/job.lock true"}},
+		{Name: "pre-run empty", Option: PreRun(" "), Error: true, As: &ErrInvalidValue{}},
+		{Name: "working dir", Option: WorkingDir("."), Expect: Config{WorkingDir: "."}},
+		{Name: "working dir missing", Option: WorkingDir("does-not-exist"), Error: true, As: &ErrInvalidValue{}},
+		{Name: "working dir file", Option: WorkingDir("config.go"), Error: true, As: &ErrInvalidValue{}},
+		{Name: "step", Option: Step("extract.sh"), Expect: Config{Steps: []string{"extract.sh"}}},
+		{Name: "empty step", Option: Step(" "), Error: true, As: &ErrInvalidValue{}},
+		{Name: "pipeline", Option: Pipeline(), Expect: Config{Pipeline: true}},
+		{Name: "continue on error", Option: ContinueOnError(), Expect: Config{ContinueOnError: true}},
+		{Name: "parallel", Option: Parallel(), Expect: Config{Parallel: true}},
+		{Name: "fail fast", Option: FailFast(), Expect: Config{FailFast: true}},
+		{Name: "kill on broken pipe", Option: KillOnBrokenPipe(), Expect: Config{KillOnBrokenPipe: true}},
+		{Name: "creates watch", Option: CreatesWatch("data/part-*.csv", time.Hour), Expect: Config{CreatesWatch: []fileWatch{{Glob: "data/part-*.csv", Interval: time.Hour}}}},
+		{Name: "creates watch invalid glob", Option: CreatesWatch("data/[part", time.Hour), Error: true, As: &ErrInvalidValue{}},
+		{Name: "creates watch invalid interval", Option: CreatesWatch("data/part-*.csv", 0), Error: true, As: &ErrInvalidValue{}},
+		{Name: "dump env on failure", Option: DumpEnvOnFailure("env.txt"), Expect: Config{EnvDumpFile: "env.txt"}},
+		{Name: "dump env on failure empty", Option: DumpEnvOnFailure(""), Error: true, As: &ErrInvalidValue{}},
+		{Name: "include env", Option: IncludeEnv("GIT_SHA, DEPLOY_ENV"), Expect: Config{IncludeEnv: []string{"GIT_SHA", "DEPLOY_ENV"}}},
+		{Name: "include env empty", Option: IncludeEnv(" , "), Error: true, As: &ErrInvalidValue{}},
+		{Name: "redact env", Option: RedactEnv("^DB_"), Expect: Config{redactEnv: []*regexp.Regexp{regexp.MustCompile("^DB_")}}},
+		{Name: "redact env invalid regex", Option: RedactEnv("("), Error: true, As: &ErrInvalidRegex{}},
+		{Name: "coerce json numbers", Option: CoerceJSONNumbers(), Expect: Config{CoerceJSONNumbers: true}},
+		{Name: "no config in report", Option: NoConfigInReport(), Expect: Config{OmitConfig: true}},
+		{Name: "no compression", Option: NoCompression(), Expect: Config{Compress: false}},
+		{Name: "batch interval", Option: BatchInterval("5s"), Expect: Config{BatchInterval: time.Duration(5 * time.Second)}},
+		{Name: "batch interval invalid", Option: BatchInterval("5T"), Error: true, As: &ErrInvalidDuration{}},
+		{Name: "batch size", Option: BatchSize("10"), Expect: Config{BatchSize: 10}},
+		{Name: "batch size invalid", Option: BatchSize("ten"), Error: true, As: &ErrInvalidNumber{}},
+		{Name: "batch size zero", Option: BatchSize("0"), Error: true, As: &ErrInvalidValue{}},
+		{Name: "batch window", Option: BatchWindow("5s"), Expect: Config{BatchWindow: time.Duration(5 * time.Second)}},
+		{Name: "batch window invalid", Option: BatchWindow("5T"), Error: true, As: &ErrInvalidDuration{}},
+		{Name: "batch reports", Option: BatchReports("1m"), Expect: Config{BatchReports: time.Minute}},
+		{Name: "batch reports invalid", Option: BatchReports("-1m"), Error: true, As: &ErrInvalidDuration{}},
 	}
 
 	for _, tc := range tt {
@@ -64,6 +187,7 @@ func TestConfigOptions(t *testing.T) {
 				assert.NoError(err, "unexpected error in option %s", tc.Name)
 			default:
 				assert.Error(err, "expected error in %s", tc.Name)
+				assert.True(errors.As(err, tc.As), "expected %T in %s, got %v", tc.As, tc.Name, err)
 			}
 		})
 	}
@@ -80,13 +204,14 @@ func TestConfigOptions(t *testing.T) {
 func TestConfigConstruction(t *testing.T) {
 	host, _ := os.Hostname()
 	shell, _ := findDefaultShell()
-	out := os.Stdout
-	err := os.Stderr
+	out := standardStream{os.Stdout}
+	err := standardStream{os.Stderr}
 	tt := []struct {
 		Name    string
 		Options []ConfigOption
 		Expect  Config
 		Error   bool
+		As      interface{}
 	}{
 		{Name: "only ID", Options: []ConfigOption{ID("test")}, Expect: Config{
 			ID:              "test",
@@ -94,11 +219,20 @@ func TestConfigConstruction(t *testing.T) {
 			StderrHistory:   30,
 			NotifyOnSuccess: true,
 			NotifyOnFailure: true,
+			MaxConcurrent:   1,
+			BatchSize:       1,
+			MetricWindow:    metricWindow,
+			MaxReportBytes:  maxReportBytes,
+			MaxLineSize:     maxLineSize,
+			StatLambda:      statLambda,
+			SampleStrategy:  "sum",
+			Compress:        true,
 			Hostname:        host,
 			host:            api,
 			port:            port,
 			useTLS:          true,
 			Shell:           shell,
+			in:              os.Stdin,
 			out:             out,
 			err:             err,
 		}},
@@ -108,16 +242,54 @@ func TestConfigConstruction(t *testing.T) {
 			StderrHistory:   30,
 			NotifyOnSuccess: true,
 			NotifyOnFailure: true,
+			MaxConcurrent:   1,
+			BatchSize:       1,
+			MetricWindow:    metricWindow,
+			MaxReportBytes:  maxReportBytes,
+			MaxLineSize:     maxLineSize,
+			StatLambda:      statLambda,
+			SampleStrategy:  "sum",
+			Compress:        true,
 			Hostname:        host,
 			host:            api,
 			port:            port,
 			useTLS:          false,
 			Shell:           shell,
+			in:              os.Stdin,
 			out:             out,
 			err:             err,
 		}},
-		{Name: "no ID", Options: []ConfigOption{}, Error: true},
-		{Name: "option error", Options: []ConfigOption{ID("test"), Rule("(")}, Error: true},
+		{Name: "command template", Options: []ConfigOption{ID("test"), CommandTemplate("backup --date {{.date}}", nil), TemplateVar("date", "2020-01-01")}, Expect: Config{
+			ID:              "test",
+			StdoutHistory:   30,
+			StderrHistory:   30,
+			NotifyOnSuccess: true,
+			NotifyOnFailure: true,
+			MaxConcurrent:   1,
+			BatchSize:       1,
+			MetricWindow:    metricWindow,
+			MaxReportBytes:  maxReportBytes,
+			MaxLineSize:     maxLineSize,
+			StatLambda:      statLambda,
+			SampleStrategy:  "sum",
+			Compress:        true,
+			Hostname:        host,
+			host:            api,
+			port:            port,
+			useTLS:          true,
+			Shell:           shell,
+			CommandTemplate: "backup --date {{.date}}",
+			vars:            map[string]string{"date": "2020-01-01"},
+			cmd:             []string{"backup", "--date", "2020-01-01"},
+			in:              os.Stdin,
+			out:             out,
+			err:             err,
+		}},
+		{Name: "command template missing var", Options: []ConfigOption{ID("test"), CommandTemplate("backup --date {{.date}}", nil)}, Error: true, As: &ErrInvalidTemplate{}},
+		{Name: "command template invalid", Options: []ConfigOption{ID("test"), CommandTemplate("backup --date {{.date", nil)}, Error: true, As: &ErrInvalidTemplate{}},
+		{Name: "rule period without quantity", Options: []ConfigOption{ID("test"), Rule("ERROR"), RulePeriod("10m")}, Error: true, As: &ErrInvalidValue{}},
+		{Name: "no ID", Options: []ConfigOption{}, Error: true, As: &ErrMissingID{}},
+		{Name: "option error", Options: []ConfigOption{ID("test"), Rule("(")}, Error: true, As: &ErrInvalidRegex{}},
 	}
 
 	for _, tc := range tt {
@@ -129,8 +301,79 @@ func TestConfigConstruction(t *testing.T) {
 				assert.Equal(t, tc.Expect, c)
 				assert.Equal(t, 0, len(err))
 			default:
-				assert.NotZero(t, len(err))
-				assert.Error(t, err[0])
+				if assert.NotZero(t, len(err)) {
+					assert.True(t, errors.As(err[0], tc.As), "expected %T, got %v", tc.As, err[0])
+				}
+			}
+		})
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Options  []ConfigOption
+		Warnings []string
+	}{
+		{Name: "no warnings", Options: []ConfigOption{MemoryWarn("1M"), MemoryKill("2M"), NotifyTimeout("1m"), KillTimeout("2m"), Creates("out.txt")}},
+		{Name: "memory kill below warn", Options: []ConfigOption{MemoryWarn("2M"), MemoryKill("1M")}, Warnings: []string{"memory-warn 2000K has no effect because the process is killed at memory-kill 1000K"}},
+		{Name: "memory kill equals warn", Options: []ConfigOption{MemoryWarn("1M"), MemoryKill("1M")}, Warnings: []string{"memory-warn 1000K has no effect because the process is killed at memory-kill 1000K"}},
+		{Name: "kill timeout before warn", Options: []ConfigOption{NotifyTimeout("2m"), KillTimeout("1m")}, Warnings: []string{"timeout-warn 2m0s has no effect because the process is killed at timeout-kill 1m0s"}},
+		{Name: "heartbeat without daemon", Options: []ConfigOption{Heartbeat("1m")}, Warnings: []string{"heartbeat has no effect because heartbeats are only sent for daemons"}},
+		{Name: "max concurrent without schedule", Options: []ConfigOption{MaxConcurrent("2")}, Warnings: []string{"max-concurrent has no effect because the command is not run on a schedule"}},
+		{Name: "error rule without error budget", Options: []ConfigOption{ErrorBudgetError("ERROR")}, Warnings: []string{"error-budget-error and error-budget-total have no effect without an SLO, set with --error-budget"}},
+		{Name: "print reports in a dry run", Options: []ConfigOption{DryRun(), PrintReports()}, Warnings: []string{"print-reports has no effect because the command is not run in a dry run"}},
+		{Name: "expected every with daemon", Options: []ConfigOption{Daemon(), ExpectedEvery("24h")}, Warnings: []string{"expected-every has no effect because a daemon runs until it exits, use heartbeat to detect a daemon that stops"}},
+		{Name: "batch window without size", Options: []ConfigOption{BatchWindow("5s")}, Warnings: []string{"batch-window has no effect because each report is sent as soon as it is created with a batch-size of 1"}},
+		{Name: "batch window with interval", Options: []ConfigOption{BatchSize("10"), BatchWindow("5s"), BatchInterval("1m")}, Warnings: []string{"batch-interval has no effect because batches are sent after batch-window"}},
+		{Name: "daemon with creates", Options: []ConfigOption{Daemon(), Creates("out.txt")}, Warnings: []string{"creates is only checked when a daemon exits, use creates-watch to check for files while it is running"}},
+		{Name: "continue on error without pipeline", Options: []ConfigOption{ContinueOnError()}, Warnings: []string{"continue-on-error has no effect because the command is not a pipeline"}},
+		{Name: "continue on error in parallel", Options: []ConfigOption{Parallel(), ContinueOnError()}, Warnings: []string{"continue-on-error has no effect because parallel commands all run unless fail-fast is set"}},
+		{Name: "fail fast without parallel", Options: []ConfigOption{FailFast()}, Warnings: []string{"fail-fast has no effect because the commands are not run in parallel"}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			c, errs := New([]string{"echo"}, append(tc.Options, ID("test"))...)
+			if len(errs) > 0 {
+				t.Fatalf("unexpected error in config: %s", errs)
+			}
+			assert.Equal(t, tc.Warnings, c.Config.Warnings)
+			// warnings are sent with each report
+			assert.Equal(t, len(tc.Warnings), len(c.Messages))
+			pb := reportFromCommand(c, proto.Success, nil)
+			for _, w := range tc.Warnings {
+				assert.Contains(t, pb.Messages, w)
+			}
+		})
+	}
+}
+
+func TestRenderCommand(t *testing.T) {
+	vars := map[string]string{"msg": "hello world", "date": "2020-01-01", "empty": ""}
+	tt := []struct {
+		Name     string
+		Template string
+		Expect   []string
+		Error    bool
+	}{
+		{Name: "var with space", Template: "echo {{.msg}}", Expect: []string{"echo", "hello world"}},
+		{Name: "double quotes", Template: `backup --note "nightly {{.date}}"`, Expect: []string{"backup", "--note", "nightly 2020-01-01"}},
+		{Name: "single quotes", Template: `echo '{{.msg}}  again' done`, Expect: []string{"echo", "hello world  again", "done"}},
+		{Name: "escaped space", Template: `echo a\ b`, Expect: []string{"echo", "a b"}},
+		{Name: "action with spaces", Template: `echo {{ printf "%s!" .msg }}`, Expect: []string{"echo", "hello world!"}},
+		{Name: "quoted empty var", Template: `echo "{{.empty}}"`, Expect: []string{"echo", ""}},
+		{Name: "unterminated quote", Template: `echo "{{.msg}}`, Error: true},
+		{Name: "empty command", Template: `{{.empty}} run`, Error: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			cmd, err := renderCommand(tc.Template, vars)
+			switch tc.Error {
+			case true:
+				assert.True(t, errors.As(err, &ErrInvalidTemplate{}), "expected ErrInvalidTemplate, got %v", err)
+			default:
+				assert.NoError(t, err)
+				assert.Equal(t, tc.Expect, cmd)
 			}
 		})
 	}
@@ -0,0 +1,240 @@
+package monny
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Configuration errors returned by New and each ConfigOption.  Use errors.As to distinguish the kind of error and
+// retrieve the invalid value.
+
+// ErrMissingID is returned when the configuration does not set an ID
+type ErrMissingID struct{}
+
+func (e ErrMissingID) Error() string {
+	return "id is required, use monny -i <id>; new ids are created with monctl create or pass your email address to get a notifications via email without an account"
+}
+
+// ErrInvalidRegex is returned when the regular expression of a rule does not compile
+type ErrInvalidRegex struct {
+	Pattern string
+	Err     error
+}
+
+func (e ErrInvalidRegex) Error() string {
+	return fmt.Sprintf("could not compile rule %s: %v", e.Pattern, e.Err)
+}
+
+func (e ErrInvalidRegex) Unwrap() error {
+	return e.Err
+}
+
+// ErrInvalidDuration is returned when the value of a duration option can not be parsed
+type ErrInvalidDuration struct {
+	Option string
+	Value  string
+}
+
+func (e ErrInvalidDuration) Error() string {
+	return fmt.Sprintf("unrecognized %s duration: %s", e.Option, e.Value)
+}
+
+// ErrInvalidMemory is returned when the value of a memory limit can not be parsed
+type ErrInvalidMemory struct {
+	Option string
+	Value  string
+}
+
+func (e ErrInvalidMemory) Error() string {
+	return fmt.Sprintf("could not parse %s limit: %s", e.Option, e.Value)
+}
+
+// ErrInvalidSize is returned when the value of a size option can not be parsed or is not positive
+type ErrInvalidSize struct {
+	Option string
+	Value  string
+}
+
+func (e ErrInvalidSize) Error() string {
+	return fmt.Sprintf("could not parse %s: %s", e.Option, e.Value)
+}
+
+// ErrInvalidNumber is returned when the value of an integer option can not be parsed
+type ErrInvalidNumber struct {
+	Option string
+	Value  string
+}
+
+func (e ErrInvalidNumber) Error() string {
+	return fmt.Sprintf("could not convert %s to integer: %s", e.Option, e.Value)
+}
+
+// ErrShellNotFound is returned when the shell can not be found in the search path.  Path is empty when no shell was
+// set and the default shell could not be determined.
+type ErrShellNotFound struct {
+	Path string
+	Err  error
+}
+
+func (e ErrShellNotFound) Error() string {
+	if len(e.Path) == 0 {
+		return "could not determine default shell, set with --shell=<full path to shell>"
+	}
+	return fmt.Sprintf("could not find shell %s: %v", e.Path, e.Err)
+}
+
+func (e ErrShellNotFound) Unwrap() error {
+	return e.Err
+}
+
+// ErrInvalidHost is returned when the reporting server is not in host:port format
+type ErrInvalidHost struct {
+	Value string
+}
+
+func (e ErrInvalidHost) Error() string {
+	return fmt.Sprintf("unknown host %s, use host:port", e.Value)
+}
+
+// ErrInvalidCertificate is returned when a CA or client certificate can not be loaded
+type ErrInvalidCertificate struct {
+	Path string
+	Err  error
+}
+
+func (e ErrInvalidCertificate) Error() string {
+	return fmt.Sprintf("could not load certificate %s: %v", e.Path, e.Err)
+}
+
+func (e ErrInvalidCertificate) Unwrap() error {
+	return e.Err
+}
+
+// ErrInvalidTemplate is returned when the command template can not be parsed or rendered
+type ErrInvalidTemplate struct {
+	Template string
+	Err      error
+}
+
+func (e ErrInvalidTemplate) Error() string {
+	return fmt.Sprintf("could not render command template %s: %v", e.Template, e.Err)
+}
+
+func (e ErrInvalidTemplate) Unwrap() error {
+	return e.Err
+}
+
+// ErrTemplateAndCommand is returned by New when both a command template and a command are given
+type ErrTemplateAndCommand struct{}
+
+func (e ErrTemplateAndCommand) Error() string {
+	return "use either a command template or a command, not both"
+}
+
+// ErrInvalidValue is returned when the value of an option is empty or not one of the accepted values
+type ErrInvalidValue struct {
+	Option string
+	Value  string
+	Reason string
+}
+
+func (e ErrInvalidValue) Error() string {
+	return fmt.Sprintf("invalid value for %s: %s", e.Option, e.Reason)
+}
+
+// ErrUnknownOption is returned for a key in the configuration file that is not an option.  Suggestion is the closest
+// option when the key looks like a typo of it.  File is the path of the configuration file, if known.
+type ErrUnknownOption struct {
+	Key        string
+	Line       int
+	Suggestion string
+	File       string
+}
+
+func (e ErrUnknownOption) Error() string {
+	msg := fmt.Sprintf("unknown option %s", e.Key)
+	if len(e.Suggestion) > 0 {
+		msg += fmt.Sprintf(", did you mean %s?", e.Suggestion)
+	}
+	if loc := configLocation(e.File, e.Line); len(loc) > 0 {
+		return fmt.Sprintf("%s: %s", loc, msg)
+	}
+	return msg
+}
+
+// ErrConfigKey is returned when the value of a key in the configuration file is invalid.  File is the path of the
+// configuration file, if known.
+type ErrConfigKey struct {
+	Key  string
+	Line int
+	Err  error
+	File string
+}
+
+func (e ErrConfigKey) Error() string {
+	if loc := configLocation(e.File, e.Line); len(loc) > 0 {
+		return fmt.Sprintf("%s: %s: %v", loc, e.Key, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Key, e.Err)
+}
+
+// configLocation returns the file and line of a key in the configuration file as file:line, or the part that is known
+func configLocation(file string, line int) string {
+	switch {
+	case len(file) > 0 && line > 0:
+		return fmt.Sprintf("%s:%d", file, line)
+	case len(file) > 0:
+		return file
+	case line > 0:
+		return fmt.Sprintf("line %d", line)
+	default:
+		return ""
+	}
+}
+
+func (e ErrConfigKey) Unwrap() error {
+	return e.Err
+}
+
+// ErrConfigFile is returned when a configuration file has one or more problems.  Errors holds every problem found in
+// the file, in order of line number.
+type ErrConfigFile struct {
+	Errors []error
+}
+
+func (e ErrConfigFile) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d problems in configuration: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// inFile returns the errors with the path of the configuration file in which they were found
+func (e ErrConfigFile) inFile(path string) ErrConfigFile {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		switch keyErr := err.(type) {
+		case ErrUnknownOption:
+			keyErr.File = path
+			err = keyErr
+		case ErrConfigKey:
+			keyErr.File = path
+			err = keyErr
+		}
+		errs = append(errs, err)
+	}
+	return ErrConfigFile{Errors: errs}
+}
+
+// errorLine returns the line of a configuration file error, or 0 if unknown
+func errorLine(err error) int {
+	switch e := err.(type) {
+	case ErrUnknownOption:
+		return e.Line
+	case ErrConfigKey:
+		return e.Line
+	default:
+		return 0
+	}
+}
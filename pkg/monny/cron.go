@@ -0,0 +1,212 @@
+package monny
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronField is the range of values of one field of a cron expression
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	cronSecond = cronField{name: "second", min: 0, max: 59}
+	cronMinute = cronField{name: "minute", min: 0, max: 59}
+	cronHour   = cronField{name: "hour", min: 0, max: 23}
+	cronDay    = cronField{name: "day of month", min: 1, max: 31}
+	cronMonth  = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// day of week 7 is also Sunday
+	cronWeekday = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// cronDescriptors are the shorthand schedules that can be used in place of a cron expression
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// schedule is a parsed cron expression.  Each field is a bit set of the values that match.
+type schedule struct {
+	second  uint64
+	minute  uint64
+	hour    uint64
+	day     uint64
+	month   uint64
+	weekday uint64
+	// every is the interval of an @every schedule, which runs at a fixed interval instead of at matching times
+	every time.Duration
+	// anyDay is true when either the day of month or day of week is *, so that both must match.  Otherwise a time
+	// matches when either does, as in cron.
+	anyDay bool
+}
+
+// parseSchedule parses a cron expression with five fields (minute hour day-of-month month day-of-week), six fields
+// with seconds first, a descriptor such as @hourly, or @every followed by a duration such as @every 90s
+func parseSchedule(expr string) (*schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval: %v", err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("interval %s is less than 1s", d)
+		}
+		return &schedule{every: d}, nil
+	}
+	if strings.HasPrefix(expr, "@") {
+		d, ok := cronDescriptors[strings.ToLower(expr)]
+		if !ok {
+			return nil, fmt.Errorf("unknown schedule %s", expr)
+		}
+		expr = d
+	}
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron expression must have 5 fields, or 6 with seconds, found %d", len(fields))
+	}
+
+	s := &schedule{}
+	var err error
+	for i, f := range []struct {
+		bits  *uint64
+		field cronField
+	}{
+		{&s.second, cronSecond}, {&s.minute, cronMinute}, {&s.hour, cronHour},
+		{&s.day, cronDay}, {&s.month, cronMonth}, {&s.weekday, cronWeekday},
+	} {
+		if *f.bits, err = parseCronField(fields[i], f.field); err != nil {
+			return nil, err
+		}
+	}
+	// day of week 7 is Sunday
+	if s.weekday&(1<<7) > 0 {
+		s.weekday |= 1
+	}
+	s.anyDay = strings.HasPrefix(fields[3], "*") || strings.HasPrefix(fields[5], "*")
+	return s, nil
+}
+
+// parseCronField returns the bit set of values matched by a comma separated list of values, ranges a-b, and steps
+// */n or a-b/n
+func parseCronField(expr string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s field", part[i+1:], f.name)
+			}
+			step = n
+			part = part[:i]
+		}
+		var lo, hi int
+		switch {
+		case part == "*":
+			lo, hi = f.min, f.max
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("invalid range %s in %s field", part, f.name)
+			}
+		default:
+			v, err := f.value(part)
+			if err != nil {
+				return 0, err
+			}
+			lo, hi = v, v
+			// a/n starts at a and repeats to the end of the range
+			if step > 1 {
+				hi = f.max
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// value parses a number or name in the field
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q in %s field", s, f.name)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s %d is out of range %d-%d", f.name, v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// next returns the first time after t that matches the schedule, in the location of t.  Returns the zero time if no
+// time matches within five years, such as for February 30.
+func (s *schedule) next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Truncate(time.Second).Add(s.every)
+	}
+	t = t.Truncate(time.Second).Add(time.Second)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !has(s.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !has(s.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !has(s.minute, t.Minute()):
+			t = t.Truncate(time.Minute).Add(time.Minute)
+		case !has(s.second, t.Second()):
+			t = t.Add(time.Second)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches returns true if the day of month and day of week of t match the schedule
+func (s *schedule) dayMatches(t time.Time) bool {
+	day := has(s.day, t.Day())
+	weekday := has(s.weekday, int(t.Weekday()))
+	if s.anyDay {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+// has returns true if the bit for v is set
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) > 0
+}
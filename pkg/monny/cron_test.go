@@ -0,0 +1,51 @@
+package monny
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// Monday
+	from := time.Date(2020, time.March, 2, 10, 17, 30, 0, time.UTC)
+	tt := []struct {
+		Name string
+		Expr string
+		Next time.Time
+	}{
+		{Name: "every minute", Expr: "* * * * *", Next: time.Date(2020, time.March, 2, 10, 18, 0, 0, time.UTC)},
+		{Name: "every second", Expr: "* * * * * *", Next: time.Date(2020, time.March, 2, 10, 17, 31, 0, time.UTC)},
+		{Name: "every 5 minutes", Expr: "*/5 * * * *", Next: time.Date(2020, time.March, 2, 10, 20, 0, 0, time.UTC)},
+		{Name: "list", Expr: "10,45 * * * *", Next: time.Date(2020, time.March, 2, 10, 45, 0, 0, time.UTC)},
+		{Name: "range with step", Expr: "0 8-18/4 * * *", Next: time.Date(2020, time.March, 2, 12, 0, 0, 0, time.UTC)},
+		{Name: "start with step", Expr: "0 20/2 * * *", Next: time.Date(2020, time.March, 2, 20, 0, 0, 0, time.UTC)},
+		{Name: "next day", Expr: "30 2 * * *", Next: time.Date(2020, time.March, 3, 2, 30, 0, 0, time.UTC)},
+		{Name: "weekday name", Expr: "0 9 * * fri", Next: time.Date(2020, time.March, 6, 9, 0, 0, 0, time.UTC)},
+		{Name: "sunday as 7", Expr: "0 9 * * 7", Next: time.Date(2020, time.March, 8, 9, 0, 0, 0, time.UTC)},
+		{Name: "day of month or week", Expr: "0 0 15 * sat", Next: time.Date(2020, time.March, 7, 0, 0, 0, 0, time.UTC)},
+		{Name: "month name", Expr: "0 0 1 jun *", Next: time.Date(2020, time.June, 1, 0, 0, 0, 0, time.UTC)},
+		{Name: "leap day", Expr: "0 0 29 2 *", Next: time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{Name: "hourly", Expr: "@hourly", Next: time.Date(2020, time.March, 2, 11, 0, 0, 0, time.UTC)},
+		{Name: "weekly", Expr: "@weekly", Next: time.Date(2020, time.March, 8, 0, 0, 0, 0, time.UTC)},
+		{Name: "every interval", Expr: "@every 90s", Next: time.Date(2020, time.March, 2, 10, 19, 0, 0, time.UTC)},
+		{Name: "never", Expr: "0 0 30 2 *"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			s, err := parseSchedule(tc.Expr)
+			if err != nil {
+				t.Fatalf("unexpected error parsing %s: %v", tc.Expr, err)
+			}
+			assert.Equal(t, tc.Next, s.next(from))
+		})
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "* * * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "x * * * *", "@often", "@every 500ms", "@every soon"} {
+		_, err := parseSchedule(expr)
+		assert.Error(t, err, expr)
+	}
+}
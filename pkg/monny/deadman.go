@@ -0,0 +1,132 @@
+package monny
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/proto"
+)
+
+// runState is kept between runs of a command with ExpectedEvery to detect runs that are missing
+type runState struct {
+	LastSuccess time.Time `json:"last_success"`
+}
+
+// stateFile returns the path of the state file for the ID of the command
+func (c *Command) stateFile() (string, error) {
+	dir := c.Config.stateDir
+	if len(dir) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("could not find the home directory for the run state: %v", err)
+		}
+		dir = filepath.Join(home, ".monny", "state")
+	}
+	// the ID is used as the file name, so it can not reach outside the state directory
+	name := strings.NewReplacer("/", "_", "\\", "_").Replace(c.Config.ID)
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// withState calls fn with the state of the runs of the command while holding a lock on the state file, so that
+// monitors with the same ID do not overwrite each other.  The state is written back when fn returns true.
+func (c *Command) withState(fn func(s *runState) bool) error {
+	path, err := c.stateFile()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("could not create the run state directory: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("could not open the run state %s: %v", path, err)
+	}
+	defer f.Close()
+	if err := lockFile(f); err != nil {
+		return fmt.Errorf("could not lock the run state %s: %v", path, err)
+	}
+	defer unlockFile(f)
+
+	var s runState
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return fmt.Errorf("could not read the run state %s: %v", path, err)
+	}
+	// a new state file is empty until the first successful run
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return fmt.Errorf("could not parse the run state %s: %v", path, err)
+		}
+	}
+	if !fn(&s) {
+		return nil
+	}
+	data, err = json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("could not encode the run state: %v", err)
+	}
+	if err := f.Truncate(0); err != nil {
+		return fmt.Errorf("could not write the run state %s: %v", path, err)
+	}
+	if _, err := f.WriteAt(data, 0); err != nil {
+		return fmt.Errorf("could not write the run state %s: %v", path, err)
+	}
+	return nil
+}
+
+// checkLateRun sends a late run report when the last successful run was longer ago than ExpectedEvery
+func (c *Command) checkLateRun() {
+	if c.Config.ExpectedEvery == 0 {
+		return
+	}
+	var last time.Time
+	if err := c.withState(func(s *runState) bool {
+		last = s.LastSuccess
+		return false
+	}); err != nil {
+		c.reportError(errorInternal, err)
+		return
+	}
+	if last.IsZero() {
+		return
+	}
+	gap := time.Since(last)
+	if gap <= c.Config.ExpectedEvery {
+		return
+	}
+	msg := fmt.Sprintf("the last successful run was %s ago at %s, runs are expected every %s", gap.Round(time.Second), last.Format(time.RFC3339), c.Config.ExpectedEvery)
+	c.log.warnf("%s", msg)
+	c.mutex.Lock()
+	c.Messages = append(c.Messages, msg)
+	c.mutex.Unlock()
+	c.send(proto.LateRun)
+}
+
+// recordSuccess keeps the time of the run in the state file when it succeeded and ExpectedEvery is set
+func (c *Command) recordSuccess() {
+	if c.Config.ExpectedEvery == 0 {
+		return
+	}
+	c.mutex.Lock()
+	success := c.Success
+	c.mutex.Unlock()
+	if !success {
+		return
+	}
+	now := time.Now().UTC()
+	if err := c.withState(func(s *runState) bool {
+		// a monitor with the same ID may have recorded a later success while this one was running
+		if s.LastSuccess.After(now) {
+			return false
+		}
+		s.LastSuccess = now
+		return true
+	}); err != nil {
+		c.reportError(errorInternal, err)
+	}
+}
@@ -0,0 +1,135 @@
+package monny
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpectedEvery(t *testing.T) {
+	tt := []struct {
+		Name string
+		Cmd  []string
+		// Ago is how long ago the last success was recorded in the state file, or no state file when zero
+		Ago  time.Duration
+		Late bool
+	}{
+		{Name: "first run", Cmd: []string{"true"}},
+		{Name: "on time", Cmd: []string{"true"}, Ago: 23 * time.Hour},
+		{Name: "overdue", Cmd: []string{"true"}, Ago: 25 * time.Hour, Late: true},
+		{Name: "overdue and failed", Cmd: []string{"false"}, Ago: 25 * time.Hour, Late: true},
+		{Name: "on time and failed", Cmd: []string{"false"}, Ago: time.Hour},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "monny-state")
+			if err != nil {
+				t.Fatalf("unexpected error creating state directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "test.json")
+			var last time.Time
+			if tc.Ago > 0 {
+				last = time.Now().Add(-tc.Ago).UTC().Truncate(time.Second)
+				data, _ := json.Marshal(runState{LastSuccess: last})
+				if err := ioutil.WriteFile(path, data, 0600); err != nil {
+					t.Fatalf("unexpected error writing state: %v", err)
+				}
+			}
+
+			c, errs := New(tc.Cmd, ID("test"), ExpectedEvery("24h"), stateDirectory(dir), logOut(&closeBuffer{}), logErr(&closeBuffer{}))
+			if len(errs) > 0 {
+				t.Fatalf("unexpected error in config: %s", errs)
+			}
+			recorder := new(reasonRecorder)
+			c.report = recorder
+			start := time.Now().UTC().Truncate(time.Second)
+			_ = c.Exec()
+			assert.NoError(t, c.Wait())
+
+			reasons := recorder.Reasons()
+			switch tc.Late {
+			case true:
+				assert.Equal(t, 1, countReasons(reasons, proto.LateRun))
+				if assert.NotEmpty(t, c.Messages) {
+					assert.Contains(t, c.Messages[0], "runs are expected every 24h0m0s")
+				}
+			default:
+				assert.Equal(t, 0, countReasons(reasons, proto.LateRun))
+			}
+
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatalf("unexpected error reading state: %v", err)
+			}
+			var s runState
+			if len(data) > 0 {
+				assert.NoError(t, json.Unmarshal(data, &s))
+			}
+			switch c.Success {
+			case true:
+				assert.False(t, s.LastSuccess.Before(start), "successful run should be recorded in the state")
+			default:
+				assert.True(t, s.LastSuccess.Equal(last), "failed run should not change the state")
+			}
+		})
+	}
+}
+
+func TestExpectedEveryConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monny-state")
+	if err != nil {
+		t.Fatalf("unexpected error creating state directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	done := make(chan error)
+	for i := 0; i < 5; i++ {
+		go func() {
+			c, errs := New([]string{"true"}, ID("test"), ExpectedEvery("1h"), stateDirectory(dir), logOut(&closeBuffer{}), logErr(&closeBuffer{}))
+			if len(errs) > 0 {
+				done <- errs[0]
+				return
+			}
+			c.report = new(reasonRecorder)
+			if err := c.Exec(); err != nil {
+				done <- err
+				return
+			}
+			c.mutex.Lock()
+			defer c.mutex.Unlock()
+			if errs := c.errors.Errors(); len(errs) > 0 {
+				done <- fmt.Errorf("%s", errs[0])
+				return
+			}
+			done <- nil
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, <-done)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "test.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading state: %v", err)
+	}
+	var s runState
+	assert.NoError(t, json.Unmarshal(data, &s), "state should not be corrupted by concurrent runs")
+	assert.WithinDuration(t, time.Now(), s.LastSuccess, 10*time.Second)
+}
+
+func TestStateFileName(t *testing.T) {
+	c, errs := New([]string{"true"}, ID("backups/db"), stateDirectory("/state"))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	path, err := c.stateFile()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("/state", "backups_db.json"), path)
+}
@@ -0,0 +1,365 @@
+package monny
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/pb"
+	"github.com/BTBurke/monny/pkg/proto"
+)
+
+// dryRunSender implements the sender interface by printing a rendering of each report instead of sending it.  Reports
+// are gated by the configuration the same as when they are sent, so only the reports that would be sent are printed.
+// Output is printed to Stderr, or appended to the file at path when one is set.  The file is opened on the first write
+// and closed by wait.
+type dryRunSender struct {
+	path  string
+	out   io.Writer
+	file  *os.File
+	mutex sync.Mutex
+}
+
+// Write prints p to the output of the dry run, opening the file on the first write
+func (s *dryRunSender) Write(p []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.write(p)
+}
+
+// write is like Write for callers that already hold the mutex
+func (s *dryRunSender) write(p []byte) (int, error) {
+	if s.out == nil {
+		s.out = os.Stderr
+		if len(s.path) > 0 {
+			f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				s.out = nil
+				return 0, fmt.Errorf("could not open dry run file: %v", err)
+			}
+			s.file = f
+			s.out = f
+		}
+	}
+	return s.out.Write(p)
+}
+
+func (s *dryRunSender) create(c *Command, reason proto.ReportReason) *pb.Report {
+	pb := reportFromCommand(c, reason, func(err error) { c.recordError(errorMarshal, err) })
+	truncateReport(pb, c.Config.MaxReportBytes)
+	return pb
+}
+
+// sendBackground prints the report as a single write so that concurrent reports are not interleaved
+func (s *dryRunSender) sendBackground(report *pb.Report, result chan error, cancel chan bool) {
+	if report == nil {
+		result <- fmt.Errorf("no report created")
+		return
+	}
+	_, err := s.Write([]byte(renderReport(report)))
+
+	select {
+	case result <- err:
+	case <-cancel:
+	}
+}
+
+// sendBatch prints each of the reports
+func (s *dryRunSender) sendBatch(reports []*pb.Report) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, report := range reports {
+		if _, err := s.write([]byte(renderReport(report))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wait closes the output when reports are printed to a file
+func (s *dryRunSender) wait() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.file != nil {
+		s.file.Close()
+		s.file, s.out = nil, nil
+	}
+}
+
+// renderReport returns a human-readable rendering of a report
+func renderReport(r *pb.Report) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "dry run: would send %s report for %s\n", r.ReportReason, r.Id)
+	fmt.Fprintf(&b, "  command: %s\n", r.UserCommand)
+	switch {
+	case r.Killed:
+		fmt.Fprintf(&b, "  killed: %s\n", r.KillReason)
+	case r.ExitCodeValid:
+		fmt.Fprintf(&b, "  exit code: %d\n", r.ExitCode)
+	}
+	fmt.Fprintf(&b, "  success: %t\n", r.Success)
+	if r.Finish > 0 {
+		fmt.Fprintf(&b, "  duration: %s\n", r.Duration)
+	}
+	if r.MaxMemory > 0 {
+		fmt.Fprintf(&b, "  max memory: %dK\n", r.MaxMemory)
+	}
+	if r.LinesProcessed > 0 {
+		fmt.Fprintf(&b, "  lines processed: %d\n", r.LinesProcessed)
+	}
+	var steps []StepResult
+	if len(r.Steps) > 0 {
+		json.Unmarshal(r.Steps, &steps)
+	}
+	var lines []string
+	for i, s := range steps {
+		var status string
+		switch {
+		case s.Skipped:
+			status = "skipped"
+		case s.Cancelled:
+			status = fmt.Sprintf("cancelled after %s", s.Duration)
+		case s.ExitCodeValid:
+			status = fmt.Sprintf("exit code %d in %s", s.ExitCode, s.Duration)
+		default:
+			status = fmt.Sprintf("killed after %s", s.Duration)
+		}
+		lines = append(lines, fmt.Sprintf("%d: %s (%s)", i+1, s.Command, status))
+		for _, line := range s.Stderr {
+			lines = append(lines, "  "+line)
+		}
+	}
+	renderLines(&b, "steps", lines)
+	renderLines(&b, "messages", r.Messages)
+	var env []string
+	for k, v := range r.Env {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	renderLines(&b, "env", env)
+	matches, _ := splitMatches(r.Matches)
+	lines = nil
+	for _, raw := range matches {
+		var m RuleMatch
+		if err := json.Unmarshal(raw, &m); err != nil {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s %s", m.Time.Format(time.RFC3339), m.Line))
+	}
+	renderLines(&b, "rule matches", lines)
+	renderLines(&b, "stdout", r.Stdout)
+	renderLines(&b, "stderr", r.Stderr)
+	return b.String()
+}
+
+func renderLines(b *strings.Builder, title string, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "  %s:\n", title)
+	for _, line := range lines {
+		fmt.Fprintf(b, "    %s\n", line)
+	}
+}
+
+// printDryRun prints the resolved configuration and what will trigger reports before the command is run
+func (c *Command) printDryRun() error {
+	cfg := c.Config
+	var b strings.Builder
+	switch {
+	case cfg.DryRun:
+		fmt.Fprintf(&b, "dry run: configuration is valid, the command is not run\n")
+	default:
+		fmt.Fprintf(&b, "dry run: configuration is valid, reports will be printed instead of sent\n")
+	}
+	fmt.Fprintf(&b, "id: %s\n", cfg.ID)
+	fmt.Fprintf(&b, "hostname: %s\n", cfg.Hostname)
+	if len(cfg.Presets) > 0 {
+		fmt.Fprintf(&b, "presets: %s\n", strings.Join(cfg.Presets, ", "))
+	}
+	switch {
+	case len(c.steps) > 0 && cfg.Parallel:
+		fmt.Fprintf(&b, "command: %d steps in parallel\n", len(c.steps))
+		for i, s := range c.steps {
+			fmt.Fprintf(&b, "  step %d: %s\n", i+1, s.command)
+		}
+		switch {
+		case cfg.FailFast:
+			fmt.Fprintf(&b, "on error: kill the other steps\n")
+		default:
+			fmt.Fprintf(&b, "on error: run the other steps to completion\n")
+		}
+	case len(c.steps) > 0:
+		fmt.Fprintf(&b, "command: pipeline of %d steps\n", len(c.steps))
+		for i, s := range c.steps {
+			fmt.Fprintf(&b, "  step %d: %s\n", i+1, s.command)
+		}
+		switch {
+		case cfg.ContinueOnError:
+			fmt.Fprintf(&b, "on error: continue with the next step\n")
+		default:
+			fmt.Fprintf(&b, "on error: stop the pipeline\n")
+		}
+	case len(c.UserCommand) > 0:
+		fmt.Fprintf(&b, "command: %s\n", strings.Join(c.UserCommand, " "))
+	default:
+		fmt.Fprintf(&b, "command: none, monitoring log lines from stdin\n")
+	}
+	if len(cfg.PreRun) > 0 {
+		fmt.Fprintf(&b, "pre-run: %s\n", cfg.PreRun)
+	}
+	if len(cfg.WorkingDir) > 0 {
+		fmt.Fprintf(&b, "working dir: %s\n", cfg.WorkingDir)
+	}
+	switch {
+	case cfg.NoShell:
+		fmt.Fprintf(&b, "shell: none, command is executed directly\n")
+	case cfg.ShellStrict:
+		fmt.Fprintf(&b, "shell: %s (set -euo pipefail)\n", cfg.Shell)
+	default:
+		fmt.Fprintf(&b, "shell: %s\n", cfg.Shell)
+	}
+	fmt.Fprintf(&b, "pty: %t\n", cfg.PTY)
+	if cfg.NoStdin {
+		fmt.Fprintf(&b, "stdin: disconnected\n")
+	}
+	if cfg.KillOnBrokenPipe {
+		fmt.Fprintf(&b, "broken pipe: stop the command when the reader of the output closes it\n")
+	}
+	fmt.Fprintf(&b, "max line size: %d bytes\n", cfg.MaxLineSize)
+	fmt.Fprintf(&b, "daemon: %t\n", cfg.Daemon)
+	if cfg.schedule != nil {
+		fmt.Fprintf(&b, "schedule: %s, next run at %s, at most %d at a time\n", cfg.Schedule, cfg.schedule.next(time.Now()).Format(time.RFC3339), cfg.MaxConcurrent)
+	}
+	if cfg.ErrorBudgetSLO > 0 {
+		fmt.Fprintf(&b, "error budget: SLO %g%% over %s, after at least %d requests\n", cfg.ErrorBudgetSLO, cfg.ErrorBudgetWindow, budgetMinRequests(cfg))
+	}
+	if cfg.ExpectedEvery > 0 {
+		fmt.Fprintf(&b, "expected every: %s\n", cfg.ExpectedEvery)
+	}
+	if cfg.Daemon && cfg.HeartbeatInterval > 0 {
+		fmt.Fprintf(&b, "heartbeat: every %s\n", cfg.HeartbeatInterval)
+	}
+	if len(cfg.OTelEndpoint) > 0 {
+		fmt.Fprintf(&b, "otel traces: %s\n", cfg.OTelEndpoint)
+	}
+	if len(cfg.PrometheusAddr) > 0 {
+		fmt.Fprintf(&b, "prometheus metrics: http://%s/metrics\n", cfg.PrometheusAddr)
+	}
+	switch {
+	case len(cfg.ReportFile) > 0:
+		fmt.Fprintf(&b, "reports: appended to %s\n", cfg.ReportFile)
+	default:
+		fmt.Fprintf(&b, "reports: sent to %s\n", net.JoinHostPort(strings.TrimPrefix(cfg.host, "https://"), cfg.port))
+	}
+	fmt.Fprintf(&b, "  tls: %t\n", cfg.useTLS)
+	fmt.Fprintf(&b, "  compress: %t\n", cfg.Compress)
+	fmt.Fprintf(&b, "  batch interval: %s\n", cfg.BatchInterval)
+	if cfg.BatchSize > 1 {
+		fmt.Fprintf(&b, "  batch size: %d reports\n", cfg.BatchSize)
+	}
+	if cfg.BatchWindow > 0 {
+		fmt.Fprintf(&b, "  batch window: %s\n", cfg.BatchWindow)
+	}
+	if cfg.BatchReports > 0 {
+		fmt.Fprintf(&b, "  alerts combined within: %s\n", cfg.BatchReports)
+	}
+	fmt.Fprintf(&b, "  max report size: %d bytes\n", cfg.MaxReportBytes)
+	fmt.Fprintf(&b, "  history: %d stdout lines, %d stderr lines\n", cfg.StdoutHistory, cfg.StderrHistory)
+	fmt.Fprintf(&b, "  include config: %t\n", !cfg.OmitConfig)
+	fmt.Fprintf(&b, "  jsonl matches: %t\n", cfg.JSONLMatches)
+	fmt.Fprintf(&b, "  merge matches on the same line: %t\n", cfg.MergeMatches)
+	if len(cfg.IncludeEnv) > 0 {
+		fmt.Fprintf(&b, "  include env: %s\n", strings.Join(cfg.IncludeEnv, ", "))
+	}
+
+	fmt.Fprintf(&b, "notifications:\n")
+	fmt.Fprintf(&b, "  on success: %t\n", cfg.NotifyOnSuccess)
+	fmt.Fprintf(&b, "  on failure: %t\n", cfg.NotifyOnFailure)
+	for _, r := range cfg.Rules {
+		switch {
+		case len(r.Field) > 0:
+			fmt.Fprintf(&b, "  on output field %s matching %s%s\n", r.Field, r.Regex, ruleDetails(r))
+		default:
+			fmt.Fprintf(&b, "  on output matching %s%s\n", r.Regex, ruleDetails(r))
+		}
+	}
+	if len(cfg.Rules) > 0 && cfg.RuleQuantity > 0 {
+		switch {
+		case cfg.RulePeriod > 0:
+			fmt.Fprintf(&b, "  when rules match at least %d times in %s\n", cfg.RuleQuantity, cfg.RulePeriod)
+		default:
+			fmt.Fprintf(&b, "  when rules match at least %d times\n", cfg.RuleQuantity)
+		}
+	}
+	for _, r := range cfg.MetricRules {
+		var limits string
+		if r.Sampling > 1 {
+			limits += fmt.Sprintf(", 1 in %d windows", r.Sampling)
+		}
+		if r.RateLimit > 0 {
+			limits += fmt.Sprintf(", at most %d windows per %s", r.RateLimit, r.RatePeriod)
+		}
+		fmt.Fprintf(&b, "  when the rate of %s lines matching %s increases (metric %s, window %s, lambda %g, sample %s%s)\n", r.Stream, r.Regex, r.Name, cfg.MetricWindow, cfg.StatLambda, cfg.SampleStrategy, limits)
+	}
+	for _, f := range cfg.Creates {
+		fmt.Fprintf(&b, "  when %s is not created\n", f)
+	}
+	for _, w := range cfg.CreatesWatch {
+		fmt.Fprintf(&b, "  when no file matching %s is created within %s while running\n", w.Glob, w.Interval)
+	}
+	if cfg.NotifyTimeout > 0 {
+		fmt.Fprintf(&b, "  when running longer than %s\n", cfg.NotifyTimeout)
+	}
+	if cfg.MemoryWarn > 0 {
+		fmt.Fprintf(&b, "  when memory use exceeds %dK\n", cfg.MemoryWarn)
+	}
+	if cfg.KillTimeout > 0 {
+		fmt.Fprintf(&b, "  kill when running longer than %s\n", cfg.KillTimeout)
+	}
+	if cfg.MemoryKill > 0 {
+		fmt.Fprintf(&b, "  kill when memory use exceeds %dK\n", cfg.MemoryKill)
+	}
+	for _, w := range cfg.Warnings {
+		fmt.Fprintf(&b, "warning: %s\n", w)
+	}
+
+	_, err := io.WriteString(c.dryRunOut, b.String())
+	return err
+}
+
+// ruleDetails returns the settings of a structured rule in parentheses, or an empty string for a plain rule
+func ruleDetails(r rule) string {
+	var details []string
+	if len(r.Name) > 0 {
+		details = append(details, "name "+r.Name)
+	}
+	if len(r.Severity) > 0 {
+		details = append(details, "severity "+r.Severity)
+	}
+	if len(r.Stream) > 0 && r.Stream != streamBoth {
+		details = append(details, string(r.Stream)+" only")
+	}
+	if r.After > 0 {
+		details = append(details, fmt.Sprintf("after %s", r.After))
+	}
+	if r.Before > 0 {
+		details = append(details, fmt.Sprintf("before %s", r.Before))
+	}
+	switch {
+	case r.Quantity > 0 && r.Period > 0:
+		details = append(details, fmt.Sprintf("at least %d times in %s", r.Quantity, r.Period))
+	case r.Quantity > 0:
+		details = append(details, fmt.Sprintf("at least %d times", r.Quantity))
+	}
+	if len(details) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(details, ", ") + ")"
+}
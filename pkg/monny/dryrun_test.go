@@ -0,0 +1,165 @@
+package monny
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// closeBuffer is a buffer that implements io.WriteCloser
+type closeBuffer struct {
+	bytes.Buffer
+}
+
+func (c *closeBuffer) Close() error {
+	return nil
+}
+
+func TestDryRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monny")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	created := filepath.Join(dir, "created")
+	path := filepath.Join(dir, "dryrun.txt")
+
+	c, errs := New([]string{"touch", created}, ID("test"), DryRunFile(path), Rule("ERROR"), RuleQuantity("5"), RulePeriod("1m"),
+		MetricRule("errors", "stderr", "FATAL"), KillTimeout("1h"), MemoryWarn("1G"), Creates(created), NoNotifyOnSuccess(),
+		logOut(&closeBuffer{}), logErr(&closeBuffer{}))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	assert.True(t, c.Config.DryRun)
+	assert.NoError(t, c.Exec())
+	assert.NoError(t, c.Wait())
+
+	_, err = os.Stat(created)
+	assert.True(t, os.IsNotExist(err), "command should not run in a dry run")
+
+	out, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error reading dry run output: %v", err)
+	}
+	for _, expect := range []string{
+		"dry run: configuration is valid, the command is not run",
+		"id: test",
+		"command: touch " + created,
+		"on output matching ERROR",
+		"when " + created + " is not created",
+		"kill when running longer than 1h0m0s",
+	} {
+		assert.Contains(t, string(out), expect)
+	}
+	assert.NotContains(t, string(out), "would send")
+}
+
+func TestPrintReports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monny")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	created := filepath.Join(dir, "created")
+	path := filepath.Join(dir, "dryrun.txt")
+
+	// reports must not be sent to the server when they are printed
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error starting listener: %v", err)
+	}
+	dialed := make(chan bool, 1)
+	go func() {
+		if conn, err := l.Accept(); err == nil {
+			conn.Close()
+			dialed <- true
+		}
+	}()
+
+	c, errs := New([]string{"sh", "-c", "echo starting; echo ERROR found; echo done >&2; exit 3"}, ID("test"), PrintReports(), DryRunFile(path), Host(l.Addr().String()), Insecure(),
+		Rule("ERROR"), MetricRule("errors", "stderr", "FATAL"), KillTimeout("1h"), MemoryWarn("1G"), Creates(created), NoNotifyOnSuccess(),
+		logOut(&closeBuffer{}), logErr(&closeBuffer{}))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	assert.False(t, c.Config.DryRun)
+	assert.IsType(t, &dryRunSender{}, c.report.(*Report).sender)
+	assert.Equal(t, ErrChildFailed{Code: 3}, c.Exec())
+	assert.NoError(t, c.Wait())
+	l.Close()
+
+	out, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error reading dry run output: %v", err)
+	}
+	for _, expect := range []string{
+		"dry run: configuration is valid, reports will be printed instead of sent",
+		"id: test",
+		"command: sh -c echo starting; echo ERROR found; echo done >&2; exit 3",
+		"reports: sent to " + l.Addr().String(),
+		"  tls: false",
+		"on success: false",
+		"on failure: true",
+		"on output matching ERROR",
+		"when the rate of stderr lines matching FATAL increases (metric errors, window 15s, lambda 0.25, sample sum)",
+		"when " + created + " is not created",
+		"when memory use exceeds 1000000K",
+		"kill when running longer than 1h0m0s",
+		"dry run: would send Alert report for test",
+		"  rule matches:\n",
+		" ERROR found\n",
+		"dry run: would send Failure report for test",
+		"  exit code: 3\n",
+		"  stdout:\n    starting\n    ERROR found\n",
+		"  stderr:\n    done\n",
+		"dry run: would send FileNotCreated report for test",
+		"file not created: " + created,
+	} {
+		assert.Contains(t, string(out), expect)
+	}
+	assert.NotContains(t, string(out), "Success report")
+
+	select {
+	case <-dialed:
+		t.Errorf("report server should not be dialed when printing reports")
+	default:
+	}
+}
+
+func TestDryRunFileAppend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monny")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dryrun.txt")
+	if err := ioutil.WriteFile(path, []byte("previous run\n"), 0644); err != nil {
+		t.Fatalf("unexpected error writing dry run file: %v", err)
+	}
+
+	// the file is not touched until the dry run prints, so configuring a command again does not truncate it
+	var c *Command
+	for i := 0; i < 2; i++ {
+		var errs []error
+		c, errs = New([]string{"true"}, ID("test"), DryRunFile(path), logOut(&closeBuffer{}), logErr(&closeBuffer{}))
+		if len(errs) > 0 {
+			t.Fatalf("unexpected error in config: %s", errs)
+		}
+	}
+	out, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "previous run\n", string(out))
+
+	assert.NoError(t, c.Exec())
+	assert.NoError(t, c.Wait())
+	out, err = ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(out), "previous run\n"), "dry run output should be appended")
+	assert.Contains(t, string(out), "id: test")
+}
@@ -1,6 +1,20 @@
 package monny
 
-import "strings"
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultRedactEnv matches the names of environment variables that commonly hold secrets.  Values of these variables
+// are always redacted in an environment dump.
+var defaultRedactEnv = regexp.MustCompile(`(?i)(token|secret|passw(or)?d|key|credential|auth|cookie|session)`)
+
+// redacted replaces the value of a redacted environment variable
+const redacted = "[REDACTED]"
 
 func envToKeyValue(env map[string]string) []string {
 	var out []string
@@ -9,3 +23,76 @@ func envToKeyValue(env map[string]string) []string {
 	}
 	return out
 }
+
+// isSecretEnv returns true when the name of the environment variable matches the default or one of the patterns
+func isSecretEnv(name string, patterns []*regexp.Regexp) bool {
+	if defaultRedactEnv.MatchString(name) {
+		return true
+	}
+	for _, p := range patterns {
+		if p.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactEnv returns a sorted copy of env in KEY=VALUE format with the value of each variable whose name matches the
+// default or one of the patterns replaced
+func redactEnv(env []string, patterns []*regexp.Regexp) []string {
+	out := make([]string, 0, len(env))
+	for _, kv := range env {
+		name := strings.SplitN(kv, "=", 2)[0]
+		if isSecretEnv(name, patterns) {
+			kv = name + "=" + redacted
+		}
+		out = append(out, kv)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// dumpEnv writes the redacted environment of the process to the env dump file, one variable per line.  The file
+// is only readable by the user because values not matching a redaction pattern are written as is.
+func (c *Command) dumpEnv() error {
+	env := c.env
+	if env == nil {
+		env = os.Environ()
+	}
+	lines := redactEnv(env, c.Config.redactEnv)
+	data := []byte(strings.Join(lines, "\n") + "\n")
+	if err := ioutil.WriteFile(c.Config.EnvDumpFile, data, 0600); err != nil {
+		return fmt.Errorf("could not write environment to %s: %v", c.Config.EnvDumpFile, err)
+	}
+	return nil
+}
+
+// snapshotEnv returns the allowlisted environment variables of the process that are included in reports.  Variables
+// that may hold secrets are skipped with a warning.  Allowlisted variables that are not set are left out.
+func (c *Command) snapshotEnv() map[string]string {
+	if len(c.Config.IncludeEnv) == 0 {
+		return nil
+	}
+	env := c.env
+	if env == nil {
+		env = os.Environ()
+	}
+	values := make(map[string]string)
+	for _, kv := range env {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 {
+			values[parts[0]] = parts[1]
+		}
+	}
+	snapshot := make(map[string]string)
+	for _, name := range c.Config.IncludeEnv {
+		if isSecretEnv(name, c.Config.redactEnv) {
+			c.log.warnf("not including environment variable %s in reports, the name matches a secret pattern", name)
+			continue
+		}
+		if v, ok := values[name]; ok {
+			snapshot[name] = v
+		}
+	}
+	return snapshot
+}
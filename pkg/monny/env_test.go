@@ -1,8 +1,15 @@
 package monny
 
 import (
-	"github.com/stretchr/testify/assert"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestEnvFormatter(t *testing.T) {
@@ -20,3 +27,115 @@ func TestEnvFormatter(t *testing.T) {
 		})
 	}
 }
+
+func TestRedactEnv(t *testing.T) {
+	tt := []struct {
+		Name     string
+		In       []string
+		Patterns []string
+		Out      []string
+	}{
+		{Name: "no secrets", In: []string{"PATH=/bin", "HOME=/root"}, Out: []string{"HOME=/root", "PATH=/bin"}},
+		{Name: "default", In: []string{"API_TOKEN=abc", "AWS_SECRET_ACCESS_KEY=def", "DB_PASSWORD=ghi", "HOME=/root"}, Out: []string{"API_TOKEN=[REDACTED]", "AWS_SECRET_ACCESS_KEY=[REDACTED]", "DB_PASSWORD=[REDACTED]", "HOME=/root"}},
+		{Name: "pattern", In: []string{"DATABASE_URL=postgres://user:pass@db", "HOME=/root"}, Patterns: []string{"^DATABASE_"}, Out: []string{"DATABASE_URL=[REDACTED]", "HOME=/root"}},
+		{Name: "value with equals", In: []string{"OPTS=a=b"}, Out: []string{"OPTS=a=b"}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			var patterns []*regexp.Regexp
+			for _, p := range tc.Patterns {
+				patterns = append(patterns, regexp.MustCompile(p))
+			}
+			assert.Equal(t, tc.Out, redactEnv(tc.In, patterns))
+		})
+	}
+}
+
+func TestDumpEnvOnFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monny")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.Setenv("MONNY_TEST_API_TOKEN", "token-value")
+	os.Setenv("MONNY_TEST_DB_URL", "postgres://user:pass@db")
+	os.Setenv("MONNY_TEST_VISIBLE", "visible-value")
+	defer func() {
+		os.Unsetenv("MONNY_TEST_API_TOKEN")
+		os.Unsetenv("MONNY_TEST_DB_URL")
+		os.Unsetenv("MONNY_TEST_VISIBLE")
+	}()
+
+	tt := []struct {
+		Name    string
+		Command string
+		Written bool
+		Err     error
+	}{
+		{Name: "failure", Command: "exit 1", Written: true, Err: ErrChildFailed{Code: 1}},
+		{Name: "success", Command: "exit 0", Written: false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.Name+".env")
+			c, errs := New([]string{"sh", "-c", tc.Command}, ID("test"), ReportFile(filepath.Join(dir, "reports.jsonl")), DumpEnvOnFailure(path),
+				RedactEnv("_DB_URL$"), logOut(&closeBuffer{}), logErr(&closeBuffer{}))
+			if len(errs) > 0 {
+				t.Fatalf("unexpected error in config: %s", errs)
+			}
+			assert.Equal(t, tc.Err, c.Exec())
+			assert.NoError(t, c.Wait())
+
+			data, err := ioutil.ReadFile(path)
+			if !tc.Written {
+				assert.True(t, os.IsNotExist(err), "env should only be written on failure")
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error reading env dump: %v", err)
+			}
+			lines := strings.Split(string(data), "\n")
+			assert.Contains(t, lines, "MONNY_TEST_API_TOKEN=[REDACTED]")
+			assert.Contains(t, lines, "MONNY_TEST_DB_URL=[REDACTED]")
+			assert.Contains(t, lines, "MONNY_TEST_VISIBLE=visible-value")
+			assert.NotContains(t, string(data), "token-value")
+			assert.NotContains(t, string(data), "user:pass")
+			assert.Contains(t, c.Messages, "environment of the failed process written to "+path)
+		})
+	}
+}
+
+func TestIncludeEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monny")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.Setenv("MONNY_TEST_GIT_SHA", "abc123")
+	os.Setenv("MONNY_TEST_API_TOKEN", "token-value")
+	os.Setenv("MONNY_TEST_DB_URL", "postgres://user:pass@db")
+	defer func() {
+		os.Unsetenv("MONNY_TEST_GIT_SHA")
+		os.Unsetenv("MONNY_TEST_API_TOKEN")
+		os.Unsetenv("MONNY_TEST_DB_URL")
+	}()
+
+	var log bytes.Buffer
+	path := filepath.Join(dir, "reports.jsonl")
+	c, errs := New([]string{"sh", "-c", "exit 1"}, ID("test"), ReportFile(path), Logger(&log), RedactEnv("_DB_URL$"),
+		IncludeEnv("MONNY_TEST_GIT_SHA,MONNY_TEST_API_TOKEN,MONNY_TEST_DB_URL,MONNY_TEST_UNSET"), logOut(&closeBuffer{}), logErr(&closeBuffer{}))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	assert.Equal(t, ErrChildFailed{Code: 1}, c.Exec())
+	assert.NoError(t, c.Wait())
+
+	reports := readReportFile(t, path)
+	if assert.Len(t, reports, 1) {
+		assert.Equal(t, map[string]string{"MONNY_TEST_GIT_SHA": "abc123"}, reports[0].Env)
+	}
+	assert.Contains(t, log.String(), "[warn] not including environment variable MONNY_TEST_API_TOKEN in reports")
+	assert.Contains(t, log.String(), "[warn] not including environment variable MONNY_TEST_DB_URL in reports")
+}
@@ -1,7 +1,10 @@
 package monny
 
 import (
+	"fmt"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/stvp/rollbar"
 )
@@ -33,7 +36,110 @@ func init() {
 // ReportError will send the result of an unexpected error to Rollbar
 // to improve the quality of the client.  Data is anonymous.
 func (e errorService) ReportError(err error) {
-	if !SuppressErrorReporting {
+	if err != nil && !SuppressErrorReporting {
 		rollbar.Error(rollbar.ERR, err)
 	}
 }
+
+// errorCategory identifies where in the client an error occurred
+type errorCategory string
+
+const (
+	// errorSink is a failure to write process output or input
+	errorSink errorCategory = "sink"
+	// errorScan is a failure to read process output, such as a line that is too long
+	errorScan errorCategory = "scan"
+	// errorMarshal is a failure to serialize part of a report
+	errorMarshal errorCategory = "marshal"
+	// errorSend is a failure to send a report
+	errorSend errorCategory = "send"
+	// errorInternal is any other unexpected error in the client
+	errorInternal errorCategory = "internal"
+)
+
+// clientError is an unexpected error in the client
+type clientError struct {
+	At       time.Time
+	Category errorCategory
+	Err      error
+}
+
+func (e clientError) String() string {
+	return fmt.Sprintf("%s %s error: %v", e.At.UTC().Format(time.RFC3339), e.Category, e.Err)
+}
+
+// errorCollector records unexpected errors in the client and passes each one to an external reporter.  Repeated
+// errors in the same category are only recorded once.  It is safe for concurrent use and a nil collector discards
+// all errors.
+type errorCollector struct {
+	mutex    sync.Mutex
+	reporter ErrorReporter
+	errors   []clientError
+	seen     map[string]bool
+}
+
+func newErrorCollector(reporter ErrorReporter) *errorCollector {
+	return &errorCollector{
+		reporter: reporter,
+		seen:     make(map[string]bool),
+	}
+}
+
+// ReportError records err as an internal error
+func (e *errorCollector) ReportError(err error) {
+	e.collect(errorInternal, err)
+}
+
+// collect records err and returns true if it has not been recorded before
+func (e *errorCollector) collect(category errorCategory, err error) (clientError, bool) {
+	if e == nil || err == nil {
+		return clientError{}, false
+	}
+	ce := clientError{At: time.Now(), Category: category, Err: err}
+	key := string(category) + ":" + err.Error()
+
+	e.mutex.Lock()
+	if e.seen[key] {
+		e.mutex.Unlock()
+		return ce, false
+	}
+	e.seen[key] = true
+	e.errors = append(e.errors, ce)
+	e.mutex.Unlock()
+
+	if e.reporter != nil {
+		e.reporter.ReportError(err)
+	}
+	return ce, true
+}
+
+// Errors returns the errors recorded in the order they occurred
+func (e *errorCollector) Errors() []clientError {
+	if e == nil {
+		return nil
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]clientError(nil), e.errors...)
+}
+
+// reportError records an unexpected error in the messages sent with the next report
+func (c *Command) reportError(category errorCategory, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.recordError(category, err)
+}
+
+// recordError is like reportError for callers that already hold the command mutex
+func (c *Command) recordError(category errorCategory, err error) {
+	if ce, ok := c.errors.collect(category, err); ok {
+		c.Messages = append(c.Messages, ce.String())
+	}
+}
+
+// printErrors writes the errors recorded while running the command to stderr
+func (c *Command) printErrors() {
+	for _, ce := range c.errors.Errors() {
+		fmt.Fprintf(c.err, "monny: %s\n", ce)
+	}
+}
@@ -0,0 +1,109 @@
+package monny
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+// countContaining returns the number of messages that contain s
+func countContaining(messages []string, s string) int {
+	n := 0
+	for _, m := range messages {
+		if strings.Contains(m, s) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestErrorCollector(t *testing.T) {
+	rec := &recordingErrors{}
+	e := newErrorCollector(rec)
+
+	_, ok := e.collect(errorSink, fmt.Errorf("sink closed"))
+	assert.True(t, ok)
+	_, ok = e.collect(errorSink, fmt.Errorf("sink closed"))
+	assert.False(t, ok)
+	// the same error in a different category is recorded separately
+	_, ok = e.collect(errorSend, fmt.Errorf("sink closed"))
+	assert.True(t, ok)
+	e.ReportError(fmt.Errorf("unexpected"))
+	_, ok = e.collect(errorSend, nil)
+	assert.False(t, ok)
+
+	errs := e.Errors()
+	if assert.Len(t, errs, 3) {
+		assert.Equal(t, errorSink, errs[0].Category)
+		assert.Equal(t, errorSend, errs[1].Category)
+		assert.Equal(t, errorInternal, errs[2].Category)
+		assert.False(t, errs[0].At.IsZero())
+		assert.Contains(t, errs[0].String(), "sink error: sink closed")
+	}
+	assert.Len(t, rec.errors, 3)
+
+	var nilCollector *errorCollector
+	nilCollector.ReportError(fmt.Errorf("discarded"))
+	assert.Len(t, nilCollector.Errors(), 0)
+}
+
+func TestClientErrors(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Options  []ConfigOption
+		Run      func(c *Command)
+		Message  string
+		InReport bool
+	}{
+		{Name: "sink", Options: []ConfigOption{logOut(failWriter{})}, Run: func(c *Command) {
+			c.Exec()
+		}, Message: "sink error: error writing log line to stdout: sink closed", InReport: true},
+		{Name: "marshal", Run: func(c *Command) {
+			c.RuleMatches = []RuleMatch{{Time: time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC), Line: "ERROR"}}
+			c.send(proto.Killed)
+			c.send(proto.Killed)
+		}, Message: "marshal error: json: error calling MarshalJSON", InReport: true},
+		{Name: "send", Options: []ConfigOption{ReportFile(filepath.Join("does", "not", "exist", "reports.jsonl"))}, Run: func(c *Command) {
+			c.send(proto.Killed)
+			c.send(proto.Killed)
+		}, Message: "send error: could not open report file"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "monny")
+			if err != nil {
+				t.Fatalf("unexpected error creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "reports.jsonl")
+
+			stderr := &closeBuffer{}
+			opts := append([]ConfigOption{ID("test"), ReportFile(path), Verbose(), logOut(&closeBuffer{}), logErr(stderr)}, tc.Options...)
+			c, errs := New([]string{"sh", "-c", "echo out; echo more"}, opts...)
+			if len(errs) > 0 {
+				t.Fatalf("unexpected error in config: %s", errs)
+			}
+			tc.Run(c)
+			assert.NoError(t, c.Wait())
+
+			assert.Equal(t, 1, countContaining(c.Messages, tc.Message), "expected one message in %v", c.Messages)
+			assert.Equal(t, 1, strings.Count(stderr.String(), tc.Message), "expected error printed once in %s", stderr.String())
+			if !tc.InReport {
+				return
+			}
+			reports := readReportFile(t, c.Config.ReportFile)
+			if assert.NotEmpty(t, reports) {
+				for _, r := range reports {
+					assert.Equal(t, 1, countContaining(r.Messages, tc.Message), "expected one message in report %v", r.Messages)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package monny
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Errors returned by Exec when the process did not complete successfully.  Use errors.As to distinguish a process that
+// failed on its own from one that monny killed.  Any other error returned by Exec is an error in monny itself.
+
+// ErrChildFailed is returned when the process exits with a non-zero status.  Code is -1 when the process was terminated
+// by a signal or its exit status could not be determined.
+type ErrChildFailed struct {
+	Code int
+}
+
+func (e ErrChildFailed) Error() string {
+	if e.Code < 0 {
+		return "process failed without an exit code"
+	}
+	return fmt.Sprintf("process failed with exit status %d", e.Code)
+}
+
+// ErrKilledOnTimeout is returned when the process is killed for running longer than the kill timeout
+type ErrKilledOnTimeout struct {
+	Timeout time.Duration
+}
+
+func (e ErrKilledOnTimeout) Error() string {
+	return fmt.Sprintf("process killed after running longer than %s", e.Timeout)
+}
+
+// ErrKilledOnMemory is returned when the process is killed for using more memory than the kill limit.  Memory and
+// Limit are in kilobytes.
+type ErrKilledOnMemory struct {
+	Memory uint64
+	Limit  uint64
+}
+
+func (e ErrKilledOnMemory) Error() string {
+	return fmt.Sprintf("process killed using %dK of memory, exceeding the limit of %dK", e.Memory, e.Limit)
+}
+
+// processError returns true for errors that describe how the process ended rather than an error in monny
+func processError(err error) bool {
+	var failed ErrChildFailed
+	var timeout ErrKilledOnTimeout
+	var memory ErrKilledOnMemory
+	return errors.As(err, &failed) || errors.As(err, &timeout) || errors.As(err, &memory)
+}
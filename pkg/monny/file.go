@@ -0,0 +1,136 @@
+package monny
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/pb"
+	"github.com/BTBurke/monny/pkg/proto"
+	"github.com/golang/protobuf/jsonpb"
+)
+
+// fileSender implements the sender interface to append reports to a local file as JSON Lines, one report per line.
+// It is used in place of the report server for environments that cannot reach a server.  The file is locked while
+// writing so that multiple monny processes can share the same file.
+type fileSender struct {
+	path   string
+	errors ErrorReporter
+	wg     sync.WaitGroup
+	mutex  sync.Mutex
+	file   *os.File
+}
+
+func (s *fileSender) create(c *Command, reason proto.ReportReason) *pb.Report {
+	return reportFromCommand(c, reason, func(err error) { c.recordError(errorMarshal, err) })
+}
+
+// sendBackground appends the report to the file as a single line
+func (s *fileSender) sendBackground(report *pb.Report, result chan error, cancel chan bool) {
+	if report == nil {
+		result <- fmt.Errorf("no report created")
+		return
+	}
+	s.wg.Add(1)
+	defer s.wg.Done()
+
+	select {
+	case result <- s.write(report):
+	case <-cancel:
+	}
+}
+
+// sendBatch appends each of the reports to the file
+func (s *fileSender) sendBatch(reports []*pb.Report) error {
+	s.wg.Add(1)
+	defer s.wg.Done()
+	for _, report := range reports {
+		if err := s.write(report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fileSender) write(report *pb.Report) error {
+	var line bytes.Buffer
+	if err := (&jsonpb.Marshaler{OrigName: true}).Marshal(&line, report); err != nil {
+		return fmt.Errorf("could not serialize report: %v", err)
+	}
+	line.WriteByte('\n')
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.file == nil {
+		f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("could not open report file: %v", err)
+		}
+		s.file = f
+	}
+	if err := lockFile(s.file); err != nil {
+		return fmt.Errorf("could not lock report file: %v", err)
+	}
+	defer unlockFile(s.file)
+	if _, err := s.file.Write(line.Bytes()); err != nil {
+		return fmt.Errorf("could not write report to file: %v", err)
+	}
+	return nil
+}
+
+// wait blocks until all reports are written, then syncs and closes the file
+func (s *fileSender) wait() {
+	s.wg.Wait()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.file == nil {
+		return
+	}
+	if err := s.file.Sync(); err != nil {
+		s.errors.ReportError(fmt.Errorf("could not sync report file: %v", err))
+	}
+	if err := s.file.Close(); err != nil {
+		s.errors.ReportError(fmt.Errorf("could not close report file: %v", err))
+	}
+	s.file = nil
+}
+
+// fileWatch is a glob of files that are expected to be created or modified at least once per interval while the
+// process is running
+type fileWatch struct {
+	Glob     string
+	Interval time.Duration
+}
+
+// found returns true if a file matching the glob was modified within the interval before now
+func (w fileWatch) found(now time.Time) (bool, error) {
+	matches, err := filepath.Glob(w.Glob)
+	if err != nil {
+		return false, err
+	}
+	for _, m := range matches {
+		finfo, err := os.Stat(m)
+		if err != nil {
+			continue
+		}
+		if now.Sub(finfo.ModTime()) <= w.Interval {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// watchInterval returns the shortest interval of the watched files, which is how often they are checked
+func watchInterval(watches []fileWatch) time.Duration {
+	var min time.Duration
+	for _, w := range watches {
+		if min == 0 || w.Interval < min {
+			min = w.Interval
+		}
+	}
+	return min
+}
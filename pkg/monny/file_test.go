@@ -0,0 +1,133 @@
+package monny
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/pb"
+	"github.com/BTBurke/monny/pkg/proto"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/stretchr/testify/assert"
+)
+
+func readReportFile(t *testing.T, path string) []*pb.Report {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error opening report file: %v", err)
+	}
+	defer f.Close()
+
+	var reports []*pb.Report
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		rpt := &pb.Report{}
+		if err := jsonpb.UnmarshalString(scanner.Text(), rpt); err != nil {
+			t.Fatalf("report is not a single line of JSON: %v", err)
+		}
+		reports = append(reports, rpt)
+	}
+	return reports
+}
+
+func TestReportFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monny")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "reports.jsonl")
+
+	r, w := io.Pipe()
+	go func() {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(r)
+		r.Close()
+	}()
+	c, errs := New([]string{"echo", "test"}, ID("test"), ReportFile(path), logErr(w), logOut(w))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	assert.NoError(t, c.Exec())
+	assert.NoError(t, c.Wait())
+
+	reports := readReportFile(t, path)
+	if assert.Len(t, reports, 1) {
+		assert.Equal(t, "test", reports[0].Id)
+		assert.Equal(t, pb.ReportReason(proto.Success), reports[0].ReportReason)
+		assert.Equal(t, []string{"test"}, reports[0].Stdout)
+	}
+}
+
+func TestReportFileConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monny")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "reports.jsonl")
+
+	// separate senders simulate separate monny processes sharing the file
+	line := strings.Repeat("x", 8*1024)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := &Command{Config: Config{ID: "test"}, Stdout: []string{line}}
+			s := &fileSender{path: path, errors: mockError{}}
+			for j := 0; j < 25; j++ {
+				result := make(chan error, 1)
+				s.sendBackground(s.create(c, proto.Alert), result, make(chan bool, 1))
+				assert.NoError(t, <-result)
+			}
+			s.wait()
+		}()
+	}
+	wg.Wait()
+
+	reports := readReportFile(t, path)
+	assert.Len(t, reports, 100)
+	for _, rpt := range reports {
+		assert.Equal(t, []string{line}, rpt.Stdout)
+	}
+}
+
+func TestCreatesWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monny")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "reports.jsonl")
+
+	// the process writes a new partition file faster than the interval, so only the missing glob is reported
+	partitions := filepath.Join(dir, "part-*.csv")
+	missing := filepath.Join(dir, "missing-*.csv")
+	script := "for i in 1 2 3 4 5 6; do touch " + filepath.Join(dir, "part-$i.csv") + "; sleep 0.1; done"
+	c, errs := New([]string{"sh", "-c", script}, ID("test"), ReportFile(path), CreatesWatch(partitions, 300*time.Millisecond),
+		CreatesWatch(missing, 200*time.Millisecond), logOut(&closeBuffer{}), logErr(&closeBuffer{}))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	assert.NoError(t, c.Exec())
+	assert.NoError(t, c.Wait())
+
+	reports := readReportFile(t, path)
+	var reasons []pb.ReportReason
+	for _, r := range reports {
+		reasons = append(reasons, r.ReportReason)
+	}
+	// the missing file is reported once while the process runs, followed by the success report
+	assert.Equal(t, []pb.ReportReason{pb.ReportReason(proto.FileNotCreated), pb.ReportReason(proto.Success)}, reasons)
+	assert.Contains(t, c.Messages, "no file matching "+missing+" created in the last 200ms")
+	assert.True(t, c.Success)
+}
@@ -0,0 +1,14 @@
+// +build windows
+
+package monny
+
+import "os"
+
+// lockFile is not supported on windows.  Each report is written in a single call to the file opened in append mode.
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
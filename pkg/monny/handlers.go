@@ -1,6 +1,7 @@
 package monny
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -13,60 +14,112 @@ import (
 // ProcessHandlers is an interface for methods called based on the current
 // status of the process
 type ProcessHandlers interface {
-	Finished(c *Command, cmd *exec.Cmd) error
+	Finished(c *Command, cmd *exec.Cmd, waitErr error) error
 	Signal(c *Command, cmd *exec.Cmd, sig os.Signal) error
 	Timeout(c *Command, cmd *exec.Cmd) error
 	TimeWarning(c *Command) error
 	CheckMemory(c *Command, cmd *exec.Cmd) error
 	KillOnHighMemory(c *Command, cmd *exec.Cmd) error
+	CheckFiles(c *Command) error
+	Heartbeat(c *Command, cmd *exec.Cmd) error
 }
 
 type handler struct{}
 
-// Finished is called when the process ends and determines whether the process completed successfully.
-// It also checks that any artifacts expected to be created exist.
-func (h handler) Finished(c *Command, cmd *exec.Cmd) error {
+// memoryFailures is the number of consecutive failures to read process memory before memory monitoring is
+// considered degraded.  A single failure is expected if the process exits between checks.
+const memoryFailures = 3
+
+// readMemory returns the memory used by a process, replaced in tests to simulate an inaccessible /proc
+var readMemory = calculateMemory
+
+// Finished is called when the process ends and determines whether the process completed successfully from the
+// error returned by waiting for it.  It also checks that any artifacts expected to be created exist.  Returns
+// ErrChildFailed when the process exits with a non-zero status.
+func (h handler) Finished(c *Command, cmd *exec.Cmd, waitErr error) error {
 	c.mutex.Lock()
-	c.Finish = time.Now()
-	c.Duration = c.Finish.Sub(c.Start)
+	c.computeDuration()
 	c.mutex.Unlock()
 
-	switch cmd.ProcessState.Success() {
-	case true:
+	var exitErr *exec.ExitError
+	var failed error
+	switch {
+	case waitErr == nil:
+		c.log.debugf("process finished: exit status 0")
 		c.mutex.Lock()
 		c.Success = true
+		c.ExitCode = 0
 		c.ExitCodeValid = true
 		c.ReportReason = proto.Success
 		c.mutex.Unlock()
-		go c.report.Send(c, proto.Success)
+		c.send(proto.Success)
 	default:
-		sysinfo, ok := cmd.ProcessState.Sys().(syscall.WaitStatus)
+		c.log.debugf("process finished: %v", waitErr)
 		c.mutex.Lock()
-		if ok {
-			c.ExitCode = int32(sysinfo.ExitStatus())
+		switch {
+		case errors.As(waitErr, &exitErr) && exitErr.ExitCode() >= 0:
+			c.ExitCode = int32(exitErr.ExitCode())
 			c.ExitCodeValid = true
+			failed = ErrChildFailed{Code: exitErr.ExitCode()}
+		case errors.As(waitErr, &exitErr):
+			// a process terminated by a signal has no exit code
+			c.Messages = append(c.Messages, fmt.Sprintf("process terminated without an exit code: %s", exitErr))
+			failed = ErrChildFailed{Code: -1}
+		default:
+			c.Messages = append(c.Messages, fmt.Sprintf("could not determine the exit status of the process: %v", waitErr))
+			failed = ErrChildFailed{Code: -1}
 		}
 		c.ReportReason = proto.Failure
 		c.Success = false
 		c.mutex.Unlock()
-		go c.report.Send(c, proto.Failure)
+		if len(c.Config.EnvDumpFile) > 0 {
+			switch err := c.dumpEnv(); {
+			case err != nil:
+				c.reportError(errorInternal, err)
+			default:
+				c.mutex.Lock()
+				c.Messages = append(c.Messages, fmt.Sprintf("environment of the failed process written to %s", c.Config.EnvDumpFile))
+				c.mutex.Unlock()
+			}
+		}
+		c.send(proto.Failure)
 	}
 	handleFileCreation(c)
-	return nil
+	if err := c.writeMetricsSnapshot(); err != nil {
+		c.reportError(errorInternal, err)
+	}
+	return failed
 }
 
-// Signal is called when a signal is trapped.  The signal is passed on to the child process
-// and a report is sent.
-func (h handler) Signal(c *Command, cmd *exec.Cmd, sig os.Signal) error {
-	c.mutex.Lock()
+// computeDuration sets the time the process finished and its duration.  It must be called with the mutex held.
+func (c *Command) computeDuration() {
 	c.Finish = time.Now()
 	c.Duration = c.Finish.Sub(c.Start)
+}
+
+// killSignal returns true for signals that stop the process and send a report that it was killed.  Other signals,
+// such as SIGHUP to reload configuration, are passed to the process while monny continues to monitor it.
+func killSignal(sig os.Signal) bool {
+	return sig == os.Interrupt || sig == syscall.SIGTERM || sig == os.Kill
+}
+
+// Signal is called when a signal is trapped.  The signal is passed on to the child process.  For an interrupt or
+// terminate signal, a report is sent that the process was killed with the signal that arrived.
+func (h handler) Signal(c *Command, cmd *exec.Cmd, sig os.Signal) error {
+	if !killSignal(sig) {
+		c.log.infof("received signal %s, passing to process", sig)
+		return cmd.Process.Signal(sig)
+	}
+	c.log.infof("received signal %s, passing to process and sending report", sig)
+	c.mutex.Lock()
+	c.computeDuration()
 	c.Killed = true
 	c.KillReason = proto.Signal
 	c.ReportReason = proto.Killed
+	c.Messages = append(c.Messages, fmt.Sprintf("monny received signal %s and passed it to the process", sig))
 	c.mutex.Unlock()
 
-	go c.report.Send(c, proto.Killed)
+	c.send(proto.Killed)
 	if err := cmd.Process.Signal(sig); err != nil {
 		return err
 	}
@@ -75,26 +128,43 @@ func (h handler) Signal(c *Command, cmd *exec.Cmd, sig os.Signal) error {
 }
 
 // Timeout is called if the process runs longer than the kill timeout setting.
-// A report is sent and the process is killed.
+// A report is sent and the process is killed.  Returns ErrKilledOnTimeout once the process is signaled.
 func (h handler) Timeout(c *Command, cmd *exec.Cmd) error {
+	c.log.infof("killing process: running longer than kill timeout of %s", c.Config.KillTimeout)
 	c.mutex.Lock()
 	c.Killed = true
 	c.KillReason = proto.Timeout
-	c.Finish = time.Now()
-	c.Duration = c.Start.Sub(c.Finish)
+	c.computeDuration()
 	c.ReportReason = proto.Killed
 	c.mutex.Unlock()
 
-	go c.report.Send(c, proto.Killed)
+	c.send(proto.Killed)
 	if err := cmd.Process.Signal(os.Kill); err != nil {
 		return err
 	}
 	//fmt.Printf("\n\nProcess timeout\n")
+	return ErrKilledOnTimeout{Timeout: c.Config.KillTimeout}
+}
+
+// Heartbeat is called every heartbeat interval while a daemon runs.  It sends a heartbeat report with the uptime and
+// current memory of the process so that the server can alert when heartbeats stop.
+func (h handler) Heartbeat(c *Command, cmd *exec.Cmd) error {
+	mem, err := readMemory(cmd.Process.Pid)
+	switch {
+	case err != nil:
+		c.log.debugf("could not read memory of process %d for heartbeat: %v", cmd.Process.Pid, err)
+	default:
+		c.mutex.Lock()
+		c.memory = mem
+		c.mutex.Unlock()
+	}
+	c.send(proto.Heartbeat)
 	return nil
 }
 
 // TimeWarning is called and a report is sent when the process runs longer than the time warning.
 func (h handler) TimeWarning(c *Command) error {
+	c.log.debugf("process running longer than %s", c.Config.NotifyTimeout)
 	if c.timeWarnSent {
 		return nil
 	}
@@ -103,21 +173,36 @@ func (h handler) TimeWarning(c *Command) error {
 	c.timeWarnSent = true
 	c.mutex.Unlock()
 
-	go c.report.Send(c, proto.TimeWarning)
+	c.send(proto.TimeWarning)
 
 	return nil
 }
 
 // CheckMemory is called by default every second for short running processes and every 30 sec
 // for daemon processes.  If memory warnings or memory kill features are enabled, reports are
-// generated when memory exceeds the setpoint (Not available on Windows)
+// generated when memory exceeds the setpoint (Not available on Windows).  If memory can not be
+// read several times in a row, a message is added to the report that memory monitoring is degraded.
 func (h handler) CheckMemory(c *Command, cmd *exec.Cmd) error {
-	mem := calculateMemory(cmd.Process.Pid)
+	mem, err := readMemory(cmd.Process.Pid)
+	if err != nil {
+		c.log.debugf("could not read memory of process %d: %v", cmd.Process.Pid, err)
+		c.memFailures++
+		if c.memFailures >= memoryFailures && !c.memDegraded {
+			c.memDegraded = true
+			c.log.warnf("memory monitoring is degraded after %d failures: %v", c.memFailures, err)
+			c.mutex.Lock()
+			c.Messages = append(c.Messages, fmt.Sprintf("memory monitoring is degraded, memory warnings and kills are disabled: %v", err))
+			c.mutex.Unlock()
+		}
+		return nil
+	}
+	c.memFailures = 0
+	c.mutex.Lock()
+	c.memory = mem
 	if mem > c.MaxMemory {
-		c.mutex.Lock()
 		c.MaxMemory = mem
-		c.mutex.Unlock()
 	}
+	c.mutex.Unlock()
 	if c.Config.MemoryWarn > 0 && mem >= c.Config.MemoryWarn {
 		if !c.memWarnSent {
 			c.mutex.Lock()
@@ -125,30 +210,33 @@ func (h handler) CheckMemory(c *Command, cmd *exec.Cmd) error {
 			c.memWarnSent = true
 			c.mutex.Unlock()
 
-			go c.report.Send(c, proto.MemoryWarning)
+			c.send(proto.MemoryWarning)
 		}
 	}
 	if c.Config.MemoryKill > 0 && mem >= c.Config.MemoryKill {
+		c.log.infof("process memory %dK exceeds kill limit of %dK", mem, c.Config.MemoryKill)
 		return fmt.Errorf("high memory kill")
 	}
 	return nil
 }
 
-// KillOnHighMemory is called when the memory exceeds the kill setpoint.
+// KillOnHighMemory is called when the memory exceeds the kill setpoint.  Returns ErrKilledOnMemory once the process is
+// signaled.
 func (h handler) KillOnHighMemory(c *Command, cmd *exec.Cmd) error {
+	c.log.infof("killing process: memory exceeds kill limit")
 	c.mutex.Lock()
 	c.Killed = true
 	c.KillReason = proto.Memory
-	c.Finish = time.Now()
-	c.Duration = c.Finish.Sub(c.Start)
+	c.computeDuration()
 	c.ReportReason = proto.Killed
+	mem := c.memory
 	c.mutex.Unlock()
 
-	go c.report.Send(c, proto.Killed)
+	c.send(proto.Killed)
 	if err := cmd.Process.Signal(os.Kill); err != nil {
 		return err
 	}
-	return nil
+	return ErrKilledOnMemory{Memory: mem, Limit: c.Config.MemoryKill}
 }
 
 // handleFileCreation is called on process completion and checks for the existence of
@@ -158,12 +246,13 @@ func handleFileCreation(c *Command) {
 		finfo, err := os.Stat(f)
 		switch {
 		case os.IsNotExist(err):
+			c.log.debugf("expected file not created: %s", f)
 			c.mutex.Lock()
 			c.Success = false
 			c.Messages = append(c.Messages, fmt.Sprintf("file not created: %s", f))
 			c.ReportReason = proto.FileNotCreated
 			c.mutex.Unlock()
-			go c.report.Send(c, proto.FileNotCreated)
+			c.send(proto.FileNotCreated)
 		case err == nil:
 			c.mutex.Lock()
 			c.Created = append(c.Created, File{
@@ -178,3 +267,29 @@ func handleFileCreation(c *Command) {
 	}
 	return
 }
+
+// CheckFiles is called periodically while the process runs to check that a file matching each glob watched with
+// CreatesWatch was created or modified within its interval.  A report is sent the first time a watched file is
+// missing and again only after a matching file has appeared.
+func (h handler) CheckFiles(c *Command) error {
+	now := time.Now()
+	for _, w := range c.Config.CreatesWatch {
+		found, err := w.found(now)
+		if err != nil {
+			return err
+		}
+		c.mutex.Lock()
+		missing := c.filesMissing[w.Glob]
+		c.filesMissing[w.Glob] = !found
+		if found || missing {
+			c.mutex.Unlock()
+			continue
+		}
+		c.log.infof("no file matching %s created in the last %s", w.Glob, w.Interval)
+		c.Messages = append(c.Messages, fmt.Sprintf("no file matching %s created in the last %s", w.Glob, w.Interval))
+		c.ReportReason = proto.FileNotCreated
+		c.mutex.Unlock()
+		c.send(proto.FileNotCreated)
+	}
+	return nil
+}
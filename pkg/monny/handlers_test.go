@@ -1,13 +1,16 @@
 package monny
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/BTBurke/monny/pkg/pb"
 	"github.com/BTBurke/monny/pkg/proto"
 	"github.com/stretchr/testify/mock"
 )
@@ -21,6 +24,11 @@ func (m *mockRep) Send(c *Command, reason proto.ReportReason) {
 	return
 }
 
+func (m *mockRep) SendBatch(reports []*pb.Report) error {
+	m.Called()
+	return nil
+}
+
 func (m *mockRep) Wait() error {
 	return nil
 }
@@ -35,11 +43,12 @@ func TestSuccessHandler(t *testing.T) {
 	mocks.On("Send").Return()
 
 	cmd := exec.Command("sleep", "1")
-	if err := cmd.Run(); err != nil {
-		t.Fatalf("unexpected error running command: %s", err)
+	waitErr := cmd.Run()
+	if waitErr != nil {
+		t.Fatalf("unexpected error running command: %s", waitErr)
 	}
 	h := handler{}
-	errHandle := h.Finished(c, cmd)
+	errHandle := h.Finished(c, cmd, waitErr)
 
 	assert.Nil(t, errHandle)
 	assert.Equal(t, proto.Success, c.ReportReason)
@@ -72,15 +81,67 @@ func TestFailureHandler(t *testing.T) {
 		t.Fatalf("unexpected error closing file: %s", err)
 	}
 	cmd := exec.Command(f.Name())
-	cmd.Run()
+	waitErr := cmd.Run()
 
 	h := handler{}
-	errHandle := h.Finished(c, cmd)
+	errHandle := h.Finished(c, cmd, waitErr)
 
-	assert.Nil(t, errHandle)
+	assert.Equal(t, ErrChildFailed{Code: 1}, errHandle)
 	assert.Equal(t, proto.Failure, c.ReportReason)
 	assert.NotZero(t, c.Duration)
 	assert.False(t, c.Success)
+	assert.True(t, c.ExitCodeValid)
+	assert.Equal(t, int32(1), c.ExitCode)
+}
+
+func TestFinishedExitStatus(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Cmd      []string
+		Success  bool
+		ExitCode int32
+		Valid    bool
+		Message  string
+		Err      error
+	}{
+		{Name: "fast exit", Cmd: []string{"true"}, Success: true, ExitCode: 0, Valid: true},
+		{Name: "nonzero exit", Cmd: []string{"sh", "-c", "exit 3"}, ExitCode: 3, Valid: true, Err: ErrChildFailed{Code: 3}},
+		{Name: "signaled", Cmd: []string{"sh", "-c", "kill -9 $$"}, Message: "process terminated without an exit code: signal: killed", Err: ErrChildFailed{Code: -1}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			c, errs := New(tc.Cmd, ID("test"), logOut(&closeBuffer{}), logErr(&closeBuffer{}))
+			if len(errs) != 0 {
+				t.Fatalf("unexpected error creating command: %s", errs)
+			}
+			c.report = new(mockReport)
+			assert.Equal(t, tc.Err, c.Exec())
+			assert.Equal(t, tc.Success, c.Success)
+			assert.Equal(t, tc.Valid, c.ExitCodeValid)
+			assert.Equal(t, tc.ExitCode, c.ExitCode)
+			if len(tc.Message) > 0 {
+				assert.Contains(t, c.Messages, tc.Message)
+			}
+		})
+	}
+}
+
+func TestFinishedWithoutProcessState(t *testing.T) {
+	c, errs := New([]string{"test"}, ID("test"))
+	if len(errs) != 0 {
+		t.Fatalf("unexpected error creating command: %s", errs)
+	}
+	c.report = new(mockReport)
+
+	// the process state is not set when waiting for the process fails
+	cmd := exec.Command("sleep", "1")
+	h := handler{}
+	assert.NotPanics(t, func() {
+		assert.Equal(t, ErrChildFailed{Code: -1}, h.Finished(c, cmd, fmt.Errorf("exec: not started")))
+	})
+	assert.Equal(t, proto.Failure, c.ReportReason)
+	assert.False(t, c.ExitCodeValid)
+	assert.Contains(t, c.Messages, "could not determine the exit status of the process: exec: not started")
 }
 
 func TestSignalHandler(t *testing.T) {
@@ -110,6 +171,7 @@ func TestSignalHandler(t *testing.T) {
 	if err := cmd.Start(); err != nil {
 		t.Fatalf("unexpected error starting process: %s", err)
 	}
+	c.Start = time.Now()
 
 	h := handler{}
 	errHandle := h.Signal(c, cmd, os.Kill)
@@ -117,7 +179,7 @@ func TestSignalHandler(t *testing.T) {
 	assert.Nil(t, errHandle)
 	assert.Equal(t, proto.Killed, c.ReportReason)
 	assert.Equal(t, proto.Signal, c.KillReason)
-	assert.NotZero(t, c.Duration)
+	assert.True(t, c.Duration > 0, "expected a positive duration, got %s", c.Duration)
 	assert.False(t, c.Success)
 }
 
@@ -148,14 +210,15 @@ func TestKillMemoryHandler(t *testing.T) {
 	if err := cmd.Start(); err != nil {
 		t.Fatalf("unexpected error starting process: %s", err)
 	}
+	c.Start = time.Now()
 
 	h := handler{}
 	errHandle := h.KillOnHighMemory(c, cmd)
 
-	assert.Nil(t, errHandle)
+	assert.Equal(t, ErrKilledOnMemory{}, errHandle)
 	assert.Equal(t, proto.Killed, c.ReportReason)
 	assert.Equal(t, proto.Memory, c.KillReason)
-	assert.NotZero(t, c.Duration)
+	assert.True(t, c.Duration > 0, "expected a positive duration, got %s", c.Duration)
 	assert.False(t, c.Success)
 }
 
@@ -186,14 +249,15 @@ func TestKillTimeoutHandler(t *testing.T) {
 	if err := cmd.Start(); err != nil {
 		t.Fatalf("unexpected error starting process: %s", err)
 	}
+	c.Start = time.Now()
 
 	h := handler{}
 	errHandle := h.Timeout(c, cmd)
 
-	assert.Nil(t, errHandle)
+	assert.Equal(t, ErrKilledOnTimeout{}, errHandle)
 	assert.Equal(t, proto.Killed, c.ReportReason)
 	assert.Equal(t, proto.Timeout, c.KillReason)
-	assert.NotZero(t, c.Duration)
+	assert.True(t, c.Duration > 0, "expected a positive duration, got %s", c.Duration)
 	assert.False(t, c.Success)
 }
 
@@ -234,6 +298,35 @@ func TestCheckMemoryHandler(t *testing.T) {
 	assert.NotZero(t, c.MaxMemory)
 }
 
+func TestCheckMemoryDegraded(t *testing.T) {
+	c, errs := New([]string{"test"}, ID("test"), MemoryKill("1K"))
+	if len(errs) != 0 {
+		t.Fatalf("unexpected error creating command: %s", errs)
+	}
+	c.report = new(mockReport)
+	// simulate /proc masked in a restricted container
+	readMemory = func(pid int) (uint64, error) {
+		return 0, fmt.Errorf("open /proc/%d/smaps: permission denied", pid)
+	}
+	defer func() { readMemory = calculateMemory }()
+	cmd := &exec.Cmd{Process: &os.Process{Pid: 4242}}
+
+	h := handler{}
+	for i := 0; i < memoryFailures-1; i++ {
+		assert.NoError(t, h.CheckMemory(c, cmd))
+	}
+	assert.Len(t, c.Messages, 0)
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, h.CheckMemory(c, cmd))
+	}
+	if assert.Len(t, c.Messages, 1) {
+		assert.Contains(t, c.Messages[0], "memory monitoring is degraded")
+		assert.Contains(t, c.Messages[0], "permission denied")
+	}
+	assert.False(t, c.Killed)
+	assert.Zero(t, c.MaxMemory)
+}
+
 func TestTimeWarnHandler(t *testing.T) {
 	c, err := New([]string{"test"}, ID("test"))
 	if err != nil {
@@ -250,3 +343,33 @@ func TestTimeWarnHandler(t *testing.T) {
 	assert.Equal(t, proto.TimeWarning, c.ReportReason)
 	assert.True(t, c.timeWarnSent)
 }
+
+func TestHeartbeatHandler(t *testing.T) {
+	c, errs := New([]string{"test"}, ID("test"), Daemon(), Heartbeat("1m"))
+	if len(errs) != 0 {
+		t.Fatalf("unexpected error creating command: %s", errs)
+	}
+	mocks := &mockRep{}
+	c.report = mocks
+	mocks.On("Send").Return()
+	readMemory = func(pid int) (uint64, error) {
+		return 2048, nil
+	}
+	defer func() { readMemory = calculateMemory }()
+	c.Start = time.Now().Add(-90 * time.Second)
+	cmd := &exec.Cmd{Process: &os.Process{Pid: 4242}}
+
+	h := handler{}
+	assert.NoError(t, h.Heartbeat(c, cmd))
+	c.Wait()
+	mocks.AssertNumberOfCalls(t, "Send", 1)
+	assert.Equal(t, uint64(2048), c.memory)
+	assert.Zero(t, c.ReportReason)
+
+	report := reportFromCommand(c, proto.Heartbeat, nil)
+	assert.Equal(t, pb.ReportReason_Heartbeat, report.ReportReason)
+	assert.Equal(t, uint64(2048), report.Memory)
+	uptime, err := time.ParseDuration(report.Duration)
+	assert.NoError(t, err)
+	assert.True(t, uptime >= 90*time.Second)
+}
@@ -1,11 +1,18 @@
 package monny
 
 import (
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/mock"
 )
 
+// TestMain suppresses error reporting for every test so that no test sends client errors to the error service
+func TestMain(m *testing.M) {
+	SuppressErrorReporting = true
+	os.Exit(m.Run())
+}
+
 // test helper silences superfluous logging calls from the mock package
 type foo struct {
 	t *testing.T
@@ -0,0 +1,116 @@
+package monny
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/go-logfmt/logfmt"
+)
+
+// Formats of the log messages about monny itself
+const (
+	logFormatText   = "text"
+	logFormatJSON   = "json"
+	logFormatLogfmt = "logfmt"
+)
+
+// logLevel is the minimum level of internal log messages that are written
+type logLevel int
+
+const (
+	logDebug logLevel = iota
+	logInfo
+	logWarn
+)
+
+func (l logLevel) String() string {
+	switch l {
+	case logDebug:
+		return "debug"
+	case logInfo:
+		return "info"
+	default:
+		return "warn"
+	}
+}
+
+// logger writes leveled log messages about the decisions made by monny itself, such as rule matches and
+// report sends.  A nil logger discards all messages.
+type logger struct {
+	level  logLevel
+	format string
+	log    *log.Logger
+}
+
+// newLogger returns a logger for the configuration, or nil when logging is not enabled.  Messages are written
+// to the writer set with Logger, otherwise to Stderr when verbose or debug logging is enabled.  Only warnings
+// are written unless verbose (info) or debug logging is enabled.  Messages are written in the format set with
+// StructuredLogging, or as text by default.
+func newLogger(cfg Config) *logger {
+	var out io.Writer = cfg.log
+	if out == nil && (cfg.Verbose || cfg.Debug) {
+		out = cfg.err
+	}
+	if out == nil {
+		return nil
+	}
+	level := logWarn
+	switch {
+	case cfg.Debug:
+		level = logDebug
+	case cfg.Verbose:
+		level = logInfo
+	}
+	switch cfg.LogFormat {
+	case logFormatJSON, logFormatLogfmt:
+		// structured formats include their own timestamp
+		return &logger{level: level, format: cfg.LogFormat, log: log.New(out, "", 0)}
+	default:
+		return &logger{level: level, format: logFormatText, log: log.New(out, "monny: ", log.LstdFlags)}
+	}
+}
+
+func (l *logger) logf(level logLevel, format string, args ...interface{}) {
+	if l == nil || level < l.level {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	switch l.format {
+	case logFormatJSON:
+		line, err := json.Marshal(struct {
+			Time   string `json:"time"`
+			Level  string `json:"level"`
+			Logger string `json:"logger"`
+			Msg    string `json:"msg"`
+		}{Time: time.Now().Format(time.RFC3339Nano), Level: level.String(), Logger: "monny", Msg: msg})
+		if err != nil {
+			return
+		}
+		l.log.Print(string(line))
+	case logFormatLogfmt:
+		var b bytes.Buffer
+		enc := logfmt.NewEncoder(&b)
+		if err := enc.EncodeKeyvals("time", time.Now().Format(time.RFC3339Nano), "level", level, "logger", "monny", "msg", msg); err != nil {
+			return
+		}
+		l.log.Print(b.String())
+	default:
+		l.log.Printf("[%s] %s", level, msg)
+	}
+}
+
+func (l *logger) debugf(format string, args ...interface{}) {
+	l.logf(logDebug, format, args...)
+}
+
+func (l *logger) infof(format string, args ...interface{}) {
+	l.logf(logInfo, format, args...)
+}
+
+func (l *logger) warnf(format string, args ...interface{}) {
+	l.logf(logWarn, format, args...)
+}
@@ -0,0 +1,138 @@
+package monny
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logfmt/logfmt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggerLevels(t *testing.T) {
+	tt := []struct {
+		Name   string
+		Config Config
+		Expect []string
+		Silent []string
+	}{
+		{Name: "default", Config: Config{}, Silent: []string{"[debug]", "[info]", "[warn]"}},
+		{Name: "logger", Config: Config{log: &bytes.Buffer{}}, Expect: []string{"[warn]"}, Silent: []string{"[debug]", "[info]"}},
+		{Name: "verbose", Config: Config{log: &bytes.Buffer{}, Verbose: true}, Expect: []string{"[info]", "[warn]"}, Silent: []string{"[debug]"}},
+		{Name: "debug", Config: Config{log: &bytes.Buffer{}, Debug: true}, Expect: []string{"[debug]", "[info]", "[warn]"}},
+		{Name: "debug to stderr", Config: Config{err: &closeBuffer{}, Debug: true}, Expect: []string{"[debug]", "[info]", "[warn]"}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			l := newLogger(tc.Config)
+			l.debugf("message")
+			l.infof("message")
+			l.warnf("message")
+
+			var out string
+			switch {
+			case tc.Config.log != nil:
+				out = tc.Config.log.(*bytes.Buffer).String()
+			case tc.Config.err != nil:
+				out = tc.Config.err.(*closeBuffer).String()
+			default:
+				assert.Nil(t, l)
+			}
+			for _, s := range tc.Expect {
+				assert.Contains(t, out, s+" message")
+			}
+			for _, s := range tc.Silent {
+				assert.NotContains(t, out, s)
+			}
+		})
+	}
+}
+
+func TestLoggerFormats(t *testing.T) {
+	tt := []struct {
+		Name   string
+		Format string
+		Parse  func(t *testing.T, line string) map[string]string
+	}{
+		{Name: "text", Format: "text", Parse: func(t *testing.T, line string) map[string]string {
+			assert.True(t, strings.HasPrefix(line, "monny: "))
+			i := strings.Index(line, "[")
+			j := strings.Index(line, "] ")
+			if i < 0 || j < i {
+				t.Fatalf("unexpected text log line: %s", line)
+			}
+			return map[string]string{"level": line[i+1 : j], "msg": line[j+2:]}
+		}},
+		{Name: "json", Format: "json", Parse: func(t *testing.T, line string) map[string]string {
+			out := make(map[string]string)
+			assert.NoError(t, json.Unmarshal([]byte(line), &out))
+			return out
+		}},
+		{Name: "logfmt", Format: "logfmt", Parse: func(t *testing.T, line string) map[string]string {
+			out := make(map[string]string)
+			d := logfmt.NewDecoder(strings.NewReader(line))
+			for d.ScanRecord() {
+				for d.ScanKeyval() {
+					out[string(d.Key())] = string(d.Value())
+				}
+			}
+			assert.NoError(t, d.Err())
+			return out
+		}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			var out bytes.Buffer
+			cfg := Config{Verbose: true}
+			if err := StructuredLogging(&out, tc.Format)(&cfg); err != nil {
+				t.Fatalf("unexpected error in config: %v", err)
+			}
+			l := newLogger(cfg)
+			l.debugf("not logged")
+			l.infof("sending %s report", "Alert")
+			l.warnf("failed to send \"%s\" report", "Failure")
+
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			if !assert.Len(t, lines, 2) {
+				return
+			}
+			info := tc.Parse(t, lines[0])
+			assert.Equal(t, "info", info["level"])
+			assert.Equal(t, "sending Alert report", info["msg"])
+			warn := tc.Parse(t, lines[1])
+			assert.Equal(t, "warn", warn["level"])
+			assert.Equal(t, `failed to send "Failure" report`, warn["msg"])
+			if tc.Format != "text" {
+				assert.Equal(t, "monny", warn["logger"])
+				_, err := time.Parse(time.RFC3339Nano, warn["time"])
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestLoggerFailedSend(t *testing.T) {
+	var log bytes.Buffer
+	c, errs := New([]string{"sh", "-c", "echo ERROR; exit 1"}, ID("test"), Rule("ERROR"), NoNotifyOnSuccess(), Debug(), Logger(&log),
+		ReportFile(filepath.Join("does", "not", "exist", "reports.jsonl")), logOut(&closeBuffer{}), logErr(&closeBuffer{}))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	c.Exec()
+	assert.NoError(t, c.Wait())
+
+	out := log.String()
+	for _, line := range []string{
+		"[debug] rule matched line on stdout: ERROR",
+		"[info] sending Alert report",
+		"[debug] process finished: exit status 1",
+		"[info] sending Failure report",
+		"[warn] failed to send Failure report: could not open report file",
+	} {
+		assert.Contains(t, out, line)
+	}
+	assert.NotContains(t, out, "sent Failure report")
+}
@@ -9,10 +9,12 @@ import (
 	"os"
 )
 
-func calculateMemory(pid int) uint64 {
+// calculateMemory returns the proportional set size of the process in kB from /proc.  An error is returned when
+// /proc for the process can not be read, such as in containers where it is masked or in a different PID namespace.
+func calculateMemory(pid int) (uint64, error) {
 	f, err := os.Open(fmt.Sprintf("/proc/%d/smaps", pid))
 	if err != nil {
-		return 0
+		return 0, err
 	}
 	defer f.Close()
 
@@ -25,13 +27,13 @@ func calculateMemory(pid int) uint64 {
 			var size uint64
 			_, err := fmt.Sscanf(string(line[4:]), "%d", &size)
 			if err != nil {
-				return 0
+				return 0, fmt.Errorf("could not parse memory from /proc/%d/smaps: %v", pid, err)
 			}
 			res += size
 		}
 	}
 	if err := r.Err(); err != nil {
-		return 0
+		return 0, err
 	}
-	return res
+	return res, nil
 }
@@ -1,6 +1,7 @@
 // +build windows
+
 package monny
 
-func calculateMemory(pid int) uint64 {
-	return 0
+func calculateMemory(pid int) (uint64, error) {
+	return 0, nil
 }
@@ -0,0 +1,237 @@
+package monny
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/metric"
+	"github.com/BTBurke/monny/pkg/proto"
+	"github.com/BTBurke/monny/pkg/stat"
+	"github.com/BTBurke/monny/pkg/trace"
+)
+
+// metricBaseline is the number of windows used to establish the baseline rate of a metric rule
+const metricBaseline = 50
+
+// metricChartHistory is the number of control chart points retained for each metric rule
+const metricChartHistory = 100
+
+// streamSelector selects which output streams are counted by a metric rule
+type streamSelector string
+
+const (
+	streamStdout streamSelector = "stdout"
+	streamStderr streamSelector = "stderr"
+	streamBoth   streamSelector = "both"
+)
+
+// sampleStrategies combine the values of the lines matching a metric rule in each window into one observation.  The
+// value of a line is the capture group named value, or 1 when the rule has none, so that sum and count are both the
+// number of matching lines by default.
+var sampleStrategies = map[string]func([]float64) float64{
+	"sum":   metric.SampleSum,
+	"avg":   metric.SampleAverage,
+	"max":   metric.SampleMax,
+	"min":   metric.SampleMin,
+	"count": metric.SampleCount,
+}
+
+// sampleStrategyNames lists the sample strategies in the order they are shown in help and errors
+var sampleStrategyNames = []string{"sum", "avg", "max", "min", "count"}
+
+// sampleValueGroup is the name of the capture group in a metric rule that holds the value of a matching line
+const sampleValueGroup = "value"
+
+type metricRule struct {
+	Name   string
+	Stream streamSelector
+	Regex  *regexp.Regexp
+	// Sampling records on average 1 in every Sampling windows in the estimator when greater than 1
+	Sampling int
+	// RateLimit is the most windows recorded in the estimator in each RatePeriod when greater than 0
+	RateLimit  int
+	RatePeriod time.Duration
+}
+
+// metricRateLimit is the rate limit of a metric rule set by MetricRuleRateLimit
+type metricRateLimit struct {
+	n      int
+	period time.Duration
+}
+
+// selects returns true if lines from stream are counted by the rule
+func (r metricRule) selects(stream streamSelector) bool {
+	return r.Stream == streamBoth || r.Stream == stream
+}
+
+// metricMonitor collects the values of matches to a metric rule in the current window and records the sample of each
+// window in a Poisson estimator
+type metricMonitor struct {
+	rule     metricRule
+	mutex    sync.Mutex
+	obs      []float64
+	strategy func([]float64) float64
+	test     *stat.Test
+	alarmed  bool
+}
+
+func newMetricMonitor(rule metricRule, lambda float64, strategy func([]float64) float64) (*metricMonitor, error) {
+	// windows are sampled by the monitor, so the estimator records each sample directly without a sample window
+	ewma, err := stat.NewEWMAStatistic("ewma", lambda, stat.NewPoisson(metricBaseline, 0, strategy, stat.KErrorRate(0.05)))
+	if err != nil {
+		return nil, err
+	}
+	shewart, err := stat.NewEWMAStatistic("shewart", 1.0, stat.NewPoisson(metricBaseline, 0, strategy, stat.KErrorRate(0.05)))
+	if err != nil {
+		return nil, err
+	}
+	opts := []stat.TestOption{stat.WithStatistic(ewma), stat.WithStatistic(shewart), stat.WithChartData(metricChartHistory)}
+	if rule.Sampling > 1 {
+		opts = append(opts, stat.WithSampling(rule.Sampling))
+	}
+	if rule.RateLimit > 0 {
+		opts = append(opts, stat.WithRateLimit(rule.RateLimit, rule.RatePeriod))
+	}
+	test, err := stat.NewPoissonTest(metric.NewName(rule.Name, map[string]string{"stream": string(rule.Stream)}), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &metricMonitor{
+		rule:     rule,
+		strategy: strategy,
+		test:     test,
+	}, nil
+}
+
+// add collects the value of the line if it is from a selected stream and matches the rule
+func (m *metricMonitor) add(line []byte, stream streamSelector) {
+	if !m.rule.selects(stream) {
+		return
+	}
+	match := m.rule.Regex.FindSubmatch(line)
+	if match == nil {
+		return
+	}
+	m.mutex.Lock()
+	m.obs = append(m.obs, m.value(match))
+	m.mutex.Unlock()
+}
+
+// value returns the number in the value capture group of a match, or 1 when there is no number
+func (m *metricMonitor) value(match [][]byte) float64 {
+	for i, name := range m.rule.Regex.SubexpNames() {
+		if name != sampleValueGroup || i >= len(match) {
+			continue
+		}
+		if v, err := strconv.ParseFloat(string(match[i]), 64); err == nil {
+			return v
+		}
+	}
+	return 1
+}
+
+// record closes the current window and records its sample in the estimator.  Returns true the first time the estimator
+// alarms.
+func (m *metricMonitor) record() (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	n := m.strategy(m.obs)
+	m.obs = nil
+
+	if err := m.test.Record(n); err != nil {
+		return false, fmt.Errorf("failed to record metric %s: %v", m.rule.Name, err)
+	}
+	if m.alarmed || !m.test.HasAlarmed() {
+		return false, nil
+	}
+	m.alarmed = true
+	return true, nil
+}
+
+// severity returns the number of windows tested before the estimator alarmed and how far the rate was beyond the limit
+// in standard deviations
+func (m *metricMonitor) severity() (int, float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	runLength, distance, _ := m.test.Severity()
+	return runLength, distance
+}
+
+// snapshot returns the current metrics and chart data of the estimator
+func (m *metricMonitor) snapshot() (map[string]float64, []stat.ChartPoint) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.test.Metric(), m.test.ChartData()
+}
+
+func newMetricMonitors(rules []metricRule, lambda float64, strategy string) ([]*metricMonitor, error) {
+	sample, ok := sampleStrategies[strategy]
+	if !ok {
+		sample = metric.SampleSum
+	}
+	var monitors []*metricMonitor
+	for _, rule := range rules {
+		m, err := newMetricMonitor(rule, lambda, sample)
+		if err != nil {
+			return nil, fmt.Errorf("could not create estimator for metric rule %s: %w", rule.Name, err)
+		}
+		monitors = append(monitors, m)
+	}
+	return monitors, nil
+}
+
+// countMetrics counts a line from stream against each metric rule
+func (c *Command) countMetrics(line []byte, stream streamSelector) {
+	for _, m := range c.metrics {
+		m.add(line, stream)
+	}
+}
+
+// startMetrics records the count of matches to each metric rule at the end of every window and sends a report when
+// the rate of matches increases.  The returned function stops recording.
+func (c *Command) startMetrics() func() {
+	if len(c.metrics) == 0 {
+		return func() {}
+	}
+	done := make(chan bool)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(c.Config.MetricWindow)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.recordMetrics()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+func (c *Command) recordMetrics() {
+	for _, m := range c.metrics {
+		alarmed, err := m.record()
+		if err != nil {
+			c.reportError(errorInternal, err)
+			continue
+		}
+		if alarmed {
+			runLength, distance := m.severity()
+			c.mutex.Lock()
+			c.Messages = append(c.Messages, fmt.Sprintf("rate of matches to metric rule %s increased to %.1f standard deviations beyond the limit after %d windows", m.rule.Name, distance, runLength))
+			c.mutex.Unlock()
+			c.traceEvent("metric_alarm", trace.String("monny.metric", m.rule.Name), trace.Int64("monny.run_length", int64(runLength)), trace.Float64("monny.distance", distance))
+			c.send(proto.AlertRate)
+		}
+	}
+}
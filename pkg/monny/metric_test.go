@@ -0,0 +1,169 @@
+package monny
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/metric"
+	"github.com/BTBurke/monny/pkg/pb"
+	"github.com/BTBurke/monny/pkg/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+// reasonReport records the reasons for reports sent by the command
+type reasonReport struct {
+	mutex   sync.Mutex
+	reasons []proto.ReportReason
+}
+
+func (r *reasonReport) Send(c *Command, reason proto.ReportReason) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.reasons = append(r.reasons, reason)
+}
+
+func (r *reasonReport) SendBatch(reports []*pb.Report) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, report := range reports {
+		r.reasons = append(r.reasons, proto.ReportReason(report.ReportReason))
+	}
+	return nil
+}
+
+func (r *reasonReport) Wait() error {
+	return nil
+}
+
+func TestMetricStreamSelection(t *testing.T) {
+	tt := []struct {
+		Name   string
+		Stream streamSelector
+		Expect int
+	}{
+		{Name: "stdout", Stream: streamStdout, Expect: 1},
+		{Name: "stderr", Stream: streamStderr, Expect: 2},
+		{Name: "both", Stream: streamBoth, Expect: 3},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			m, err := newMetricMonitor(metricRule{Name: "errors", Stream: tc.Stream, Regex: regexp.MustCompile("ERROR")}, statLambda, metric.SampleSum)
+			if err != nil {
+				t.Fatalf("unexpected error creating monitor: %v", err)
+			}
+			m.add([]byte("ERROR on stdout"), streamStdout)
+			m.add([]byte("ok"), streamStdout)
+			m.add([]byte("ERROR on stderr"), streamStderr)
+			m.add([]byte("another ERROR"), streamStderr)
+			m.add([]byte("ok"), streamStderr)
+			assert.Equal(t, tc.Expect, len(m.obs))
+		})
+	}
+}
+
+func TestMetricSampleStrategy(t *testing.T) {
+	lines := []string{"latency=10", "latency=30", "latency=fast", "ok"}
+	tt := []struct {
+		Strategy string
+		Expect   float64
+	}{
+		{Strategy: "sum", Expect: 41},
+		{Strategy: "avg", Expect: 41.0 / 3},
+		{Strategy: "max", Expect: 30},
+		{Strategy: "min", Expect: 1},
+		{Strategy: "count", Expect: 3},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Strategy, func(t *testing.T) {
+			monitors, err := newMetricMonitors([]metricRule{{Name: "latency", Stream: streamBoth, Regex: regexp.MustCompile(`latency=(?P<value>\w+)`)}}, statLambda, tc.Strategy)
+			if err != nil {
+				t.Fatalf("unexpected error creating monitor: %v", err)
+			}
+			m := monitors[0]
+			for _, line := range lines {
+				m.add([]byte(line), streamStdout)
+			}
+			assert.InDelta(t, tc.Expect, m.strategy(m.obs), 1e-9)
+			_, err = m.record()
+			assert.NoError(t, err)
+			assert.Empty(t, m.obs)
+		})
+	}
+}
+
+func TestMetricRuleSampling(t *testing.T) {
+	// limits are set before the rule to check that they do not depend on the order of the options
+	c, errs := New([]string{"echo"}, ID("test"), MetricRuleSampling("errors", 10), MetricRuleRateLimit("errors", 50, time.Hour), MetricRule("errors", "stderr", "ERROR"))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	assert.Equal(t, 10, c.Config.MetricRules[0].Sampling)
+	assert.Equal(t, 50, c.Config.MetricRules[0].RateLimit)
+	assert.Equal(t, time.Hour, c.Config.MetricRules[0].RatePeriod)
+
+	m := c.metrics[0]
+	for i := 0; i < 2000; i++ {
+		m.add([]byte("ERROR"), streamStderr)
+		_, err := m.record()
+		assert.NoError(t, err)
+	}
+	recorded, dropped := m.test.Sampled()
+	assert.Equal(t, uint64(50), recorded)
+	assert.Equal(t, uint64(1950), dropped)
+
+	_, errs = New([]string{"echo"}, ID("test"), MetricRuleSampling("warnings", 10), MetricRule("errors", "stderr", "ERROR"))
+	if assert.Len(t, errs, 1) {
+		assert.IsType(t, ErrInvalidValue{}, errs[0])
+	}
+}
+
+func TestMetricErrorRate(t *testing.T) {
+	// one error per window on stderr to establish the baseline, followed by bursts of errors
+	script := `i=0; while [ $i -lt 75 ]; do echo ERROR >&2; echo ok; sleep 0.02; i=$((i+1)); done;
+j=0; while [ $j -lt 10 ]; do k=0; while [ $k -lt 50 ]; do echo ERROR >&2; k=$((k+1)); done; sleep 0.02; j=$((j+1)); done`
+
+	tt := []struct {
+		Name   string
+		Stream string
+		Alarm  bool
+	}{
+		{Name: "stderr", Stream: "stderr", Alarm: true},
+		{Name: "stdout", Stream: "stdout", Alarm: false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			r, w := io.Pipe()
+			go func() {
+				buf := new(bytes.Buffer)
+				buf.ReadFrom(r)
+				r.Close()
+			}()
+			c, errs := New([]string{"sh", "-c", script}, ID("test"), MetricRule("errors", tc.Stream, "ERROR"), MetricWindow("20ms"), logErr(w), logOut(w))
+			if len(errs) > 0 {
+				t.Fatalf("unexpected error in config: %s", errs)
+			}
+			rpt := new(reasonReport)
+			c.report = rpt
+
+			if err := c.Exec(); err != nil {
+				t.Fatalf("unexpected error running: %s", err)
+			}
+			assert.Equal(t, tc.Alarm, c.metrics[0].test.HasAlarmed())
+			rpt.mutex.Lock()
+			defer rpt.mutex.Unlock()
+			switch tc.Alarm {
+			case true:
+				assert.Contains(t, rpt.reasons, proto.AlertRate)
+				if assert.NotEmpty(t, c.Messages) {
+					assert.Regexp(t, `^rate of matches to metric rule errors increased to \d+\.\d standard deviations beyond the limit after \d+ windows$`, c.Messages[len(c.Messages)-1])
+				}
+			default:
+				assert.NotContains(t, rpt.reasons, proto.AlertRate)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package monny
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// newScanner returns a scanner for log output that splits on newlines or, when configured for
+// multi-line JSON, on complete JSON objects.  Lines and JSON objects may be up to MaxLineSize.
+func (c *Command) newScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), c.Config.lineSize())
+	if c.Config.MultiLineJSON {
+		scanner.Split(scanMultiLineJSON)
+	}
+	return scanner
+}
+
+// lineSize returns the longest line that is scanned, which is the default for commands created without New
+func (c Config) lineSize() int {
+	if c.MaxLineSize <= 0 {
+		return maxLineSize
+	}
+	return c.MaxLineSize
+}
+
+// scanMultiLineJSON is a bufio.SplitFunc that accumulates lines of a pretty-printed JSON object until the
+// brace depth of the root object returns to zero, then emits the full object as a single token.  Lines that
+// do not start a JSON object are emitted as-is, the same as bufio.ScanLines.  A line that starts with a brace but
+// is not valid JSON, or is followed by other text on the same line, is also emitted as-is, so that output such as
+// {WARN} disk almost full is not split or joined with the lines after it.
+func scanMultiLineJSON(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	start := bytes.IndexFunc(data, func(r rune) bool { return r != ' ' && r != '\t' && r != '\r' })
+	if start < 0 || data[start] != '{' {
+		return bufio.ScanLines(data, atEOF)
+	}
+
+	depth := 0
+	inString := false
+	escaped := false
+	for i := start; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inString && b == '\\':
+			escaped = true
+		case b == '"':
+			inString = !inString
+		case inString:
+		case b == '{':
+			depth++
+		case b == '}':
+			depth--
+			if depth == 0 {
+				// the object is only a token if the remainder of the line is whitespace
+				rest := data[i+1:]
+				end := bytes.IndexFunc(rest, func(r rune) bool { return r != ' ' && r != '\t' && r != '\r' })
+				switch {
+				case end < 0 && !atEOF:
+					return 0, nil, nil
+				case !json.Valid(data[start : i+1]):
+					return bufio.ScanLines(data, atEOF)
+				case end < 0:
+					return len(data), data[start : i+1], nil
+				case rest[end] == '\n':
+					return i + 1 + end + 1, data[start : i+1], nil
+				default:
+					return bufio.ScanLines(data, atEOF)
+				}
+			}
+		}
+	}
+
+	// the object is not closed, so request more data as long as it is the start of a valid JSON object.  Otherwise,
+	// the first line is emitted as-is instead of joining it with the lines that follow.
+	if atEOF || !jsonPrefix(data[start:]) {
+		return bufio.ScanLines(data, atEOF)
+	}
+	return 0, nil, nil
+}
+
+// jsonPrefix returns true if data is the start of a valid JSON value that is not yet complete
+func jsonPrefix(data []byte) bool {
+	var v json.RawMessage
+	return json.NewDecoder(bytes.NewReader(data)).Decode(&v) == io.ErrUnexpectedEOF
+}
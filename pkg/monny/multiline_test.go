@@ -0,0 +1,92 @@
+package monny
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const prettyJSON string = `{
+  "code": 404,
+  "msg": "test message",
+  "nested": {
+    "nest1": "test {with braces}"
+  },
+  "escaped": "quote \" and brace }"
+}`
+
+func TestScanMultiLineJSON(t *testing.T) {
+	tt := []struct {
+		Name   string
+		Input  string
+		Expect []string
+	}{
+		{Name: "plain lines", Input: "line 1\nline 2\n", Expect: []string{"line 1", "line 2"}},
+		{Name: "single line json", Input: testJSON + "\n", Expect: []string{testJSON}},
+		{Name: "multi-line json", Input: prettyJSON + "\n", Expect: []string{prettyJSON}},
+		{Name: "multi-line json no trailing newline", Input: prettyJSON, Expect: []string{prettyJSON}},
+		{Name: "mixed", Input: "start\n" + prettyJSON + "\nfinish\n", Expect: []string{"start", prettyJSON, "finish"}},
+		{Name: "consecutive objects", Input: prettyJSON + "\n" + prettyJSON + "\n", Expect: []string{prettyJSON, prettyJSON}},
+		{Name: "objects on one line", Input: `{"a": 1}{"b": 2}` + "\n", Expect: []string{`{"a": 1}{"b": 2}`}},
+		{Name: "json with trailing text", Input: `{"a":1} trailing` + "\nnext\n", Expect: []string{`{"a":1} trailing`, "next"}},
+		{Name: "tag in braces", Input: "{WARN} disk almost full\nnext\n", Expect: []string{"{WARN} disk almost full", "next"}},
+		{Name: "braces only", Input: "{WARN}\nnext\n", Expect: []string{"{WARN}", "next"}},
+		{Name: "unclosed brace", Input: "{ starting job\nline 2\nline 3\n", Expect: []string{"{ starting job", "line 2", "line 3"}},
+		{Name: "unclosed brace alone", Input: "{\nline 2\n}\n", Expect: []string{"{", "line 2", "}"}},
+		{Name: "crlf", Input: "{\r\n  \"a\": 1\r\n}\r\nnext\r\n", Expect: []string{"{\r\n  \"a\": 1\r\n}", "next"}},
+		{Name: "unclosed object", Input: "{\n  \"a\": 1\n", Expect: []string{"{", `  "a": 1`}},
+		{Name: "unclosed object before plain lines", Input: "{\n  \"a\": 1\nnext\n", Expect: []string{"{", `  "a": 1`, "next"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			// one byte reader forces the split function to request more data
+			scanner := bufio.NewScanner(&oneByteReader{r: strings.NewReader(tc.Input)})
+			scanner.Split(scanMultiLineJSON)
+			var out []string
+			for scanner.Scan() {
+				out = append(out, scanner.Text())
+			}
+			assert.NoError(t, scanner.Err())
+			assert.Equal(t, tc.Expect, out)
+		})
+	}
+}
+
+type oneByteReader struct {
+	r io.Reader
+}
+
+func (o *oneByteReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return o.r.Read(p[0:1])
+}
+
+func TestMultiLineJSONRules(t *testing.T) {
+	r, w := io.Pipe()
+	go func() {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(r)
+		r.Close()
+	}()
+	input := "start\n" + prettyJSON + "\nfinish\n"
+	c, err := New([]string{}, ID("test"), MultiLineJSON(), JSONRule("nested.nest1", "braces"), logIn(strings.NewReader(input)), logErr(w), logOut(w))
+	if err != nil {
+		t.Fatalf("unexpected error in config: %s", err)
+	}
+	c.report = new(mockReport)
+
+	if err := c.Exec(); err != nil {
+		t.Fatalf("unexpected error running: %s", err)
+	}
+	assert.Equal(t, []string{"start", prettyJSON, "finish"}, c.Stdout)
+	if assert.Len(t, c.RuleMatches, 1) {
+		assert.Equal(t, prettyJSON, c.RuleMatches[0].Line)
+	}
+}
@@ -0,0 +1,88 @@
+package monny
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// execParallel starts every step at the same time and monitors them as a group.  When a step fails and the steps fail
+// fast, the other steps are killed.  A single report for all steps is sent when the last one exits, which is
+// successful only if every step succeeds.
+func (c *Command) execParallel() error {
+	c.Start = time.Now()
+	c.mutex.Lock()
+	c.Steps = make([]StepResult, len(c.steps))
+	for i, s := range c.steps {
+		c.Steps[i] = StepResult{Command: s.command, Start: c.Start}
+	}
+	c.mutex.Unlock()
+
+	g := newProcessGroup(len(c.steps))
+	var cmd *exec.Cmd
+	for i, s := range c.steps {
+		c.log.debugf("starting step %d in parallel: %s", i+1, s.command)
+		started, runFinished, err := c.startProcess(s.args, s.tag, false)
+		if err != nil {
+			g.kill(nil)
+			return err
+		}
+		cmd = started
+		g.add(i, started, runFinished)
+	}
+
+	var failed error
+	cancelled := make(map[int]bool)
+	running := len(c.steps)
+	err := c.monitorGroup(g, func(i int, waitErr error) (bool, error) {
+		running--
+		stopped := c.stoppedOnBrokenPipe()
+		switch {
+		case cancelled[i]:
+			c.finishStep(i, false, nil, c.stderrTagged(c.steps[i].tag))
+			c.mutex.Lock()
+			c.Steps[i].Cancelled = true
+			c.mutex.Unlock()
+		case stopped:
+			c.finishStep(i, true, nil, nil)
+		default:
+			c.finishStep(i, true, waitErr, c.stderrTagged(c.steps[i].tag))
+		}
+		if waitErr != nil && failed == nil && !cancelled[i] && !stopped {
+			failed = waitErr
+			c.mutex.Lock()
+			c.Messages = append(c.Messages, fmt.Sprintf("step %d failed: %s: %v", i+1, c.steps[i].command, waitErr))
+			c.mutex.Unlock()
+			if c.Config.FailFast {
+				c.log.infof("killing other steps: step %d failed", i+1)
+				for _, j := range g.ids() {
+					cancelled[j] = true
+				}
+				g.kill(nil)
+			}
+		}
+		return running == 0, nil
+	})
+	if running > 0 {
+		// the steps were killed, which already sent a report
+		return err
+	}
+	c.out.Close()
+	c.err.Close()
+	c.Cleanup()
+	return c.handler.Finished(c, cmd, failed)
+}
+
+// stderrTagged returns the lines in the stderr history written by the step with tag, without the tag
+func (c *Command) stderrTagged(tag string) []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var lines []string
+	for _, line := range c.Stderr {
+		if strings.HasPrefix(line, tag) {
+			lines = append(lines, strings.TrimPrefix(line, tag))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,86 @@
+package monny
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParallelTagging(t *testing.T) {
+	cmd := []string{"echo", "one", "--", "sh", "-c", "echo ERROR: two; echo warning >&2", "--", "echo", "three"}
+	out := &closeBuffer{}
+	c, errs := New(cmd, ID("test"), Parallel(), Rule("^ERROR"), logOut(out), logErr(&closeBuffer{}))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	recorder := new(reasonRecorder)
+	c.report = recorder
+	assert.NoError(t, c.Exec())
+	c.Wait()
+
+	stdout := append([]string{}, c.Stdout...)
+	sort.Strings(stdout)
+	assert.Equal(t, []string{"[1] one", "[2] ERROR: two", "[3] three"}, stdout, "each line should be tagged with its step")
+	assert.Equal(t, []string{"[2] warning"}, c.Stderr)
+	echoed := strings.Split(strings.TrimSpace(out.String()), "\n")
+	sort.Strings(echoed)
+	assert.Equal(t, stdout, echoed, "echoed lines should be tagged with their step")
+	assert.Len(t, c.RuleMatches, 1, "rules should match the line without the tag")
+
+	assert.True(t, c.Success)
+	assert.Contains(t, recorder.reasons, proto.Success)
+	if assert.Len(t, c.Steps, 3) {
+		for i, step := range c.Steps {
+			assert.True(t, step.Success, "step %d", i+1)
+		}
+	}
+}
+
+func TestParallelFailure(t *testing.T) {
+	failing := []string{"sh", "-c", "echo bad row >&2; sleep 0.2; exit 3"}
+	tt := []struct {
+		Name    string
+		Cmd     []string
+		Options []ConfigOption
+		// Cancelled is whether each step was killed after the failure
+		Cancelled []bool
+		Success   []bool
+	}{
+		{Name: "fail fast", Cmd: append(append([]string{"sleep", "5", "--"}, failing...), "--", "sleep", "5"), Options: []ConfigOption{FailFast()}, Cancelled: []bool{true, false, true}, Success: []bool{false, false, false}},
+		{Name: "run to completion", Cmd: append(append([]string{"sleep", "0.5", "--"}, failing...), "--", "sleep", "0.5"), Cancelled: []bool{false, false, false}, Success: []bool{true, false, true}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			c, errs := New(tc.Cmd, append(tc.Options, ID("test"), Parallel(), logOut(&closeBuffer{}), logErr(&closeBuffer{}))...)
+			if len(errs) > 0 {
+				t.Fatalf("unexpected error in config: %s", errs)
+			}
+			recorder := new(reasonRecorder)
+			c.report = recorder
+			start := time.Now()
+			var failed ErrChildFailed
+			assert.True(t, errors.As(c.Exec(), &failed))
+			assert.True(t, time.Since(start) < 3*time.Second, "steps should be killed when one fails fast")
+			c.Wait()
+
+			assert.Equal(t, 3, failed.Code)
+			assert.Equal(t, []proto.ReportReason{proto.Failure}, recorder.reasons, "one report should cover every step")
+			assert.False(t, c.Success)
+			if !assert.Len(t, c.Steps, 3) {
+				return
+			}
+			for i := range c.Steps {
+				assert.Equal(t, tc.Cancelled[i], c.Steps[i].Cancelled, "step %d", i+1)
+				assert.Equal(t, tc.Success[i], c.Steps[i].Success, "step %d", i+1)
+			}
+			assert.Equal(t, int32(3), c.Steps[1].ExitCode)
+			assert.Equal(t, []string{"bad row"}, c.Steps[1].Stderr)
+		})
+	}
+}
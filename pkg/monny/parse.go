@@ -1,64 +1,283 @@
 package monny
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/BTBurke/monny/pkg/units"
 	"github.com/go-yaml/yaml"
 	"github.com/spf13/pflag"
 )
 
+// envPrefix is prepended to the upper case name of each option, with dashes replaced by underscores, to set the option
+// with an environment variable (e.g., MONNY_ID or MONNY_TIMEOUT_WARN)
+const envPrefix = "MONNY_"
+
+// listOptions can be set more than once.  Multiple values in an environment variable are separated by commas.
+var listOptions = map[string]bool{
+	"rule":                   true,
+	"rule-json":              true,
+	"rule-window":            true,
+	"metric-rule":            true,
+	"metric-rule-sampling":   true,
+	"metric-rule-rate-limit": true,
+	"creates":                true,
+	"creates-watch":          true,
+	"var":                    true,
+	"redact-env":             true,
+	"preset":                 true,
+	"step":                   true,
+}
+
 type options struct {
 	options []ConfigOption
+	file    []ConfigOption
+	config  bool
 	err     error
 }
 
-// ParseCommandLine configures the client from command line options or from
-// a YAML configuration file passed with the -c flag.  Returns the user command
-// and a slice of functional options that can be applied to the configuration.
+// configSources are the sources of options other than flags.  When no configuration file is passed with -c, each
+// of files is checked in order and the first that exists is used.
+type configSources struct {
+	files []string
+	env   []ConfigOption
+}
+
+// ParseCommandLine configures the client from command line options, MONNY_* environment variables, and
+// YAML configuration files.  Configuration files are passed with the -c flag, which may be repeated, or discovered at
+// ./monny.yaml, $XDG_CONFIG_HOME/monny/config.yaml, or /etc/monny/config.yaml, in that order.  Returns the user command and a
+// slice of functional options that can be applied to the configuration.  Flags take precedence over environment
+// variables, which take precedence over the configuration file.  An explicit run subcommand is skipped.
 func ParseCommandLine() ([]string, []ConfigOption, error) {
+	_, args := Subcommand(os.Args[1:])
+	return ParseRun(args)
+}
+
+// ParseRun parses the arguments of the run subcommand in the same way as ParseCommandLine
+func ParseRun(args []string) ([]string, []ConfigOption, error) {
 	pf := createFlagSet()
-	return parse(os.Args[1:], pf)
+	env, err := parseEnv(pf)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parse(args, pf, configSources{files: configFiles(), env: env})
+}
+
+// configFiles returns the paths where a configuration file is discovered in order of precedence.  When
+// XDG_CONFIG_HOME is not set, it defaults to $HOME/.config.
+func configFiles() []string {
+	files := []string{"monny.yaml"}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if len(dir) == 0 {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".config")
+		}
+	}
+	if len(dir) > 0 {
+		files = append(files, filepath.Join(dir, "monny", "config.yaml"))
+	}
+	return append(files, filepath.Join(string(filepath.Separator), "etc", "monny", "config.yaml"))
+}
+
+// discoverConfig returns the options from the first of files that exists.  Files that do not exist are skipped, but
+// a file that exists and can not be read or parsed is an error.
+func discoverConfig(files []string) ([]ConfigOption, error) {
+	path := findConfig(files)
+	if len(path) == 0 {
+		return nil, nil
+	}
+	opts, err := parseFromFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read configuration file %s: %v", path, err)
+	}
+	return opts, nil
 }
 
-func parse(args []string, pf *pflag.FlagSet) ([]string, []ConfigOption, error) {
+// findConfig returns the first of files that exists, or an empty string if none exist
+func findConfig(files []string) string {
+	for _, path := range files {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		return path
+	}
+	return ""
+}
+
+// parseEnv returns options set by MONNY_* environment variables for each flag in the flag set.  Boolean options
+// are set when the variable is true.
+func parseEnv(pf *pflag.FlagSet) ([]ConfigOption, error) {
+	var opts []ConfigOption
+	var err error
+	pf.VisitAll(func(flag *pflag.Flag) {
+		if err != nil || flag.Name == "config" {
+			return
+		}
+		name := envPrefix + strings.ToUpper(strings.Replace(flag.Name, "-", "_", -1))
+		value, ok := os.LookupEnv(name)
+		if !ok || len(value) == 0 {
+			return
+		}
+
+		values := []string{value}
+		switch {
+		case flag.Value.Type() == "bool":
+			set, perr := strconv.ParseBool(value)
+			if perr != nil {
+				err = fmt.Errorf("invalid value for %s, should be true or false: %s", name, value)
+				return
+			}
+			if !set {
+				return
+			}
+			values = []string{""}
+		case listOptions[flag.Name]:
+			values = nil
+			for _, v := range strings.Split(value, ",") {
+				if v = strings.TrimSpace(v); len(v) > 0 {
+					values = append(values, v)
+				}
+			}
+		}
+		for _, v := range values {
+			opt, herr := handleOption(flag.Name, v)
+			if herr != nil {
+				err = fmt.Errorf("invalid value for %s: %v", name, herr)
+				return
+			}
+			opts = append(opts, opt)
+		}
+	})
+	return opts, err
+}
+
+// parse returns the user command and the options set by args and src.  Options from the configuration file are
+// applied first, followed by env and then flags, so that flags override both.  The configuration files passed with -c
+// are used regardless of their position in args, otherwise a configuration file is discovered from src.  Options from
+// several files are applied in the order the files are passed, so that later files override single valued options of
+// earlier files and add to repeated options, such as rules.
+func parse(args []string, pf *pflag.FlagSet, src configSources) ([]string, []ConfigOption, error) {
 	options := options{}
 	if err := pf.ParseAll(args, parseFlag(&options)); err != nil {
-		return pf.Args(), options.options, err
+		return pf.Args(), nil, err
+	}
+	if options.err != nil {
+		return pf.Args(), nil, options.err
 	}
-	return pf.Args(), options.options, options.err
+	file := options.file
+	if !options.config {
+		var err error
+		if file, err = discoverConfig(src.files); err != nil {
+			return pf.Args(), nil, err
+		}
+	}
+	var opts []ConfigOption
+	opts = append(opts, file...)
+	opts = append(opts, src.env...)
+	opts = append(opts, options.options...)
+	return pf.Args(), opts, nil
 }
 
 func createFlagSet() *pflag.FlagSet {
 	pf := pflag.NewFlagSet("monny", pflag.ContinueOnError)
 	pf.Usage = func() {
-		fmt.Printf("Usage of monny:\nmonny -i <identifier> <options> mycommand\nmonny -i <identifier> <options> -- mycommand <mycommand-options>\n")
+		fmt.Printf("Usage of monny:\nmonny [run] -i <identifier> <options> mycommand\nmonny [run] -i <identifier> <options> -- mycommand <mycommand-options>\n")
+		fmt.Printf("\nOther commands:\n%s", subcommandUsage())
 		fmt.Printf("\n%s", pf.FlagUsagesWrapped(10))
+		fmt.Printf("\nOptions can also be set with environment variables by prefixing the option with %s (e.g. %sID=myjob, %sTIMEOUT_WARN=5m).  Separate multiple rules with commas.\n", envPrefix, envPrefix, envPrefix)
+		fmt.Printf("Without -c, the configuration file is read from the first of ./monny.yaml, $XDG_CONFIG_HOME/monny/config.yaml, or /etc/monny/config.yaml that exists.  Flags override environment variables, which override the configuration file.\n")
 		fmt.Printf("\n\nFor unknown flag errors, add an empty flag separator (--) between the flags for monny and your command.  Example:\n\nmonny -i id -c config.yml -- mycommand --otherflag\n")
 	}
 
 	pf.StringP("id", "i", "", "Identifier for this monitor (required)")
-	pf.StringP("config", "c", "", "Use yaml configuration file")
+	pf.StringArrayP("config", "c", nil, "Use yaml configuration file.  Repeat to combine several files in order, with later files overriding options set by earlier files and adding to repeated options such as rule and creates.")
+	pf.String("preset", "", "Apply the options of a named preset.  Options set by flags, environment variables, or the configuration file override the preset.")
+	pf.String("presets-file", "", "Load named presets from this YAML file.  Each top-level key is a preset name with options in the configuration file format.")
 	pf.String("rule", "", "Creates a notification if this string appears in the output.  Regex OK.")
 	pf.String("rule-json", "", "Creates a notification if this text appears in the JSON output.  Accepts the field and a regular expression or simple text separated by a colon (e.g. field:value).  Nested JSON structures are accessed using a flattened path with a dot (e.g. field.nested:value).")
+	pf.String("rule-window", "", "Creates a notification if this text appears in the output within a window of time after the process starts.  Accepts the start and end of the window and a regular expression separated by colons (e.g. 10m::ERROR after 10 minutes, :5m:ERROR during the first 5 minutes).")
+	pf.Int("rule-quantity", 0, "Send a report when the number of rule matches reaches this value instead of on every match.")
+	pf.String("rule-period", "", "Used with --rule-quantity to send a report when the rule matches reach the quantity within this period (e.g., 10m).  Accepts values in us, s, m, h, or a number of seconds.")
+	pf.String("metric-rule", "", "Creates a notification if the rate of lines matching a regex increases.  Accepts a name, the stream to count (stdout, stderr, or both), and the regex separated by colons (e.g. errors:stderr:ERROR).")
+	pf.String("metric-rule-sampling", "", "Record on average 1 in every k windows of a metric rule in its estimator to protect it from a high rate of windows.  Accepts the name of the metric rule and k separated by a colon (e.g. errors:10).")
+	pf.String("metric-rule-rate-limit", "", "Record at most n windows of a metric rule in its estimator in each period.  Accepts the name of the metric rule, n, and the period separated by colons (e.g. errors:100:1h).")
+	pf.String("metric-window", "15s", "Window over which metric rule matches are counted (e.g., 30s).  Accepts values in us, s, m, h, or a number of seconds.")
+	pf.Float64("stat-lambda", statLambda, "Weight of each observation in the EWMA estimator of metric rules, greater than 0 and at most 1.  Larger values detect changes in the rate of matches sooner but alarm falsely more often.")
+	pf.String("sample-strategy", "sum", "How the lines matching a metric rule in each window are combined into one observation: sum, avg, max, min, or count.  The value of a line is the capture group named value, or 1.")
+	pf.String("error-budget", "", "Send a report when the error rate exceeds the error budget of an SLO over a rolling window.  Accepts the SLO percentage and window separated by a colon (e.g., 99.9:720h).")
+	pf.String("error-budget-error", "", "Count lines matching this regex as errors against the error budget")
+	pf.String("error-budget-total", "", "Count lines matching this regex as requests in the error budget, by default every line")
+	pf.Int("error-budget-min-requests", errorBudgetMinRequests, "Count at least this many requests in the window before the error budget can be exhausted")
+	pf.String("prometheus-export", "", "Serve the estimator metrics of metric rules in the Prometheus text format at /metrics on this address (e.g., :9464) while the process runs")
+	pf.String("otel-endpoint", "", "Export a trace span covering the process to an OpenTelemetry collector using OTLP over HTTP (e.g., localhost:4318).  Rule matches and alarms are recorded as events on the span.")
+	pf.String("metrics-snapshot", "", "Write the final metrics of metric rule estimators to this file when the process finishes.  Files ending in .csv are written as CSV, otherwise JSON.")
 	pf.Int("stdout-history", 30, "Number of lines of stdout to send with the report.")
 	pf.Int("stderr-history", 30, "Number of lines of stderr to send with the report.")
 	pf.Bool("no-notify-on-success", false, "Do not send a report on succesful completion of this process.")
 	pf.Bool("no-notify-on-failure", false, "Do not send a notification on failure.")
 	pf.Bool("daemon", false, "Designate this process as a daemon or long-running process. Any notifications triggered will be sent immediately instead of waiting for the process to finish.")
-	pf.String("memory-warn", "", "Send a notification when memory use exceeds the value.  Accepts integers ending in K, M, G.  Example: 100M")
-	pf.String("memory-kill", "", "Kill the process and send a notification when memory use exceeds the value.  Accepts integers ending in K, M, G.  Example: 100M")
-	pf.Duration("timeout-warn", time.Duration(0), "Send a notification if process duration exceeds value (e.g., 32m).  Accepts values in us, s, m, h.")
-	pf.Duration("timeout-kill", time.Duration(0), "Kill process and send a notification if process duration exceeds value (e.g., 32m).  Accepts values in us, s, m, h.")
+	pf.String("schedule", "", "Run the command on a cron schedule until monny is stopped, such as \"*/5 * * * *\", \"@hourly\" or \"@every 10m\".  A sixth field at the start sets the seconds.")
+	pf.String("max-concurrent", "1", "Number of scheduled runs of the command that can run at the same time.  A run that is due when this many are running is skipped and reported.")
+	pf.String("expected-every", "", "Send a late run report when the last successful run was longer ago than this interval (e.g., 24h), for cron jobs without a server to notice missing runs")
+	pf.String("heartbeat", "", "Send a heartbeat report with the uptime and memory of a daemon at this interval (e.g., 5m)")
+	pf.String("memory-warn", "", "Send a notification when memory use exceeds the value.  Accepts sizes ending in K, M, G or KiB, MiB, GiB, or a number of kilobytes.  Example: 1.5G")
+	pf.String("memory-kill", "", "Kill the process and send a notification when memory use exceeds the value.  Accepts sizes ending in K, M, G or KiB, MiB, GiB, or a number of kilobytes.  Example: 1.5G")
+	pf.String("timeout-warn", "", "Send a notification if process duration exceeds value (e.g., 32m).  Accepts values in us, s, m, h, or a number of seconds.")
+	pf.String("timeout-kill", "", "Kill process and send a notification if process duration exceeds value (e.g., 32m).  Accepts values in us, s, m, h, or a number of seconds.")
 	pf.String("creates", "", "Send notification if file is not created after end of process")
+	pf.String("creates-watch", "", "Send notification while the process is running if no file matching a glob is created within an interval.  Accepts the glob and interval separated by a colon (e.g. 'data/part-*.csv:1h').")
 	pf.String("host", "", "Host to which to send the reports as host:port")
+	pf.String("report-file", "", "Append reports to this file as JSON Lines instead of sending them to the report server")
 	pf.Bool("insecure", false, "Do not use TLS to secure connection for reports")
+	pf.String("token", "", "API token used to authenticate reports to the report server.  Can also be set with the MONNY_TOKEN environment variable.")
+	pf.String("ca-cert", "", "Trust this PEM encoded certificate authority when connecting to the report server")
+	pf.String("client-cert", "", "Present a client certificate to the report server.  Accepts the paths to the PEM encoded certificate and key separated by a comma (e.g. cert.pem,key.pem).")
+	pf.Bool("tls-skip-verify", false, "Do not verify the certificate of the report server")
+	pf.String("dump-env-on-failure", "", "Write the environment of the process to this file when it fails, with secrets redacted.  The environment is not sent in the report.")
+	pf.String("include-env", "", "Include these comma-separated environment variables and their values in each report.  Variables with names like TOKEN, SECRET, PASSWORD, or KEY are never included.")
+	pf.String("redact-env", "", "Redact environment variables with names matching this regex in the file written by --dump-env-on-failure.  Variables with names like TOKEN, SECRET, PASSWORD, or KEY are always redacted.")
+	pf.Bool("jsonl-matches", false, "Encode rule matches in reports as JSON Lines instead of a single JSON array")
+	pf.Bool("merge-matches", false, "Record one rule match for a line that matches several rules")
+	pf.Bool("coerce-json-numbers", false, "Match JSON rules against string fields that contain a number (e.g. \"123.4\") in the same format as JSON numbers (e.g. 123.400000)")
+	pf.Bool("no-config-in-report", false, "Do not include the monny configuration in reports")
 	pf.Bool("no-error-reports", false, "Do not send reports when there are unexpected errors in the client")
+	pf.Bool("dry-run", false, "Validate the configuration and print the resolved configuration to stderr without running the command or sending reports")
+	pf.Bool("print-reports", false, "Print the resolved configuration, then run the command and print each report to stderr instead of sending it")
+	pf.String("dry-run-file", "", "Append the output of --dry-run or --print-reports to this file instead of stderr.  Enables --dry-run unless --print-reports is set.")
+	pf.String("log-format", "", "Format of the log messages of monny: text, json, or logfmt")
+	pf.Bool("verbose", false, "Log report sends and kill decisions to stderr and print unexpected errors in monny on exit.  Errors are also sent in report messages.")
+	pf.Bool("debug", false, "Log every decision made by monny to stderr, including rule matches, reports that are not sent, and process events.")
+	pf.BoolP("quiet", "q", false, "Do not echo the output of the process.  Output is still monitored for rules and sent in reports.")
 	pf.String("shell", "", "Shell to use to execute command")
+	pf.Bool("no-shell", false, "Execute the command directly without a shell.  Shell operators such as && and | are passed to the command as arguments.")
+	pf.Bool("no-stdin", false, "Disconnect the command from the stdin of monny so that it reads no input")
+	pf.Bool("shell-strict", false, "Run commands that contain shell operators with set -euo pipefail")
+	pf.Bool("pty", false, "Run the command attached to a pseudo-terminal for tools that detect a TTY.  Stdout and stderr are combined into a single stream.")
+	pf.Bool("multiline-json", false, "Parse JSON log entries that are pretty-printed across multiple lines as a single entry.")
+	pf.String("command-template", "", "Render the command from a template before execution (e.g., \"backup --date {{.date}}\").  Variables are set with --var.  Words are split like the shell, so a variable with spaces is a single argument.")
+	pf.String("pre-run", "", "Run this command before the monitored command, such as to acquire a lock.  Its output is not checked for rules.  If it fails, the command is not run and a failure report is sent.")
+	pf.String("working-dir", "", "Run the command and the pre-run command in this directory instead of the current directory")
+	pf.String("step", "", "Add a command to run as a step of a pipeline.  Steps run in order and one report covers the whole pipeline.  Can be set more than once, or as a list of steps in the configuration file.")
+	pf.Bool("pipeline", false, "Run the commands separated by -- as the steps of a pipeline (e.g., monny --pipeline -- extract.sh -- transform.sh -- load.sh)")
+	pf.Bool("continue-on-error", false, "Run the remaining steps of a pipeline after a step fails instead of stopping at the first failure")
+	pf.Bool("parallel", false, "Run the steps, or the commands separated by --, at the same time with one report for all of them (e.g., monny --parallel -- cmd1 -- cmd2).  Each line of output is tagged with its step.")
+	pf.Bool("fail-fast", false, "Kill the other parallel commands when one fails")
+	pf.Bool("kill-on-broken-pipe", false, "Stop the command with SIGPIPE when the reader of the output closes it, such as monny -- producer | head, instead of running it to completion without echoing its output")
+	pf.String("var", "", "Set a variable for the command template as key=value")
+	pf.String("max-report-size", "3MiB", "Maximum size of a report.  The oldest lines of stdout and stderr are dropped from larger reports.  Accepts sizes ending in K, M or KiB, MiB.  Example: 512KiB")
+	pf.String("max-line-size", "1MiB", "Longest line of output that is processed.  Output is no longer captured after a longer line.  Accepts sizes ending in K, M or KiB, MiB.  Example: 4MiB")
+	pf.Bool("no-compression", false, "Do not compress reports sent to the report server")
+	pf.String("batch-reports", "", "Combine alerts from rule matches within this window into a single report (e.g., 1m).  Accepts values in us, s, m, h, or a number of seconds.")
+	pf.String("batch-interval", "", "Send reports generated within this interval to the server in a single call (e.g., 5s).  Accepts values in us, s, m, h, or a number of seconds.")
+	pf.Int("batch-size", 1, "Send reports to the server in a single call once this many are waiting.  With --batch-interval, the reports are sent as soon as this many are waiting instead of when the interval elapses.")
+	pf.String("batch-window", "", "Used with --batch-size to send a batch that is not full once this window has elapsed since its first report (e.g., 5s).  Accepts values in us, s, m, h, or a number of seconds.")
 
 	return pf
 }
@@ -67,12 +286,13 @@ func parseFlag(o *options) func(*pflag.Flag, string) error {
 	return func(flag *pflag.Flag, value string) error {
 		switch flag.Name {
 		case "config":
+			o.config = true
 			opts, err := parseFromFile(value)
 			if err != nil {
 				o.err = err
 				return err
 			}
-			o.options = append(o.options, opts...)
+			o.file = append(o.file, opts...)
 		default:
 			option, err := handleOption(flag.Name, value)
 			if err != nil {
@@ -89,6 +309,14 @@ func handleOption(name string, value string) (ConfigOption, error) {
 	switch name {
 	case "id":
 		return ID(value), nil
+	case "preset":
+		return Preset(value), nil
+	case "presets-file":
+		// presets are registered when the option is parsed, before any preset is applied to the configuration
+		if err := LoadPresets(value); err != nil {
+			return nil, err
+		}
+		return func(c *Config) error { return nil }, nil
 	case "rule":
 		return Rule(value), nil
 	case "rule-json":
@@ -97,6 +325,92 @@ func handleOption(name string, value string) (ConfigOption, error) {
 			return nil, fmt.Errorf("invalid format for json rule, should be field:value only in %s", value)
 		}
 		return JSONRule(jrule[0][0:len(jrule[0])-1], jrule[1]), nil
+	case "rule-window":
+		wrule := strings.SplitN(value, ":", 3)
+		if len(wrule) != 3 {
+			return nil, fmt.Errorf("invalid format for rule window, should be after:before:regex only in %s", value)
+		}
+		var window [2]time.Duration
+		for i, d := range wrule[0:2] {
+			if len(d) == 0 {
+				continue
+			}
+			var err error
+			if window[i], err = units.ParseDuration(d); err != nil {
+				return nil, ErrInvalidDuration{Option: "rule-window", Value: d}
+			}
+		}
+		return RuleWindow(wrule[2], window[0], window[1]), nil
+	case "rule-quantity":
+		return RuleQuantity(value), nil
+	case "rule-period":
+		return RulePeriod(value), nil
+	case "metric-rule":
+		mrule := strings.SplitN(value, ":", 3)
+		if len(mrule) != 3 {
+			return nil, fmt.Errorf("invalid format for metric rule, should be name:stream:regex only in %s", value)
+		}
+		return MetricRule(mrule[0], mrule[1], mrule[2]), nil
+	case "metric-rule-sampling":
+		sampling := strings.SplitN(value, ":", 2)
+		if len(sampling) != 2 {
+			return nil, fmt.Errorf("invalid format for metric rule sampling, should be name:k only in %s", value)
+		}
+		k, err := strconv.Atoi(sampling[1])
+		if err != nil {
+			return nil, ErrInvalidNumber{Option: "metric-rule-sampling", Value: sampling[1]}
+		}
+		return MetricRuleSampling(sampling[0], k), nil
+	case "metric-rule-rate-limit":
+		limit := strings.SplitN(value, ":", 3)
+		if len(limit) != 3 {
+			return nil, fmt.Errorf("invalid format for metric rule rate limit, should be name:n:period only in %s", value)
+		}
+		n, err := strconv.Atoi(limit[1])
+		if err != nil {
+			return nil, ErrInvalidNumber{Option: "metric-rule-rate-limit", Value: limit[1]}
+		}
+		period, err := units.ParseDuration(limit[2])
+		if err != nil {
+			return nil, ErrInvalidDuration{Option: "metric-rule-rate-limit", Value: limit[2]}
+		}
+		return MetricRuleRateLimit(limit[0], n, period), nil
+	case "metric-window":
+		return MetricWindow(value), nil
+	case "sample-strategy":
+		return SampleStrategy(value), nil
+	case "stat-lambda":
+		lambda, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, ErrInvalidNumber{Option: "stat-lambda", Value: value}
+		}
+		return StatLambda(lambda), nil
+	case "error-budget":
+		budget := strings.SplitN(value, ":", 2)
+		if len(budget) != 2 {
+			return nil, fmt.Errorf("invalid format for error budget, should be slo:window only in %s", value)
+		}
+		slo, err := strconv.ParseFloat(strings.TrimSuffix(budget[0], "%"), 64)
+		if err != nil {
+			return nil, ErrInvalidNumber{Option: "error-budget", Value: budget[0]}
+		}
+		window, err := units.ParseDuration(budget[1])
+		if err != nil {
+			return nil, ErrInvalidDuration{Option: "error-budget", Value: budget[1]}
+		}
+		return ErrorBudget(slo, window), nil
+	case "error-budget-error":
+		return ErrorBudgetError(value), nil
+	case "error-budget-total":
+		return ErrorBudgetTotal(value), nil
+	case "error-budget-min-requests":
+		return ErrorBudgetMinRequests(value), nil
+	case "prometheus-export":
+		return WithPrometheusExport(value), nil
+	case "otel-endpoint":
+		return OTelTracing(value), nil
+	case "metrics-snapshot":
+		return MetricsSnapshotFile(value), nil
 	case "stdout-history":
 		return StdoutHistory(value), nil
 	case "stderr-history":
@@ -107,6 +421,14 @@ func handleOption(name string, value string) (ConfigOption, error) {
 		return NoNotifyOnFailure(), nil
 	case "daemon":
 		return Daemon(), nil
+	case "heartbeat":
+		return Heartbeat(value), nil
+	case "schedule":
+		return Schedule(value), nil
+	case "max-concurrent":
+		return MaxConcurrent(value), nil
+	case "expected-every":
+		return ExpectedEvery(value), nil
 	case "memory-warn":
 		return MemoryWarn(value), nil
 	case "memory-kill":
@@ -117,90 +439,370 @@ func handleOption(name string, value string) (ConfigOption, error) {
 		return KillTimeout(value), nil
 	case "creates":
 		return Creates(value), nil
+	case "creates-watch":
+		i := strings.LastIndex(value, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid format for creates watch, should be glob:interval only in %s", value)
+		}
+		interval, err := units.ParseDuration(value[i+1:])
+		if err != nil {
+			return nil, ErrInvalidDuration{Option: "creates-watch", Value: value[i+1:]}
+		}
+		return CreatesWatch(value[:i], interval), nil
 	case "host":
 		return Host(value), nil
+	case "report-file":
+		return ReportFile(value), nil
 	case "insecure":
 		return Insecure(), nil
+	case "token":
+		return APIToken(value), nil
+	case "ca-cert":
+		return CACert(value), nil
+	case "client-cert":
+		paths := strings.Split(value, ",")
+		if len(paths) != 2 {
+			return nil, fmt.Errorf("invalid format for client cert, should be cert,key only in %s", value)
+		}
+		return ClientCert(paths[0], paths[1]), nil
+	case "tls-skip-verify":
+		return TLSSkipVerify(), nil
+	case "dump-env-on-failure":
+		return DumpEnvOnFailure(value), nil
+	case "include-env":
+		return IncludeEnv(value), nil
+	case "redact-env":
+		return RedactEnv(value), nil
+	case "jsonl-matches":
+		return UseJSONLMatches(), nil
+	case "merge-matches":
+		return MergeMatches(), nil
+	case "coerce-json-numbers":
+		return CoerceJSONNumbers(), nil
+	case "no-config-in-report":
+		return NoConfigInReport(), nil
 	case "no-error-reports":
 		return NoErrorReports(), nil
+	case "dry-run":
+		return DryRun(), nil
+	case "print-reports":
+		return PrintReports(), nil
+	case "dry-run-file":
+		return DryRunFile(value), nil
+	case "verbose":
+		return Verbose(), nil
+	case "log-format":
+		return StructuredLogging(nil, value), nil
+	case "debug":
+		return Debug(), nil
+	case "quiet":
+		return Quiet(), nil
 	case "shell":
 		return Shell(value), nil
+	case "no-shell":
+		return NoShell(), nil
+	case "no-stdin":
+		return NoStdin(), nil
+	case "shell-strict":
+		return ShellStrict(), nil
+	case "pty":
+		return PTY(), nil
+	case "multiline-json":
+		return MultiLineJSON(), nil
+	case "command-template":
+		return CommandTemplate(value, nil), nil
+	case "pre-run":
+		return PreRun(value), nil
+	case "working-dir":
+		return WorkingDir(value), nil
+	case "step":
+		return Step(value), nil
+	case "pipeline":
+		return Pipeline(), nil
+	case "continue-on-error":
+		return ContinueOnError(), nil
+	case "parallel":
+		return Parallel(), nil
+	case "fail-fast":
+		return FailFast(), nil
+	case "kill-on-broken-pipe":
+		return KillOnBrokenPipe(), nil
+	case "var":
+		kv := strings.SplitN(value, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid format for template variable, should be key=value only in %s", value)
+		}
+		return TemplateVar(kv[0], kv[1]), nil
+	case "max-line-size":
+		return MaxLineSize(value), nil
+	case "max-report-size":
+		return MaxReportBytes(value), nil
+	case "no-compression":
+		return NoCompression(), nil
+	case "batch-reports":
+		return BatchReports(value), nil
+	case "batch-interval":
+		return BatchInterval(value), nil
+	case "batch-size":
+		return BatchSize(value), nil
+	case "batch-window":
+		return BatchWindow(value), nil
 	default:
 		return nil, fmt.Errorf("Unknown option: %s", name)
 	}
 }
 
+// parseFromFile returns the options set in a configuration file.  Errors in the file include its path.
 func parseFromFile(fpath string) ([]ConfigOption, error) {
-	var options []ConfigOption
 	data, err := ioutil.ReadFile(fpath)
 	if err != nil {
-		return options, err
+		return nil, err
+	}
+	opts, err := parseYAML(data)
+	var fileErr ErrConfigFile
+	if errors.As(err, &fileErr) {
+		return opts, fileErr.inFile(fpath)
 	}
+	return opts, err
+}
 
+// parseYAML returns the options set in YAML data in the configuration file format.  Every problem in the file is
+// collected and returned together as ErrConfigFile with the line of each key.
+func parseYAML(data []byte) ([]ConfigOption, error) {
 	cfg := make(map[string]interface{})
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return options, err
+		return nil, err
 	}
-	for k, v := range cfg {
+	return parseYAMLValues(cfg, yamlKeyLines(data))
+}
 
-		switch v.(type) {
-		case string:
-			opt, err := handleOption(k, v.(string))
-			if err != nil {
-				return options, err
-			}
-			options = append(options, opt)
-		case int:
-			opt, err := handleOption(k, strconv.Itoa(v.(int)))
+// parseYAMLValues returns the options set by each key of cfg.  Lines holds the line number of each key, if known,
+// that is included in errors.
+func parseYAMLValues(cfg map[string]interface{}, lines map[string]int) ([]ConfigOption, error) {
+	var options []ConfigOption
+	var errs []error
+	pf := createFlagSet()
+	fail := func(k string, err error) {
+		errs = append(errs, ErrConfigKey{Key: k, Line: lines[k], Err: err})
+	}
+	for k, v := range cfg {
+		if k == "rules" {
+			opts, err := parseRules(v)
 			if err != nil {
-				return options, err
+				fail(k, err)
+				continue
 			}
-			options = append(options, opt)
-		case bool:
-			opt, err := handleOption(k, "")
+			options = append(options, opts...)
+			continue
+		}
+		name := k
+		if k == "steps" {
+			// steps is the list form of step
+			name = "step"
+		}
+		flag := pf.Lookup(name)
+		if k == "config" || flag == nil {
+			errs = append(errs, ErrUnknownOption{Key: k, Line: lines[k], Suggestion: suggestOption(k, pf)})
+			continue
+		}
+		values, err := yamlValues(flag, v)
+		if err != nil {
+			fail(k, err)
+			continue
+		}
+		for _, value := range values {
+			opt, err := handleOption(name, value)
 			if err != nil {
-				return options, err
+				fail(k, err)
+				break
 			}
 			options = append(options, opt)
-		// handles the case of a list of rules
-		case interface{}:
-			alt := listFieldsYAML{}
-			if err := yaml.Unmarshal(data, &alt); err != nil {
-				return options, fmt.Errorf("Could not unmarshal config value for key: %s", k)
-			}
-			if len(alt.Rule) == 0 && len(alt.JSONRule) == 0 && len(alt.Creates) == 0 {
-				return options, fmt.Errorf("Unknown option: %s", k)
-			}
-			for _, val := range alt.Rule {
-				opt, err := handleOption("rule", val)
-				if err != nil {
-					return options, err
-				}
-				options = append(options, opt)
-			}
-			for _, val := range alt.JSONRule {
-				opt, err := handleOption("rule-json", val)
-				if err != nil {
-					return options, err
-				}
-				options = append(options, opt)
+		}
+	}
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool { return errorLine(errs[i]) < errorLine(errs[j]) })
+		return options, ErrConfigFile{Errors: errs}
+	}
+	return options, nil
+}
+
+// yamlValues checks a value in the configuration file against the type of the flag of the option, and returns the
+// values to set with handleOption.  Options that can be set more than once also accept a list of values.  A boolean
+// option set to false returns no values.
+func yamlValues(flag *pflag.Flag, v interface{}) ([]string, error) {
+	items, ok := v.([]interface{})
+	if !ok {
+		return yamlScalar(flag, v)
+	}
+	if !listOptions[flag.Name] {
+		return nil, fmt.Errorf("expected %s, got a list (%s can only be set once)", yamlTypeName(flag.Value.Type()), flag.Name)
+	}
+	var values []string
+	for i, item := range items {
+		value, err := yamlScalar(flag, item)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %v", i+1, err)
+		}
+		values = append(values, value...)
+	}
+	return values, nil
+}
+
+// yamlScalar returns the value of a single YAML value for the flag of an option.  Numbers are accepted for string
+// options, such as a duration in seconds, and strings for numeric options are validated when the option is applied.
+func yamlScalar(flag *pflag.Flag, v interface{}) ([]string, error) {
+	kind := flag.Value.Type()
+	switch value := v.(type) {
+	case bool:
+		if kind == "bool" {
+			if value {
+				return []string{""}, nil
 			}
-			for _, val := range alt.Creates {
-				opt, err := handleOption("creates", val)
-				if err != nil {
-					return options, err
-				}
-				options = append(options, opt)
+			return nil, nil
+		}
+	case string:
+		if kind != "bool" {
+			return []string{value}, nil
+		}
+	case int:
+		if kind != "bool" {
+			return []string{strconv.Itoa(value)}, nil
+		}
+	case float64:
+		if kind == "string" || kind == "float64" {
+			return []string{strconv.FormatFloat(value, 'f', -1, 64)}, nil
+		}
+	}
+	return nil, fmt.Errorf("expected %s, got %s", yamlTypeName(kind), yamlDescribe(v))
+}
+
+// yamlTypeName describes the YAML value expected for a flag type
+func yamlTypeName(kind string) string {
+	switch kind {
+	case "bool":
+		return "true or false"
+	case "int":
+		return "an integer"
+	case "float64":
+		return "a number"
+	default:
+		return "a string or number"
+	}
+}
+
+// yamlDescribe describes a value decoded from YAML for error messages
+func yamlDescribe(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return "no value"
+	case bool:
+		return fmt.Sprintf("%t", value)
+	case string:
+		return fmt.Sprintf("the string %q", value)
+	case int, float64:
+		return fmt.Sprintf("the number %v", value)
+	case []interface{}:
+		return "a list"
+	case map[interface{}]interface{}, map[string]interface{}:
+		return "a map"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// yamlKeyLines returns the line number of each top-level key in YAML data.  The YAML decoder does not report the
+// position of keys, so they are found by scanning for unindented lines in key: value form.
+func yamlKeyLines(data []byte) map[string]int {
+	lines := make(map[string]int)
+	for i, line := range strings.Split(string(data), "\n") {
+		if len(line) == 0 || line[0] == ' ' || line[0] == '\t' || line[0] == '#' || line[0] == '-' {
+			continue
+		}
+		idx := strings.Index(line, ":")
+		if idx <= 0 {
+			continue
+		}
+		key := strings.Trim(strings.TrimSpace(line[:idx]), `"'`)
+		if _, ok := lines[key]; !ok {
+			lines[key] = i + 1
+		}
+	}
+	return lines
+}
+
+// suggestOption returns the name of the option closest to key when key looks like a typo, or an empty string
+func suggestOption(key string, pf *pflag.FlagSet) string {
+	names := []string{"rules", "steps"}
+	pf.VisitAll(func(flag *pflag.Flag) {
+		if flag.Name != "config" {
+			names = append(names, flag.Name)
+		}
+	})
+	sort.Strings(names)
+
+	best, min := "", -1
+	for _, name := range names {
+		d := editDistance(key, name)
+		if min < 0 || d < min {
+			best, min = name, d
+		}
+	}
+	// allow about one edit for every three characters so that short keys are not matched to unrelated options
+	if min > 0 && (min <= 2 || min <= len(key)/3) && min < len(key) {
+		return best
+	}
+	return ""
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
 			}
-		default:
-			return options, fmt.Errorf("Could not process config key %s, unknown type", k)
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
 		}
+		prev, curr = curr, prev
 	}
-	return options, nil
+	return prev[len(b)]
+}
+
+func minInt(values ...int) int {
+	min := values[0]
+	for _, v := range values[1:] {
+		if v < min {
+			min = v
+		}
+	}
+	return min
 }
 
-type listFieldsYAML struct {
-	Rule     []string `yaml:"rule"`
-	JSONRule []string `yaml:"rule-json"`
-	Creates  []string `yaml:"creates"`
+// parseRules returns an option for each entry of the structured rules list in the configuration file.  An entry with
+// an unknown key is an error.
+func parseRules(v interface{}) ([]ConfigOption, error) {
+	entries, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("rules should be a list of rules")
+	}
+	var options []ConfigOption
+	for i, entry := range entries {
+		data, err := yaml.Marshal(entry)
+		if err != nil {
+			return nil, fmt.Errorf("could not read rule %d: %v", i, err)
+		}
+		var spec RuleSpec
+		if err := yaml.UnmarshalStrict(data, &spec); err != nil {
+			return nil, fmt.Errorf("invalid rule %d: %v", i, err)
+		}
+		options = append(options, StructuredRule(spec))
+	}
+	return options, nil
 }
@@ -1,10 +1,14 @@
 package monny
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-yaml/yaml"
 	"github.com/stretchr/testify/assert"
@@ -20,21 +24,89 @@ func TestParseFlags(t *testing.T) {
 		{Name: "id", Cmdline: "--id test", Expected: []ConfigOption{ID("test")}, Error: false},
 		{Name: "rule", Cmdline: "--rule test", Expected: []ConfigOption{Rule("test")}, Error: false},
 		{Name: "rule-json", Cmdline: "--rule-json field:test", Expected: []ConfigOption{JSONRule("field", "test")}, Error: false},
+		{Name: "rule-window", Cmdline: "--rule-window 10m::ERROR --rule-window :5m:a:b", Expected: []ConfigOption{RuleWindow("ERROR", 10*time.Minute, 0), RuleWindow("a:b", 0, 5*time.Minute)}, Error: false},
+		{Name: "rule-window invalid", Cmdline: "--rule-window 10x::ERROR", Error: true},
+		{Name: "rule-quantity", Cmdline: "--rule-quantity 5 --rule-period 10m", Expected: []ConfigOption{RuleQuantity("5"), RulePeriod("10m0s")}, Error: false},
+		{Name: "metric-rule", Cmdline: "--metric-rule errors:stderr:ERROR:.*", Expected: []ConfigOption{MetricRule("errors", "stderr", "ERROR:.*")}, Error: false},
+		{Name: "metric-rule invalid", Cmdline: "--metric-rule errors:ERROR", Expected: []ConfigOption{}, Error: true},
+		{Name: "metric-rule-sampling", Cmdline: "--metric-rule-sampling errors:10", Expected: []ConfigOption{MetricRuleSampling("errors", 10)}, Error: false},
+		{Name: "metric-rule-sampling invalid", Cmdline: "--metric-rule-sampling errors:ten", Expected: []ConfigOption{}, Error: true},
+		{Name: "metric-rule-rate-limit", Cmdline: "--metric-rule-rate-limit errors:100:1h", Expected: []ConfigOption{MetricRuleRateLimit("errors", 100, time.Hour)}, Error: false},
+		{Name: "metric-rule-rate-limit invalid", Cmdline: "--metric-rule-rate-limit errors:100", Expected: []ConfigOption{}, Error: true},
+		{Name: "metric-window", Cmdline: "--metric-window 30s", Expected: []ConfigOption{MetricWindow("30s")}, Error: false},
+		{Name: "metrics-snapshot", Cmdline: "--metrics-snapshot metrics.csv", Expected: []ConfigOption{MetricsSnapshotFile("metrics.csv")}, Error: false},
+		{Name: "stat-lambda", Cmdline: "--stat-lambda 0.1", Expected: []ConfigOption{StatLambda(0.1)}, Error: false},
+		{Name: "stat-lambda invalid", Cmdline: "--stat-lambda fast", Error: true},
+		{Name: "sample-strategy", Cmdline: "--sample-strategy max", Expected: []ConfigOption{SampleStrategy("max")}, Error: false},
+		{Name: "otel-endpoint", Cmdline: "--otel-endpoint localhost:4318", Expected: []ConfigOption{OTelTracing("localhost:4318")}, Error: false},
+		{Name: "prometheus-export", Cmdline: "--prometheus-export localhost:9464", Expected: []ConfigOption{WithPrometheusExport("localhost:9464")}, Error: false},
 		{Name: "stdout-history", Cmdline: "--stdout-history 75", Expected: []ConfigOption{StdoutHistory("75")}, Error: false},
 		{Name: "stderr-history", Cmdline: "--stderr-history 75", Expected: []ConfigOption{StderrHistory("75")}, Error: false},
 		{Name: "no-notify-on-success", Cmdline: "--no-notify-on-success", Expected: []ConfigOption{NoNotifyOnSuccess()}, Error: false},
 		{Name: "no-notify-on-failure", Cmdline: "--no-notify-on-failure", Expected: []ConfigOption{NoNotifyOnFailure()}, Error: false},
 		{Name: "daemon", Cmdline: "--daemon", Expected: []ConfigOption{Daemon()}, Error: false},
+		{Name: "heartbeat", Cmdline: "--daemon --heartbeat 5m", Expected: []ConfigOption{Daemon(), Heartbeat("5m")}, Error: false},
+		{Name: "schedule", Cmdline: "--schedule @hourly --max-concurrent 2", Expected: []ConfigOption{Schedule("@hourly"), MaxConcurrent("2")}, Error: false},
+		{Name: "error budget", Cmdline: "--error-budget 99.9:720h --error-budget-error ERROR --error-budget-total ^GET", Expected: []ConfigOption{ErrorBudget(99.9, 720*time.Hour), ErrorBudgetError("ERROR"), ErrorBudgetTotal("^GET")}, Error: false},
+		{Name: "error budget percent", Cmdline: "--error-budget 99%:1h --error-budget-error ERROR", Expected: []ConfigOption{ErrorBudget(99, time.Hour), ErrorBudgetError("ERROR")}, Error: false},
+		{Name: "error budget invalid", Cmdline: "--error-budget 99.9", Error: true},
+		{Name: "error budget min requests", Cmdline: "--error-budget-min-requests 100", Expected: []ConfigOption{ErrorBudgetMinRequests("100")}, Error: false},
+		{Name: "expected every", Cmdline: "--expected-every 24h", Expected: []ConfigOption{ExpectedEvery("24h")}, Error: false},
 		{Name: "memory-warn", Cmdline: "--memory-warn 100K", Expected: []ConfigOption{MemoryWarn("100K")}, Error: false},
 		{Name: "memory-kill", Cmdline: "--memory-kill 1G", Expected: []ConfigOption{MemoryKill("1G")}, Error: false},
 		{Name: "timeout-warn", Cmdline: "--timeout-warn 10m", Expected: []ConfigOption{NotifyTimeout("10m")}, Error: false},
 		{Name: "timeout-kill", Cmdline: "--timeout-kill 30m", Expected: []ConfigOption{KillTimeout("30m")}, Error: false},
+		{Name: "timeout-warn seconds", Cmdline: "--timeout-warn 90", Expected: []ConfigOption{NotifyTimeout("1m30s")}, Error: false},
 		{Name: "creates", Cmdline: "--creates /path/foo/bar", Expected: []ConfigOption{Creates("/path/foo/bar")}, Error: false},
 		{Name: "creates multiple", Cmdline: "--creates /path/foo/bar --creates /this/one/too", Expected: []ConfigOption{Creates("/path/foo/bar"), Creates("/this/one/too")}, Error: false},
+		{Name: "creates-watch", Cmdline: "--creates-watch data/part-*.csv:1h", Expected: []ConfigOption{CreatesWatch("data/part-*.csv", time.Hour)}, Error: false},
+		{Name: "creates-watch invalid", Cmdline: "--creates-watch data/part-*.csv", Expected: []ConfigOption{}, Error: true},
 		{Name: "host", Cmdline: "--host localhost:8080", Expected: []ConfigOption{Host("localhost:8080")}, Error: false},
+		{Name: "report-file", Cmdline: "--report-file /var/log/monny.jsonl", Expected: []ConfigOption{ReportFile("/var/log/monny.jsonl")}, Error: false},
 		{Name: "insecure", Cmdline: "--insecure", Expected: []ConfigOption{Insecure()}, Error: false},
+		{Name: "token", Cmdline: "--token abc123", Expected: []ConfigOption{APIToken("abc123")}, Error: false},
+		{Name: "ca-cert", Cmdline: "--ca-cert /path/ca.pem", Expected: []ConfigOption{CACert("/path/ca.pem")}, Error: false},
+		{Name: "client-cert", Cmdline: "--client-cert /path/cert.pem,/path/key.pem", Expected: []ConfigOption{ClientCert("/path/cert.pem", "/path/key.pem")}, Error: false},
+		{Name: "client-cert invalid", Cmdline: "--client-cert /path/cert.pem", Expected: []ConfigOption{}, Error: true},
+		{Name: "tls-skip-verify", Cmdline: "--tls-skip-verify", Expected: []ConfigOption{TLSSkipVerify()}, Error: false},
+		{Name: "jsonl-matches", Cmdline: "--jsonl-matches", Expected: []ConfigOption{UseJSONLMatches()}, Error: false},
+		{Name: "merge-matches", Cmdline: "--merge-matches", Expected: []ConfigOption{MergeMatches()}, Error: false},
+		{Name: "include-env", Cmdline: "--include-env GIT_SHA,DEPLOY_ENV", Expected: []ConfigOption{IncludeEnv("GIT_SHA,DEPLOY_ENV")}, Error: false},
+		{Name: "dump-env-on-failure", Cmdline: "--dump-env-on-failure env.txt --redact-env ^DB_", Expected: []ConfigOption{DumpEnvOnFailure("env.txt"), RedactEnv("^DB_")}, Error: false},
+		{Name: "coerce-json-numbers", Cmdline: "--coerce-json-numbers", Expected: []ConfigOption{CoerceJSONNumbers()}, Error: false},
+		{Name: "no-config-in-report", Cmdline: "--no-config-in-report", Expected: []ConfigOption{NoConfigInReport()}, Error: false},
 		{Name: "no-error-reports", Cmdline: "--no-error-reports", Expected: []ConfigOption{NoErrorReports()}, Error: false},
 		{Name: "shell", Cmdline: "--shell /usr/bin/zsh", Expected: []ConfigOption{Shell("/usr/bin/zsh")}, Error: false},
+		{Name: "dry-run", Cmdline: "--dry-run", Expected: []ConfigOption{DryRun()}, Error: false},
+		{Name: "print-reports", Cmdline: "--print-reports", Expected: []ConfigOption{PrintReports()}, Error: false},
+		{Name: "verbose", Cmdline: "--verbose", Expected: []ConfigOption{Verbose()}, Error: false},
+		{Name: "debug", Cmdline: "--debug", Expected: []ConfigOption{Debug()}, Error: false},
+		{Name: "log format", Cmdline: "--log-format json", Expected: []ConfigOption{StructuredLogging(nil, "json")}, Error: false},
+		{Name: "quiet", Cmdline: "--quiet", Expected: []ConfigOption{Quiet()}, Error: false},
+		{Name: "quiet short", Cmdline: "-q", Expected: []ConfigOption{Quiet()}, Error: false},
+		{Name: "pty", Cmdline: "--pty", Expected: []ConfigOption{PTY()}, Error: false},
+		{Name: "no shell", Cmdline: "--no-shell", Expected: []ConfigOption{NoShell()}, Error: false},
+		{Name: "no stdin", Cmdline: "--no-stdin", Expected: []ConfigOption{NoStdin()}, Error: false},
+		{Name: "shell strict", Cmdline: "--shell-strict", Expected: []ConfigOption{ShellStrict()}, Error: false},
+		{Name: "multiline-json", Cmdline: "--multiline-json", Expected: []ConfigOption{MultiLineJSON()}, Error: false},
+		{Name: "command-template", Cmdline: "--command-template backup", Expected: []ConfigOption{CommandTemplate("backup", nil)}, Error: false},
+		{Name: "pre-run", Cmdline: "--pre-run prime-cache", Expected: []ConfigOption{PreRun("prime-cache")}, Error: false},
+		{Name: "working-dir", Cmdline: "--working-dir .", Expected: []ConfigOption{WorkingDir(".")}, Error: false},
+		{Name: "step", Cmdline: "--step extract.sh --step load.sh", Expected: []ConfigOption{Step("extract.sh"), Step("load.sh")}, Error: false},
+		{Name: "pipeline", Cmdline: "--pipeline", Expected: []ConfigOption{Pipeline()}, Error: false},
+		{Name: "continue-on-error", Cmdline: "--continue-on-error", Expected: []ConfigOption{ContinueOnError()}, Error: false},
+		{Name: "parallel", Cmdline: "--parallel", Expected: []ConfigOption{Parallel()}, Error: false},
+		{Name: "fail-fast", Cmdline: "--fail-fast", Expected: []ConfigOption{FailFast()}, Error: false},
+		{Name: "kill-on-broken-pipe", Cmdline: "--kill-on-broken-pipe", Expected: []ConfigOption{KillOnBrokenPipe()}, Error: false},
+		{Name: "var", Cmdline: "--var date=2020-01-01 --var host=a=b", Expected: []ConfigOption{TemplateVar("date", "2020-01-01"), TemplateVar("host", "a=b")}, Error: false},
+		{Name: "var invalid", Cmdline: "--var date", Expected: []ConfigOption{}, Error: true},
+		{Name: "max-report-size", Cmdline: "--max-report-size 512K", Expected: []ConfigOption{MaxReportBytes("512K")}, Error: false},
+		{Name: "max-line-size", Cmdline: "--max-line-size 4MiB", Expected: []ConfigOption{MaxLineSize("4MiB")}, Error: false},
+		{Name: "no-compression", Cmdline: "--no-compression", Expected: []ConfigOption{NoCompression()}, Error: false},
+		{Name: "batch-reports", Cmdline: "--batch-reports 1m", Expected: []ConfigOption{BatchReports("1m0s")}, Error: false},
+		{Name: "batch-interval", Cmdline: "--batch-interval 5s", Expected: []ConfigOption{BatchInterval("5s")}, Error: false},
+		{Name: "batch-size", Cmdline: "--batch-interval 5s --batch-size 10", Expected: []ConfigOption{BatchInterval("5s"), BatchSize("10")}, Error: false},
+		{Name: "batch-window", Cmdline: "--batch-size 10 --batch-window 5s", Expected: []ConfigOption{BatchSize("10"), BatchWindow("5s")}, Error: false},
 		{Name: "error on unknown flag", Cmdline: "--does-not-exist", Expected: []ConfigOption{}, Error: true},
 		{Name: "multiple rules", Cmdline: "--rule test --rule foo", Expected: []ConfigOption{Rule("test"), Rule("foo")}, Error: false},
 		{Name: "multiple json rules", Cmdline: "--rule-json field:test --rule-json foo:bar", Expected: []ConfigOption{JSONRule("field", "test"), JSONRule("foo", "bar")}, Error: false},
@@ -43,7 +115,7 @@ func TestParseFlags(t *testing.T) {
 	for _, tc := range tt {
 		t.Run(tc.Name, func(t *testing.T) {
 			pf := createFlagSet()
-			_, options, err := parse(strings.Split(tc.Cmdline, " "), pf)
+			_, options, err := parse(strings.Split(tc.Cmdline, " "), pf, configSources{})
 			if tc.Error {
 				assert.Error(t, err)
 			} else {
@@ -66,24 +138,77 @@ func TestParseYAML(t *testing.T) {
 		{Name: "id", Yaml: map[string]interface{}{"id": "test"}, Expected: []ConfigOption{ID("test")}, Error: false},
 		{Name: "rule", Yaml: map[string]interface{}{"rule": "test"}, Expected: []ConfigOption{Rule("test")}, Error: false},
 		{Name: "rule-json", Yaml: map[string]interface{}{"rule-json": "field:test"}, Expected: []ConfigOption{JSONRule("field", "test")}, Error: false},
+		{Name: "metric-rule", Yaml: map[string]interface{}{"metric-rule": "errors:stderr:ERROR"}, Expected: []ConfigOption{MetricRule("errors", "stderr", "ERROR")}, Error: false},
+		{Name: "metric rule limits", Yaml: map[string]interface{}{"metric-rule-sampling": "errors:10", "metric-rule-rate-limit": "errors:100:1h", "metric-rule": "errors:stderr:ERROR"}, Expected: []ConfigOption{MetricRule("errors", "stderr", "ERROR"), MetricRuleSampling("errors", 10), MetricRuleRateLimit("errors", 100, time.Hour)}, Error: false},
+		{Name: "metric-window", Yaml: map[string]interface{}{"metric-window": "30s"}, Expected: []ConfigOption{MetricWindow("30s")}, Error: false},
+		{Name: "multiple metric rules", Yaml: map[string]interface{}{"metric-rule": []string{"errors:stderr:ERROR", "warnings:both:WARN"}}, Expected: []ConfigOption{MetricRule("errors", "stderr", "ERROR"), MetricRule("warnings", "both", "WARN")}, Error: false},
+		{Name: "metrics-snapshot", Yaml: map[string]interface{}{"metrics-snapshot": "metrics.csv"}, Expected: []ConfigOption{MetricsSnapshotFile("metrics.csv")}, Error: false},
+		{Name: "stat-lambda", Yaml: map[string]interface{}{"stat-lambda": 0.1}, Expected: []ConfigOption{StatLambda(0.1)}, Error: false},
+		{Name: "sample-strategy", Yaml: map[string]interface{}{"sample-strategy": "count"}, Expected: []ConfigOption{SampleStrategy("count")}, Error: false},
+		{Name: "otel-endpoint", Yaml: map[string]interface{}{"otel-endpoint": "http://localhost:4318"}, Expected: []ConfigOption{OTelTracing("http://localhost:4318")}, Error: false},
+		{Name: "prometheus-export", Yaml: map[string]interface{}{"prometheus-export": ":9464"}, Expected: []ConfigOption{WithPrometheusExport(":9464")}, Error: false},
 		{Name: "stdout-history", Yaml: map[string]interface{}{"stdout-history": 75}, Expected: []ConfigOption{StdoutHistory("75")}, Error: false},
 		{Name: "stderr-history", Yaml: map[string]interface{}{"stderr-history": 75}, Expected: []ConfigOption{StderrHistory("75")}, Error: false},
 		{Name: "no-notify-on-success", Yaml: map[string]interface{}{"no-notify-on-success": true}, Expected: []ConfigOption{NoNotifyOnSuccess()}, Error: false},
 		{Name: "no-notify-on-failure", Yaml: map[string]interface{}{"no-notify-on-failure": true}, Expected: []ConfigOption{NoNotifyOnFailure()}, Error: false},
 		{Name: "daemon", Yaml: map[string]interface{}{"daemon": true}, Expected: []ConfigOption{Daemon()}, Error: false},
+		{Name: "heartbeat", Yaml: map[string]interface{}{"heartbeat": "5m"}, Expected: []ConfigOption{Heartbeat("5m")}, Error: false},
+		{Name: "schedule", Yaml: map[string]interface{}{"schedule": "*/5 * * * *", "max-concurrent": 2}, Expected: []ConfigOption{Schedule("*/5 * * * *"), MaxConcurrent("2")}, Error: false},
+		{Name: "error budget", Yaml: map[string]interface{}{"error-budget": "99.9:720h", "error-budget-error": "ERROR"}, Expected: []ConfigOption{ErrorBudget(99.9, 720*time.Hour), ErrorBudgetError("ERROR")}, Error: false},
+		{Name: "error budget min requests", Yaml: map[string]interface{}{"error-budget-min-requests": 100}, Expected: []ConfigOption{ErrorBudgetMinRequests("100")}, Error: false},
+		{Name: "expected every", Yaml: map[string]interface{}{"expected-every": "24h"}, Expected: []ConfigOption{ExpectedEvery("24h")}, Error: false},
 		{Name: "memory-warn", Yaml: map[string]interface{}{"memory-warn": "100K"}, Expected: []ConfigOption{MemoryWarn("100K")}, Error: false},
 		{Name: "memory-kill", Yaml: map[string]interface{}{"memory-kill": "1G"}, Expected: []ConfigOption{MemoryKill("1G")}, Error: false},
 		{Name: "timeout-warn", Yaml: map[string]interface{}{"timeout-warn": "10m"}, Expected: []ConfigOption{NotifyTimeout("10m")}, Error: false},
 		{Name: "timeout-kill", Yaml: map[string]interface{}{"timeout-kill": "30m"}, Expected: []ConfigOption{KillTimeout("30m")}, Error: false},
+		{Name: "timeout-warn integer", Yaml: map[string]interface{}{"timeout-warn": 30}, Expected: []ConfigOption{NotifyTimeout("30s")}, Error: false},
+		{Name: "batch-interval float", Yaml: map[string]interface{}{"batch-interval": 2.5}, Expected: []ConfigOption{BatchInterval("2500ms")}, Error: false},
+		{Name: "memory-kill fractional", Yaml: map[string]interface{}{"memory-kill": "1.5GiB"}, Expected: []ConfigOption{MemoryKill("1610612K")}, Error: false},
 		{Name: "creates", Yaml: map[string]interface{}{"creates": "/path/foo/bar"}, Expected: []ConfigOption{Creates("/path/foo/bar")}, Error: false},
 		{Name: "creates multiple", Yaml: map[string]interface{}{"creates": []string{"/path/foo/bar", "/this/one/too"}}, Expected: []ConfigOption{Creates("/path/foo/bar"), Creates("/this/one/too")}, Error: false},
 		{Name: "host", Yaml: map[string]interface{}{"host": "localhost:8080"}, Expected: []ConfigOption{Host("localhost:8080")}, Error: false},
+		{Name: "report-file", Yaml: map[string]interface{}{"report-file": "/var/log/monny.jsonl"}, Expected: []ConfigOption{ReportFile("/var/log/monny.jsonl")}, Error: false},
 		{Name: "insecure", Yaml: map[string]interface{}{"insecure": true}, Expected: []ConfigOption{Insecure()}, Error: false},
+		{Name: "token", Yaml: map[string]interface{}{"token": "abc123"}, Expected: []ConfigOption{APIToken("abc123")}, Error: false},
+		{Name: "ca-cert", Yaml: map[string]interface{}{"ca-cert": "/path/ca.pem"}, Expected: []ConfigOption{CACert("/path/ca.pem")}, Error: false},
+		{Name: "client-cert", Yaml: map[string]interface{}{"client-cert": "/path/cert.pem,/path/key.pem"}, Expected: []ConfigOption{ClientCert("/path/cert.pem", "/path/key.pem")}, Error: false},
+		{Name: "tls-skip-verify", Yaml: map[string]interface{}{"tls-skip-verify": true}, Expected: []ConfigOption{TLSSkipVerify()}, Error: false},
+		{Name: "jsonl-matches", Yaml: map[string]interface{}{"jsonl-matches": true}, Expected: []ConfigOption{UseJSONLMatches()}, Error: false},
+		{Name: "merge-matches", Yaml: map[string]interface{}{"merge-matches": true}, Expected: []ConfigOption{MergeMatches()}, Error: false},
+		{Name: "no-config-in-report", Yaml: map[string]interface{}{"no-config-in-report": true}, Expected: []ConfigOption{NoConfigInReport()}, Error: false},
 		{Name: "no-error-reports", Yaml: map[string]interface{}{"no-error-reports": true}, Expected: []ConfigOption{NoErrorReports()}, Error: false},
 		{Name: "shell", Yaml: map[string]interface{}{"shell": "/usr/bin/zsh"}, Expected: []ConfigOption{Shell("/usr/bin/zsh")}, Error: false},
+		{Name: "dry-run", Yaml: map[string]interface{}{"dry-run": true}, Expected: []ConfigOption{DryRun()}, Error: false},
+		{Name: "print-reports", Yaml: map[string]interface{}{"print-reports": true}, Expected: []ConfigOption{PrintReports()}, Error: false},
+		{Name: "verbose", Yaml: map[string]interface{}{"verbose": true}, Expected: []ConfigOption{Verbose()}, Error: false},
+		{Name: "debug", Yaml: map[string]interface{}{"debug": true}, Expected: []ConfigOption{Debug()}, Error: false},
+		{Name: "log format", Yaml: map[string]interface{}{"log-format": "logfmt"}, Expected: []ConfigOption{StructuredLogging(nil, "logfmt")}, Error: false},
+		{Name: "quiet", Yaml: map[string]interface{}{"quiet": true}, Expected: []ConfigOption{Quiet()}, Error: false},
+		{Name: "pty", Yaml: map[string]interface{}{"pty": true}, Expected: []ConfigOption{PTY()}, Error: false},
+		{Name: "no shell", Yaml: map[string]interface{}{"no-shell": true}, Expected: []ConfigOption{NoShell()}, Error: false},
+		{Name: "no stdin", Yaml: map[string]interface{}{"no-stdin": true}, Expected: []ConfigOption{NoStdin()}, Error: false},
+		{Name: "shell strict", Yaml: map[string]interface{}{"shell-strict": true}, Expected: []ConfigOption{ShellStrict()}, Error: false},
+		{Name: "multiline-json", Yaml: map[string]interface{}{"multiline-json": true}, Expected: []ConfigOption{MultiLineJSON()}, Error: false},
+		{Name: "command-template", Yaml: map[string]interface{}{"command-template": "backup --date {{.date}}"}, Expected: []ConfigOption{CommandTemplate("backup --date {{.date}}", nil)}, Error: false},
+		{Name: "pre-run", Yaml: map[string]interface{}{"pre-run": "flock /tmp/job.lock true"}, Expected: []ConfigOption{PreRun("flock /tmp/job.lock true")}, Error: false},
+		{Name: "working-dir", Yaml: map[string]interface{}{"working-dir": "."}, Expected: []ConfigOption{WorkingDir(".")}, Error: false},
+		{Name: "steps", Yaml: map[string]interface{}{"steps": []string{"extract.sh", "transform.sh --strict"}}, Expected: []ConfigOption{Step("extract.sh"), Step("transform.sh --strict")}, Error: false},
+		{Name: "pipeline", Yaml: map[string]interface{}{"pipeline": true}, Expected: []ConfigOption{Pipeline()}, Error: false},
+		{Name: "continue-on-error", Yaml: map[string]interface{}{"continue-on-error": true}, Expected: []ConfigOption{ContinueOnError()}, Error: false},
+		{Name: "parallel", Yaml: map[string]interface{}{"parallel": true}, Expected: []ConfigOption{Parallel()}, Error: false},
+		{Name: "fail-fast", Yaml: map[string]interface{}{"fail-fast": true}, Expected: []ConfigOption{FailFast()}, Error: false},
+		{Name: "kill-on-broken-pipe", Yaml: map[string]interface{}{"kill-on-broken-pipe": true}, Expected: []ConfigOption{KillOnBrokenPipe()}, Error: false},
+		{Name: "var", Yaml: map[string]interface{}{"var": []string{"date=2020-01-01", "host=a"}}, Expected: []ConfigOption{TemplateVar("date", "2020-01-01"), TemplateVar("host", "a")}, Error: false},
+		{Name: "max-report-size", Yaml: map[string]interface{}{"max-report-size": "512K"}, Expected: []ConfigOption{MaxReportBytes("512K")}, Error: false},
+		{Name: "max-line-size", Yaml: map[string]interface{}{"max-line-size": "4MiB"}, Expected: []ConfigOption{MaxLineSize("4MiB")}, Error: false},
+		{Name: "no-compression", Yaml: map[string]interface{}{"no-compression": true}, Expected: []ConfigOption{NoCompression()}, Error: false},
+		{Name: "batch-interval", Yaml: map[string]interface{}{"batch-interval": "5s"}, Expected: []ConfigOption{BatchInterval("5s")}, Error: false},
+		{Name: "batch-size", Yaml: map[string]interface{}{"batch-size": 10}, Expected: []ConfigOption{BatchSize("10")}, Error: false},
+		{Name: "batch-window", Yaml: map[string]interface{}{"batch-window": "5s"}, Expected: []ConfigOption{BatchWindow("5s")}, Error: false},
 		{Name: "error on unknown flag", Yaml: map[string]interface{}{"does-not-exist": "test"}, Expected: []ConfigOption{}, Error: true},
 		{Name: "multiple rules", Yaml: map[string]interface{}{"rule": []string{"test", "foo"}}, Expected: []ConfigOption{Rule("test"), Rule("foo")}, Error: false},
 		{Name: "multiple json rules", Yaml: map[string]interface{}{"rule-json": []string{"field:test", "foo:bar"}}, Expected: []ConfigOption{JSONRule("field", "test"), JSONRule("foo", "bar")}, Error: false},
+		{Name: "multiple redact env", Yaml: map[string]interface{}{"redact-env": []string{"^DB_", "_URL$"}}, Expected: []ConfigOption{RedactEnv("^DB_"), RedactEnv("_URL$")}, Error: false},
 	}
 
 	for _, tc := range tt {
@@ -106,7 +231,7 @@ func TestParseYAML(t *testing.T) {
 			}
 
 			pf := createFlagSet()
-			_, options, err := parse([]string{"-c", f.Name()}, pf)
+			_, options, err := parse([]string{"-c", f.Name()}, pf, configSources{})
 			if tc.Error {
 				assert.Error(t, err)
 			} else {
@@ -119,6 +244,246 @@ func TestParseYAML(t *testing.T) {
 	}
 }
 
+func TestParseStructuredRules(t *testing.T) {
+	tt := []struct {
+		Name   string
+		Yaml   string
+		Expect []rule
+		Error  string
+	}{
+		{Name: "legacy", Yaml: "rule:\n  - ERROR\nrule-json:\n  - level:fatal\n", Expect: []rule{
+			{Regex: regexp.MustCompile("ERROR")},
+			{Field: "level", Regex: regexp.MustCompile("fatal")},
+		}},
+		{Name: "structured", Yaml: "rules:\n  - pattern: No space left\n    name: disk-full\n    severity: critical\n    stream: stderr\n  - field: duration\n    pattern: '^[0-9]{4,}'\n    quantity: 10\n    period: 5m\n", Expect: []rule{
+			{Regex: regexp.MustCompile("No space left"), Name: "disk-full", Severity: "critical", Stream: streamStderr},
+			{Field: "duration", Regex: regexp.MustCompile("^[0-9]{4,}"), Quantity: 10, Period: 5 * time.Minute},
+		}},
+		{Name: "mixed", Yaml: "rule: ERROR\nrule-json:\n  - level:fatal\nrules:\n  - pattern: panic\n    name: panic\n", Expect: []rule{
+			{Regex: regexp.MustCompile("ERROR")},
+			{Field: "level", Regex: regexp.MustCompile("fatal")},
+			{Regex: regexp.MustCompile("panic"), Name: "panic"},
+		}},
+		{Name: "window", Yaml: "rules:\n  - pattern: ERROR\n    after: 10m\n    before: 1h\n", Expect: []rule{
+			{Regex: regexp.MustCompile("ERROR"), After: 10 * time.Minute, Before: time.Hour},
+		}},
+		{Name: "unknown key", Yaml: "rules:\n  - pattern: ERROR\n  - pattern: panic\n    level: critical\n", Error: "invalid rule 1"},
+		{Name: "not a map", Yaml: "rules:\n  - ERROR\n", Error: "invalid rule 0"},
+		{Name: "not a list", Yaml: "rules: ERROR\n", Error: "rules should be a list"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			opts, err := parseYAML([]byte(tc.Yaml))
+			if len(tc.Error) > 0 {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.Error)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			var cfg Config
+			for _, opt := range opts {
+				assert.NoError(t, opt(&cfg))
+			}
+			// options from different keys are applied in any order
+			assert.ElementsMatch(t, tc.Expect, cfg.Rules)
+
+			// the structured rules round trip through YAML
+			specs := make([]RuleSpec, 0, len(cfg.Rules))
+			for _, r := range cfg.Rules {
+				specs = append(specs, RuleSpec{Pattern: r.Regex.String(), Field: r.Field, Name: r.Name, Severity: r.Severity, Quantity: r.Quantity, Period: r.Period, Stream: string(r.Stream), After: r.After, Before: r.Before})
+			}
+			data, err := yaml.Marshal(map[string]interface{}{"rules": specs})
+			assert.NoError(t, err)
+			opts, err = parseYAML(data)
+			assert.NoError(t, err)
+			var again Config
+			for _, opt := range opts {
+				assert.NoError(t, opt(&again))
+			}
+			assert.Equal(t, cfg.Rules, again.Rules)
+		})
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	data := "id: test\nstdout-histroy: 10\nrule:\n  - ERROR\ndeamon: true\n# comment\nrul: FATAL\nmemory-warn: 10X\n"
+	opts, err := parseYAML([]byte(data))
+	var fileErr ErrConfigFile
+	if !assert.True(t, errors.As(err, &fileErr)) {
+		t.FailNow()
+	}
+	if assert.Len(t, fileErr.Errors, 3) {
+		assert.Equal(t, ErrUnknownOption{Key: "stdout-histroy", Line: 2, Suggestion: "stdout-history"}, fileErr.Errors[0])
+		assert.Equal(t, ErrUnknownOption{Key: "deamon", Line: 5, Suggestion: "daemon"}, fileErr.Errors[1])
+		assert.Equal(t, ErrUnknownOption{Key: "rul", Line: 7, Suggestion: "rule"}, fileErr.Errors[2])
+	}
+	assert.Contains(t, err.Error(), "line 2: unknown option stdout-histroy, did you mean stdout-history?")
+	// options from valid keys are still returned
+	assert.Len(t, opts, 3)
+
+	// invalid values are reported when the options are applied
+	var cfg Config
+	var memErr ErrInvalidMemory
+	for _, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			assert.True(t, errors.As(err, &memErr))
+		}
+	}
+	assert.Equal(t, "test", cfg.ID)
+
+	_, err = parseYAML([]byte("zzzzzzzz: 1\nclient-cert: cert.pem\n"))
+	if assert.True(t, errors.As(err, &fileErr)) && assert.Len(t, fileErr.Errors, 2) {
+		assert.Equal(t, ErrUnknownOption{Key: "zzzzzzzz", Line: 1}, fileErr.Errors[0])
+		var keyErr ErrConfigKey
+		assert.True(t, errors.As(fileErr.Errors[1], &keyErr))
+		assert.Equal(t, 2, keyErr.Line)
+	}
+}
+
+func TestParseYAMLTypes(t *testing.T) {
+	tt := []struct {
+		Name    string
+		Yaml    string
+		Options int
+		Error   string
+	}{
+		{Name: "string", Yaml: "id: test\n", Options: 1},
+		{Name: "number for string", Yaml: "timeout-warn: 300\n", Options: 1},
+		{Name: "number for float", Yaml: "stat-lambda: 1\n", Options: 1},
+		{Name: "bool true", Yaml: "daemon: true\n", Options: 1},
+		{Name: "bool false", Yaml: "daemon: false\n", Options: 0},
+		{Name: "list of numbers", Yaml: "creates:\n  - 1\n  - out.txt\n", Options: 2},
+		{Name: "map for scalar", Yaml: "id: test\nshell:\n  path: /bin/sh\n", Error: "line 2: shell: expected a string or number, got a map"},
+		{Name: "list for scalar", Yaml: "id:\n  - one\n  - two\n", Error: "line 1: id: expected a string or number, got a list (id can only be set once)"},
+		{Name: "no value", Yaml: "id:\n", Error: "line 1: id: expected a string or number, got no value"},
+		{Name: "string for bool", Yaml: "daemon: sometimes\n", Error: `line 1: daemon: expected true or false, got the string "sometimes"`},
+		{Name: "number for bool", Yaml: "pty: 1\n", Error: "line 1: pty: expected true or false, got the number 1"},
+		{Name: "fraction for integer", Yaml: "stdout-history: 1.5\n", Error: "line 1: stdout-history: expected an integer, got the number 1.5"},
+		{Name: "map in list", Yaml: "rule:\n  - ERROR\n  - pattern: FATAL\n", Error: "line 1: rule: item 2: expected a string or number, got a map"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			opts, err := parseYAML([]byte(tc.Yaml))
+			if len(tc.Error) > 0 {
+				var fileErr ErrConfigFile
+				if assert.True(t, errors.As(err, &fileErr)) && assert.Len(t, fileErr.Errors, 1) {
+					assert.Equal(t, tc.Error, fileErr.Errors[0].Error())
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, opts, tc.Options)
+		})
+	}
+}
+
+func TestParseFromFileErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monny")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yml")
+	if err := ioutil.WriteFile(path, []byte("id: test\nstdouthistory: 10\ndaemon: maybe\n"), 0644); err != nil {
+		t.Fatalf("unexpected error writing config: %v", err)
+	}
+
+	_, err = parseFromFile(path)
+	var fileErr ErrConfigFile
+	if assert.True(t, errors.As(err, &fileErr)) && assert.Len(t, fileErr.Errors, 2) {
+		assert.Equal(t, ErrUnknownOption{Key: "stdouthistory", Line: 2, Suggestion: "stdout-history", File: path}, fileErr.Errors[0])
+		assert.Equal(t, path+":2: unknown option stdouthistory, did you mean stdout-history?", fileErr.Errors[0].Error())
+		assert.Equal(t, path+`:3: daemon: expected true or false, got the string "maybe"`, fileErr.Errors[1].Error())
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tt := []struct {
+		A, B   string
+		Expect int
+	}{
+		{A: "", B: "", Expect: 0},
+		{A: "rule", B: "", Expect: 4},
+		{A: "rule", B: "rule", Expect: 0},
+		{A: "rul", B: "rule", Expect: 1},
+		{A: "deamon", B: "daemon", Expect: 2},
+		{A: "kitten", B: "sitting", Expect: 3},
+	}
+	for _, tc := range tt {
+		assert.Equal(t, tc.Expect, editDistance(tc.A, tc.B), "%s to %s", tc.A, tc.B)
+		assert.Equal(t, tc.Expect, editDistance(tc.B, tc.A), "%s to %s", tc.B, tc.A)
+	}
+}
+
+func TestParseEnv(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Env      map[string]string
+		Expected []ConfigOption
+		Error    bool
+	}{
+		{Name: "none", Env: map[string]string{}, Expected: []ConfigOption{}},
+		{Name: "id", Env: map[string]string{"MONNY_ID": "test"}, Expected: []ConfigOption{ID("test")}},
+		{Name: "token", Env: map[string]string{"MONNY_TOKEN": "abc123"}, Expected: []ConfigOption{APIToken("abc123")}},
+		{Name: "host", Env: map[string]string{"MONNY_HOST": "localhost:8080"}, Expected: []ConfigOption{Host("localhost:8080")}},
+		{Name: "dashed name", Env: map[string]string{"MONNY_TIMEOUT_WARN": "5m"}, Expected: []ConfigOption{NotifyTimeout("5m")}},
+		{Name: "multiple rules", Env: map[string]string{"MONNY_RULE": "test, foo"}, Expected: []ConfigOption{Rule("test"), Rule("foo")}},
+		{Name: "multiple json rules", Env: map[string]string{"MONNY_RULE_JSON": "field:test,foo:bar"}, Expected: []ConfigOption{JSONRule("field", "test"), JSONRule("foo", "bar")}},
+		{Name: "bool true", Env: map[string]string{"MONNY_DAEMON": "true"}, Expected: []ConfigOption{Daemon()}},
+		{Name: "bool false", Env: map[string]string{"MONNY_DAEMON": "0"}, Expected: []ConfigOption{}},
+		{Name: "error on invalid bool", Env: map[string]string{"MONNY_DAEMON": "maybe"}, Error: true},
+		{Name: "error on invalid value", Env: map[string]string{"MONNY_RULE_JSON": "novalue"}, Error: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			for k, v := range tc.Env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+			options, err := parseEnv(createFlagSet())
+			if tc.Error {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, options, len(tc.Expected))
+			expected, received := createComparisonConfigs(tc.Expected, options)
+			assert.Equal(t, expected, received)
+		})
+	}
+}
+
+func TestParsePrecedence(t *testing.T) {
+	f, err := ioutil.TempFile("", "xrcfg")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp config file: %s", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write([]byte("id: file\nhost: file:8080\nshell: /bin/bash\n")); err != nil {
+		t.Fatalf("unexpected error writing to file: %s", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("unexpected error closing file: %s", err)
+	}
+
+	os.Setenv("MONNY_ID", "env")
+	defer os.Unsetenv("MONNY_ID")
+	os.Setenv("MONNY_HOST", "env:8080")
+	defer os.Unsetenv("MONNY_HOST")
+
+	// precedence is file < env < flags regardless of the position of the config flag
+	pf := createFlagSet()
+	env, err := parseEnv(pf)
+	if err != nil {
+		t.Fatalf("unexpected error parsing env: %s", err)
+	}
+	_, options, err := parse([]string{"--id", "flag", "-c", f.Name()}, pf, configSources{env: env})
+	assert.NoError(t, err)
+	expected, received := createComparisonConfigs([]ConfigOption{ID("flag"), Host("env:8080"), Shell("/bin/bash")}, options)
+	assert.Equal(t, expected, received)
+}
+
 func createComparisonConfigs(expected []ConfigOption, received []ConfigOption) (Config, Config) {
 	expectedConfig := Config{}
 	for _, eo := range expected {
@@ -130,3 +495,91 @@ func createComparisonConfigs(expected []ConfigOption, received []ConfigOption) (
 	}
 	return expectedConfig, receivedConfig
 }
+
+func TestParseDiscovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monny")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// write creates a configuration file in a subdirectory of dir so that each path is distinct
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name, "config.yaml")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("unexpected error creating config dir: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("unexpected error writing config: %v", err)
+		}
+		return path
+	}
+	local := write("local", "id: local\nshell: /bin/local\n")
+	user := write("user", "id: user\nshell: /bin/user\n")
+	system := write("system", "id: system\nshell: /bin/system\n")
+	explicit := write("explicit", "id: explicit\n")
+	base := write("base", "id: base\nshell: /bin/base\nrule: ERROR\ncreates: base.csv\n")
+	override := write("override", "shell: /bin/override\nrule:\n  - FATAL\n  - panic\ncreates: override.csv\n")
+	invalid := write("invalid", "id: [unterminated\n")
+	missing := filepath.Join(dir, "missing", "config.yaml")
+	// a directory can not be read as a file, even with elevated permissions
+	unreadable := filepath.Join(dir, "unreadable", "config.yaml")
+	if err := os.MkdirAll(unreadable, 0755); err != nil {
+		t.Fatalf("unexpected error creating dir: %v", err)
+	}
+
+	tt := []struct {
+		Name     string
+		Args     []string
+		Files    []string
+		Env      []ConfigOption
+		Expected []ConfigOption
+		Error    bool
+	}{
+		{Name: "no files", Files: []string{missing}, Expected: []ConfigOption{}},
+		{Name: "local first", Files: []string{local, user, system}, Expected: []ConfigOption{ID("local"), Shell("/bin/local")}},
+		{Name: "user when no local", Files: []string{missing, user, system}, Expected: []ConfigOption{ID("user"), Shell("/bin/user")}},
+		{Name: "system when no local or user", Files: []string{missing, missing, system}, Expected: []ConfigOption{ID("system"), Shell("/bin/system")}},
+		{Name: "explicit overrides discovery", Args: []string{"-c", explicit}, Files: []string{local}, Expected: []ConfigOption{ID("explicit")}},
+		{Name: "several files in order", Args: []string{"-c", base, "-c", override}, Files: []string{local}, Expected: []ConfigOption{ID("base"), Shell("/bin/override"), Rule("ERROR"), Rule("FATAL"), Rule("panic"), Creates("base.csv"), Creates("override.csv")}},
+		{Name: "flag overrides several files", Args: []string{"-c", base, "--shell", "/bin/flag", "-c", override}, Expected: []ConfigOption{ID("base"), Shell("/bin/flag"), Rule("ERROR"), Rule("FATAL"), Rule("panic"), Creates("base.csv"), Creates("override.csv")}},
+		{Name: "invalid file of several", Args: []string{"-c", base, "-c", invalid}, Error: true},
+		{Name: "env overrides file", Files: []string{local}, Env: []ConfigOption{ID("env")}, Expected: []ConfigOption{ID("env"), Shell("/bin/local")}},
+		{Name: "flag overrides env", Args: []string{"--id", "flag"}, Files: []string{local}, Env: []ConfigOption{ID("env")}, Expected: []ConfigOption{ID("flag"), Shell("/bin/local")}},
+		{Name: "flag overrides file", Args: []string{"--shell", "/bin/flag"}, Files: []string{local}, Expected: []ConfigOption{ID("local"), Shell("/bin/flag")}},
+		{Name: "unreadable file", Files: []string{unreadable, system}, Error: true},
+		{Name: "invalid file", Files: []string{invalid, system}, Error: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			pf := createFlagSet()
+			_, options, err := parse(tc.Args, pf, configSources{files: tc.Files, env: tc.Env})
+			if tc.Error {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			expected, received := createComparisonConfigs(tc.Expected, options)
+			assert.Equal(t, expected, received)
+		})
+	}
+}
+
+func TestConfigFiles(t *testing.T) {
+	xdg, ok := os.LookupEnv("XDG_CONFIG_HOME")
+	defer func() {
+		if ok {
+			os.Setenv("XDG_CONFIG_HOME", xdg)
+		} else {
+			os.Unsetenv("XDG_CONFIG_HOME")
+		}
+	}()
+
+	os.Setenv("XDG_CONFIG_HOME", filepath.Join("home", "config"))
+	assert.Equal(t, []string{"monny.yaml", filepath.Join("home", "config", "monny", "config.yaml"), filepath.Join(string(filepath.Separator), "etc", "monny", "config.yaml")}, configFiles())
+
+	os.Unsetenv("XDG_CONFIG_HOME")
+	if home, err := os.UserHomeDir(); err == nil {
+		assert.Equal(t, filepath.Join(home, ".config", "monny", "config.yaml"), configFiles()[1])
+	}
+}
@@ -0,0 +1,84 @@
+package monny
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// pipeWriter echoes the output of the process to a sink until the reader of the sink closes it, such as head in
+// monny -- producer | head.  Once the pipe is broken, writes are discarded so that the output of the process is
+// still monitored without reporting an error for each line.
+type pipeWriter struct {
+	io.WriteCloser
+	broken int32
+	closed func()
+}
+
+func (w *pipeWriter) Write(p []byte) (int, error) {
+	if atomic.LoadInt32(&w.broken) == 1 {
+		return len(p), nil
+	}
+	n, err := w.WriteCloser.Write(p)
+	if errors.Is(err, syscall.EPIPE) {
+		if atomic.CompareAndSwapInt32(&w.broken, 0, 1) {
+			w.closed()
+		}
+		return len(p), nil
+	}
+	return n, err
+}
+
+// watchBrokenPipe wraps the output of the process so that a closed reader stops echoing output and is signaled
+// on outputClosed
+func (c *Command) watchBrokenPipe() {
+	c.outputClosed = make(chan struct{})
+	closed := func(stream string) func() {
+		return func() {
+			c.log.warnf("reader of %s closed the pipe, output of the process is no longer echoed", stream)
+			c.closeOutput.Do(func() { close(c.outputClosed) })
+		}
+	}
+	c.out = &pipeWriter{WriteCloser: c.out, closed: closed("stdout")}
+	c.err = &pipeWriter{WriteCloser: c.err, closed: closed("stderr")}
+}
+
+// stopOnBrokenPipe signals each process with SIGPIPE when the output of monny is closed, as the process would be if
+// it wrote to the closed pipe itself.  A process is killed where SIGPIPE can not be sent.
+func (c *Command) stopOnBrokenPipe(cmds ...*exec.Cmd) {
+	c.log.infof("stopping process: reader closed the output")
+	c.mutex.Lock()
+	c.brokenPipe = true
+	c.Messages = append(c.Messages, "the reader of the output of monny closed it and the process was stopped")
+	c.mutex.Unlock()
+	for _, cmd := range cmds {
+		if err := cmd.Process.Signal(syscall.SIGPIPE); err != nil {
+			if err := cmd.Process.Kill(); err != nil {
+				c.reportError(errorInternal, fmt.Errorf("could not stop process after the output was closed: %v", err))
+			}
+		}
+	}
+}
+
+// stoppedOnBrokenPipe returns true if the process was stopped because the output was closed.  Like producer in
+// producer | head, it is not a failure of the process.
+func (c *Command) stoppedOnBrokenPipe() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.brokenPipe
+}
+
+// notifyBrokenPipe receives SIGPIPE so that writes to a closed stdout or stderr return EPIPE instead of terminating
+// monny before a report is sent.  The returned function stops receiving it.
+func notifyBrokenPipe() func() {
+	sigpipe := make(chan os.Signal, 1)
+	signal.Notify(sigpipe, syscall.SIGPIPE)
+	return func() {
+		signal.Stop(sigpipe)
+	}
+}
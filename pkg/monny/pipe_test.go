@@ -0,0 +1,59 @@
+package monny
+
+import (
+	"bufio"
+	"os"
+	"testing"
+
+	"github.com/BTBurke/monny/pkg/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+// closeEarly returns the write end of a pipe whose reader closes it after reading one line, like head -n 1
+func closeEarly(t *testing.T) *os.File {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("unexpected error creating pipe: %v", err)
+	}
+	go func() {
+		bufio.NewReader(r).ReadString('\n')
+		r.Close()
+	}()
+	return w
+}
+
+func TestBrokenPipe(t *testing.T) {
+	tt := []struct {
+		Name    string
+		Cmd     []string
+		Options []ConfigOption
+		Stdout  string
+		Message bool
+	}{
+		{Name: "run to completion", Cmd: []string{"sh", "-c", "for i in $(seq 1 5000); do echo $i; done"}, Stdout: "5000"},
+		{Name: "kill on broken pipe", Cmd: []string{"yes"}, Options: []ConfigOption{KillOnBrokenPipe(), KillTimeout("10s")}, Message: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			w := closeEarly(t)
+			defer w.Close()
+			c, errs := New(tc.Cmd, append(tc.Options, ID("test"), logOut(w), logErr(&closeBuffer{}))...)
+			if len(errs) > 0 {
+				t.Fatalf("unexpected error in config: %s", errs)
+			}
+			recorder := new(reasonRecorder)
+			c.report = recorder
+			assert.NoError(t, c.Exec())
+			c.Wait()
+
+			assert.Empty(t, c.errors.Errors(), "a closed reader should not report errors")
+			assert.True(t, c.Success)
+			assert.Equal(t, []proto.ReportReason{proto.Success}, recorder.reasons)
+			if len(tc.Stdout) > 0 {
+				assert.Equal(t, tc.Stdout, c.Stdout[len(c.Stdout)-1], "output should be monitored after the reader is closed")
+			}
+			assert.Equal(t, tc.Message, len(c.Messages) > 0)
+		})
+	}
+}
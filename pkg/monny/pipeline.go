@@ -0,0 +1,169 @@
+package monny
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// pipelineSeparator separates the commands of a pipeline, such as monny --pipeline -- extract.sh -- load.sh
+const pipelineSeparator = "--"
+
+// StepResult is the result of one step of a pipeline or of commands run in parallel.  Steps that were not run because
+// an earlier step failed are skipped, and parallel steps that were killed because another step failed are cancelled.
+// A step that was run but has no exit code was killed or terminated by a signal.
+type StepResult struct {
+	Command       string
+	Start         time.Time
+	Duration      time.Duration
+	ExitCode      int32
+	ExitCodeValid bool
+	Success       bool
+	Skipped       bool `json:",omitempty"`
+	Cancelled     bool `json:",omitempty"`
+	// Stderr holds the last lines of stderr of a failed step
+	Stderr []string `json:",omitempty"`
+}
+
+// step is a command that runs as one step of a pipeline or in parallel.  The output of parallel steps is tagged with
+// the number of the step.
+type step struct {
+	command string
+	args    []string
+	tag     string
+}
+
+// newSteps returns the steps of a pipeline from the configured steps, or from the command split on -- when it is run
+// as a pipeline or in parallel.  Other commands have no steps.
+func newSteps(cfg Config, usercmd []string) ([]step, error) {
+	var steps []step
+	switch {
+	case len(cfg.Steps) > 0:
+		if len(usercmd) > 0 {
+			return nil, ErrInvalidValue{Option: "step", Reason: "use either steps or a command, not both"}
+		}
+		for _, command := range cfg.Steps {
+			args := []string{cfg.Shell, "-c", command}
+			if cfg.NoShell {
+				args = strings.Fields(command)
+			}
+			steps = append(steps, step{command: command, args: args})
+		}
+	case cfg.Pipeline, cfg.Parallel:
+		option := "pipeline"
+		if cfg.Parallel {
+			option = "parallel"
+		}
+		var args []string
+		for i := 0; i <= len(usercmd); i++ {
+			if i < len(usercmd) && usercmd[i] != pipelineSeparator {
+				args = append(args, usercmd[i])
+				continue
+			}
+			if len(args) == 0 {
+				return nil, ErrInvalidValue{Option: option, Value: strings.Join(usercmd, " "), Reason: "each step must have a command, separated by --"}
+			}
+			steps = append(steps, step{command: strings.Join(args, " "), args: args})
+			args = nil
+		}
+	}
+	if cfg.Parallel {
+		for i := range steps {
+			steps[i].tag = fmt.Sprintf("[%d] ", i+1)
+		}
+	}
+	return steps, nil
+}
+
+// execPipeline runs each step of the pipeline in order under one monitor.  The pipeline stops at the first step that
+// fails unless it continues on error.  A single report for the whole pipeline is sent when it finishes, with the
+// exit code of the first step that failed.
+func (c *Command) execPipeline() error {
+	c.mutex.Lock()
+	c.Steps = make([]StepResult, len(c.steps))
+	for i, s := range c.steps {
+		c.Steps[i] = StepResult{Command: s.command, Skipped: true}
+	}
+	c.mutex.Unlock()
+
+	c.Start = time.Now()
+	var cmd *exec.Cmd
+	var failed error
+	for i, s := range c.steps {
+		if failed != nil && !c.Config.ContinueOnError {
+			break
+		}
+		c.log.debugf("running step %d of the pipeline: %s", i+1, s.command)
+		c.mutex.Lock()
+		lines := c.stderrLines
+		c.Steps[i].Skipped = false
+		c.Steps[i].Start = time.Now()
+		c.mutex.Unlock()
+
+		var err error
+		var runFinished <-chan error
+		cmd, runFinished, err = c.startProcess(s.args, "", false)
+		if err != nil {
+			return err
+		}
+		var exited bool
+		var waitErr error
+		if err := c.monitor(cmd, runFinished, func(err error) error {
+			exited, waitErr = true, err
+			return nil
+		}); !exited {
+			// the step was killed, which already sent a report
+			c.finishStep(i, false, nil, c.stderrSince(lines))
+			return err
+		}
+		if c.stoppedOnBrokenPipe() {
+			// the remaining steps are not run after the reader of the output is gone
+			c.finishStep(i, true, nil, nil)
+			break
+		}
+		c.finishStep(i, true, waitErr, c.stderrSince(lines))
+		if waitErr != nil && failed == nil {
+			failed = waitErr
+			c.mutex.Lock()
+			c.Messages = append(c.Messages, fmt.Sprintf("step %d of the pipeline failed: %s: %v", i+1, s.command, waitErr))
+			c.mutex.Unlock()
+		}
+	}
+	c.out.Close()
+	c.err.Close()
+	c.Cleanup()
+	return c.handler.Finished(c, cmd, failed)
+}
+
+// finishStep records the duration and exit code of step i, which has no exit code when it did not exit but was killed.
+// When it failed, the lines it wrote to stderr are kept.
+func (c *Command) finishStep(i int, exited bool, waitErr error, stderr []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	result := &c.Steps[i]
+	result.Duration = time.Since(result.Start)
+	var exitErr *exec.ExitError
+	switch {
+	case exited && waitErr == nil:
+		result.Success = true
+		result.ExitCodeValid = true
+		return
+	case errors.As(waitErr, &exitErr) && exitErr.ExitCode() >= 0:
+		result.ExitCode = int32(exitErr.ExitCode())
+		result.ExitCodeValid = true
+	}
+	result.Stderr = stderr
+}
+
+// stderrSince returns the lines written to stderr after the first lines, up to the stderr history
+func (c *Command) stderrSince(lines int) []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	n := c.stderrLines - lines
+	if n > len(c.Stderr) {
+		n = len(c.Stderr)
+	}
+	return append([]string(nil), c.Stderr[len(c.Stderr)-n:]...)
+}
@@ -0,0 +1,105 @@
+package monny
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/BTBurke/monny/pkg/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipeline(t *testing.T) {
+	failing := []string{"sh", "-c", "echo transforming; echo bad row >&2; exit 2"}
+	pipeline := append(append(append([]string{"echo", "extract", "--"}, failing...), "--"), "echo", "load")
+	tt := []struct {
+		Name    string
+		Cmd     []string
+		Options []ConfigOption
+		Stdout  []string
+		// Ran is whether each step was run
+		Ran []bool
+	}{
+		{Name: "stop at failure", Cmd: pipeline, Options: []ConfigOption{Pipeline()}, Stdout: []string{"extract", "transforming"}, Ran: []bool{true, true, false}},
+		{Name: "continue on error", Cmd: pipeline, Options: []ConfigOption{Pipeline(), ContinueOnError()}, Stdout: []string{"extract", "transforming", "load"}, Ran: []bool{true, true, true}},
+		{Name: "steps", Options: []ConfigOption{Step("echo extract"), Step("echo transforming; echo bad row >&2; exit 2"), Step("echo load")}, Stdout: []string{"extract", "transforming"}, Ran: []bool{true, true, false}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			c, errs := New(tc.Cmd, append(tc.Options, ID("test"), logOut(&closeBuffer{}), logErr(&closeBuffer{}))...)
+			if len(errs) > 0 {
+				t.Fatalf("unexpected error in config: %s", errs)
+			}
+			recorder := new(reasonRecorder)
+			c.report = recorder
+			var failed ErrChildFailed
+			assert.True(t, errors.As(c.Exec(), &failed))
+			assert.Equal(t, 2, failed.Code)
+			c.Wait()
+
+			assert.Equal(t, []proto.ReportReason{proto.Failure}, recorder.reasons, "one report should cover the pipeline")
+			assert.Equal(t, tc.Stdout, c.Stdout)
+			assert.False(t, c.Success)
+			assert.Equal(t, int32(2), c.ExitCode)
+			if !assert.Len(t, c.Steps, 3) {
+				return
+			}
+			for i, ran := range tc.Ran {
+				assert.Equal(t, !ran, c.Steps[i].Skipped, "step %d", i+1)
+			}
+			assert.True(t, c.Steps[0].Success)
+			assert.True(t, c.Steps[0].ExitCodeValid)
+			assert.True(t, c.Steps[0].Duration > 0)
+			assert.Empty(t, c.Steps[0].Stderr)
+
+			assert.False(t, c.Steps[1].Success)
+			assert.True(t, c.Steps[1].ExitCodeValid)
+			assert.Equal(t, int32(2), c.Steps[1].ExitCode)
+			assert.Equal(t, []string{"bad row"}, c.Steps[1].Stderr)
+			assert.Equal(t, tc.Ran[2], c.Steps[2].Success)
+
+			var steps []StepResult
+			report := reportFromCommand(c, proto.Failure, func(err error) { t.Errorf("unexpected error: %v", err) })
+			assert.NoError(t, json.Unmarshal(report.Steps, &steps))
+			assert.Equal(t, len(c.Steps), len(steps))
+			assert.Equal(t, c.Steps[1].Command, steps[1].Command)
+			assert.Equal(t, c.Steps[1].Stderr, steps[1].Stderr)
+		})
+	}
+}
+
+func TestNewSteps(t *testing.T) {
+	tt := []struct {
+		Name    string
+		Cmd     []string
+		Options []ConfigOption
+		Steps   []string
+		Err     bool
+	}{
+		{Name: "not a pipeline", Cmd: []string{"git", "log", "--", "file"}},
+		{Name: "pipeline", Cmd: []string{"extract.sh", "--", "load.sh", "-v"}, Options: []ConfigOption{Pipeline()}, Steps: []string{"extract.sh", "load.sh -v"}},
+		{Name: "empty step", Cmd: []string{"extract.sh", "--", "--", "load.sh"}, Options: []ConfigOption{Pipeline()}, Err: true},
+		{Name: "steps and command", Cmd: []string{"extract.sh"}, Options: []ConfigOption{Step("load.sh")}, Err: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			cfg, errs := newConfig(append(tc.Options, ID("test"))...)
+			if len(errs) > 0 {
+				t.Fatalf("unexpected error in config: %s", errs)
+			}
+			steps, err := newSteps(cfg, tc.Cmd)
+			if tc.Err {
+				assert.True(t, errors.As(err, &ErrInvalidValue{}))
+				return
+			}
+			assert.NoError(t, err)
+			var commands []string
+			for _, s := range steps {
+				commands = append(commands, s.command)
+			}
+			assert.Equal(t, tc.Steps, commands)
+		})
+	}
+}
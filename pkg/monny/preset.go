@@ -0,0 +1,83 @@
+package monny
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/go-yaml/yaml"
+)
+
+// presets holds the named bundles of options registered with RegisterPreset or LoadPresets
+var presets = struct {
+	sync.RWMutex
+	m map[string][]ConfigOption
+}{m: make(map[string][]ConfigOption)}
+
+// RegisterPreset registers a named bundle of options that is applied with Preset.  Registering a preset with the same
+// name replaces it.
+func RegisterPreset(name string, opts ...ConfigOption) error {
+	if len(name) == 0 {
+		return fmt.Errorf("preset name must not be empty")
+	}
+	if len(opts) == 0 {
+		return fmt.Errorf("preset %s must have at least one option", name)
+	}
+	presets.Lock()
+	defer presets.Unlock()
+	presets.m[name] = append([]ConfigOption(nil), opts...)
+	return nil
+}
+
+// LoadPresets registers each preset in the YAML file at path.  Each top-level key is the name of a preset and its
+// value uses the same format as the configuration file:
+//
+//	nightly:
+//	  timeout-warn: 1h
+//	  rule:
+//	    - ERROR
+func LoadPresets(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	all := make(map[string]map[string]interface{})
+	if err := yaml.Unmarshal(data, &all); err != nil {
+		return fmt.Errorf("could not read presets file %s: %v", path, err)
+	}
+	for name, v := range all {
+		opts, err := parseYAMLValues(v, nil)
+		if err != nil {
+			return fmt.Errorf("could not read preset %s in %s: %v", name, path, err)
+		}
+		if err := RegisterPreset(name, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Preset applies the options of a registered preset in the order they were registered.  Options applied after the
+// preset override it.  Each preset is applied at most once, so presets may include other presets.
+func Preset(name string) ConfigOption {
+	return func(c *Config) error {
+		presets.RLock()
+		opts, ok := presets.m[name]
+		presets.RUnlock()
+		if !ok {
+			return ErrInvalidValue{Option: "preset", Reason: fmt.Sprintf("no preset registered with name %s", name)}
+		}
+		for _, p := range c.Presets {
+			if p == name {
+				return nil
+			}
+		}
+		c.Presets = append(c.Presets, name)
+		for _, opt := range opts {
+			if err := opt(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,96 @@
+package monny
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreset(t *testing.T) {
+	defer func() {
+		presets.Lock()
+		presets.m = make(map[string][]ConfigOption)
+		presets.Unlock()
+	}()
+
+	assert.NoError(t, RegisterPreset("nightly", NotifyTimeout("1h"), KillTimeout("2h"), NoNotifyOnSuccess(), StdoutHistory("100")))
+	assert.NoError(t, RegisterPreset("quiet-nightly", Preset("nightly"), Quiet()))
+	assert.NoError(t, RegisterPreset("loop", Preset("loop"), Daemon()))
+	assert.Error(t, RegisterPreset("", Daemon()))
+	assert.Error(t, RegisterPreset("empty"))
+
+	expect := Config{
+		Presets:         []string{"nightly"},
+		NotifyTimeout:   1 * time.Hour,
+		KillTimeout:     2 * time.Hour,
+		NotifyOnSuccess: false,
+		StdoutHistory:   100,
+	}
+	var cfg Config
+	assert.NoError(t, Preset("nightly")(&cfg))
+	assert.Equal(t, expect, cfg)
+
+	// nested presets are expanded and options applied after a preset override it
+	cfg = Config{}
+	for _, opt := range []ConfigOption{Preset("quiet-nightly"), StdoutHistory("10")} {
+		assert.NoError(t, opt(&cfg))
+	}
+	assert.Equal(t, []string{"quiet-nightly", "nightly"}, cfg.Presets)
+	assert.Equal(t, 1*time.Hour, cfg.NotifyTimeout)
+	assert.Equal(t, 10, cfg.StdoutHistory)
+	assert.Equal(t, discard{}, cfg.out)
+
+	// a preset that includes itself is only applied once
+	cfg = Config{}
+	assert.NoError(t, Preset("loop")(&cfg))
+	assert.Equal(t, []string{"loop"}, cfg.Presets)
+	assert.True(t, cfg.Daemon)
+
+	var invalid ErrInvalidValue
+	assert.True(t, errors.As(Preset("missing")(&Config{}), &invalid))
+}
+
+func TestLoadPresets(t *testing.T) {
+	defer func() {
+		presets.Lock()
+		presets.m = make(map[string][]ConfigOption)
+		presets.Unlock()
+	}()
+
+	dir, err := ioutil.TempDir("", "monny")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "presets.yaml")
+	content := "nightly:\n  timeout-warn: 1h\n  stdout-history: 100\n  daemon: true\n  rule:\n    - ERROR\n    - FATAL\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("unexpected error writing presets: %v", err)
+	}
+
+	_, opts, err := parse([]string{"--presets-file", path, "--preset", "nightly", "--stderr-history", "5", "echo"}, createFlagSet(), configSources{})
+	assert.NoError(t, err)
+	var cfg Config
+	for _, opt := range opts {
+		assert.NoError(t, opt(&cfg))
+	}
+	assert.Equal(t, []string{"nightly"}, cfg.Presets)
+	assert.Equal(t, 1*time.Hour, cfg.NotifyTimeout)
+	assert.Equal(t, 100, cfg.StdoutHistory)
+	assert.Equal(t, 5, cfg.StderrHistory)
+	assert.True(t, cfg.Daemon)
+	assert.Len(t, cfg.Rules, 2)
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := ioutil.WriteFile(invalid, []byte("nightly:\n  not-an-option: 1\n"), 0644); err != nil {
+		t.Fatalf("unexpected error writing presets: %v", err)
+	}
+	assert.Error(t, LoadPresets(invalid))
+	assert.Error(t, LoadPresets(filepath.Join(dir, "missing.yaml")))
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"regexp"
 )
 
 // LogProcessorOption overrides default behavior.  Options are applied in a defined order
@@ -16,9 +17,10 @@ type LogProcessorOption interface {
 }
 
 type logProcOpt struct {
-	hist    int
-	sources []source
-	sinks   []sink
+	hist     int
+	sources  []source
+	patterns []pattern
+	sinks    []sink
 }
 
 // sourceOrSink for monny, either from the wrapped process or the monny process itself
@@ -67,6 +69,12 @@ type source struct {
 	in   io.Reader
 }
 
+// a pattern matched against each log line with the callback for matching lines
+type pattern struct {
+	r  *regexp.Regexp
+	cb func(LogEvent)
+}
+
 // a configured sink for writing processed logs
 type sink struct {
 	name sourceOrSink
@@ -86,6 +94,7 @@ const (
 	noOutput
 	noStdoutIn
 	noStderrIn
+	patterns
 )
 
 // options should return an optF struct to apply the option and declare its priority to the constructor
@@ -121,7 +130,7 @@ func WithCommand(c *exec.Cmd) LogProcessorOption {
 		case c.Path == "":
 			l.sources = append(l.sources, source{
 				name: mStdin,
-				q:    NewQueue(l.hist),
+				q:    NewQueue(WithQueueCapacity(l.hist)),
 				in:   os.Stdin,
 			})
 			l.sinks = append(l.sinks, sink{
@@ -141,11 +150,11 @@ func WithCommand(c *exec.Cmd) LogProcessorOption {
 			}
 			l.sources = append(l.sources, source{
 				name: pStdout,
-				q:    NewQueue(l.hist),
+				q:    NewQueue(WithQueueCapacity(l.hist)),
 				in:   outPipe,
 			}, source{
 				name: pStderr,
-				q:    NewQueue(l.hist),
+				q:    NewQueue(WithQueueCapacity(l.hist)),
 				in:   errPipe,
 			})
 			l.sinks = append(l.sinks, sink{
@@ -205,6 +214,24 @@ func WithNoStderrInput() LogProcessorOption {
 	}
 }
 
+// WithPattern calls cb with each log line from any source that matches r, without subscribing to the event bus.  It
+// can be used more than once to match several patterns, and cb is called for each pattern that matches.  The callback
+// is called in the scanner goroutine of the source before the line is emitted to the bus, so it must synchronize its
+// own state and return quickly.  Line is reused for the next line after cb returns and must be copied to be kept.
+func WithPattern(r *regexp.Regexp, cb func(LogEvent)) LogProcessorOption {
+	f := func(l *logProcOpt) error {
+		if r == nil || cb == nil {
+			return fmt.Errorf("pattern and callback are required")
+		}
+		l.patterns = append(l.patterns, pattern{r: r, cb: cb})
+		return nil
+	}
+	return optF{
+		f:   f,
+		pri: patterns,
+	}
+}
+
 // filter one sink from the default configured sinks
 func filterSink(sinks []sink, target sourceOrSink) []sink {
 	fSinks := []sink{}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/BTBurke/monny/pkg/eventbus"
@@ -31,10 +32,13 @@ type LogProcessor struct {
 	*logProcOpt
 
 	wg sync.WaitGroup
+	// lines counts the lines scanned from each source, in the same order as sources
+	lines []int64
 }
 
 // NewLogProcessor returns a log processor configured to the available options.  Typically it is called
-// WithCommand(exec.Cmd) in order to hook into the wrapped process pipes.
+// WithCommand(exec.Cmd) in order to hook into the wrapped process pipes.  Create the event bus WithOrderedDelivery
+// so that subscribers receive log lines in the order they were read.
 func NewLogProcessor(eb *eventbus.EventBus, options ...LogProcessorOption) (*LogProcessor, error) {
 	opt := &logProcOpt{
 		hist: 30,
@@ -46,41 +50,48 @@ func NewLogProcessor(eb *eventbus.EventBus, options ...LogProcessorOption) (*Log
 		}
 	}
 
-	l := &LogProcessor{opt, sync.WaitGroup{}}
+	l := &LogProcessor{logProcOpt: opt, lines: make([]int64, len(opt.sources))}
 	done := func() { l.wg.Done() }
 
-	for _, s := range opt.sources {
+	for i, s := range opt.sources {
 		l.wg.Add(1)
 
 		// some special cases here to maintain pStdout->mStdout and pStderr->mStderr log sinks
 		switch s.name {
 		case pStdout:
-			go startLogEmitter(eb, s, filterSink(opt.sinks, mStderr), done)
+			go startLogEmitter(eb, s, filterSink(opt.sinks, mStderr), opt.patterns, &l.lines[i], done)
 		case pStderr:
-			go startLogEmitter(eb, s, filterSink(opt.sinks, mStdout), done)
+			go startLogEmitter(eb, s, filterSink(opt.sinks, mStdout), opt.patterns, &l.lines[i], done)
 		default:
-			go startLogEmitter(eb, s, opt.sinks, done)
+			go startLogEmitter(eb, s, opt.sinks, opt.patterns, &l.lines[i], done)
 		}
 	}
 	return l, nil
 }
 
 // startLogEmitter scans the supplied source and emits each log line (newline delimited) to the LogTopic
-// bus for downstream processing.  Lines are then written to the sinks, if any.  Done is called to signal
+// bus for downstream processing.  Lines matching any of the patterns are first passed to the pattern callback.
+// Lines are then written to the sinks, if any.  Each line scanned is counted in lines.  Done is called to signal
 // to the LogProcessor that the scanner has closed and all logs have been emitted to the bus.
-func startLogEmitter(bus eventbus.EventDispatcher, src source, sinks []sink, done func()) {
+func startLogEmitter(bus eventbus.EventDispatcher, src source, sinks []sink, patterns []pattern, lines *int64, done func()) {
 	if done != nil {
 		defer done()
 	}
 	scanner := bufio.NewScanner(src.in)
 	for scanner.Scan() {
 		data := scanner.Bytes()
+		atomic.AddInt64(lines, 1)
 		src.q.Add(string(data))
 
 		payload := LogEvent{
 			Timestamp: time.Now().UTC(),
 			Line:      data,
 		}
+		for _, p := range patterns {
+			if p.r.Match(data) {
+				p.cb(payload)
+			}
+		}
 		evt, err := eventbus.NewEvent(LogLine, payload)
 		if err != nil {
 			newError(bus, EventError{fmt.Errorf("unable to construct log event: %v", err)})
@@ -95,12 +106,42 @@ func startLogEmitter(bus eventbus.EventDispatcher, src source, sinks []sink, don
 	}
 }
 
+// LineCount returns the total number of log lines processed from all sources so far
+func (l *LogProcessor) LineCount() int {
+	var total int
+	for _, n := range l.LineCountBySource() {
+		total += n
+	}
+	return total
+}
+
+// LineCountBySource returns the number of log lines processed from each source so far
+func (l *LogProcessor) LineCountBySource() map[sourceOrSink]int {
+	counts := make(map[sourceOrSink]int, len(l.sources))
+	for i, s := range l.sources {
+		counts[s.name] += int(atomic.LoadInt64(&l.lines[i]))
+	}
+	return counts
+}
+
+// Flusher is implemented by sinks that buffer writes, such as a sink wrapping a bufio.Writer.  Buffered sinks are
+// flushed before they are closed so that the last log lines are not lost.
+type Flusher interface {
+	Flush() error
+}
+
 // Wait will wait for all log sources to finish processing.  Context can can
 // use a timeout or cancel to set a reasonable time for finishing.  An error is
-// returned only if the context is cancelled before all logs are processed.
-func (l *LogProcessor) Wait(ctx context.Context) error {
+// returned if the context is cancelled before all logs are processed or a
+// buffered sink can not be flushed.
+func (l *LogProcessor) Wait(ctx context.Context) (err error) {
 	defer func() {
 		for _, s := range l.sinks {
+			if f, ok := s.out.(Flusher); ok {
+				if ferr := f.Flush(); ferr != nil && err == nil {
+					err = fmt.Errorf("error flushing sink %s: %v", s.name, ferr)
+				}
+			}
 			s.out.Close()
 		}
 	}()
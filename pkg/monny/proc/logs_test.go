@@ -0,0 +1,108 @@
+package proc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/eventbus"
+	"github.com/stretchr/testify/assert"
+)
+
+// bufferedSink buffers writes to a bytes.Buffer that are only visible after Flush
+type bufferedSink struct {
+	*bufio.Writer
+	out    *bytes.Buffer
+	closed bool
+}
+
+func (b *bufferedSink) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestWaitFlushesSinks(t *testing.T) {
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("log line %d", i))
+	}
+	out := new(bytes.Buffer)
+	s := &bufferedSink{Writer: bufio.NewWriterSize(out, 4096), out: out}
+
+	l := &LogProcessor{logProcOpt: &logProcOpt{
+		sources: []source{{name: pStdout, q: NewQueue(WithQueueCapacity(10)), in: strings.NewReader(strings.Join(lines, "\n"))}},
+		sinks:   []sink{{name: mStdout, out: s}},
+	}, lines: make([]int64, 1)}
+	l.wg.Add(1)
+	go startLogEmitter(eventbus.New(), l.sources[0], l.sinks, nil, &l.lines[0], l.wg.Done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, l.Wait(ctx))
+	assert.True(t, s.closed)
+	assert.Equal(t, strings.Join(lines, "\n")+"\n", out.String())
+}
+
+func TestLineCount(t *testing.T) {
+	l := &LogProcessor{logProcOpt: &logProcOpt{
+		sources: []source{
+			{name: pStdout, q: NewQueue(), in: strings.NewReader("one\ntwo\nthree\n")},
+			{name: pStderr, q: NewQueue(), in: strings.NewReader("error\n")},
+			{name: logfile, q: NewQueue(), in: strings.NewReader("")},
+		},
+	}, lines: make([]int64, 3)}
+	bus := eventbus.New()
+	for i, src := range l.sources {
+		l.wg.Add(1)
+		go startLogEmitter(bus, src, nil, nil, &l.lines[i], l.wg.Done)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, l.Wait(ctx))
+	assert.Equal(t, 4, l.LineCount())
+	assert.Equal(t, map[sourceOrSink]int{pStdout: 3, pStderr: 1, logfile: 0}, l.LineCountBySource())
+}
+
+func TestWithPattern(t *testing.T) {
+	var mutex sync.Mutex
+	var errors, warnings []string
+	record := func(matches *[]string) func(LogEvent) {
+		return func(e LogEvent) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			*matches = append(*matches, string(e.Line))
+		}
+	}
+	opt := &logProcOpt{
+		sources: []source{
+			{name: pStdout, q: NewQueue(), in: strings.NewReader("ok\nwarning: disk low\nok\n")},
+			{name: pStderr, q: NewQueue(), in: strings.NewReader("error: disk full\nwarning or error\n")},
+		},
+	}
+	for _, o := range []LogProcessorOption{
+		WithPattern(regexp.MustCompile("^error|or error"), record(&errors)),
+		WithPattern(regexp.MustCompile("warning"), record(&warnings)),
+	} {
+		assert.NoError(t, o.apply(opt))
+	}
+	l := &LogProcessor{logProcOpt: opt, lines: make([]int64, 2)}
+	bus := eventbus.New()
+	for i, src := range l.sources {
+		l.wg.Add(1)
+		go startLogEmitter(bus, src, nil, l.patterns, &l.lines[i], l.wg.Done)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, l.Wait(ctx))
+	assert.Equal(t, []string{"error: disk full", "warning or error"}, errors)
+	assert.ElementsMatch(t, []string{"warning: disk low", "warning or error"}, warnings)
+	assert.Error(t, WithPattern(nil, func(LogEvent) {}).apply(&logProcOpt{}))
+}
@@ -4,20 +4,40 @@ import (
 	"sync"
 )
 
+// defaultQueueCapacity is the number of lines kept by a queue unless set with WithQueueCapacity
+const defaultQueueCapacity = 30
+
 // Queue is a FIFO string queue used principally by the log parser to maintain a limited
-// log history
+// log history.  It is safe for concurrent use.
 type Queue struct {
 	q        []string
 	capacity int
-	mu       sync.Mutex
+	mu       sync.RWMutex
 }
 
-// NewQueue returns a new FIFO string queue with capacity cap.  Capacity is not fixed as subsequent
-// calls to add without pop will grow the size of the queue.  Use Enqueue to maintain a fixed capacity
-// queue.
-func NewQueue(capacity int) *Queue {
-	q := make([]string, 0, capacity+1)
-	return &Queue{q: q, capacity: capacity}
+// QueueOption changes the default behavior of a queue
+type QueueOption func(*Queue)
+
+// WithQueueCapacity sets the number of lines kept by the queue so that the history of each log source
+// can have its own size.  Capacities less than 1 are ignored.
+func WithQueueCapacity(n int) QueueOption {
+	return func(q *Queue) {
+		if n > 0 {
+			q.capacity = n
+		}
+	}
+}
+
+// NewQueue returns a new FIFO string queue that keeps the most recent 30 strings, or the capacity set with
+// WithQueueCapacity.  Capacity is not fixed as subsequent calls to add without pop will grow the size of the
+// queue.  Use Add to maintain a fixed capacity queue.
+func NewQueue(opts ...QueueOption) *Queue {
+	q := &Queue{capacity: defaultQueueCapacity}
+	for _, opt := range opts {
+		opt(q)
+	}
+	q.q = make([]string, 0, q.capacity+1)
+	return q
 }
 
 func (q *Queue) add(s string) {
@@ -43,11 +63,12 @@ func (q *Queue) Add(s string) {
 	}
 }
 
-// Copy will lock the queue from further writes and copy the current queue into a new slice.  The new slice length
-// will be less than or equal to the initial capacity if the queue is not completely full.
-func (q *Queue) Copy() []string {
-	q.mu.Lock()
-	defer q.mu.Unlock()
+// Snapshot returns a copy of the current queue in the order the strings were added.  Readers do not block each
+// other, so subscribers can read the recent history while the queue is written.  The length of the copy will be
+// less than or equal to the capacity if the queue is not completely full.
+func (q *Queue) Snapshot() []string {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
 
 	s := make([]string, len(q.q))
 	copy(s, q.q)
@@ -55,6 +76,11 @@ func (q *Queue) Copy() []string {
 	return s
 }
 
+// Copy returns a copy of the current queue, the same as Snapshot
+func (q *Queue) Copy() []string {
+	return q.Snapshot()
+}
+
 // Clear will discard everything in the queue and initialize a new queue with the same capacity
 func (q *Queue) Clear() {
 	q.mu.Lock()
@@ -1,6 +1,8 @@
 package proc
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -20,7 +22,7 @@ func TestQueue(t *testing.T) {
 
 	for _, tc := range tt {
 		t.Run(tc.Name, func(t *testing.T) {
-			q := NewQueue(2)
+			q := NewQueue(WithQueueCapacity(2))
 			for _, s := range tc.In {
 				q.Add(s)
 			}
@@ -32,3 +34,48 @@ func TestQueue(t *testing.T) {
 		})
 	}
 }
+
+func TestQueueCapacity(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Opts     []QueueOption
+		Capacity int
+	}{
+		{"default", nil, defaultQueueCapacity},
+		{"capacity", []QueueOption{WithQueueCapacity(5)}, 5},
+		{"ignore zero", []QueueOption{WithQueueCapacity(0)}, defaultQueueCapacity},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			q := NewQueue(tc.Opts...)
+			for i := 0; i < tc.Capacity+10; i++ {
+				q.Add(fmt.Sprintf("%d", i))
+			}
+			s := q.Snapshot()
+			assert.Len(t, s, tc.Capacity)
+			assert.Equal(t, fmt.Sprintf("%d", tc.Capacity+9), s[len(s)-1])
+		})
+	}
+}
+
+func TestQueueSnapshotConcurrent(t *testing.T) {
+	q := NewQueue(WithQueueCapacity(10))
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			q.Add(fmt.Sprintf("%d", i))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			s := q.Snapshot()
+			assert.True(t, len(s) <= 10)
+		}
+	}()
+	wg.Wait()
+	assert.Equal(t, "999", q.Snapshot()[9])
+}
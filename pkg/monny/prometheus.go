@@ -0,0 +1,138 @@
+package monny
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logfmt/logfmt"
+)
+
+// prometheusShutdown is how long scrapes in progress are allowed to finish after the process exits
+const prometheusShutdown = 2 * time.Second
+
+// startPrometheus serves the estimator metrics of each metric rule at /metrics in the Prometheus text format.  The
+// returned function shuts down the server.
+func (c *Command) startPrometheus() func() {
+	if len(c.Config.PrometheusAddr) == 0 {
+		return func() {}
+	}
+	l, err := net.Listen("tcp", c.Config.PrometheusAddr)
+	if err != nil {
+		c.reportError(errorInternal, fmt.Errorf("could not start prometheus export on %s: %v", c.Config.PrometheusAddr, err))
+		return func() {}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", c.servePrometheus)
+	srv := &http.Server{Handler: mux}
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		if err := srv.Serve(l); err != nil && err != http.ErrServerClosed {
+			c.reportError(errorInternal, fmt.Errorf("prometheus export stopped: %v", err))
+		}
+	}()
+	c.log.infof("serving prometheus metrics at http://%s/metrics", l.Addr())
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), prometheusShutdown)
+		defer cancel()
+		srv.Shutdown(ctx)
+		<-done
+	}
+}
+
+func (c *Command) servePrometheus(w http.ResponseWriter, r *http.Request) {
+	metrics := make(map[string]float64)
+	for _, m := range c.metrics {
+		current, _ := m.snapshot()
+		for k, v := range current {
+			metrics[k] = v
+		}
+	}
+	for k, v := range c.budget.Metric() {
+		metrics[k] = v
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := writePrometheus(w, metrics); err != nil {
+		c.reportError(errorInternal, fmt.Errorf("could not write prometheus metrics: %v", err))
+	}
+}
+
+// writePrometheus writes metrics named like errors[stream=stdout strategy=ewma] as Prometheus gauges named like
+// monny_errors{stream="stdout",strategy="ewma"}.  Annotations in the name are dropped.
+func writePrometheus(w io.Writer, metrics map[string]float64) error {
+	series := make(map[string][]string)
+	for name, value := range metrics {
+		base, labels := prometheusName(name)
+		series[base] = append(series[base], base+labels+" "+strconv.FormatFloat(value, 'g', -1, 64))
+	}
+	names := make([]string, 0, len(series))
+	for name := range series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		lines := series[name]
+		sort.Strings(lines)
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", name)
+		for _, line := range lines {
+			fmt.Fprintf(&b, "%s\n", line)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// prometheusName converts a metric name with metadata to a Prometheus metric name and label set
+func prometheusName(name string) (string, string) {
+	base, md := name, ""
+	if i := strings.Index(name, "["); i >= 0 && strings.HasSuffix(name, "]") {
+		base, md = name[:i], name[i+1:len(name)-1]
+	}
+
+	var labels []string
+	d := logfmt.NewDecoder(strings.NewReader(md))
+	for d.ScanRecord() {
+		for d.ScanKeyval() {
+			if strings.HasPrefix(string(d.Key()), "@") {
+				continue
+			}
+			labels = append(labels, fmt.Sprintf("%s=\"%s\"", prometheusIdent(string(d.Key())), prometheusEscape.Replace(string(d.Value()))))
+		}
+	}
+	sort.Strings(labels)
+
+	base = "monny_" + prometheusIdent(base)
+	if len(labels) == 0 {
+		return base, ""
+	}
+	return base, "{" + strings.Join(labels, ",") + "}"
+}
+
+// prometheusEscape escapes a label value in the text format, where only backslashes, double quotes and newlines are
+// escaped
+var prometheusEscape = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// prometheusIdent replaces characters that are not allowed in Prometheus metric and label names with underscores.
+// Names can not start with a digit, so they are prefixed with an underscore.
+func prometheusIdent(s string) string {
+	if len(s) > 0 && s[0] >= '0' && s[0] <= '9' {
+		s = "_" + s
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
@@ -0,0 +1,101 @@
+package monny
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, writePrometheus(&b, map[string]float64{
+		"errors[stream=stderr strategy=ewma type=estimator value=limit]":   4.5,
+		"errors[stream=stderr strategy=ewma type=estimator value=current]": 1.25,
+		"slow-requests[stream=\"std out\" @mean]":                          2,
+		"plain":            0,
+		"status[5xx=true]": 3,
+	}))
+	assert.Equal(t, `# TYPE monny_errors gauge
+monny_errors{strategy="ewma",stream="stderr",type="estimator",value="current"} 1.25
+monny_errors{strategy="ewma",stream="stderr",type="estimator",value="limit"} 4.5
+# TYPE monny_plain gauge
+monny_plain 0
+# TYPE monny_slow_requests gauge
+monny_slow_requests{stream="std out"} 2
+# TYPE monny_status gauge
+monny_status{_5xx="true"} 3
+`, b.String())
+
+	// only backslashes, double quotes and newlines are escaped in label values
+	base, labels := prometheusName(`paths[path="C:\\tmp \"a\"\té\nb"]`)
+	assert.Equal(t, "monny_paths", base)
+	assert.Equal(t, "{path=\"C:\\\\tmp \\\"a\\\"\té\\nb\"}", labels)
+}
+
+// failingWriter is a response writer that fails every write
+type failingWriter struct {
+	header http.Header
+}
+
+func (f *failingWriter) Header() http.Header {
+	return f.header
+}
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func (f *failingWriter) WriteHeader(status int) {}
+
+func TestServePrometheusWriteError(t *testing.T) {
+	c, errs := New([]string{"echo"}, ID("test"), MetricRule("errors", "stderr", "ERROR"))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	rec := &recordingErrors{}
+	c.errors = newErrorCollector(rec)
+	c.servePrometheus(&failingWriter{header: make(http.Header)}, httptest.NewRequest("GET", "/metrics", nil))
+	if assert.Len(t, rec.errors, 1) {
+		assert.Contains(t, rec.errors[0].Error(), "connection reset")
+	}
+}
+
+func TestPrometheusExport(t *testing.T) {
+	// find a free port for the export
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error finding a free port: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	c, errs := New([]string{"echo"}, ID("test"), MetricRule("errors", "stderr", "ERROR"), WithPrometheusExport(addr))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	stop := c.startPrometheus()
+
+	resp, err := http.Get("http://" + addr + "/metrics")
+	if err != nil {
+		stop()
+		t.Fatalf("unexpected error scraping metrics: %v", err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "# TYPE monny_errors gauge\n")
+	assert.Contains(t, string(body), `monny_errors{strategy="ewma",stream="stderr",type="estimator",value="limit"} `)
+	assert.Contains(t, string(body), `monny_errors{strategy="shewart",stream="stderr",type="estimator",value="current"} `)
+
+	// the server is shut down when the process exits
+	stop()
+	_, err = http.Get("http://" + addr + "/metrics")
+	assert.Error(t, err)
+}
@@ -0,0 +1,76 @@
+// +build linux
+
+package monny
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+// startPTY starts the command attached to a new pseudo-terminal and returns the controlling end.  Stdout and
+// Stderr of the process are combined into a single stream by the terminal.
+func startPTY(cmd *exec.Cmd) (io.ReadCloser, error) {
+	master, slave, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+	defer slave.Close()
+
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err := cmd.Start(); err != nil {
+		master.Close()
+		return nil, err
+	}
+	return ptyReader{master}, nil
+}
+
+func openPTY() (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not open pseudo-terminal: %v", err)
+	}
+	var n uint32
+	if err := ioctl(master.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("could not get pseudo-terminal number: %v", err)
+	}
+	var unlock int32
+	if err := ioctl(master.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("could not unlock pseudo-terminal: %v", err)
+	}
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("could not open pseudo-terminal: %v", err)
+	}
+	return master, slave, nil
+}
+
+func ioctl(fd uintptr, cmd uintptr, ptr uintptr) error {
+	_, _, e := syscall.Syscall(syscall.SYS_IOCTL, fd, cmd, ptr)
+	if e != 0 {
+		return e
+	}
+	return nil
+}
+
+// ptyReader treats EIO as EOF, which is returned by Linux when reading from the terminal after the process exits
+type ptyReader struct {
+	*os.File
+}
+
+func (p ptyReader) Read(b []byte) (int, error) {
+	n, err := p.File.Read(b)
+	if pe, ok := err.(*os.PathError); ok && pe.Err == syscall.EIO {
+		return n, io.EOF
+	}
+	return n, err
+}
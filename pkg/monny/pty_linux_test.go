@@ -0,0 +1,48 @@
+// +build linux
+
+package monny
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPTY(t *testing.T) {
+	tt := []struct {
+		Name    string
+		Options []ConfigOption
+		Stdout  []string
+	}{
+		{Name: "pipe", Stdout: []string{"piped"}},
+		{Name: "pty", Options: []ConfigOption{PTY()}, Stdout: []string{"interactive"}},
+		{Name: "pty combines stderr", Options: []ConfigOption{PTY(), Rule("interactive")}, Stdout: []string{"interactive"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			r, w := io.Pipe()
+			go func() {
+				buf := new(bytes.Buffer)
+				buf.ReadFrom(r)
+				r.Close()
+			}()
+			opts := append(tc.Options, ID("test"), logOut(w), logErr(w))
+			c, err := New([]string{"sh", "-c", "if [ -t 1 ]; then echo interactive >&2; else echo piped; fi"}, opts...)
+			if err != nil {
+				t.Fatalf("unexpected error setting config: %s", err)
+			}
+			c.report = new(mockReport)
+			if err := c.Exec(); err != nil {
+				t.Fatalf("unexpected error execing command: %s", err)
+			}
+			if err := c.Cleanup(); err != nil {
+				t.Fatalf("unexpected cleanup error: %s", err)
+			}
+			assert.Equal(t, tc.Stdout, c.Stdout)
+			assert.Equal(t, len(c.Config.Rules), len(c.RuleMatches))
+		})
+	}
+}
@@ -0,0 +1,13 @@
+// +build !linux
+
+package monny
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+func startPTY(cmd *exec.Cmd) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("running a command with a pseudo-terminal is only supported on linux")
+}
@@ -1,65 +1,210 @@
 package monny
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/BTBurke/monny/pkg/pb"
 	"github.com/BTBurke/monny/pkg/proto"
 	"github.com/cenkalti/backoff"
+	protobuf "github.com/golang/protobuf/proto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/status"
 )
 
 // ReportSender is an interface for sending reports
 type ReportSender interface {
 	Send(c *Command, reason proto.ReportReason)
+	SendBatch(reports []*pb.Report) error
 	Wait() error
 }
 
 // Report is a wrapper for sending a report via GRPC. See pb.Report for details.
 type Report struct {
 	sender sender
+
+	// alerts are coalesced into a single report within the BatchReports window
+	alertMutex  sync.Mutex
+	alertTimer  *time.Timer
+	alertCmd    *Command
+	alertReason proto.ReportReason
+	alertFlush  sync.WaitGroup
+}
+
+// newReport returns a Report that prints each report in a dry run, appends to the report file when one is
+// configured, or sends to the reporting server otherwise.  Errors that occur after the last report is sent are recorded in errors.
+func newReport(cfg Config, errors ErrorReporter, log *logger) *Report {
+	if cfg.DryRun || cfg.PrintReports {
+		return &Report{
+			sender: &dryRunSender{
+				path: cfg.dryRunFile,
+			},
+		}
+	}
+	if len(cfg.ReportFile) > 0 {
+		return &Report{
+			sender: &fileSender{
+				path:   cfg.ReportFile,
+				errors: errors,
+			},
+		}
+	}
+	interval := cfg.BatchInterval
+	if cfg.BatchWindow > 0 {
+		interval = cfg.BatchWindow
+	}
+	return &Report{
+		sender: &senderService{
+			host:     cfg.host,
+			port:     cfg.port,
+			interval: interval,
+			size:     cfg.BatchSize,
+			log:      log,
+		},
+	}
+}
+
+// dryRunOut returns the output of a dry run, or nil when reports are sent
+func (r *Report) dryRunOut() io.Writer {
+	if s, ok := r.sender.(*dryRunSender); ok {
+		return s
+	}
+	return nil
+}
+
+// batchCloser is implemented by reporters that hold reports in a batch until the batch interval elapses
+type batchCloser interface {
+	closeBatch()
 }
 
 // sender is an interface for creating and sending a report in the background.
 type sender interface {
 	create(c *Command, reason proto.ReportReason) *pb.Report
 	sendBackground(report *pb.Report, result chan error, cancel chan bool)
+	sendBatch(reports []*pb.Report) error
 	wait()
 }
 
-// senderService implements the sender interface to send reports in the background using GRPC
+// senderService implements the sender interface to send reports in the background using GRPC.  A single
+// connection to the report server is shared by all reports and is redialed after a failed send.  When an
+// interval or a size greater than 1 is set, reports are batched and sent together after the interval elapses, or as
+// soon as the batch holds size reports.  A batch without an interval is sent when it is full or the command finishes.
 type senderService struct {
-	host   string
-	port   string
-	opts   []grpc.DialOption
-	errors ErrorReporter
-	wg     sync.WaitGroup
+	host     string
+	port     string
+	opts     []grpc.DialOption
+	wg       sync.WaitGroup
+	interval time.Duration
+	size     int
+	log      *logger
+
+	credentials sync.Once
+	connMutex   sync.Mutex
+	conn        *grpc.ClientConn
+
+	batchMutex  sync.Mutex
+	batch       []pendingReport
+	timer       *time.Timer
+	flushMutex  sync.Mutex
+	unsupported bool
+	// closing is true once the command has finished, after which reports are sent without waiting for the interval
+	closing bool
+}
+
+// pendingReport is a report waiting in the batch to be sent on the next flush
+type pendingReport struct {
+	report *pb.Report
+	result chan error
 }
 
 // Create prepares a new report based on the current status of the command.
 func (s *senderService) create(c *Command, reason proto.ReportReason) *pb.Report {
-	pb := reportFromCommand(c, reason, s.errors.ReportError)
-	if c.Config.useTLS {
-		s.opts = append(s.opts, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{})))
+	pb := reportFromCommand(c, reason, func(err error) { c.recordError(errorMarshal, err) })
+	truncateReport(pb, c.Config.MaxReportBytes)
+	s.credentials.Do(func() { s.configure(c.Config) })
+	return pb
+}
+
+// configure sets the dial options for compression, the API token, and TLS from the configuration
+func (s *senderService) configure(cfg Config) {
+	if cfg.Compress {
+		s.opts = append(s.opts, grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)))
+	}
+	if len(cfg.token) > 0 {
+		s.opts = append(s.opts, grpc.WithPerRPCCredentials(tokenCredentials{token: cfg.token, secure: cfg.useTLS}))
+	}
+	if cfg.useTLS {
+		tlsCfg := &tls.Config{}
+		if cfg.tls != nil {
+			tlsCfg = cfg.tls.Clone()
+		}
+		s.opts = append(s.opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg)))
 	} else {
 		s.opts = append(s.opts, grpc.WithInsecure())
 	}
-	return pb
 }
 
 // Send will send a report based on the current run status
 // of the command.  This is safe to call in a go routine to send
 // in the background.  It will attempt to send a report for 1hr
 // using exponential backoff if the call fails. (default)
+// Alerts are added to the pending batch instead when BatchReports is set.
 func (r *Report) Send(c *Command, reason proto.ReportReason) {
+	if c.Config.BatchReports > 0 && (reason == proto.Alert || reason == proto.AlertRate) {
+		r.batchAlert(c, reason)
+		return
+	}
+	r.send(c, reason)
+}
+
+// batchAlert starts a new batch of alerts that is sent when the window elapses, or adds the alert to the pending
+// batch.  The report is created when the batch is sent so that it includes all matches in the window.
+func (r *Report) batchAlert(c *Command, reason proto.ReportReason) {
+	r.alertMutex.Lock()
+	defer r.alertMutex.Unlock()
+	if r.alertTimer != nil {
+		// an alert is sent unconditionally, while an alert rate is only sent when the quantity is reached
+		if reason == proto.Alert {
+			r.alertReason = reason
+		}
+		c.log.debugf("%s report added to the pending batch", reason)
+		return
+	}
+	c.log.debugf("%s report batched for %s", reason, c.Config.BatchReports)
+	r.alertCmd = c
+	r.alertReason = reason
+	r.alertFlush.Add(1)
+	r.alertTimer = time.AfterFunc(c.Config.BatchReports, func() {
+		defer r.alertFlush.Done()
+		r.flushAlerts()
+	})
+}
+
+// flushAlerts sends the pending batch of alerts as a single report
+func (r *Report) flushAlerts() {
+	r.alertMutex.Lock()
+	c, reason := r.alertCmd, r.alertReason
+	r.alertCmd, r.alertTimer = nil, nil
+	r.alertMutex.Unlock()
+	if c == nil {
+		return
+	}
+	r.send(c, reason)
+}
+
+func (r *Report) send(c *Command, reason proto.ReportReason) {
 	c.mutex.Lock()
 	pb := r.sender.create(c, reason)
 	c.mutex.Unlock()
@@ -73,51 +218,61 @@ func (r *Report) Send(c *Command, reason proto.ReportReason) {
 		close(cancel)
 	}
 
+	// skip logs a report that is not sent because of the configuration
+	skip := func(why string) {
+		c.log.debugf("%s report not sent: %s", reason, why)
+		closeChannels()
+	}
+
 	cb := func() { return }
 	switch reason {
 	case proto.Failure:
 		if c.Config.NotifyOnFailure {
 			go r.sender.sendBackground(pb, result, cancel)
 		} else {
-			closeChannels()
+			skip("notifications on failure are disabled")
 			return
 		}
 	case proto.Success:
 		if c.Config.NotifyOnSuccess {
 			go r.sender.sendBackground(pb, result, cancel)
 		} else {
-			closeChannels()
+			skip("notifications on success are disabled")
 			return
 		}
-	case proto.FileNotCreated, proto.Killed:
+	case proto.FileNotCreated, proto.Killed, proto.Skipped, proto.LateRun, proto.SLOViolation:
 		go r.sender.sendBackground(pb, result, cancel)
 	case proto.Alert:
 		go r.sender.sendBackground(pb, result, cancel)
 		cb = func() {
+			c.mutex.Lock()
 			c.RuleMatches = []RuleMatch{}
+			c.mutex.Unlock()
 			return
 		}
 	case proto.AlertRate:
-		alertRateExceeded := calcAlertRate(c.RuleMatches, c.Config.RuleQuantity, c.Config.RulePeriod)
+		alertRateExceeded := ruleRateExceeded(c.RuleMatches, c.Config)
 		if alertRateExceeded {
 			go r.sender.sendBackground(pb, result, cancel)
 			cb = func() {
+				c.mutex.Lock()
 				c.RuleMatches = []RuleMatch{}
+				c.mutex.Unlock()
 				return
 			}
 		} else {
-			closeChannels()
+			skip("rule matches have not reached the quantity")
 			return
 		}
 	case proto.MemoryWarning:
 		if c.memWarnSent {
-			closeChannels()
+			skip("memory warning already sent")
 			return
 		}
 		go r.sender.sendBackground(pb, result, cancel)
 	case proto.TimeWarning:
 		if c.timeWarnSent {
-			closeChannels()
+			skip("time warning already sent")
 			return
 		}
 		go r.sender.sendBackground(pb, result, cancel)
@@ -125,44 +280,88 @@ func (r *Report) Send(c *Command, reason proto.ReportReason) {
 		if c.Config.Daemon {
 			go r.sender.sendBackground(pb, result, cancel)
 		} else {
-			closeChannels()
+			skip("start reports are only sent for daemons")
+			return
+		}
+	case proto.Heartbeat:
+		if c.Config.Daemon {
+			go r.sender.sendBackground(pb, result, cancel)
+		} else {
+			skip("heartbeat reports are only sent for daemons")
 			return
 		}
 	default:
 		return
 	}
+	c.log.infof("sending %s report", reason)
 
 	select {
 	case err := <-result:
 		switch {
 		case err == nil:
+			c.log.infof("sent %s report", reason)
 			cb()
 		default:
-			c.errors.ReportError(err)
+			c.log.warnf("failed to send %s report: %v", reason, err)
+			c.reportError(errorSend, err)
 		}
 	case <-timeout:
 		cancel <- true
-		c.errors.ReportError(fmt.Errorf("timeout on background report send: msg=%+v", pb))
+		c.log.warnf("timeout on background send of %s report", reason)
+		c.reportError(errorSend, fmt.Errorf("timeout on background send of %s report", reason))
 	}
 	closeChannels()
 }
 
+// SendBatch sends reports that are already created together in a single call, such as reports that were saved
+// while the server was unavailable.  Failed calls are retried using exponential backoff.
+func (r *Report) SendBatch(reports []*pb.Report) error {
+	if len(reports) == 0 {
+		return nil
+	}
+	return backoff.Retry(func() error { return r.sender.sendBatch(reports) }, backoff.NewExponentialBackOff())
+}
+
+// closeBatch sends the pending batch of reports without waiting for the interval to elapse, and sends reports
+// created afterward as soon as they are added.  It is called when the command finishes so that Command.Wait does not
+// block until the interval elapses.
+func (r *Report) closeBatch() {
+	if s, ok := r.sender.(*senderService); ok {
+		s.batchMutex.Lock()
+		s.closing = true
+		s.batchMutex.Unlock()
+		go s.flush()
+	}
+}
+
 // Wait will cause the process to block until the report is finished sending in the background.
 // This function is typically called on the Command at the top level to prevent the client
 // from exiting.  See Command.Wait().
 func (r *Report) Wait() error {
+	// send the pending batch of alerts now instead of waiting for the window to elapse
+	r.alertMutex.Lock()
+	pending := r.alertTimer != nil && r.alertTimer.Stop()
+	r.alertMutex.Unlock()
+	if pending {
+		r.flushAlerts()
+		r.alertFlush.Done()
+	}
+	r.alertFlush.Wait()
 	r.sender.wait()
 	return nil
 }
 
 func (s *senderService) wait() {
+	s.flush()
 	s.wg.Wait()
+	s.closeConnection()
 	return
 }
 
 // Send will transmit a report to the notification server using a go routine.
 // Errors will cause an exponential backoff until the call is successful or a timeout
-// is received from the parent.
+// is received from the parent.  When batching is enabled, the result is returned after
+// the batch containing the report is sent.
 func (s *senderService) sendBackground(report *pb.Report, result chan error, cancel chan bool) {
 	if report == nil {
 		result <- fmt.Errorf("no report created")
@@ -170,29 +369,169 @@ func (s *senderService) sendBackground(report *pb.Report, result chan error, can
 	}
 	s.wg.Add(1)
 	defer s.wg.Done()
-	send := func() error {
-		conn, err := grpc.Dial(net.JoinHostPort(s.host, s.port), s.opts...)
-		if err != nil {
-			return err
-		}
-		defer conn.Close()
 
-		client := pb.NewReportsClient(conn)
-		ack, err := client.Create(context.Background(), report)
+	var done chan error
+	switch {
+	case s.interval > 0 || s.size > 1:
+		done = s.enqueue(report)
+	default:
+		done = make(chan error, 1)
+		done <- backoff.RetryNotify(func() error { return s.send(report) }, backoff.NewExponentialBackOff(), s.retry)
+	}
+	select {
+	case result <- <-done:
+	case <-cancel:
+	}
+}
+
+// enqueue adds the report to the current batch, starting the flush timer for the first
+// report in a batch.  A full batch is flushed without waiting for the timer.
+func (s *senderService) enqueue(report *pb.Report) chan error {
+	done := make(chan error, 1)
+
+	s.batchMutex.Lock()
+	s.batch = append(s.batch, pendingReport{report: report, result: done})
+	if s.timer == nil && s.interval > 0 {
+		s.timer = time.AfterFunc(s.interval, s.flush)
+	}
+	full := s.size > 1 && len(s.batch) >= s.size
+	closing := s.closing
+	s.batchMutex.Unlock()
+
+	if full || closing {
+		go s.flush()
+	}
+	return done
+}
+
+// flush sends all reports in the current batch and returns the result to each waiting sender
+func (s *senderService) flush() {
+	s.flushMutex.Lock()
+	defer s.flushMutex.Unlock()
+
+	s.batchMutex.Lock()
+	batch := s.batch
+	s.batch = nil
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.batchMutex.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+	reports := make([]*pb.Report, 0, len(batch))
+	for _, pending := range batch {
+		reports = append(reports, pending.report)
+	}
+	err := backoff.RetryNotify(func() error { return s.sendBatch(reports) }, backoff.NewExponentialBackOff(), s.retry)
+	for _, pending := range batch {
+		pending.result <- err
+	}
+}
+
+// retry logs a failed send before it is retried
+func (s *senderService) retry(err error, wait time.Duration) {
+	s.log.warnf("send to %s failed, retrying in %s: %v", net.JoinHostPort(s.host, s.port), wait, err)
+}
+
+// sendBatch sends the reports in a single call to CreateBatch.  If the server does not implement
+// batching, reports are sent individually and batching is skipped for future calls.
+func (s *senderService) sendBatch(reports []*pb.Report) error {
+	if !s.unsupported {
+		conn, err := s.connection()
 		if err != nil {
 			return err
 		}
-		if !ack.Success {
+		ack, err := pb.NewReportsClient(conn).CreateBatch(context.Background(), &pb.ReportBatch{Reports: reports})
+		switch {
+		case status.Code(err) == codes.Unimplemented:
+			s.unsupported = true
+		case err != nil:
+			s.resetConnection(conn)
+			return err
+		case !ack.Success:
 			return fmt.Errorf("send fail")
+		default:
+			return nil
 		}
-		return nil
 	}
-	select {
-	case result <- backoff.Retry(send, backoff.NewExponentialBackOff()):
-	case <-cancel:
+	for _, report := range reports {
+		if err := s.send(report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send sends a single report using Create
+func (s *senderService) send(report *pb.Report) error {
+	conn, err := s.connection()
+	if err != nil {
+		return err
+	}
+	ack, err := pb.NewReportsClient(conn).Create(context.Background(), report)
+	if err != nil {
+		s.resetConnection(conn)
+		return err
+	}
+	if !ack.Success {
+		return fmt.Errorf("send fail")
+	}
+	return nil
+}
+
+// connection returns the shared connection to the report server, dialing if there is no
+// open connection
+func (s *senderService) connection() (*grpc.ClientConn, error) {
+	s.connMutex.Lock()
+	defer s.connMutex.Unlock()
+	if s.conn != nil {
+		return s.conn, nil
+	}
+	conn, err := grpc.Dial(net.JoinHostPort(s.host, s.port), s.opts...)
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+	return conn, nil
+}
+
+// resetConnection closes a connection that failed so that the next send will redial
+func (s *senderService) resetConnection(conn *grpc.ClientConn) {
+	s.connMutex.Lock()
+	defer s.connMutex.Unlock()
+	if s.conn == conn {
+		s.conn.Close()
+		s.conn = nil
 	}
 }
 
+func (s *senderService) closeConnection() {
+	s.connMutex.Lock()
+	defer s.connMutex.Unlock()
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// tokenCredentials attaches the API token to each call as a bearer token in the authorization header
+type tokenCredentials struct {
+	token  string
+	secure bool
+}
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + t.token}, nil
+}
+
+// RequireTransportSecurity requires TLS unless the connection was explicitly made insecure
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return t.secure
+}
+
 // calcAlertRate determines if the rate of rule matches exceeds the limit in the
 // specified period
 func calcAlertRate(matches []RuleMatch, quantity int, period time.Duration) bool {
@@ -218,36 +557,224 @@ func calcAlertRate(matches []RuleMatch, quantity int, period time.Duration) bool
 	}
 }
 
+// ruleRateExceeded returns true when the matches of a rule with its own quantity reach it within the period of the
+// rule.  Matches of the other rules are counted together against RuleQuantity and RulePeriod.
+func ruleRateExceeded(matches []RuleMatch, cfg Config) bool {
+	var pooled []RuleMatch
+	own := make(map[int][]RuleMatch)
+	for _, m := range matches {
+		if m.rule < len(cfg.Rules) && cfg.Rules[m.rule].Quantity > 0 {
+			own[m.rule] = append(own[m.rule], m)
+			continue
+		}
+		pooled = append(pooled, m)
+	}
+	for i, m := range own {
+		if calcAlertRate(m, cfg.Rules[i].Quantity, cfg.Rules[i].Period) {
+			return true
+		}
+	}
+	if len(own) > 0 && len(pooled) == 0 {
+		return false
+	}
+	return calcAlertRate(pooled, cfg.RuleQuantity, cfg.RulePeriod)
+}
+
 // reportFromCommand converts a Command to a pb.Report, doing
 // some conversion to be compatible with PB types and storage
 // schema on the backend
 func reportFromCommand(c *Command, reason proto.ReportReason, onError func(e error)) *pb.Report {
+	// marshal first so that errors are included in the messages of this report
+	created := marshalCreated(c.Created, onError)
+	matches := marshalMatches(c.RuleMatches, c.Config.JSONLMatches, onError)
+	config := marshalConfig(c.Config, onError)
+	steps := marshalSteps(c.Steps, onError)
+	duration := c.Duration
+	if reason == proto.Heartbeat {
+		// the uptime of a daemon that is still running
+		duration = time.Since(c.Start)
+	}
+	if duration < 0 {
+		onError(fmt.Errorf("duration of the process is negative (%s), reported as 0s", duration))
+		duration = 0
+	}
 	return &pb.Report{
-		Id:            c.Config.ID,
-		Hostname:      c.Config.Hostname,
-		Stdout:        c.Stdout,
-		Stderr:        c.Stderr,
-		Success:       c.Success,
-		MaxMemory:     c.MaxMemory,
-		Killed:        c.Killed,
-		KillReason:    pb.KillReason(c.KillReason),
-		Created:       marshalCreated(c.Created, onError),
-		ReportReason:  pb.ReportReason(reason),
-		Start:         c.Start.Unix(),
-		Finish:        c.Finish.Unix(),
-		Duration:      c.Duration.String(),
-		ExitCode:      c.ExitCode,
-		ExitCodeValid: c.ExitCodeValid,
-		Messages:      c.Messages,
-		Matches:       marshalMatches(c.RuleMatches, onError),
-		UserCommand:   strings.Join(c.UserCommand, " "),
-		Config:        marshalConfig(c.Config, onError),
-		CreatedAt:     time.Now().Unix(),
-	}
-}
-
-func marshalMatches(a []RuleMatch, onError func(e error)) []byte {
-	b, err := json.Marshal(a)
+		Id:             c.Config.ID,
+		Hostname:       c.Config.Hostname,
+		Stdout:         c.Stdout,
+		Stderr:         c.Stderr,
+		Success:        c.Success,
+		MaxMemory:      c.MaxMemory,
+		Memory:         c.memory,
+		Killed:         c.Killed,
+		KillReason:     pb.KillReason(c.KillReason),
+		Created:        created,
+		ReportReason:   pb.ReportReason(reason),
+		Start:          c.Start.Unix(),
+		Finish:         c.Finish.Unix(),
+		Duration:       duration.String(),
+		ExitCode:       c.ExitCode,
+		ExitCodeValid:  c.ExitCodeValid,
+		Messages:       c.Messages,
+		Matches:        matches,
+		UserCommand:    strings.Join(c.UserCommand, " "),
+		Config:         config,
+		Env:            c.Env,
+		Steps:          steps,
+		LinesProcessed: int64(c.stdoutLines + c.stderrLines),
+		CreatedAt:      time.Now().Unix(),
+	}
+}
+
+// truncateReport drops the oldest lines of stdout and stderr, then the oldest rule matches, until the serialized
+// report is no larger than max bytes.  A message is added to the report with the amount dropped.  The report is
+// measured once and the encoded size of each dropped line or match is subtracted, so the matches are only encoded
+// again after the loop.
+func truncateReport(r *pb.Report, max int) {
+	size := protobuf.Size(r)
+	if max <= 0 || size <= max {
+		return
+	}
+	// reserve space for the truncation message
+	limit := max - 256
+
+	matches, jsonl := splitMatches(r.Matches)
+	matchesLen := len(r.Matches)
+	stdoutLen, stderrLen := linesSize(r.Stdout), linesSize(r.Stderr)
+	current := size
+	var stdout, stderr, dropped int
+	var droppedMatches int
+	for current > limit {
+		switch {
+		case len(r.Stdout) > 0 && (stdoutLen >= stderrLen || len(r.Stderr) == 0):
+			before := r.Stdout[0]
+			r.Stdout, dropped = truncateLines(r.Stdout, current-limit)
+			current -= droppedSize(before, r.Stdout, dropped)
+			stdoutLen -= droppedLen(before, r.Stdout, dropped)
+			stdout += dropped
+		case len(r.Stderr) > 0:
+			before := r.Stderr[0]
+			r.Stderr, dropped = truncateLines(r.Stderr, current-limit)
+			current -= droppedSize(before, r.Stderr, dropped)
+			stderrLen -= droppedLen(before, r.Stderr, dropped)
+			stderr += dropped
+		case len(matches) > 0:
+			n := len(matches[0]) + 1
+			if !jsonl && len(matches) == 1 {
+				// the brackets of an empty array are kept
+				n--
+			}
+			current -= bytesFieldSize(matchesFieldTag, matchesLen) - bytesFieldSize(matchesFieldTag, matchesLen-n)
+			matchesLen -= n
+			matches = matches[1:]
+			droppedMatches++
+		default:
+			r.Matches = joinMatches(matches, jsonl)
+			r.Messages = append(r.Messages, fmt.Sprintf("report exceeds max report size of %d bytes after truncation", max))
+			return
+		}
+	}
+	if droppedMatches > 0 {
+		r.Matches = joinMatches(matches, jsonl)
+	}
+	r.Messages = append(r.Messages, fmt.Sprintf("report truncated from %d to %d bytes: dropped %d stdout lines, %d stderr lines, %d rule matches", size, protobuf.Size(r), stdout, stderr, droppedMatches))
+}
+
+// lineFieldTag and matchesFieldTag are the encoded sizes of the field tags of a line of stdout or stderr (fields 3
+// and 4) and the rule matches (field 17) in a report
+const (
+	lineFieldTag    = 1
+	matchesFieldTag = 2
+)
+
+// bytesFieldSize returns the encoded size of a string or bytes field of n bytes.  Empty fields are not encoded.
+func bytesFieldSize(tag int, n int) int {
+	if n == 0 {
+		return 0
+	}
+	return tag + protobuf.SizeVarint(uint64(n)) + n
+}
+
+// droppedSize returns how much smaller the encoded report is after truncateLines dropped the first line, or cut the
+// line before down to the first of lines
+func droppedSize(before string, lines []string, dropped int) int {
+	lineSize := func(line string) int { return lineFieldTag + protobuf.SizeVarint(uint64(len(line))) + len(line) }
+	if dropped > 0 {
+		return lineSize(before)
+	}
+	return lineSize(before) - lineSize(lines[0])
+}
+
+// droppedLen returns how many bytes of output truncateLines removed
+func droppedLen(before string, lines []string, dropped int) int {
+	if dropped > 0 {
+		return len(before)
+	}
+	return len(before) - len(lines[0])
+}
+
+// splitMatches returns each encoded match from either a JSON array or JSON Lines and whether they were JSON Lines
+func splitMatches(b []byte) ([]json.RawMessage, bool) {
+	var matches []json.RawMessage
+	if err := json.Unmarshal(b, &matches); err == nil {
+		return matches, false
+	}
+	for _, line := range bytes.Split(b, []byte{'\n'}) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			matches = append(matches, json.RawMessage(line))
+		}
+	}
+	return matches, true
+}
+
+// joinMatches encodes matches in the same format returned by splitMatches
+func joinMatches(matches []json.RawMessage, jsonl bool) []byte {
+	if jsonl {
+		var buf bytes.Buffer
+		for _, match := range matches {
+			buf.Write(match)
+			buf.WriteByte('\n')
+		}
+		return buf.Bytes()
+	}
+	b, err := json.Marshal(matches)
+	if err != nil {
+		return []byte("[]")
+	}
+	return b
+}
+
+// truncateLines drops the oldest line.  If it is the only line, the line is shortened by excess bytes from the start.
+// Returns the remaining lines and the number of lines that were dropped.
+func truncateLines(lines []string, excess int) ([]string, int) {
+	if len(lines) > 1 || len(lines[0]) <= excess {
+		return lines[1:], 1
+	}
+	// cut on a rune boundary to keep the line valid UTF-8
+	cut := excess
+	for cut < len(lines[0]) && !utf8.RuneStart(lines[0][cut]) {
+		cut++
+	}
+	return []string{lines[0][cut:]}, 0
+}
+
+func linesSize(lines []string) int {
+	size := 0
+	for _, line := range lines {
+		size += len(line)
+	}
+	return size
+}
+
+func marshalMatches(a []RuleMatch, jsonl bool, onError func(e error)) []byte {
+	var b []byte
+	var err error
+	switch jsonl {
+	case true:
+		b, err = MarshalMatchesJSONL(a)
+	default:
+		b, err = json.Marshal(a)
+	}
 	if err != nil {
 		// Error will be reported externally. Report will continue even if this
 		// conversion fails.
@@ -256,6 +783,20 @@ func marshalMatches(a []RuleMatch, onError func(e error)) []byte {
 	return b
 }
 
+// MarshalMatchesJSONL encodes each rule match as a JSON object on its own line (JSON Lines).  Each line, including
+// the last, ends with a newline.  No matches are encoded as an empty slice.
+func MarshalMatchesJSONL(matches []RuleMatch) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, match := range matches {
+		// Encode terminates each object with a newline
+		if err := enc.Encode(match); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
 func marshalCreated(a []File, onError func(e error)) []byte {
 	b, err := json.Marshal(a)
 	if err != nil {
@@ -266,12 +807,30 @@ func marshalCreated(a []File, onError func(e error)) []byte {
 	return b
 }
 
-func marshalConfig(a Config, onError func(e error)) []byte {
+// marshalSteps serializes the results of the steps of a pipeline, or nothing when the command is not a pipeline
+func marshalSteps(a []StepResult, onError func(e error)) []byte {
+	if len(a) == 0 {
+		return nil
+	}
 	b, err := json.Marshal(a)
+	if err != nil {
+		onError(err)
+	}
+	return b
+}
+
+// marshalConfig serializes the sanitized configuration, or an empty object when the configuration is omitted
+// from reports
+func marshalConfig(a Config, onError func(e error)) []byte {
+	if a.OmitConfig {
+		return []byte("{}")
+	}
+	b, err := json.Marshal(a.Sanitized())
 	if err != nil {
 		// Error will be reported externally. Report will continue even if this
 		// conversion fails.
 		onError(err)
+		return []byte("{}")
 	}
 	return b
 }
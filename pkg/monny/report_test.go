@@ -1,11 +1,22 @@
 package monny
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
+	protobuf "github.com/golang/protobuf/proto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
 
 	"github.com/BTBurke/monny/pkg/pb"
 	"github.com/BTBurke/monny/pkg/proto"
@@ -31,6 +42,11 @@ func (m *mockSender) sendBackground(report *pb.Report, result chan error, cancel
 	result <- nil
 }
 
+func (m *mockSender) sendBatch(reports []*pb.Report) error {
+	m.Called(reports)
+	return nil
+}
+
 func (m *mockSender) wait() {
 	m.Called()
 	return
@@ -55,6 +71,8 @@ func TestReportCreation(t *testing.T) {
 		{Name: "file not created", ShouldSend: true, Reason: proto.Killed, TestCase: baseCase(proto.Killed)},
 		{Name: "start daemon", ShouldSend: true, Reason: proto.Start, TestCase: baseCase(proto.Start, Daemon())},
 		{Name: "start no daemon", ShouldSend: false, Reason: proto.Start, TestCase: baseCase(proto.Start)},
+		{Name: "heartbeat daemon", ShouldSend: true, Reason: proto.Heartbeat, TestCase: baseCase(proto.Heartbeat, Daemon(), Heartbeat("1m"))},
+		{Name: "heartbeat no daemon", ShouldSend: false, Reason: proto.Heartbeat, TestCase: baseCase(proto.Heartbeat, Heartbeat("1m"))},
 		{Name: "warn time", ShouldSend: true, Reason: proto.TimeWarning, TestCase: baseCase(proto.TimeWarning)},
 		{Name: "warn memory", ShouldSend: true, Reason: proto.MemoryWarning, TestCase: baseCase(proto.MemoryWarning)},
 	}
@@ -136,6 +154,40 @@ func TestRateCheck(t *testing.T) {
 
 }
 
+func TestRuleRateCheck(t *testing.T) {
+	cfg := Config{
+		RuleQuantity: 3,
+		Rules: []rule{
+			{Regex: regexp.MustCompile("ERROR")},
+			{Regex: regexp.MustCompile("SLOW"), Quantity: 5, Period: time.Minute},
+		},
+	}
+	forRule := func(i int, matches []RuleMatch) []RuleMatch {
+		for j := range matches {
+			matches[j].rule = i
+		}
+		return matches
+	}
+	tt := []struct {
+		Name        string
+		RuleMatches []RuleMatch
+		Exceeds     bool
+	}{
+		{Name: "pooled exceeds", RuleMatches: forRule(0, createMatches(0, 3)), Exceeds: true},
+		{Name: "pooled under", RuleMatches: forRule(0, createMatches(0, 2)), Exceeds: false},
+		{Name: "own quantity exceeds", RuleMatches: forRule(1, createMatches(30*time.Second, 5)), Exceeds: true},
+		{Name: "own quantity under", RuleMatches: forRule(1, createMatches(30*time.Second, 4)), Exceeds: false},
+		{Name: "own period under", RuleMatches: forRule(1, createMatches(10*time.Minute, 5)), Exceeds: false},
+		{Name: "own quantity not pooled", RuleMatches: append(forRule(0, createMatches(0, 2)), forRule(1, createMatches(0, 2))...), Exceeds: false},
+		{Name: "no matches", RuleMatches: []RuleMatch{}, Exceeds: false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			assert.Equal(t, tc.Exceeds, ruleRateExceeded(tc.RuleMatches, cfg))
+		})
+	}
+}
+
 func createMatches(t time.Duration, num int) []RuleMatch {
 	if num == 0 {
 		return []RuleMatch{}
@@ -168,6 +220,318 @@ func (m *mockReportsServer) Create(ctx context.Context, rpt *pb.Report) (*pb.Rep
 	return args.Get(0).(*pb.ReportAck), args.Error(1)
 }
 
+func (m *mockReportsServer) CreateBatch(ctx context.Context, batch *pb.ReportBatch) (*pb.ReportAck, error) {
+	args := m.Called(batch)
+	return args.Get(0).(*pb.ReportAck), args.Error(1)
+}
+
+// mockUnbatchedServer is a report server that does not implement CreateBatch
+type mockUnbatchedServer struct {
+	pb.UnimplementedReportsServer
+	mock.Mock
+}
+
+func (m *mockUnbatchedServer) Create(ctx context.Context, rpt *pb.Report) (*pb.ReportAck, error) {
+	args := m.Called()
+	return args.Get(0).(*pb.ReportAck), args.Error(1)
+}
+
+// countingListener counts the number of connections accepted by the server
+type countingListener struct {
+	net.Listener
+	mutex sync.Mutex
+	count int
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.mutex.Lock()
+		l.count++
+		l.mutex.Unlock()
+	}
+	return conn, err
+}
+
+func (l *countingListener) connections() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.count
+}
+
+func startReportServer(t *testing.T, srv pb.ReportsServer) (*countingListener, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	counter := &countingListener{Listener: lis}
+	grpcServer := grpc.NewServer()
+	pb.RegisterReportsServer(grpcServer, srv)
+	go grpcServer.Serve(counter)
+	return counter, grpcServer.Stop
+}
+
+func newTestSender(t *testing.T, lis net.Listener, interval time.Duration) *senderService {
+	host, port, err := net.SplitHostPort(lis.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error parsing listener address: %s", err)
+	}
+	return &senderService{
+		host:     host,
+		port:     port,
+		interval: interval,
+	}
+}
+
+// sendReports sends n reports in the background and returns a channel of their results
+func sendReports(s *senderService, c *Command, n int) chan error {
+	results := make(chan error, n)
+	for i := 0; i < n; i++ {
+		rpt := s.create(c, proto.Alert)
+		go func() {
+			result := make(chan error, 1)
+			cancel := make(chan bool, 1)
+			s.sendBackground(rpt, result, cancel)
+			results <- <-result
+		}()
+	}
+	return results
+}
+
+func TestSendConnectionReuse(t *testing.T) {
+	c, errs := New([]string{"test"}, ID("test"), Insecure())
+	if len(errs) != 0 {
+		t.Fatalf("unexpected error creating cmd: %s", errs)
+	}
+	mocks := new(mockReportsServer)
+	mocks.On("Create").Return(&pb.ReportAck{Success: true}, nil)
+	lis, stop := startReportServer(t, mocks)
+	defer stop()
+
+	s := newTestSender(t, lis, 0)
+	for i := 0; i < 3; i++ {
+		rpt := s.create(c, proto.Alert)
+		result := make(chan error, 1)
+		cancel := make(chan bool, 1)
+		s.sendBackground(rpt, result, cancel)
+		assert.NoError(t, <-result)
+	}
+	s.wait()
+
+	mocks.AssertNumberOfCalls(t, "Create", 3)
+	assert.Equal(t, 1, lis.connections())
+}
+
+func TestSendBatch(t *testing.T) {
+	c, errs := New([]string{"test"}, ID("test"), Insecure())
+	if len(errs) != 0 {
+		t.Fatalf("unexpected error creating cmd: %s", errs)
+	}
+	mocks := new(mockReportsServer)
+	mocks.On("CreateBatch", mock.Anything).Return(&pb.ReportAck{Success: true}, nil)
+	lis, stop := startReportServer(t, mocks)
+	defer stop()
+
+	// interval is long enough that only wait will flush the batch
+	s := newTestSender(t, lis, time.Hour)
+	results := sendReports(s, c, 3)
+	waitForBatch(t, s, 3)
+	s.wait()
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, <-results)
+	}
+	mocks.AssertNumberOfCalls(t, "CreateBatch", 1)
+	mocks.AssertNotCalled(t, "Create")
+	batch := mocks.Calls[0].Arguments.Get(0).(*pb.ReportBatch)
+	assert.Len(t, batch.Reports, 3)
+	for _, rpt := range batch.Reports {
+		assert.Equal(t, "test", rpt.Id)
+		assert.Equal(t, pb.ReportReason_Alert, rpt.ReportReason)
+	}
+	assert.Equal(t, 1, lis.connections())
+}
+
+func TestSendBatchInterval(t *testing.T) {
+	c, errs := New([]string{"test"}, ID("test"), Insecure())
+	if len(errs) != 0 {
+		t.Fatalf("unexpected error creating cmd: %s", errs)
+	}
+	mocks := new(mockReportsServer)
+	mocks.On("CreateBatch", mock.Anything).Return(&pb.ReportAck{Success: true}, nil)
+	lis, stop := startReportServer(t, mocks)
+	defer stop()
+
+	s := newTestSender(t, lis, 50*time.Millisecond)
+	results := sendReports(s, c, 2)
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, <-results)
+	}
+	results = sendReports(s, c, 1)
+	assert.NoError(t, <-results)
+	s.wait()
+
+	mocks.AssertNumberOfCalls(t, "CreateBatch", 2)
+	assert.Equal(t, 1, lis.connections())
+}
+
+func TestSendBatchSize(t *testing.T) {
+	c, errs := New([]string{"test"}, ID("test"), Insecure())
+	if len(errs) != 0 {
+		t.Fatalf("unexpected error creating cmd: %s", errs)
+	}
+	mocks := new(mockReportsServer)
+	mocks.On("CreateBatch", mock.Anything).Return(&pb.ReportAck{Success: true}, nil)
+	lis, stop := startReportServer(t, mocks)
+	defer stop()
+
+	// interval is long enough that only a full batch or wait will flush the batch
+	s := newTestSender(t, lis, time.Hour)
+	s.size = 2
+	results := sendReports(s, c, 2)
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, <-results)
+	}
+	mocks.AssertNumberOfCalls(t, "CreateBatch", 1)
+
+	results = sendReports(s, c, 1)
+	waitForBatch(t, s, 1)
+	s.wait()
+	assert.NoError(t, <-results)
+
+	mocks.AssertNumberOfCalls(t, "CreateBatch", 2)
+	assert.Len(t, mocks.Calls[0].Arguments.Get(0).(*pb.ReportBatch).Reports, 2)
+	assert.Len(t, mocks.Calls[1].Arguments.Get(0).(*pb.ReportBatch).Reports, 1)
+}
+
+func TestSendBatchSizeWithoutInterval(t *testing.T) {
+	c, errs := New([]string{"test"}, ID("test"), Insecure())
+	if len(errs) != 0 {
+		t.Fatalf("unexpected error creating cmd: %s", errs)
+	}
+	mocks := new(mockReportsServer)
+	mocks.On("CreateBatch", mock.Anything).Return(&pb.ReportAck{Success: true}, nil)
+	lis, stop := startReportServer(t, mocks)
+	defer stop()
+
+	// without an interval, a batch is only sent when it is full or on wait
+	s := newTestSender(t, lis, 0)
+	s.size = 2
+	results := sendReports(s, c, 2)
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, <-results)
+	}
+	mocks.AssertNumberOfCalls(t, "CreateBatch", 1)
+
+	results = sendReports(s, c, 1)
+	waitForBatch(t, s, 1)
+	s.wait()
+	assert.NoError(t, <-results)
+
+	mocks.AssertNumberOfCalls(t, "CreateBatch", 2)
+	assert.Len(t, mocks.Calls[0].Arguments.Get(0).(*pb.ReportBatch).Reports, 2)
+	assert.Len(t, mocks.Calls[1].Arguments.Get(0).(*pb.ReportBatch).Reports, 1)
+}
+
+func TestReportSendBatch(t *testing.T) {
+	c, errs := New([]string{"test"}, ID("test"), Insecure())
+	if len(errs) != 0 {
+		t.Fatalf("unexpected error creating cmd: %s", errs)
+	}
+	mocks := new(mockReportsServer)
+	mocks.On("CreateBatch", mock.Anything).Return(&pb.ReportAck{Success: true}, nil)
+	lis, stop := startReportServer(t, mocks)
+	defer stop()
+
+	s := newTestSender(t, lis, 0)
+	r := &Report{sender: s}
+	reports := []*pb.Report{s.create(c, proto.Alert), s.create(c, proto.Heartbeat), s.create(c, proto.Alert)}
+	assert.NoError(t, r.SendBatch(reports))
+	assert.NoError(t, r.SendBatch(nil))
+	assert.NoError(t, r.Wait())
+
+	mocks.AssertNumberOfCalls(t, "CreateBatch", 1)
+	assert.Len(t, mocks.Calls[0].Arguments.Get(0).(*pb.ReportBatch).Reports, 3)
+	assert.Equal(t, 1, lis.connections())
+}
+
+func TestSendBatchFallback(t *testing.T) {
+	c, errs := New([]string{"test"}, ID("test"), Insecure())
+	if len(errs) != 0 {
+		t.Fatalf("unexpected error creating cmd: %s", errs)
+	}
+	mocks := new(mockUnbatchedServer)
+	mocks.On("Create").Return(&pb.ReportAck{Success: true}, nil)
+	lis, stop := startReportServer(t, mocks)
+	defer stop()
+
+	s := newTestSender(t, lis, time.Hour)
+	results := sendReports(s, c, 3)
+	waitForBatch(t, s, 3)
+	s.wait()
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, <-results)
+	}
+	mocks.AssertNumberOfCalls(t, "Create", 3)
+	assert.True(t, s.unsupported)
+}
+
+func TestDaemonBatch(t *testing.T) {
+	mocks := new(mockReportsServer)
+	mocks.On("CreateBatch", mock.Anything).Return(&pb.ReportAck{Success: true}, nil)
+	lis, stop := startReportServer(t, mocks)
+	defer stop()
+
+	// heartbeats of a daemon within the interval are sent in one call when the command exits, without waiting for the
+	// interval to elapse.  The last heartbeat is well before the exit, otherwise its send can reach the batch after
+	// it is closed.
+	c, errs := New([]string{"sleep", "1"}, ID("test"), Daemon(), Heartbeat("300ms"), BatchInterval("1h"), Host(lis.Addr().String()), Insecure(),
+		logOut(&closeBuffer{}), logErr(&closeBuffer{}))
+	if len(errs) != 0 {
+		t.Fatalf("unexpected error creating cmd: %s", errs)
+	}
+	assert.NoError(t, c.Exec())
+	assert.NoError(t, c.Wait())
+
+	mocks.AssertNotCalled(t, "Create")
+	var heartbeats, calls int
+	for _, call := range mocks.Calls {
+		var n int
+		for _, rpt := range call.Arguments.Get(0).(*pb.ReportBatch).Reports {
+			if rpt.ReportReason == pb.ReportReason_Heartbeat {
+				n++
+			}
+		}
+		if n > 0 {
+			calls++
+		}
+		heartbeats += n
+	}
+	// the success report may be sent in a later call after the command exits
+	assert.Equal(t, 1, calls, "heartbeats should be sent in a single call")
+	assert.True(t, heartbeats >= 2, "expected at least 2 heartbeats in the batch, got %d", heartbeats)
+	assert.Equal(t, 1, lis.connections())
+}
+
+// waitForBatch blocks until n reports are waiting in the batch
+func waitForBatch(t *testing.T, s *senderService, n int) {
+	timeout := time.After(5 * time.Second)
+	for {
+		s.batchMutex.Lock()
+		pending := len(s.batch)
+		s.batchMutex.Unlock()
+		if pending == n {
+			return
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("timeout waiting for batch, expected %d got %d", n, pending)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
 func TestSendBackground(t *testing.T) {
 	c, errs := New([]string{"test"}, ID("test"), Insecure())
 	if len(errs) != 0 {
@@ -187,9 +551,8 @@ func TestSendBackground(t *testing.T) {
 	defer grpcServer.Stop()
 
 	s := &senderService{
-		host:   "127.0.0.1",
-		port:   "34129",
-		errors: mockError{},
+		host: "127.0.0.1",
+		port: "34129",
 	}
 	rpt := s.create(c, proto.Success)
 	result := make(chan error, 1)
@@ -203,3 +566,328 @@ func TestSendBackground(t *testing.T) {
 	}
 
 }
+
+func TestReportNegativeDuration(t *testing.T) {
+	c, errs := New([]string{"test"}, ID("test"))
+	if len(errs) != 0 {
+		t.Fatalf("unexpected error creating cmd: %s", errs)
+	}
+	c.Start = time.Now()
+	c.Finish = c.Start.Add(-time.Minute)
+	c.Duration = c.Finish.Sub(c.Start)
+
+	var reported []error
+	rpt := reportFromCommand(c, proto.Killed, func(e error) { reported = append(reported, e) })
+	assert.Equal(t, "0s", rpt.Duration)
+	if assert.Len(t, reported, 1) {
+		assert.Contains(t, reported[0].Error(), "negative (-1m0s)")
+	}
+}
+
+func TestReportLinesProcessed(t *testing.T) {
+	c, errs := New([]string{"test"}, ID("test"), StdoutHistory("2"), StderrHistory("2"))
+	if len(errs) != 0 {
+		t.Fatalf("unexpected error creating cmd: %s", errs)
+	}
+	for _, line := range []string{"one", "two", "three", "four", "five"} {
+		c.processStdout([]byte(line), "")
+	}
+	c.processStderr([]byte("error"), "")
+
+	rpt := reportFromCommand(c, proto.Success, func(e error) { t.Errorf("unexpected error: %v", e) })
+	assert.Equal(t, int64(6), rpt.LinesProcessed, "lines dropped from the history should still be counted")
+	assert.Contains(t, renderReport(rpt), "lines processed: 6")
+}
+
+func TestTruncateReport(t *testing.T) {
+	line := strings.Repeat("a", 500*1024)
+	lines := func(n int) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = fmt.Sprintf("%d %s", i, line)
+		}
+		return out
+	}
+	shortLines := func(n int) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = fmt.Sprintf("%d %s", i, line[:200])
+		}
+		return out
+	}
+	matches := func(n int) []RuleMatch {
+		out := make([]RuleMatch, n)
+		for i := range out {
+			out[i] = RuleMatch{Time: time.Now(), Line: line}
+		}
+		return out
+	}
+
+	tt := []struct {
+		Name      string
+		Stdout    []string
+		Stderr    []string
+		Matches   []RuleMatch
+		Max       int
+		Options   []ConfigOption
+		Truncated bool
+	}{
+		{Name: "under limit", Stdout: []string{"test"}, Max: 1024 * 1024},
+		{Name: "no limit", Stdout: lines(10), Max: 0},
+		{Name: "stdout", Stdout: lines(10), Max: 1024 * 1024, Truncated: true},
+		{Name: "stdout and stderr", Stdout: lines(6), Stderr: lines(6), Max: 2 * 1024 * 1024, Truncated: true},
+		{Name: "many short lines", Stdout: shortLines(20000), Max: 1024 * 1024, Truncated: true},
+		{Name: "single long line", Stdout: []string{strings.Repeat("日本", 1024*1024)}, Max: 1024 * 1024, Truncated: true},
+		{Name: "matches", Stdout: lines(2), Matches: matches(6), Max: 1024 * 1024, Truncated: true},
+		{Name: "jsonl matches", Stdout: lines(2), Matches: matches(6), Max: 1024 * 1024, Options: []ConfigOption{UseJSONLMatches()}, Truncated: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			c, errs := New([]string{"test"}, append(tc.Options, ID("test"))...)
+			if len(errs) != 0 {
+				t.Fatalf("unexpected error creating cmd: %s", errs)
+			}
+			c.Stdout = tc.Stdout
+			c.Stderr = tc.Stderr
+			c.RuleMatches = tc.Matches
+			rpt := reportFromCommand(c, proto.Alert, func(e error) {})
+			before := protobuf.Size(rpt)
+
+			truncateReport(rpt, tc.Max)
+
+			switch tc.Truncated {
+			case true:
+				assert.True(t, protobuf.Size(rpt) <= tc.Max, "report size %d exceeds limit %d", protobuf.Size(rpt), tc.Max)
+				if assert.Len(t, rpt.Messages, 1) {
+					assert.Contains(t, rpt.Messages[0], fmt.Sprintf("report truncated from %d", before))
+				}
+				if len(tc.Stdout) > 1 && len(rpt.Stdout) > 0 {
+					// newest lines are kept
+					assert.Equal(t, tc.Stdout[len(tc.Stdout)-1], rpt.Stdout[len(rpt.Stdout)-1])
+				}
+				assert.True(t, utf8.ValidString(strings.Join(rpt.Stdout, "")))
+				_, err := protobuf.Marshal(rpt)
+				assert.NoError(t, err)
+				if len(tc.Matches) > 0 {
+					// remaining matches are encoded in the same format
+					remaining, jsonl := splitMatches(rpt.Matches)
+					assert.Equal(t, c.Config.JSONLMatches, jsonl)
+					assert.True(t, len(remaining) < len(tc.Matches))
+				}
+			default:
+				assert.Equal(t, before, protobuf.Size(rpt))
+				assert.Len(t, rpt.Messages, 0)
+			}
+		})
+	}
+}
+
+func TestMarshalMatchesJSONL(t *testing.T) {
+	now := time.Now().UTC()
+	tt := []struct {
+		Name    string
+		Matches []RuleMatch
+	}{
+		{Name: "none"},
+		{Name: "single", Matches: []RuleMatch{{Time: now, Line: "error", Index: [][]int{{0, 5}}}}},
+		{Name: "multiple", Matches: []RuleMatch{{Time: now, Line: "error 1"}, {Time: now, Line: "error\n2"}, {Time: now, Line: "error 3"}}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			b, err := MarshalMatchesJSONL(tc.Matches)
+			assert.NoError(t, err)
+			lines := strings.Split(string(b), "\n")
+			// each match ends with a newline
+			assert.Len(t, lines, len(tc.Matches)+1)
+			assert.Equal(t, "", lines[len(lines)-1])
+			for i, line := range lines[0 : len(lines)-1] {
+				var match RuleMatch
+				assert.NoError(t, json.Unmarshal([]byte(line), &match))
+				assert.Equal(t, tc.Matches[i], match)
+			}
+		})
+	}
+}
+
+// compressionHandler records the compression of RPCs received by the server
+type compressionHandler struct {
+	mutex       sync.Mutex
+	compression []string
+}
+
+func (h *compressionHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h *compressionHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if header, ok := s.(*stats.InHeader); ok {
+		h.mutex.Lock()
+		h.compression = append(h.compression, header.Compression)
+		h.mutex.Unlock()
+	}
+}
+
+func (h *compressionHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h *compressionHandler) HandleConn(ctx context.Context, s stats.ConnStats) {}
+
+func TestSendCompressed(t *testing.T) {
+	tt := []struct {
+		Name        string
+		Options     []ConfigOption
+		Compression string
+	}{
+		{Name: "gzip", Compression: "gzip"},
+		{Name: "no compression", Options: []ConfigOption{NoCompression()}, Compression: ""},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("failed to listen: %v", err)
+			}
+			handler := &compressionHandler{}
+			mocks := new(mockReportsServer)
+			mocks.On("Create").Return(&pb.ReportAck{Success: true}, nil)
+			grpcServer := grpc.NewServer(grpc.StatsHandler(handler))
+			pb.RegisterReportsServer(grpcServer, mocks)
+			go grpcServer.Serve(lis)
+			defer grpcServer.Stop()
+
+			c, errs := New([]string{"test"}, append(tc.Options, ID("test"), Insecure())...)
+			if len(errs) != 0 {
+				t.Fatalf("unexpected error creating cmd: %s", errs)
+			}
+			s := newTestSender(t, lis, 0)
+			defer s.closeConnection()
+			assert.NoError(t, s.send(s.create(c, proto.Success)))
+			mocks.AssertNumberOfCalls(t, "Create", 1)
+
+			handler.mutex.Lock()
+			defer handler.mutex.Unlock()
+			assert.Equal(t, []string{tc.Compression}, handler.compression)
+		})
+	}
+}
+
+// tokenServer records the authorization metadata and config of each report received
+type tokenServer struct {
+	pb.UnimplementedReportsServer
+	mutex         sync.Mutex
+	authorization []string
+	config        []byte
+}
+
+func (s *tokenServer) Create(ctx context.Context, rpt *pb.Report) (*pb.ReportAck, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.authorization = md.Get("authorization")
+	s.config = rpt.Config
+	return &pb.ReportAck{Success: true}, nil
+}
+
+func TestSendToken(t *testing.T) {
+	tt := []struct {
+		Name          string
+		Options       []ConfigOption
+		Authorization []string
+	}{
+		{Name: "token", Options: []ConfigOption{APIToken("s3cr3t-token")}, Authorization: []string{"Bearer s3cr3t-token"}},
+		{Name: "no token", Authorization: nil},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			srv := &tokenServer{}
+			lis, stop := startReportServer(t, srv)
+			defer stop()
+
+			c, errs := New([]string{"test"}, append(tc.Options, ID("test"), Insecure())...)
+			if len(errs) != 0 {
+				t.Fatalf("unexpected error creating cmd: %s", errs)
+			}
+			s := newTestSender(t, lis, 0)
+			defer s.closeConnection()
+			assert.NoError(t, s.send(s.create(c, proto.Success)))
+
+			srv.mutex.Lock()
+			defer srv.mutex.Unlock()
+			assert.Equal(t, tc.Authorization, srv.authorization)
+			assert.NotEmpty(t, srv.config)
+			assert.NotContains(t, string(srv.config), "s3cr3t-token")
+		})
+	}
+}
+
+func TestMarshalConfig(t *testing.T) {
+	secrets := []ConfigOption{
+		ID("test"),
+		APIToken("s3cr3t-token"),
+		Rule("db.internal.example.com"),
+		MetricRule("errors", "stderr", "auth.internal.example.com"),
+		CommandTemplate("backup --password {{.password}}", map[string]string{"password": "hunter2"}),
+		StdoutHistory("50"),
+		KillTimeout("1h"),
+	}
+	tt := []struct {
+		Name    string
+		Options []ConfigOption
+		Expect  map[string]interface{}
+	}{
+		{Name: "sanitized", Options: secrets, Expect: map[string]interface{}{"ID": "test", "StdoutHistory": float64(50), "KillTimeout": float64(time.Hour)}},
+		{Name: "omitted", Options: append(secrets, NoConfigInReport()), Expect: map[string]interface{}{}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			cfg, errs := newConfig(tc.Options...)
+			if len(errs) > 0 {
+				t.Fatalf("unexpected error in config: %s", errs)
+			}
+			b := marshalConfig(cfg, func(e error) { t.Errorf("unexpected error: %v", e) })
+
+			var decoded map[string]interface{}
+			if err := json.Unmarshal(b, &decoded); err != nil {
+				t.Fatalf("config is not valid JSON: %v", err)
+			}
+			for k, v := range tc.Expect {
+				assert.Equal(t, v, decoded[k], "field %s", k)
+			}
+			if len(tc.Expect) == 0 {
+				assert.Len(t, decoded, 0)
+			}
+			for _, secret := range []string{"s3cr3t-token", "internal.example.com", "hunter2", "password", cfg.Shell} {
+				assert.NotContains(t, string(b), secret)
+			}
+		})
+	}
+}
+
+func TestBatchReports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monny")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "reports.jsonl")
+
+	// the first two matches are sent together when the window elapses and the last is sent on exit
+	c, errs := New([]string{"sh", "-c", "echo ERROR 1; echo ERROR 2; sleep 0.6; echo ERROR 3"}, ID("test"), Rule("ERROR"), BatchReports("300ms"),
+		NoNotifyOnSuccess(), ReportFile(path), logOut(&closeBuffer{}), logErr(&closeBuffer{}))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	assert.NoError(t, c.Exec())
+	assert.NoError(t, c.Wait())
+
+	reports := readReportFile(t, path)
+	if assert.Len(t, reports, 2) {
+		for i, expect := range []int{2, 1} {
+			assert.Equal(t, pb.ReportReason(proto.Alert), reports[i].ReportReason)
+			matches, _ := splitMatches(reports[i].Matches)
+			assert.Len(t, matches, expect)
+		}
+	}
+}
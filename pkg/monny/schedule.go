@@ -0,0 +1,199 @@
+package monny
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/proto"
+)
+
+// Scheduler runs the command on the schedule set with the Schedule option, such as in a container without cron.  Each
+// run is a new Command created with the same options, so that the rule matches, output history, and reports of one
+// run do not carry over to the next.
+type Scheduler struct {
+	Config Config
+
+	usercmd  []string
+	options  []ConfigOption
+	log      *logger
+	stop     chan struct{}
+	stopOnce sync.Once
+	runs     sync.WaitGroup
+	mutex    sync.Mutex
+	// running holds the runs in progress and the time each was scheduled
+	running map[*Command]time.Time
+	// prepare is called with the command of each run before it is started or skipped
+	prepare func(c *Command)
+}
+
+// NewScheduler prepares the user's command to run on the schedule set in options.  Options are checked by creating a
+// command the same way as each run.
+func NewScheduler(usercmd []string, options ...ConfigOption) (*Scheduler, []error) {
+	c, errs := New(usercmd, options...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if c.Config.schedule == nil {
+		return nil, []error{ErrInvalidValue{Option: "schedule", Reason: "a schedule is required, set with --schedule"}}
+	}
+	if len(c.UserCommand) == 0 && len(c.steps) == 0 {
+		return nil, []error{ErrInvalidValue{Option: "schedule", Value: c.Config.Schedule, Reason: "a command is required to run on a schedule"}}
+	}
+	return &Scheduler{
+		Config:  c.Config,
+		usercmd: usercmd,
+		options: options,
+		log:     c.log,
+		stop:    make(chan struct{}),
+		running: make(map[*Command]time.Time),
+	}, nil
+}
+
+// Run starts a run of the command each time the schedule matches until monny receives an interrupt or terminate
+// signal, or Stop is called.  It then waits for the runs in progress to finish and send their reports before
+// returning.  A second interrupt or terminate signal is passed on to the runs in progress to kill them.  Other
+// signals are passed on to the runs in progress as they arrive.
+func (s *Scheduler) Run() error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	next := s.Config.schedule.next(time.Now())
+	if next.IsZero() {
+		return fmt.Errorf("schedule %s does not match any time in the next five years", s.Config.Schedule)
+	}
+	s.log.infof("running on schedule %s, first run at %s", s.Config.Schedule, next.Format(time.RFC3339))
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+
+	tick := timer.C
+	stop := s.stop
+	var finished chan struct{}
+	shutdown := func() {
+		tick = nil
+		stop = nil
+		finished = make(chan struct{})
+		go func() {
+			s.runs.Wait()
+			close(finished)
+		}()
+	}
+	for {
+		select {
+		case <-tick:
+			s.start(next)
+			// the timer may fire before the wall clock reaches the scheduled time
+			from := time.Now()
+			if from.Before(next) {
+				from = next
+			}
+			next = s.Config.schedule.next(from)
+			if next.IsZero() {
+				s.log.warnf("schedule %s does not match any time in the next five years", s.Config.Schedule)
+				shutdown()
+				continue
+			}
+			timer.Reset(time.Until(next))
+		case sig := <-signals:
+			if killSignal(sig) && finished == nil {
+				s.log.infof("received signal %s, waiting for %d runs in progress to finish", sig, s.inProgress())
+				shutdown()
+				continue
+			}
+			s.forward(sig)
+		case <-stop:
+			s.log.infof("schedule stopped, waiting for %d runs in progress to finish", s.inProgress())
+			shutdown()
+		case <-finished:
+			return nil
+		}
+	}
+}
+
+// Stop stops starting new runs, the same as an interrupt signal.  Run returns once the runs in progress finish.
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// start runs the command scheduled at, unless the most runs allowed by MaxConcurrent are still running, in which
+// case a report is sent that the run was skipped
+func (s *Scheduler) start(at time.Time) {
+	c, errs := New(s.usercmd, s.options...)
+	if len(errs) > 0 {
+		s.log.warnf("could not create the run scheduled at %s: %v", at.Format(time.RFC3339), errs[0])
+		return
+	}
+	if s.prepare != nil {
+		s.prepare(c)
+	}
+
+	s.mutex.Lock()
+	running := len(s.running)
+	if running >= s.Config.MaxConcurrent {
+		s.mutex.Unlock()
+		s.runs.Add(1)
+		go func() {
+			defer s.runs.Done()
+			c.skip(at, running)
+			c.Wait()
+		}()
+		return
+	}
+	c.signals = make(chan os.Signal, 1)
+	s.running[c] = at
+	s.mutex.Unlock()
+
+	s.runs.Add(1)
+	go func() {
+		defer s.runs.Done()
+		s.log.infof("starting the run scheduled at %s", at.Format(time.RFC3339))
+		err := c.Exec()
+		s.mutex.Lock()
+		delete(s.running, c)
+		s.mutex.Unlock()
+		if werr := c.Wait(); werr != nil {
+			s.log.warnf("not all reports of the run scheduled at %s were sent: %v", at.Format(time.RFC3339), werr)
+		}
+		switch {
+		case err != nil:
+			s.log.infof("run scheduled at %s failed: %v", at.Format(time.RFC3339), err)
+		default:
+			s.log.infof("run scheduled at %s succeeded", at.Format(time.RFC3339))
+		}
+	}()
+}
+
+// forward passes the signal on to the runs in progress
+func (s *Scheduler) forward(sig os.Signal) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for c := range s.running {
+		select {
+		case c.signals <- sig:
+		default:
+			s.log.warnf("signal %s was not passed to the run scheduled at %s, an earlier signal is pending", sig, s.running[c].Format(time.RFC3339))
+		}
+	}
+}
+
+// inProgress returns the number of runs in progress
+func (s *Scheduler) inProgress() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.running)
+}
+
+// skip sends a report that the run scheduled at was skipped because running runs, the most allowed, had not finished
+func (c *Command) skip(at time.Time, running int) {
+	msg := fmt.Sprintf("the run scheduled at %s was skipped because %d runs were still in progress, the most allowed by max-concurrent", at.Format(time.RFC3339), running)
+	c.log.warnf("%s", msg)
+	c.mutex.Lock()
+	c.Start = at
+	c.Finish = at
+	c.Messages = append(c.Messages, msg)
+	c.mutex.Unlock()
+	c.send(proto.Skipped)
+}
@@ -0,0 +1,125 @@
+package monny
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+// countReasons returns the number of times reason was sent
+func countReasons(reasons []proto.ReportReason, reason proto.ReportReason) int {
+	var n int
+	for _, r := range reasons {
+		if r == reason {
+			n++
+		}
+	}
+	return n
+}
+
+func TestScheduler(t *testing.T) {
+	tt := []struct {
+		Name    string
+		Cmd     []string
+		Options []ConfigOption
+		// Success and Skipped are the least number of each report expected
+		Success int
+		Skipped int
+	}{
+		{Name: "fast command", Cmd: []string{"echo", "run"}, Success: 2},
+		{Name: "overlapping command", Cmd: []string{"sleep", "1.5"}, Success: 1, Skipped: 1},
+		{Name: "concurrent runs", Cmd: []string{"sleep", "1.5"}, Options: []ConfigOption{MaxConcurrent("3")}, Success: 2},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			s, errs := NewScheduler(tc.Cmd, append(tc.Options, ID("test"), Schedule("* * * * * *"), logOut(&closeBuffer{}), logErr(&closeBuffer{}))...)
+			if len(errs) > 0 {
+				t.Fatalf("unexpected error in config: %s", errs)
+			}
+			recorder := new(reasonRecorder)
+			var mutex sync.Mutex
+			var runs []*Command
+			s.prepare = func(c *Command) {
+				c.report = recorder
+				mutex.Lock()
+				runs = append(runs, c)
+				mutex.Unlock()
+			}
+
+			time.AfterFunc(2200*time.Millisecond, s.Stop)
+			assert.NoError(t, s.Run())
+
+			reasons := recorder.Reasons()
+			assert.True(t, countReasons(reasons, proto.Success) >= tc.Success, "expected at least %d successful runs in %v", tc.Success, reasons)
+			assert.True(t, countReasons(reasons, proto.Skipped) >= tc.Skipped, "expected at least %d skipped runs in %v", tc.Skipped, reasons)
+			if tc.Skipped == 0 {
+				assert.Equal(t, 0, countReasons(reasons, proto.Skipped))
+			}
+			assert.Equal(t, len(runs), len(reasons), "each run should send one report")
+			assert.Equal(t, 0, s.inProgress(), "runs in progress should finish before Run returns")
+			if tc.Cmd[0] == "echo" {
+				for _, c := range runs {
+					assert.Equal(t, []string{"run"}, c.Stdout, "each run should have its own output history")
+				}
+			}
+		})
+	}
+}
+
+func TestSchedulerShutdown(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGTERM can not be sent on windows")
+	}
+	s, errs := NewScheduler([]string{"sh", "-c", "sleep 1; echo done"}, ID("test"), Schedule("@every 1s"), logOut(&closeBuffer{}), logErr(&closeBuffer{}))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	recorder := new(reasonRecorder)
+	started := make(chan *Command, 10)
+	s.prepare = func(c *Command) {
+		c.report = recorder
+		started <- c
+	}
+
+	result := make(chan error, 1)
+	go func() { result <- s.Run() }()
+	var run *Command
+	select {
+	case run = <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no run was started")
+	}
+	// wait for the process to start before stopping monny
+	time.Sleep(200 * time.Millisecond)
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("unexpected error finding monny: %v", err)
+	}
+	assert.NoError(t, p.Signal(syscall.SIGTERM))
+
+	select {
+	case err := <-result:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduler did not stop after SIGTERM")
+	}
+	assert.Equal(t, []proto.ReportReason{proto.Success}, recorder.Reasons(), "the run in progress should finish instead of being killed")
+	assert.Equal(t, []string{"done"}, run.Stdout)
+	assert.Len(t, started, 0, "no run should start after SIGTERM")
+}
+
+func TestNewSchedulerErrors(t *testing.T) {
+	_, errs := NewScheduler([]string{"echo"}, ID("test"))
+	assert.NotEmpty(t, errs, "a schedule is required")
+	_, errs = NewScheduler(nil, ID("test"), Schedule("@hourly"))
+	assert.NotEmpty(t, errs, "a command is required")
+	_, errs = NewScheduler([]string{"echo"}, ID("test"), Schedule("@hourly"), Daemon())
+	assert.NotEmpty(t, errs, "a daemon can not be scheduled")
+}
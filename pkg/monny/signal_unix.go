@@ -0,0 +1,12 @@
+// +build !windows
+
+package monny
+
+import (
+	"os"
+	"syscall"
+)
+
+// forwardedSignals are the signals received by monny that are passed to the process.  Interrupt and terminate also
+// send a report that the process was killed.
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGUSR1, syscall.SIGUSR2}
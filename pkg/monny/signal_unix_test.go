@@ -0,0 +1,74 @@
+// +build !windows
+
+package monny
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignalForwarding(t *testing.T) {
+	script := `trap 'echo got USR1' USR1; trap 'echo got TERM; exit 143' TERM; echo ready; while true; do sleep 0.05; done`
+	c, errs := New([]string{"sh", "-c", script}, ID("test"), logOut(&closeBuffer{}), logErr(&closeBuffer{}))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	report := new(reasonRecorder)
+	c.report = report
+
+	// waitFor polls stdout of the process for a line
+	waitFor := func(line string) bool {
+		for i := 0; i < 200; i++ {
+			c.mutex.Lock()
+			out := strings.Join(c.Stdout, "\n")
+			c.mutex.Unlock()
+			if strings.Contains(out, line) {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- c.Exec() }()
+	if !waitFor("ready") {
+		t.Fatalf("process did not start")
+	}
+	// allow the signal handler to be registered after the process starts
+	time.Sleep(100 * time.Millisecond)
+	self, _ := os.FindProcess(os.Getpid())
+
+	assert.NoError(t, self.Signal(syscall.SIGUSR1))
+	assert.True(t, waitFor("got USR1"), "process did not receive SIGUSR1")
+	select {
+	case <-done:
+		t.Fatalf("monny returned after a signal that is passed through")
+	default:
+	}
+	c.mutex.Lock()
+	killed := c.Killed
+	c.mutex.Unlock()
+	assert.False(t, killed)
+
+	assert.NoError(t, self.Signal(syscall.SIGTERM))
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("monny did not return after SIGTERM")
+	}
+	assert.True(t, waitFor("got TERM"), "process did not receive SIGTERM")
+	assert.NoError(t, c.Wait())
+
+	assert.Equal(t, []proto.ReportReason{proto.Killed}, report.Reasons())
+	assert.True(t, c.Killed)
+	assert.Equal(t, proto.Signal, c.KillReason)
+	assert.Contains(t, c.Messages, "monny received signal terminated and passed it to the process")
+}
@@ -0,0 +1,89 @@
+package monny
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/stat"
+)
+
+// metricsSnapshot is the final state of the estimators for each metric rule when the process finishes
+type metricsSnapshot struct {
+	ID      string                       `json:"id"`
+	Time    time.Time                    `json:"time"`
+	Metrics map[string]float64           `json:"metrics"`
+	Chart   map[string][]stat.ChartPoint `json:"chart,omitempty"`
+}
+
+func (c *Command) metricsSnapshot() metricsSnapshot {
+	snapshot := metricsSnapshot{
+		ID:      c.Config.ID,
+		Time:    time.Now(),
+		Metrics: make(map[string]float64),
+	}
+	for k, v := range c.budget.Metric() {
+		snapshot.Metrics[k] = v
+	}
+	for _, m := range c.metrics {
+		metrics, chart := m.snapshot()
+		for k, v := range metrics {
+			snapshot.Metrics[k] = v
+		}
+		if len(chart) > 0 {
+			if snapshot.Chart == nil {
+				snapshot.Chart = make(map[string][]stat.ChartPoint)
+			}
+			snapshot.Chart[m.rule.Name] = chart
+		}
+	}
+	return snapshot
+}
+
+// writeMetricsSnapshot writes the final estimator metrics to the snapshot file.  Files ending in .csv are written as
+// metric,value rows, otherwise the metrics and chart data are written as JSON.
+func (c *Command) writeMetricsSnapshot() error {
+	if len(c.Config.MetricsSnapshot) == 0 {
+		return nil
+	}
+	snapshot := c.metricsSnapshot()
+
+	var data []byte
+	var err error
+	switch strings.ToLower(filepath.Ext(c.Config.MetricsSnapshot)) {
+	case ".csv":
+		data, err = snapshot.csv()
+	default:
+		data, err = json.MarshalIndent(snapshot, "", "  ")
+	}
+	if err != nil {
+		return fmt.Errorf("could not serialize metrics snapshot: %v", err)
+	}
+	if err := ioutil.WriteFile(c.Config.MetricsSnapshot, data, 0644); err != nil {
+		return fmt.Errorf("could not write metrics snapshot: %v", err)
+	}
+	return nil
+}
+
+func (s metricsSnapshot) csv() ([]byte, error) {
+	keys := make([]string, 0, len(s.Metrics))
+	for k := range s.Metrics {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var out strings.Builder
+	w := csv.NewWriter(&out)
+	w.Write([]string{"metric", "value"})
+	for _, k := range keys {
+		w.Write([]string{k, strconv.FormatFloat(s.Metrics[k], 'g', -1, 64)})
+	}
+	w.Flush()
+	return []byte(out.String()), w.Error()
+}
@@ -0,0 +1,135 @@
+package monny
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingErrors records errors reported by the command
+type recordingErrors struct {
+	mutex  sync.Mutex
+	errors []error
+}
+
+func (r *recordingErrors) ReportError(err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.errors = append(r.errors, err)
+}
+
+// runSnapshotJob runs a job after driving the estimator of a metric rule past its baseline.  The windows are
+// recorded before the job runs, and the metric window is longer than the job, so that the snapshot does not depend on
+// the timing of the job output.
+func runSnapshotJob(t *testing.T, path string) (*Command, *recordingErrors) {
+	r, w := io.Pipe()
+	go func() {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(r)
+		r.Close()
+	}()
+	c, errs := New([]string{"sh", "-c", "echo ERROR >&2"}, ID("test"), MetricRule("errors", "stderr", "ERROR"), MetricWindow("1h"), MetricsSnapshotFile(path), logErr(w), logOut(w))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	c.report = new(mockReport)
+	rec := &recordingErrors{}
+	c.errors = newErrorCollector(rec)
+	for i := 0; i < 80; i++ {
+		c.countMetrics([]byte("ERROR"), streamStderr)
+		c.recordMetrics()
+	}
+	if err := c.Exec(); err != nil {
+		t.Fatalf("unexpected error running: %s", err)
+	}
+	return c, rec
+}
+
+func expectedMetricKeys(c *Command) []string {
+	var keys []string
+	metrics, _ := c.metrics[0].snapshot()
+	for k := range metrics {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestMetricsSnapshotJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monny")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshot.json")
+
+	c, rec := runSnapshotJob(t, path)
+	assert.Len(t, rec.errors, 0)
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error reading snapshot: %v", err)
+	}
+	var snapshot metricsSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		t.Fatalf("snapshot is not valid JSON: %v", err)
+	}
+	assert.Equal(t, "test", snapshot.ID)
+	var keys []string
+	for k, v := range snapshot.Metrics {
+		keys = append(keys, k)
+		assert.True(t, v > 0.0, "expected positive value for %s: %v", k, v)
+	}
+	sort.Strings(keys)
+	assert.Len(t, keys, 4)
+	assert.Equal(t, expectedMetricKeys(c), keys)
+	assert.NotEmpty(t, snapshot.Chart["errors"])
+}
+
+func TestMetricsSnapshotCSV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monny")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshot.csv")
+
+	c, rec := runSnapshotJob(t, path)
+	assert.Len(t, rec.errors, 0)
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error reading snapshot: %v", err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("snapshot is not valid CSV: %v", err)
+	}
+	if assert.Len(t, rows, 5) {
+		assert.Equal(t, []string{"metric", "value"}, rows[0])
+		var keys []string
+		for _, row := range rows[1:] {
+			keys = append(keys, row[0])
+		}
+		assert.Equal(t, expectedMetricKeys(c), keys)
+	}
+}
+
+func TestMetricsSnapshotError(t *testing.T) {
+	_, rec := runSnapshotJob(t, filepath.Join(os.TempDir(), "does-not-exist", "snapshot.json"))
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	if assert.Len(t, rec.errors, 1) {
+		assert.Contains(t, rec.errors[0].Error(), "could not write metrics snapshot")
+	}
+}
@@ -0,0 +1,269 @@
+package monny
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/pb"
+	"github.com/cenkalti/backoff"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/spf13/pflag"
+)
+
+// Subcommands of monny.  Run is used when the first argument is not a subcommand, so that monny -i id mycommand and
+// monny -- mycommand run the command as before subcommands were added.
+const (
+	SubcommandRun      = "run"
+	SubcommandParallel = "parallel"
+	SubcommandCheck    = "check"
+	SubcommandFlush    = "flush"
+	SubcommandVersion  = "version"
+)
+
+// subcommands describes each subcommand other than run in the usage
+var subcommands = []struct {
+	name        string
+	usage       string
+	description string
+}{
+	{name: SubcommandParallel, usage: "monny parallel -i <identifier> <options> -- cmd1 -- cmd2", description: "Run the commands separated by -- at the same time with one report for all of them, the same as run --parallel"},
+	{name: SubcommandCheck, usage: "monny check [-c] [config.yaml]", description: "Parse and validate the configuration file, then exit"},
+	{name: SubcommandFlush, usage: "monny flush --report-file reports.jsonl <options>", description: "Send the reports appended to a report file to the report server"},
+	{name: SubcommandVersion, usage: "monny version [--short]", description: "Print the version of monny"},
+}
+
+// Build information is set at link time (e.g., -ldflags "-X github.com/BTBurke/monny/pkg/monny.Version=v1.0.0")
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// flushTimeout is how long flush retries sending reports before returning them to the report file
+var flushTimeout = time.Minute
+
+// Subcommand returns the subcommand named by the first argument and the remaining arguments.  When the first argument
+// is not a subcommand, it returns the run subcommand and all arguments.
+func Subcommand(args []string) (string, []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case SubcommandRun, SubcommandParallel, SubcommandCheck, SubcommandFlush, SubcommandVersion:
+			return args[0], args[1:]
+		}
+	}
+	return SubcommandRun, args
+}
+
+func subcommandUsage() string {
+	var b strings.Builder
+	for _, s := range subcommands {
+		fmt.Fprintf(&b, "%s\n    %s\n", s.usage, s.description)
+	}
+	return b.String()
+}
+
+// subcommandFlagSet returns a flag set for the subcommand with the named flags of the run subcommand
+func subcommandFlagSet(name string, flags ...string) *pflag.FlagSet {
+	run := createFlagSet()
+	pf := pflag.NewFlagSet("monny "+name, pflag.ContinueOnError)
+	for _, f := range flags {
+		pf.AddFlag(run.Lookup(f))
+	}
+	pf.Usage = func() {
+		for _, s := range subcommands {
+			if s.name == name {
+				fmt.Printf("Usage of monny %s:\n%s\n\n%s\n", name, s.usage, s.description)
+			}
+		}
+		fmt.Printf("\n%s", pf.FlagUsagesWrapped(10))
+	}
+	return pf
+}
+
+// Check parses and validates a configuration file, printing errors and warnings to out.  The file is passed as an
+// argument or with -c, or discovered in the same locations as the run subcommand.  Files passed with several -c flags
+// are combined in the same way as the run subcommand.  Options set by MONNY_* environment variables are applied so that
+// the configuration is validated as it would be run.
+func Check(args []string, out io.Writer) error {
+	pf := subcommandFlagSet(SubcommandCheck, "config")
+	if err := pf.Parse(args); err != nil {
+		return err
+	}
+	paths, _ := pf.GetStringArray("config")
+	switch {
+	case pf.NArg() > 1, pf.NArg() == 1 && len(paths) > 0:
+		return fmt.Errorf("check accepts a single configuration file, use -c for each file to combine several")
+	case pf.NArg() == 1:
+		paths = []string{pf.Arg(0)}
+	case len(paths) == 0:
+		path := findConfig(configFiles())
+		if len(path) == 0 {
+			return fmt.Errorf("no configuration file found at %s", strings.Join(configFiles(), ", "))
+		}
+		paths = []string{path}
+	}
+	name, verb := "file "+paths[0], "is"
+	if len(paths) > 1 {
+		name, verb = "files "+strings.Join(paths, ", "), "are"
+	}
+
+	var file []ConfigOption
+	for _, p := range paths {
+		opts, err := parseFromFile(p)
+		if err != nil {
+			return fmt.Errorf("could not read configuration file %s: %v", p, err)
+		}
+		file = append(file, opts...)
+	}
+	env, err := parseEnv(createFlagSet())
+	if err != nil {
+		return err
+	}
+	cfg, errs := newConfig(append(file, env...)...)
+	if len(errs) > 0 {
+		fmt.Fprintf(out, "Configuration %s %s invalid:\n", name, verb)
+		for _, e := range errs {
+			fmt.Fprintf(out, "  %s\n", e)
+		}
+		return fmt.Errorf("found %d errors in configuration %s", len(errs), name)
+	}
+	for _, w := range cfg.Warnings {
+		fmt.Fprintf(out, "Warning: %s\n", w)
+	}
+	fmt.Fprintf(out, "Configuration %s %s valid\n", name, verb)
+	return nil
+}
+
+// Flush sends the reports appended to a report file by --report-file to the report server, then removes them from the
+// file.  Reports that can not be sent remain in the file.  The report server and its credentials are set with the same
+// flags, environment variables, and configuration file as the run subcommand.
+func Flush(args []string, out io.Writer) error {
+	pf := subcommandFlagSet(SubcommandFlush, "config", "report-file", "host", "insecure", "token", "ca-cert", "client-cert", "tls-skip-verify", "no-compression", "verbose", "debug")
+	env, err := parseEnv(pf)
+	if err != nil {
+		return err
+	}
+	rest, opts, err := parse(args, pf, configSources{files: configFiles(), env: env})
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(rest, " "))
+	}
+	cfg := defaultConfig()
+	for _, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return err
+		}
+	}
+	if len(cfg.ReportFile) == 0 {
+		return fmt.Errorf("no report file to flush, set with --report-file")
+	}
+
+	s := &senderService{host: cfg.host, port: cfg.port, log: newLogger(cfg)}
+	s.configure(cfg)
+	defer s.closeConnection()
+	n, err := flushReportFile(cfg.ReportFile, func(reports []*pb.Report) error {
+		b := backoff.NewExponentialBackOff()
+		b.MaxElapsedTime = flushTimeout
+		return backoff.RetryNotify(func() error { return s.sendBatch(reports) }, b, s.retry)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Sent %d reports from %s to %s\n", n, cfg.ReportFile, net.JoinHostPort(cfg.host, cfg.port))
+	return nil
+}
+
+// flushReportFile removes the reports from the report file and sends them.  If they can not be sent, the reports are
+// appended back to the file.  Returns the number of reports sent.
+func flushReportFile(path string, send func([]*pb.Report) error) (int, error) {
+	data, reports, err := takeReports(path)
+	if err != nil || len(reports) == 0 {
+		return 0, err
+	}
+	if err := send(reports); err != nil {
+		if rerr := appendReports(path, data); rerr != nil {
+			return 0, fmt.Errorf("could not send reports: %v, and could not return them to the report file: %v", err, rerr)
+		}
+		return 0, fmt.Errorf("could not send reports, they remain in %s: %v", path, err)
+	}
+	return len(reports), nil
+}
+
+// takeReports reads and parses every report in the report file, then truncates it.  The file is locked so that
+// reports appended by running processes are not lost.  The file is not changed if any report can not be parsed.
+func takeReports(path string) ([]byte, []*pb.Report, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not open report file: %v", err)
+	}
+	defer f.Close()
+	if err := lockFile(f); err != nil {
+		return nil, nil, fmt.Errorf("could not lock report file: %v", err)
+	}
+	defer unlockFile(f)
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read report file: %v", err)
+	}
+	var reports []*pb.Report
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		report := &pb.Report{}
+		if err := jsonpb.Unmarshal(bytes.NewReader(line), report); err != nil {
+			return nil, nil, fmt.Errorf("could not parse report on line %d of %s: %v", i+1, path, err)
+		}
+		reports = append(reports, report)
+	}
+	if err := f.Truncate(0); err != nil {
+		return nil, nil, fmt.Errorf("could not remove reports from report file: %v", err)
+	}
+	return data, reports, nil
+}
+
+// appendReports appends the lines of reports that were not sent back to the report file
+func appendReports(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return err
+	}
+	_, err = f.Write(data)
+	unlockFile(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// PrintVersion prints the version of monny and the build information set at link time
+func PrintVersion(args []string, out io.Writer) error {
+	pf := subcommandFlagSet(SubcommandVersion)
+	short := pf.Bool("short", false, "Print only the version")
+	if err := pf.Parse(args); err != nil {
+		return err
+	}
+	if pf.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(pf.Args(), " "))
+	}
+	if *short {
+		fmt.Fprintln(out, Version)
+		return nil
+	}
+	fmt.Fprintf(out, "monny %s (commit %s, built %s, %s %s/%s)\n", Version, Commit, BuildDate, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return nil
+}
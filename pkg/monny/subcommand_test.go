@@ -0,0 +1,202 @@
+package monny
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSubcommand(t *testing.T) {
+	tt := []struct {
+		Name    string
+		Args    []string
+		Sub     string
+		Remains []string
+	}{
+		{Name: "no args", Args: []string{}, Sub: SubcommandRun, Remains: []string{}},
+		{Name: "implicit run", Args: []string{"-i", "test", "mycmd"}, Sub: SubcommandRun, Remains: []string{"-i", "test", "mycmd"}},
+		{Name: "implicit run separator", Args: []string{"--", "mycmd", "check"}, Sub: SubcommandRun, Remains: []string{"--", "mycmd", "check"}},
+		{Name: "run", Args: []string{"run", "-i", "test", "mycmd"}, Sub: SubcommandRun, Remains: []string{"-i", "test", "mycmd"}},
+		{Name: "parallel", Args: []string{"parallel", "--fail-fast", "--", "a", "--", "b"}, Sub: SubcommandParallel, Remains: []string{"--fail-fast", "--", "a", "--", "b"}},
+		{Name: "check", Args: []string{"check", "config.yaml"}, Sub: SubcommandCheck, Remains: []string{"config.yaml"}},
+		{Name: "flush", Args: []string{"flush", "--report-file", "reports.jsonl"}, Sub: SubcommandFlush, Remains: []string{"--report-file", "reports.jsonl"}},
+		{Name: "version", Args: []string{"version"}, Sub: SubcommandVersion, Remains: []string{}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			sub, args := Subcommand(tc.Args)
+			assert.Equal(t, tc.Sub, sub)
+			assert.Equal(t, tc.Remains, args)
+		})
+	}
+}
+
+func TestParseRun(t *testing.T) {
+	cmd, opts, err := ParseRun([]string{"-i", "test", "--", "mycmd", "--flag"})
+	if err != nil {
+		t.Fatalf("unexpected error parsing run: %v", err)
+	}
+	assert.Equal(t, []string{"mycmd", "--flag"}, cmd)
+	cfg, errs := newConfig(opts...)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	assert.Equal(t, "test", cfg.ID)
+}
+
+func TestCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monny")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("unexpected error writing config: %v", err)
+		}
+		return path
+	}
+	valid := write("valid.yaml", "id: test\ntimeout-warn: 10m\n")
+	warning := write("warning.yaml", "id: test\ntimeout-warn: 10m\ntimeout-kill: 5m\n")
+	invalid := write("invalid.yaml", "timeout-warn: 10m\nrule-period: 5m\n")
+	malformed := write("malformed.yaml", "id: [unterminated\n")
+	// a shared base without an identifier is only valid when combined with a file that sets one
+	base := write("base.yaml", "timeout-kill: 1h\n")
+
+	tt := []struct {
+		Name   string
+		Args   []string
+		Output []string
+		Error  bool
+	}{
+		{Name: "valid", Args: []string{valid}, Output: []string{"is valid"}},
+		{Name: "valid flag", Args: []string{"-c", valid}, Output: []string{"is valid"}},
+		{Name: "base alone", Args: []string{base}, Output: []string{"is invalid", "id"}, Error: true},
+		{Name: "several files", Args: []string{"-c", base, "-c", valid}, Output: []string{"Configuration files " + base + ", " + valid + " are valid"}},
+		{Name: "several files with an error", Args: []string{"-c", valid, "-c", malformed}, Error: true},
+		{Name: "warning", Args: []string{warning}, Output: []string{"Warning: ", "is valid"}},
+		{Name: "invalid", Args: []string{invalid}, Output: []string{"is invalid", "id", "rule-period"}, Error: true},
+		{Name: "malformed", Args: []string{malformed}, Error: true},
+		{Name: "missing", Args: []string{filepath.Join(dir, "missing.yaml")}, Error: true},
+		{Name: "two files", Args: []string{valid, warning}, Error: true},
+		{Name: "unknown flag", Args: []string{"--id", "test", valid}, Error: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := Check(tc.Args, &out)
+			switch tc.Error {
+			case true:
+				assert.Error(t, err)
+			default:
+				assert.NoError(t, err)
+			}
+			for _, o := range tc.Output {
+				assert.Contains(t, out.String(), o)
+			}
+		})
+	}
+}
+
+func TestFlush(t *testing.T) {
+	timeout := flushTimeout
+	flushTimeout = 100 * time.Millisecond
+	defer func() { flushTimeout = timeout }()
+
+	tt := []struct {
+		Name    string
+		Success bool
+	}{
+		{Name: "sent", Success: true},
+		{Name: "not sent", Success: false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "monny")
+			if err != nil {
+				t.Fatalf("unexpected error creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "reports.jsonl")
+			s := &fileSender{path: path, errors: mockError{}}
+			for _, id := range []string{"first", "second"} {
+				assert.NoError(t, s.write(&pb.Report{Id: id, ReportReason: pb.ReportReason_Alert}))
+			}
+			s.wait()
+			before, _ := ioutil.ReadFile(path)
+
+			mocks := new(mockReportsServer)
+			switch tc.Success {
+			case true:
+				mocks.On("CreateBatch", mock.Anything).Return(&pb.ReportAck{Success: true}, nil)
+			default:
+				mocks.On("CreateBatch", mock.Anything).Return(&pb.ReportAck{}, fmt.Errorf("unavailable"))
+			}
+			lis, stop := startReportServer(t, mocks)
+			defer stop()
+
+			var out bytes.Buffer
+			err = Flush([]string{"--report-file", path, "--host", lis.Addr().String(), "--insecure"}, &out)
+			after, _ := ioutil.ReadFile(path)
+			switch tc.Success {
+			case true:
+				assert.NoError(t, err)
+				assert.Contains(t, out.String(), "Sent 2 reports")
+				assert.Empty(t, after)
+				batch := mocks.Calls[0].Arguments.Get(0).(*pb.ReportBatch)
+				if assert.Len(t, batch.Reports, 2) {
+					assert.Equal(t, "first", batch.Reports[0].Id)
+					assert.Equal(t, "second", batch.Reports[1].Id)
+				}
+			default:
+				assert.Error(t, err)
+				assert.Equal(t, string(before), string(after))
+			}
+		})
+	}
+}
+
+func TestFlushErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monny")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	corrupt := filepath.Join(dir, "corrupt.jsonl")
+	if err := ioutil.WriteFile(corrupt, []byte("{\"id\": \"test\"}\nnot json\n"), 0644); err != nil {
+		t.Fatalf("unexpected error writing report file: %v", err)
+	}
+
+	var out bytes.Buffer
+	assert.Error(t, Flush([]string{}, &out))
+	assert.Error(t, Flush([]string{"--report-file", filepath.Join(dir, "missing.jsonl")}, &out))
+	assert.Error(t, Flush([]string{"--report-file", corrupt, "extra"}, &out))
+	err = Flush([]string{"--report-file", corrupt}, &out)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "line 2")
+	}
+	// reports are not removed when the file can not be parsed
+	data, _ := ioutil.ReadFile(corrupt)
+	assert.Equal(t, 2, strings.Count(string(data), "\n"))
+}
+
+func TestPrintVersion(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, PrintVersion([]string{"--short"}, &out))
+	assert.Equal(t, Version+"\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, PrintVersion([]string{}, &out))
+	assert.True(t, strings.HasPrefix(out.String(), "monny "+Version+" (commit "+Commit))
+	assert.Error(t, PrintVersion([]string{"extra"}, &out))
+}
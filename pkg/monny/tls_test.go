@@ -0,0 +1,201 @@
+package monny
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/pb"
+	"github.com/BTBurke/monny/pkg/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// testPKI holds paths to a self-signed CA and certificates signed by it
+type testPKI struct {
+	dir        string
+	ca         *x509.Certificate
+	caKey      *ecdsa.PrivateKey
+	caPath     string
+	serverCert tls.Certificate
+	clientCert string
+	clientKey  string
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	dir, err := ioutil.TempDir("", "monnytls")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %s", err)
+	}
+	p := &testPKI{dir: dir}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error generating CA key: %s", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "monny test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("unexpected error creating CA: %s", err)
+	}
+	p.ca, _ = x509.ParseCertificate(caDER)
+	p.caKey = caKey
+	p.caPath = p.write(t, "ca.pem", "CERTIFICATE", caDER)
+
+	serverDER, serverKey := p.sign(t, 2, x509.ExtKeyUsageServerAuth)
+	p.serverCert, err = tls.X509KeyPair(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: serverDER}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: serverKey}))
+	if err != nil {
+		t.Fatalf("unexpected error loading server cert: %s", err)
+	}
+
+	clientDER, clientKey := p.sign(t, 3, x509.ExtKeyUsageClientAuth)
+	p.clientCert = p.write(t, "client.pem", "CERTIFICATE", clientDER)
+	p.clientKey = p.write(t, "client.key", "EC PRIVATE KEY", clientKey)
+	return p
+}
+
+func (p *testPKI) sign(t *testing.T, serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error generating key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, p.ca, &key.PublicKey, p.caKey)
+	if err != nil {
+		t.Fatalf("unexpected error signing certificate: %s", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("unexpected error marshaling key: %s", err)
+	}
+	return der, keyDER
+}
+
+func (p *testPKI) write(t *testing.T, name string, blockType string, der []byte) string {
+	path := filepath.Join(p.dir, name)
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatalf("unexpected error writing %s: %s", name, err)
+	}
+	return path
+}
+
+func (p *testPKI) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(p.ca)
+	return pool
+}
+
+func TestTLSOptions(t *testing.T) {
+	pki := newTestPKI(t)
+	defer os.RemoveAll(pki.dir)
+
+	tt := []struct {
+		Name    string
+		Options []ConfigOption
+		Error   bool
+	}{
+		{Name: "ca cert", Options: []ConfigOption{CACert(pki.caPath)}},
+		{Name: "ca cert missing", Options: []ConfigOption{CACert(filepath.Join(pki.dir, "missing.pem"))}, Error: true},
+		{Name: "ca cert invalid", Options: []ConfigOption{CACert(pki.clientKey)}, Error: true},
+		{Name: "client cert", Options: []ConfigOption{ClientCert(pki.clientCert, pki.clientKey)}},
+		{Name: "client cert missing key", Options: []ConfigOption{ClientCert(pki.clientCert, filepath.Join(pki.dir, "missing.key"))}, Error: true},
+		{Name: "skip verify", Options: []ConfigOption{TLSSkipVerify()}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			_, errs := newConfig(append(tc.Options, ID("test"))...)
+			switch tc.Error {
+			case true:
+				if assert.NotZero(t, len(errs)) {
+					var target ErrInvalidCertificate
+					assert.True(t, errors.As(errs[0], &target), "expected ErrInvalidCertificate, got %v", errs[0])
+				}
+			default:
+				assert.Zero(t, len(errs))
+			}
+		})
+	}
+}
+
+func TestSendTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	defer os.RemoveAll(pki.dir)
+
+	tt := []struct {
+		Name       string
+		Options    []ConfigOption
+		ClientAuth tls.ClientAuthType
+		Error      bool
+	}{
+		{Name: "ca cert", Options: []ConfigOption{CACert(pki.caPath)}},
+		{Name: "no ca cert", Error: true},
+		{Name: "skip verify", Options: []ConfigOption{TLSSkipVerify()}},
+		{Name: "client cert", Options: []ConfigOption{CACert(pki.caPath), ClientCert(pki.clientCert, pki.clientKey)}, ClientAuth: tls.RequireAndVerifyClientCert},
+		{Name: "no client cert", Options: []ConfigOption{CACert(pki.caPath)}, ClientAuth: tls.RequireAndVerifyClientCert, Error: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("failed to listen: %v", err)
+			}
+			creds := credentials.NewTLS(&tls.Config{
+				Certificates: []tls.Certificate{pki.serverCert},
+				ClientCAs:    pki.pool(),
+				ClientAuth:   tc.ClientAuth,
+			})
+			mocks := new(mockReportsServer)
+			mocks.On("Create").Return(&pb.ReportAck{Success: true}, nil)
+			grpcServer := grpc.NewServer(grpc.Creds(creds))
+			pb.RegisterReportsServer(grpcServer, mocks)
+			go grpcServer.Serve(lis)
+			defer grpcServer.Stop()
+
+			c, errs := New([]string{"test"}, append(tc.Options, ID("test"), Host(lis.Addr().String()))...)
+			if len(errs) != 0 {
+				t.Fatalf("unexpected error creating cmd: %s", errs)
+			}
+			s := c.report.(*Report).sender.(*senderService)
+			defer s.closeConnection()
+
+			err = s.send(s.create(c, proto.Success))
+			switch tc.Error {
+			case true:
+				assert.Error(t, err)
+				mocks.AssertNotCalled(t, "Create")
+			default:
+				assert.NoError(t, err)
+				mocks.AssertNumberOfCalls(t, "Create", 1)
+			}
+		})
+	}
+}
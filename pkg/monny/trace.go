@@ -0,0 +1,129 @@
+package monny
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/proto"
+	"github.com/BTBurke/monny/pkg/trace"
+)
+
+const tracerName = "github.com/BTBurke/monny"
+
+// tracerShutdown is how long spans are allowed to export after the process exits
+const tracerShutdown = 10 * time.Second
+
+// startSpan starts a span covering the execution of the command when a tracer is configured.  A trace context
+// in the TRACEPARENT environment variable is used as the parent.
+func (c *Command) startSpan() {
+	if c.Config.tracer == nil {
+		return
+	}
+	ctx := trace.ContextFromEnvironment(context.Background())
+	_, c.span = c.Config.tracer.Tracer(tracerName).Start(ctx, "monny", trace.WithAttributes(
+		trace.String("monny.id", c.Config.ID),
+		trace.String("monny.command", strings.Join(c.UserCommand, " ")),
+		trace.String("host.name", c.Config.Hostname),
+	))
+}
+
+// endSpan sets the status of the span from the result of the command and ends it
+func (c *Command) endSpan(err error) {
+	if c.span == nil {
+		return
+	}
+	c.mutex.Lock()
+	c.span.SetAttributes(
+		trace.Bool("monny.success", c.Success),
+		trace.Int64("monny.duration_ms", c.Duration.Milliseconds()),
+	)
+	if c.ExitCodeValid {
+		c.span.SetAttributes(trace.Int64("monny.exit_code", int64(c.ExitCode)))
+	}
+	if c.MaxMemory > 0 {
+		c.span.SetAttributes(trace.Int64("monny.max_memory_kb", int64(c.MaxMemory)))
+	}
+	if c.Killed {
+		c.span.SetAttributes(trace.String("monny.kill_reason", c.KillReason.String()))
+	}
+	switch {
+	case err != nil && !processError(err):
+		c.span.SetStatus(trace.StatusError, err.Error())
+	case c.Killed:
+		c.span.SetStatus(trace.StatusError, fmt.Sprintf("killed: %s", c.KillReason))
+	case c.Success:
+		c.span.SetStatus(trace.StatusOK, "")
+	case c.ExitCodeValid:
+		c.span.SetStatus(trace.StatusError, fmt.Sprintf("exit code %d", c.ExitCode))
+	default:
+		c.span.SetStatus(trace.StatusError, "process failed")
+	}
+	c.mutex.Unlock()
+	c.span.End()
+}
+
+// traceMatches records each rule match as an event on the span
+func (c *Command) traceMatches(stream streamSelector, matches []RuleMatch) {
+	for _, m := range matches {
+		attrs := []trace.Attribute{trace.String("monny.stream", string(stream))}
+		if len(m.Rule) > 0 {
+			attrs = append(attrs, trace.String("monny.rule", m.Rule))
+		}
+		if len(m.Severity) > 0 {
+			attrs = append(attrs, trace.String("monny.severity", m.Severity))
+		}
+		c.traceEvent("rule_match", attrs...)
+	}
+}
+
+// traceEvent records an event on the span when tracing
+func (c *Command) traceEvent(name string, attrs ...trace.Attribute) {
+	if c.span != nil {
+		c.span.AddEvent(name, attrs...)
+	}
+}
+
+// shutdownTracer exports the spans held by tracers that export when they are shut down, such as the tracer of
+// OTelTracing
+func (c *Command) shutdownTracer() {
+	s, ok := c.Config.tracer.(interface{ Shutdown(context.Context) error })
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), tracerShutdown)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		c.reportError(errorInternal, fmt.Errorf("could not export trace: %v", err))
+	}
+}
+
+// send records the report reason as an event on the span and sends the report in the background.  Wait blocks
+// until the report is sent.
+func (c *Command) send(reason proto.ReportReason) {
+	if c.span != nil {
+		c.span.AddEvent(reason.String(), trace.String("monny.report_reason", reason.String()))
+	}
+	c.sending.Add(1)
+	go func() {
+		defer c.sending.Done()
+		c.report.Send(c, reason)
+	}()
+}
+
+// traceEnv returns the environment for the process with TRACEPARENT set to the command span so that traces
+// from the process are children of the command span.  Returns nil to inherit the environment when not tracing.
+func (c *Command) traceEnv() []string {
+	if c.span == nil || !c.span.SpanContext().IsValid() {
+		return nil
+	}
+	var env []string
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, trace.TraceParentEnv+"=") {
+			env = append(env, e)
+		}
+	}
+	return append(env, trace.TraceParentEnv+"="+c.span.SpanContext().TraceParent())
+}
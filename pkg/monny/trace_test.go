@@ -0,0 +1,242 @@
+package monny
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/proto"
+	"github.com/BTBurke/monny/pkg/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockTracer records spans started by the command
+type mockTracer struct {
+	spans []*mockSpan
+}
+
+func (m *mockTracer) Tracer(name string) trace.Tracer {
+	return m
+}
+
+func (m *mockTracer) Start(ctx context.Context, name string, opts ...trace.SpanOption) (context.Context, trace.Span) {
+	parent := trace.SpanContextFromContext(ctx)
+	span := &mockSpan{
+		name:   name,
+		parent: parent,
+		sc: trace.SpanContext{
+			TraceID:    trace.TraceID{1},
+			SpanID:     trace.SpanID{byte(len(m.spans) + 1)},
+			TraceFlags: trace.FlagsSampled,
+		},
+		attributes: trace.NewSpanConfig(opts...).Attributes,
+	}
+	if parent.IsValid() {
+		span.sc.TraceID = parent.TraceID
+	}
+	m.spans = append(m.spans, span)
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+type mockSpan struct {
+	mutex       sync.Mutex
+	name        string
+	parent      trace.SpanContext
+	sc          trace.SpanContext
+	attributes  []trace.Attribute
+	events      []string
+	status      trace.StatusCode
+	description string
+	ended       bool
+}
+
+func (m *mockSpan) SpanContext() trace.SpanContext { return m.sc }
+
+func (m *mockSpan) AddEvent(name string, attrs ...trace.Attribute) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.events = append(m.events, name)
+}
+
+func (m *mockSpan) SetAttributes(attrs ...trace.Attribute) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.attributes = append(m.attributes, attrs...)
+}
+
+func (m *mockSpan) SetStatus(code trace.StatusCode, description string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.status = code
+	m.description = description
+}
+
+func (m *mockSpan) End() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.ended = true
+}
+
+func (m *mockSpan) attribute(key string) interface{} {
+	for _, attr := range m.attributes {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return nil
+}
+
+func TestTracing(t *testing.T) {
+	parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	tt := []struct {
+		Name        string
+		Cmd         []string
+		Options     []ConfigOption
+		TraceParent string
+		Events      []string
+		Status      trace.StatusCode
+		Description string
+		ExitCode    interface{}
+	}{
+		{Name: "success", Cmd: []string{"echo", "test"}, Events: []string{proto.Success.String()}, Status: trace.StatusOK, ExitCode: int64(0)},
+		{Name: "failure", Cmd: []string{"sh", "-c", "exit 3"}, Events: []string{proto.Failure.String()}, Status: trace.StatusError, Description: "exit code 3", ExitCode: int64(3)},
+		{Name: "alert", Cmd: []string{"echo", "error"}, Options: []ConfigOption{Rule("error")}, Events: []string{"rule_match", proto.Alert.String(), proto.Success.String()}, Status: trace.StatusOK, ExitCode: int64(0)},
+		{Name: "timeout", Cmd: []string{"sleep", "3"}, Options: []ConfigOption{KillTimeout("200ms")}, Events: []string{proto.Killed.String()}, Status: trace.StatusError, Description: "killed: Timeout"},
+		{Name: "remote parent", Cmd: []string{"echo", "test"}, TraceParent: parent, Events: []string{proto.Success.String()}, Status: trace.StatusOK, ExitCode: int64(0)},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			if len(tc.TraceParent) > 0 {
+				os.Setenv(trace.TraceParentEnv, tc.TraceParent)
+				defer os.Unsetenv(trace.TraceParentEnv)
+			}
+			r, w := io.Pipe()
+			go func() {
+				buf := new(bytes.Buffer)
+				buf.ReadFrom(r)
+				r.Close()
+			}()
+			tracer := &mockTracer{}
+			opts := append(tc.Options, ID("test"), WithTracer(tracer), logErr(w), logOut(w))
+			c, errs := New(tc.Cmd, opts...)
+			if len(errs) > 0 {
+				t.Fatalf("unexpected error in config: %s", errs)
+			}
+			c.report = new(mockReport)
+
+			if err := c.Exec(); err != nil && !processError(err) {
+				t.Fatalf("unexpected error running: %s", err)
+			}
+			if !assert.Len(t, tracer.spans, 1) {
+				return
+			}
+			span := tracer.spans[0]
+			span.mutex.Lock()
+			defer span.mutex.Unlock()
+			assert.True(t, span.ended)
+			assert.Equal(t, tc.Events, span.events)
+			assert.Equal(t, tc.Status, span.status)
+			assert.Equal(t, tc.Description, span.description)
+			assert.Equal(t, tc.ExitCode, span.attribute("monny.exit_code"))
+			assert.Equal(t, "test", span.attribute("monny.id"))
+			if len(tc.TraceParent) > 0 {
+				assert.Equal(t, tc.TraceParent, span.parent.TraceParent())
+				assert.Equal(t, span.parent.TraceID, span.sc.TraceID)
+			} else {
+				assert.False(t, span.parent.IsValid())
+			}
+		})
+	}
+}
+
+func TestTraceParentPropagation(t *testing.T) {
+	r, w := io.Pipe()
+	go func() {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(r)
+		r.Close()
+	}()
+	tracer := &mockTracer{}
+	c, errs := New([]string{"sh", "-c", "echo $TRACEPARENT"}, ID("test"), WithTracer(tracer), logErr(w), logOut(w))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	c.report = new(mockReport)
+
+	if err := c.Exec(); err != nil {
+		t.Fatalf("unexpected error running: %s", err)
+	}
+	if assert.Len(t, tracer.spans, 1) {
+		assert.Equal(t, []string{tracer.spans[0].sc.TraceParent()}, c.Stdout)
+	}
+}
+
+func TestTraceExport(t *testing.T) {
+	r, w := io.Pipe()
+	go func() {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(r)
+		r.Close()
+	}()
+	exporter := &trace.InMemoryExporter{}
+	c, errs := New([]string{"sh", "-c", "echo ERROR; exit 2"}, ID("test"), StructuredRule(RuleSpec{Pattern: "ERROR", Name: "errors", Severity: "high"}), WithTracer(trace.NewProvider(exporter)), logErr(w), logOut(w))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	c.report = new(mockReport)
+
+	assert.Equal(t, ErrChildFailed{Code: 2}, c.Exec())
+	// spans are exported after all reports are sent
+	assert.Empty(t, exporter.Spans())
+	assert.NoError(t, c.Wait())
+	spans := exporter.Spans()
+	if !assert.Len(t, spans, 1) {
+		return
+	}
+	span := spans[0]
+	assert.Equal(t, "monny", span.Name)
+	assert.Equal(t, int64(2), span.Attribute("monny.exit_code"))
+	assert.Equal(t, false, span.Attribute("monny.success"))
+	assert.NotNil(t, span.Attribute("monny.duration_ms"))
+	assert.Nil(t, span.Attribute("monny.kill_reason"))
+	assert.Equal(t, trace.StatusError, span.Status)
+	if assert.Len(t, span.Events, 3) {
+		assert.Equal(t, "rule_match", span.Events[0].Name)
+		assert.Equal(t, []trace.Attribute{trace.String("monny.stream", "stdout"), trace.String("monny.rule", "errors"), trace.String("monny.severity", "high")}, span.Events[0].Attributes)
+		assert.Equal(t, proto.Alert.String(), span.Events[1].Name)
+		assert.Equal(t, proto.Failure.String(), span.Events[2].Name)
+	}
+}
+
+func TestOTelTracing(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	r, w := io.Pipe()
+	go func() {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(r)
+		r.Close()
+	}()
+	c, errs := New([]string{"sleep", "3"}, ID("test"), KillTimeout("200ms"), OTelTracing(srv.URL), logErr(w), logOut(w))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error in config: %s", errs)
+	}
+	assert.Equal(t, srv.URL+"/v1/traces", c.Config.OTelEndpoint)
+	c.report = new(mockReport)
+	assert.Equal(t, ErrKilledOnTimeout{Timeout: 200 * time.Millisecond}, c.Exec())
+	assert.NoError(t, c.Wait())
+	assert.Contains(t, string(body), `{"key":"monny.kill_reason","value":{"stringValue":"Timeout"}}`)
+	assert.Contains(t, string(body), `{"key":"service.name","value":{"stringValue":"monny"}}`)
+}
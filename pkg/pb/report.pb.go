@@ -37,19 +37,27 @@ const (
 	ReportReason_FileNotCreated ReportReason = 7
 	ReportReason_Killed         ReportReason = 8
 	ReportReason_Start          ReportReason = 9
+	ReportReason_Heartbeat      ReportReason = 10
+	ReportReason_Skipped        ReportReason = 11
+	ReportReason_LateRun        ReportReason = 12
+	ReportReason_SLOViolation   ReportReason = 13
 )
 
 var ReportReason_name = map[int32]string{
-	0: "Unknown",
-	1: "Success",
-	2: "Failure",
-	3: "Alert",
-	4: "AlertRate",
-	5: "MemoryWarning",
-	6: "TimeWarning",
-	7: "FileNotCreated",
-	8: "Killed",
-	9: "Start",
+	0:  "Unknown",
+	1:  "Success",
+	2:  "Failure",
+	3:  "Alert",
+	4:  "AlertRate",
+	5:  "MemoryWarning",
+	6:  "TimeWarning",
+	7:  "FileNotCreated",
+	8:  "Killed",
+	9:  "Start",
+	10: "Heartbeat",
+	11: "Skipped",
+	12: "LateRun",
+	13: "SLOViolation",
 }
 
 var ReportReason_value = map[string]int32{
@@ -63,6 +71,10 @@ var ReportReason_value = map[string]int32{
 	"FileNotCreated": 7,
 	"Killed":         8,
 	"Start":          9,
+	"Heartbeat":      10,
+	"Skipped":        11,
+	"LateRun":        12,
+	"SLOViolation":   13,
 }
 
 func (x ReportReason) String() string {
@@ -105,29 +117,33 @@ func (KillReason) EnumDescriptor() ([]byte, []int) {
 }
 
 type Report struct {
-	Id                   string       `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Hostname             string       `protobuf:"bytes,2,opt,name=hostname,proto3" json:"hostname,omitempty"`
-	Stdout               []string     `protobuf:"bytes,3,rep,name=stdout,proto3" json:"stdout,omitempty"`
-	Stderr               []string     `protobuf:"bytes,4,rep,name=stderr,proto3" json:"stderr,omitempty"`
-	Success              bool         `protobuf:"varint,5,opt,name=success,proto3" json:"success,omitempty"`
-	MaxMemory            uint64       `protobuf:"varint,6,opt,name=max_memory,json=maxMemory,proto3" json:"max_memory,omitempty"`
-	Killed               bool         `protobuf:"varint,7,opt,name=killed,proto3" json:"killed,omitempty"`
-	KillReason           KillReason   `protobuf:"varint,8,opt,name=kill_reason,json=killReason,proto3,enum=monny.monitor.KillReason" json:"kill_reason,omitempty"`
-	Created              []byte       `protobuf:"bytes,9,opt,name=created,proto3" json:"created,omitempty"`
-	ReportReason         ReportReason `protobuf:"varint,10,opt,name=report_reason,json=reportReason,proto3,enum=monny.monitor.ReportReason" json:"report_reason,omitempty"`
-	Start                int64        `protobuf:"varint,11,opt,name=start,proto3" json:"start,omitempty"`
-	Finish               int64        `protobuf:"varint,12,opt,name=finish,proto3" json:"finish,omitempty"`
-	Duration             string       `protobuf:"bytes,13,opt,name=duration,proto3" json:"duration,omitempty"`
-	ExitCode             int32        `protobuf:"varint,14,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
-	ExitCodeValid        bool         `protobuf:"varint,15,opt,name=exit_code_valid,json=exitCodeValid,proto3" json:"exit_code_valid,omitempty"`
-	Messages             []string     `protobuf:"bytes,16,rep,name=messages,proto3" json:"messages,omitempty"`
-	Matches              []byte       `protobuf:"bytes,17,opt,name=matches,proto3" json:"matches,omitempty"`
-	UserCommand          string       `protobuf:"bytes,18,opt,name=user_command,json=userCommand,proto3" json:"user_command,omitempty"`
-	Config               []byte       `protobuf:"bytes,19,opt,name=config,proto3" json:"config,omitempty"`
-	CreatedAt            int64        `protobuf:"varint,20,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
-	XXX_unrecognized     []byte       `json:"-"`
-	XXX_sizecache        int32        `json:"-"`
+	Id                   string            `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Hostname             string            `protobuf:"bytes,2,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	Stdout               []string          `protobuf:"bytes,3,rep,name=stdout,proto3" json:"stdout,omitempty"`
+	Stderr               []string          `protobuf:"bytes,4,rep,name=stderr,proto3" json:"stderr,omitempty"`
+	Success              bool              `protobuf:"varint,5,opt,name=success,proto3" json:"success,omitempty"`
+	MaxMemory            uint64            `protobuf:"varint,6,opt,name=max_memory,json=maxMemory,proto3" json:"max_memory,omitempty"`
+	Killed               bool              `protobuf:"varint,7,opt,name=killed,proto3" json:"killed,omitempty"`
+	KillReason           KillReason        `protobuf:"varint,8,opt,name=kill_reason,json=killReason,proto3,enum=monny.monitor.KillReason" json:"kill_reason,omitempty"`
+	Created              []byte            `protobuf:"bytes,9,opt,name=created,proto3" json:"created,omitempty"`
+	ReportReason         ReportReason      `protobuf:"varint,10,opt,name=report_reason,json=reportReason,proto3,enum=monny.monitor.ReportReason" json:"report_reason,omitempty"`
+	Start                int64             `protobuf:"varint,11,opt,name=start,proto3" json:"start,omitempty"`
+	Finish               int64             `protobuf:"varint,12,opt,name=finish,proto3" json:"finish,omitempty"`
+	Duration             string            `protobuf:"bytes,13,opt,name=duration,proto3" json:"duration,omitempty"`
+	ExitCode             int32             `protobuf:"varint,14,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	ExitCodeValid        bool              `protobuf:"varint,15,opt,name=exit_code_valid,json=exitCodeValid,proto3" json:"exit_code_valid,omitempty"`
+	Messages             []string          `protobuf:"bytes,16,rep,name=messages,proto3" json:"messages,omitempty"`
+	Matches              []byte            `protobuf:"bytes,17,opt,name=matches,proto3" json:"matches,omitempty"`
+	UserCommand          string            `protobuf:"bytes,18,opt,name=user_command,json=userCommand,proto3" json:"user_command,omitempty"`
+	Config               []byte            `protobuf:"bytes,19,opt,name=config,proto3" json:"config,omitempty"`
+	CreatedAt            int64             `protobuf:"varint,20,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Env                  map[string]string `protobuf:"bytes,21,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Memory               uint64            `protobuf:"varint,22,opt,name=memory,proto3" json:"memory,omitempty"`
+	Steps                []byte            `protobuf:"bytes,23,opt,name=steps,proto3" json:"steps,omitempty"`
+	LinesProcessed       int64             `protobuf:"varint,24,opt,name=lines_processed,json=linesProcessed,proto3" json:"lines_processed,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
 }
 
 func (m *Report) Reset()         { *m = Report{} }
@@ -295,6 +311,34 @@ func (m *Report) GetCreatedAt() int64 {
 	return 0
 }
 
+func (m *Report) GetEnv() map[string]string {
+	if m != nil {
+		return m.Env
+	}
+	return nil
+}
+
+func (m *Report) GetMemory() uint64 {
+	if m != nil {
+		return m.Memory
+	}
+	return 0
+}
+
+func (m *Report) GetSteps() []byte {
+	if m != nil {
+		return m.Steps
+	}
+	return nil
+}
+
+func (m *Report) GetLinesProcessed() int64 {
+	if m != nil {
+		return m.LinesProcessed
+	}
+	return 0
+}
+
 type ReportAck struct {
 	Success              bool     `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -334,54 +378,106 @@ func (m *ReportAck) GetSuccess() bool {
 	return false
 }
 
+type ReportBatch struct {
+	Reports              []*Report `protobuf:"bytes,1,rep,name=reports,proto3" json:"reports,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *ReportBatch) Reset()         { *m = ReportBatch{} }
+func (m *ReportBatch) String() string { return proto.CompactTextString(m) }
+func (*ReportBatch) ProtoMessage()    {}
+func (*ReportBatch) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3eedb623aa6ca98c, []int{2}
+}
+
+func (m *ReportBatch) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReportBatch.Unmarshal(m, b)
+}
+func (m *ReportBatch) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReportBatch.Marshal(b, m, deterministic)
+}
+func (m *ReportBatch) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReportBatch.Merge(m, src)
+}
+func (m *ReportBatch) XXX_Size() int {
+	return xxx_messageInfo_ReportBatch.Size(m)
+}
+func (m *ReportBatch) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReportBatch.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReportBatch proto.InternalMessageInfo
+
+func (m *ReportBatch) GetReports() []*Report {
+	if m != nil {
+		return m.Reports
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterEnum("monny.monitor.ReportReason", ReportReason_name, ReportReason_value)
 	proto.RegisterEnum("monny.monitor.KillReason", KillReason_name, KillReason_value)
 	proto.RegisterType((*Report)(nil), "monny.monitor.Report")
+	proto.RegisterMapType((map[string]string)(nil), "monny.monitor.Report.EnvEntry")
 	proto.RegisterType((*ReportAck)(nil), "monny.monitor.ReportAck")
+	proto.RegisterType((*ReportBatch)(nil), "monny.monitor.ReportBatch")
 }
 
 func init() { proto.RegisterFile("report.proto", fileDescriptor_3eedb623aa6ca98c) }
 
 var fileDescriptor_3eedb623aa6ca98c = []byte{
-	// 584 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x53, 0x4b, 0x6f, 0x13, 0x31,
-	0x10, 0xee, 0xe6, 0xb1, 0xc9, 0x4e, 0x1e, 0x75, 0x4d, 0x41, 0xa6, 0x15, 0xd2, 0x52, 0x09, 0xb4,
-	0xea, 0x21, 0x48, 0xe5, 0x06, 0x97, 0x86, 0x4a, 0xbd, 0x54, 0xf4, 0xb0, 0xe5, 0x21, 0x71, 0x89,
-	0xdc, 0x5d, 0x37, 0xb5, 0xb2, 0xb6, 0x23, 0xdb, 0x81, 0xf6, 0xcf, 0xf0, 0x0b, 0xf9, 0x11, 0xc8,
-	0x8f, 0x0d, 0x2d, 0xea, 0x6d, 0xbe, 0x6f, 0xc7, 0xdf, 0xcc, 0x7c, 0x33, 0x0b, 0x63, 0xcd, 0xd6,
-	0x4a, 0xdb, 0xd9, 0x5a, 0x2b, 0xab, 0xf0, 0x44, 0x28, 0x29, 0xef, 0x67, 0x42, 0x49, 0x6e, 0x95,
-	0x3e, 0xfa, 0xd3, 0x83, 0xb4, 0xf4, 0xdf, 0xf1, 0x14, 0x3a, 0xbc, 0x26, 0x49, 0x9e, 0x14, 0x59,
-	0xd9, 0xe1, 0x35, 0x3e, 0x80, 0xe1, 0xad, 0x32, 0x56, 0x52, 0xc1, 0x48, 0xc7, 0xb3, 0x5b, 0x8c,
-	0x5f, 0x40, 0x6a, 0x6c, 0xad, 0x36, 0x96, 0x74, 0xf3, 0x6e, 0x91, 0x95, 0x11, 0x45, 0x9e, 0x69,
-	0x4d, 0x7a, 0x5b, 0x9e, 0x69, 0x8d, 0x09, 0x0c, 0xcc, 0xa6, 0xaa, 0x98, 0x31, 0xa4, 0x9f, 0x27,
-	0xc5, 0xb0, 0x6c, 0x21, 0x7e, 0x05, 0x20, 0xe8, 0xdd, 0x42, 0x30, 0xa1, 0xf4, 0x3d, 0x49, 0xf3,
-	0xa4, 0xe8, 0x95, 0x99, 0xa0, 0x77, 0x9f, 0x3d, 0xe1, 0x04, 0x57, 0xbc, 0x69, 0x58, 0x4d, 0x06,
-	0xfe, 0x5d, 0x44, 0xf8, 0x03, 0x8c, 0x5c, 0xb4, 0xd0, 0x8c, 0x1a, 0x25, 0xc9, 0x30, 0x4f, 0x8a,
-	0xe9, 0xc9, 0xcb, 0xd9, 0xa3, 0xe1, 0x66, 0x17, 0xbc, 0x69, 0x4a, 0x9f, 0x50, 0xc2, 0x6a, 0x1b,
-	0xbb, 0x66, 0x2a, 0xcd, 0xa8, 0x65, 0x35, 0xc9, 0xf2, 0xa4, 0x18, 0x97, 0x2d, 0xc4, 0xa7, 0x30,
-	0x09, 0x66, 0xb5, 0xba, 0xe0, 0x75, 0x0f, 0xff, 0xd3, 0x0d, 0x86, 0x45, 0xe5, 0x68, 0x6f, 0xd4,
-	0xde, 0x87, 0xbe, 0xb1, 0x54, 0x5b, 0x32, 0xca, 0x93, 0xa2, 0x5b, 0x06, 0xe0, 0xa6, 0xb8, 0xe1,
-	0x92, 0x9b, 0x5b, 0x32, 0xf6, 0x74, 0x44, 0xce, 0xe2, 0x7a, 0xa3, 0xa9, 0xe5, 0x4a, 0x92, 0x49,
-	0xb0, 0xb8, 0xc5, 0xf8, 0x10, 0x32, 0x76, 0xc7, 0xed, 0xa2, 0x52, 0x35, 0x23, 0xd3, 0x3c, 0x29,
-	0xfa, 0xe5, 0xd0, 0x11, 0x67, 0xaa, 0x66, 0xf8, 0x2d, 0xec, 0x6e, 0x3f, 0x2e, 0x7e, 0xd2, 0x86,
-	0xd7, 0x64, 0xd7, 0xfb, 0x33, 0x69, 0x53, 0xbe, 0x39, 0xd2, 0x15, 0x10, 0xcc, 0x18, 0xba, 0x64,
-	0x86, 0x20, 0xbf, 0x91, 0x2d, 0x76, 0x36, 0x08, 0x6a, 0xab, 0x5b, 0x66, 0xc8, 0x5e, 0xb0, 0x21,
-	0x42, 0xfc, 0x1a, 0xc6, 0x1b, 0xc3, 0xf4, 0xa2, 0x52, 0x42, 0x50, 0x59, 0x13, 0xec, 0x5b, 0x1b,
-	0x39, 0xee, 0x2c, 0x50, 0x6e, 0xa2, 0x4a, 0xc9, 0x1b, 0xbe, 0x24, 0xcf, 0xfc, 0xdb, 0x88, 0xdc,
-	0x3a, 0xa3, 0x99, 0x0b, 0x6a, 0xc9, 0xbe, 0x9f, 0x36, 0x8b, 0xcc, 0xdc, 0x1e, 0xbd, 0x81, 0x2c,
-	0x98, 0x37, 0xaf, 0x56, 0x0f, 0x8f, 0x22, 0x79, 0x74, 0x14, 0xc7, 0xbf, 0x13, 0x18, 0x3f, 0x34,
-	0x19, 0x8f, 0x60, 0xf0, 0x55, 0xae, 0xa4, 0xfa, 0x25, 0xd1, 0x8e, 0x03, 0x57, 0x21, 0x11, 0x25,
-	0x0e, 0x9c, 0x53, 0xde, 0x6c, 0x34, 0x43, 0x1d, 0x9c, 0x41, 0x7f, 0xde, 0x30, 0x6d, 0x51, 0x17,
-	0x4f, 0x20, 0xf3, 0x61, 0x49, 0x2d, 0x43, 0x3d, 0xbc, 0x07, 0x93, 0x70, 0x51, 0xdf, 0xa9, 0x96,
-	0x5c, 0x2e, 0x51, 0x1f, 0xef, 0xc2, 0xe8, 0x0b, 0x17, 0xac, 0x25, 0x52, 0x8c, 0x61, 0x7a, 0xce,
-	0x1b, 0x76, 0xa9, 0xec, 0x59, 0x68, 0x18, 0x0d, 0x30, 0x40, 0x7a, 0xe1, 0x2f, 0x0e, 0x0d, 0x9d,
-	0xfa, 0x95, 0x5b, 0x27, 0xca, 0x8e, 0x4f, 0x01, 0xfe, 0x1d, 0x97, 0xab, 0x75, 0xa9, 0x6c, 0xcc,
-	0xf3, 0xfd, 0x39, 0x61, 0xb5, 0xb1, 0x28, 0x71, 0x02, 0xa1, 0x30, 0xea, 0xb8, 0xf8, 0x8a, 0x2f,
-	0x25, 0x6d, 0x50, 0xf7, 0xe4, 0x1c, 0x06, 0x61, 0x42, 0x83, 0x3f, 0x42, 0x1a, 0x0a, 0xe2, 0xe7,
-	0x4f, 0x1e, 0xda, 0x01, 0x79, 0x92, 0x9e, 0x57, 0xab, 0xa3, 0x9d, 0x4f, 0xc3, 0x1f, 0xe9, 0x7a,
-	0xb5, 0x7c, 0xb7, 0xbe, 0xbe, 0x4e, 0xfd, 0x0f, 0xfe, 0xfe, 0x6f, 0x00, 0x00, 0x00, 0xff, 0xff,
-	0xb0, 0x22, 0x9e, 0xf8, 0xf0, 0x03, 0x00, 0x00,
+	// 756 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x54, 0xed, 0x8e, 0xdc, 0x34,
+	0x14, 0x6d, 0x26, 0x3b, 0x99, 0xc9, 0x4d, 0x66, 0xd6, 0x35, 0x6d, 0x31, 0x5b, 0x81, 0xc2, 0x4a,
+	0x40, 0xd4, 0x1f, 0x53, 0xb4, 0x48, 0x08, 0x15, 0x09, 0x75, 0xbb, 0xda, 0x0a, 0xa9, 0xa5, 0xa0,
+	0x2c, 0x14, 0x89, 0x3f, 0x23, 0x6f, 0xe2, 0xce, 0x5a, 0x49, 0xec, 0xc8, 0x76, 0x86, 0x9d, 0x87,
+	0xe0, 0x51, 0x78, 0x20, 0xde, 0x06, 0xd9, 0x4e, 0x86, 0x2d, 0x1a, 0xf5, 0xdf, 0x3d, 0x27, 0xd7,
+	0xf7, 0xe3, 0xf8, 0x38, 0x90, 0x2a, 0xd6, 0x49, 0x65, 0x56, 0x9d, 0x92, 0x46, 0xe2, 0x45, 0x2b,
+	0x85, 0xd8, 0xad, 0x5a, 0x29, 0xb8, 0x91, 0xea, 0xf4, 0xef, 0x08, 0xa2, 0xc2, 0x7d, 0xc7, 0x4b,
+	0x98, 0xf0, 0x8a, 0x04, 0x59, 0x90, 0xc7, 0xc5, 0x84, 0x57, 0xf8, 0x04, 0xe6, 0x37, 0x52, 0x1b,
+	0x41, 0x5b, 0x46, 0x26, 0x8e, 0xdd, 0x63, 0xfc, 0x08, 0x22, 0x6d, 0x2a, 0xd9, 0x1b, 0x12, 0x66,
+	0x61, 0x1e, 0x17, 0x03, 0x1a, 0x78, 0xa6, 0x14, 0x39, 0xda, 0xf3, 0x4c, 0x29, 0x4c, 0x60, 0xa6,
+	0xfb, 0xb2, 0x64, 0x5a, 0x93, 0x69, 0x16, 0xe4, 0xf3, 0x62, 0x84, 0xf8, 0x53, 0x80, 0x96, 0xde,
+	0xae, 0x5b, 0xd6, 0x4a, 0xb5, 0x23, 0x51, 0x16, 0xe4, 0x47, 0x45, 0xdc, 0xd2, 0xdb, 0x9f, 0x1c,
+	0x61, 0x0b, 0xd6, 0xbc, 0x69, 0x58, 0x45, 0x66, 0xee, 0xdc, 0x80, 0xf0, 0x33, 0x48, 0x6c, 0xb4,
+	0x56, 0x8c, 0x6a, 0x29, 0xc8, 0x3c, 0x0b, 0xf2, 0xe5, 0xd9, 0x27, 0xab, 0xf7, 0x96, 0x5b, 0xbd,
+	0xe2, 0x4d, 0x53, 0xb8, 0x84, 0x02, 0xea, 0x7d, 0x6c, 0x87, 0x29, 0x15, 0xa3, 0x86, 0x55, 0x24,
+	0xce, 0x82, 0x3c, 0x2d, 0x46, 0x88, 0x9f, 0xc3, 0xc2, 0x8b, 0x35, 0xd6, 0x05, 0x57, 0xf7, 0xf1,
+	0xff, 0xea, 0x7a, 0xc1, 0x86, 0xca, 0xa9, 0xba, 0x83, 0xf0, 0x03, 0x98, 0x6a, 0x43, 0x95, 0x21,
+	0x49, 0x16, 0xe4, 0x61, 0xe1, 0x81, 0xdd, 0xe2, 0x1d, 0x17, 0x5c, 0xdf, 0x90, 0xd4, 0xd1, 0x03,
+	0xb2, 0x12, 0x57, 0xbd, 0xa2, 0x86, 0x4b, 0x41, 0x16, 0x5e, 0xe2, 0x11, 0xe3, 0xc7, 0x10, 0xb3,
+	0x5b, 0x6e, 0xd6, 0xa5, 0xac, 0x18, 0x59, 0x66, 0x41, 0x3e, 0x2d, 0xe6, 0x96, 0xb8, 0x90, 0x15,
+	0xc3, 0x5f, 0xc2, 0xf1, 0xfe, 0xe3, 0x7a, 0x4b, 0x1b, 0x5e, 0x91, 0x63, 0xa7, 0xcf, 0x62, 0x4c,
+	0x79, 0x6b, 0x49, 0xdb, 0xa0, 0x65, 0x5a, 0xd3, 0x0d, 0xd3, 0x04, 0xb9, 0x1b, 0xd9, 0x63, 0x2b,
+	0x43, 0x4b, 0x4d, 0x79, 0xc3, 0x34, 0xb9, 0xef, 0x65, 0x18, 0x20, 0xfe, 0x1c, 0xd2, 0x5e, 0x33,
+	0xb5, 0x2e, 0x65, 0xdb, 0x52, 0x51, 0x11, 0xec, 0x46, 0x4b, 0x2c, 0x77, 0xe1, 0x29, 0xbb, 0x51,
+	0x29, 0xc5, 0x3b, 0xbe, 0x21, 0x1f, 0xb9, 0xb3, 0x03, 0xb2, 0xd7, 0x39, 0x88, 0xb9, 0xa6, 0x86,
+	0x3c, 0x70, 0xdb, 0xc6, 0x03, 0x73, 0x6e, 0xf0, 0xd7, 0x10, 0x32, 0xb1, 0x25, 0x0f, 0xb3, 0x30,
+	0x4f, 0xce, 0x3e, 0x3b, 0x28, 0xeb, 0xea, 0x52, 0x6c, 0x2f, 0x85, 0x51, 0xbb, 0xc2, 0xa6, 0xda,
+	0x46, 0x83, 0x37, 0x1e, 0x39, 0x6f, 0x0c, 0xc8, 0x0b, 0xcd, 0x3a, 0x4d, 0x3e, 0x76, 0xfd, 0x3d,
+	0xc0, 0x5f, 0xc1, 0x71, 0xc3, 0x05, 0xd3, 0xeb, 0x4e, 0x49, 0x6b, 0x2f, 0x56, 0x11, 0xe2, 0x66,
+	0x58, 0x3a, 0xfa, 0x97, 0x91, 0x3d, 0xf9, 0x16, 0xe6, 0x63, 0x1f, 0x8c, 0x20, 0xac, 0xd9, 0x6e,
+	0x70, 0xbe, 0x0d, 0x6d, 0xf1, 0x2d, 0x6d, 0xfa, 0xd1, 0xf7, 0x1e, 0x3c, 0x9b, 0x7c, 0x17, 0x9c,
+	0x7e, 0x01, 0xb1, 0x1f, 0xf3, 0xbc, 0xac, 0xef, 0xba, 0x3a, 0x78, 0xcf, 0xd5, 0xa7, 0x3f, 0x40,
+	0xe2, 0xd3, 0x5e, 0x58, 0x49, 0xf1, 0x53, 0x98, 0x79, 0x97, 0xd8, 0x44, 0xbb, 0xfa, 0xc3, 0xc3,
+	0x8e, 0x1a, 0xb3, 0x9e, 0xfc, 0x13, 0x40, 0x7a, 0xd7, 0x65, 0x38, 0x81, 0xd9, 0x6f, 0xa2, 0x16,
+	0xf2, 0x4f, 0x81, 0xee, 0x59, 0x70, 0xe5, 0x1b, 0xa1, 0xc0, 0x82, 0x97, 0x94, 0x37, 0xbd, 0x62,
+	0x68, 0x82, 0x63, 0x98, 0x9e, 0x37, 0x4c, 0x19, 0x14, 0xe2, 0x05, 0xc4, 0x2e, 0x2c, 0xa8, 0x61,
+	0xe8, 0x08, 0xdf, 0x87, 0x85, 0x7f, 0x52, 0xbf, 0x53, 0x25, 0xb8, 0xd8, 0xa0, 0x29, 0x3e, 0x86,
+	0xe4, 0x57, 0xde, 0xb2, 0x91, 0x88, 0x30, 0x86, 0xe5, 0x4b, 0xde, 0xb0, 0x37, 0xd2, 0x5c, 0xf8,
+	0x1b, 0x43, 0x33, 0x0c, 0x10, 0xbd, 0x72, 0x4f, 0x0e, 0xcd, 0x6d, 0xf5, 0x2b, 0xeb, 0x67, 0x14,
+	0xdb, 0xea, 0x3f, 0x32, 0xaa, 0xcc, 0x35, 0xa3, 0x06, 0x81, 0x9b, 0xa8, 0xe6, 0x5d, 0xc7, 0x2a,
+	0x94, 0x58, 0xf0, 0x9a, 0x1a, 0x56, 0xf4, 0x02, 0xa5, 0x18, 0x41, 0x7a, 0xf5, 0xfa, 0xe7, 0xb7,
+	0x5c, 0x36, 0xce, 0xd6, 0x68, 0xf1, 0xe4, 0x39, 0xc0, 0x7f, 0x0f, 0xd3, 0x16, 0x7a, 0x23, 0xcd,
+	0xd0, 0xc2, 0xad, 0x66, 0x67, 0x92, 0xbd, 0x41, 0x81, 0xed, 0xed, 0x67, 0x46, 0x13, 0x1b, 0x5f,
+	0xf1, 0x8d, 0xa0, 0x0d, 0x0a, 0xcf, 0xfe, 0x0a, 0x60, 0xe6, 0xd5, 0xd1, 0xf8, 0x7b, 0x88, 0xfc,
+	0xb0, 0xf8, 0xb0, 0xa6, 0x27, 0xe4, 0x20, 0x7d, 0x5e, 0xd6, 0xa7, 0xf7, 0xf0, 0x25, 0x24, 0xfe,
+	0xb0, 0xbf, 0xa6, 0x93, 0x83, 0xa9, 0xee, 0xdb, 0x87, 0xca, 0xbc, 0x98, 0xff, 0x11, 0x75, 0xf5,
+	0xe6, 0x69, 0x77, 0x7d, 0x1d, 0xb9, 0x9f, 0xec, 0x37, 0xff, 0x0e, 0x00, 0x7b, 0xa7, 0x14, 0xda,
+	0x74, 0x05, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -397,6 +493,7 @@ const _ = grpc.SupportPackageIsVersion4
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type ReportsClient interface {
 	Create(ctx context.Context, in *Report, opts ...grpc.CallOption) (*ReportAck, error)
+	CreateBatch(ctx context.Context, in *ReportBatch, opts ...grpc.CallOption) (*ReportAck, error)
 }
 
 type reportsClient struct {
@@ -416,9 +513,19 @@ func (c *reportsClient) Create(ctx context.Context, in *Report, opts ...grpc.Cal
 	return out, nil
 }
 
+func (c *reportsClient) CreateBatch(ctx context.Context, in *ReportBatch, opts ...grpc.CallOption) (*ReportAck, error) {
+	out := new(ReportAck)
+	err := c.cc.Invoke(ctx, "/monny.monitor.Reports/CreateBatch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ReportsServer is the server API for Reports service.
 type ReportsServer interface {
 	Create(context.Context, *Report) (*ReportAck, error)
+	CreateBatch(context.Context, *ReportBatch) (*ReportAck, error)
 }
 
 // UnimplementedReportsServer can be embedded to have forward compatible implementations.
@@ -428,6 +535,9 @@ type UnimplementedReportsServer struct {
 func (*UnimplementedReportsServer) Create(ctx context.Context, req *Report) (*ReportAck, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
 }
+func (*UnimplementedReportsServer) CreateBatch(ctx context.Context, req *ReportBatch) (*ReportAck, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateBatch not implemented")
+}
 
 func RegisterReportsServer(s *grpc.Server, srv ReportsServer) {
 	s.RegisterService(&_Reports_serviceDesc, srv)
@@ -451,6 +561,24 @@ func _Reports_Create_Handler(srv interface{}, ctx context.Context, dec func(inte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Reports_CreateBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReportBatch)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReportsServer).CreateBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/monny.monitor.Reports/CreateBatch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReportsServer).CreateBatch(ctx, req.(*ReportBatch))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Reports_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "monny.monitor.Reports",
 	HandlerType: (*ReportsServer)(nil),
@@ -459,6 +587,10 @@ var _Reports_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Create",
 			Handler:    _Reports_Create_Handler,
 		},
+		{
+			MethodName: "CreateBatch",
+			Handler:    _Reports_CreateBatch_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "report.proto",
@@ -14,6 +14,10 @@ const (
 	FileNotCreated
 	Killed
 	Start
+	Heartbeat
+	Skipped
+	LateRun
+	SLOViolation
 )
 
 type KillReason int32
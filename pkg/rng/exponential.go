@@ -0,0 +1,27 @@
+package rng
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+var _ RNG = &ExponentialRNG{}
+
+// ExponentialRNG generates Exponentially distributed numbers with rate lambda (mean 1/lambda) using the inverse transform
+// method.  A uniform sample U in [0, 1) is transformed by the inverse of the CDF, -ln(1-U)/lambda.
+type ExponentialRNG struct {
+	lambda float64
+	r      *rand.Rand
+}
+
+func (r *ExponentialRNG) Rand() float64 {
+	return -math.Log(1.0-r.r.Float64()) / r.lambda
+}
+
+func NewExponentialRNG(lambda float64) *ExponentialRNG {
+	return &ExponentialRNG{
+		lambda: lambda,
+		r:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
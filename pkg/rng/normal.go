@@ -0,0 +1,28 @@
+package rng
+
+import (
+	"math/rand"
+	"time"
+)
+
+var _ RNG = &NormalRNG{}
+
+// NormalRNG generates Normally distributed numbers.  Samples from the standard normal distribution are generated using the
+// ziggurat algorithm of math/rand, then scaled by the standard deviation and shifted by the mean.
+type NormalRNG struct {
+	mean   float64
+	stddev float64
+	r      *rand.Rand
+}
+
+func (r *NormalRNG) Rand() float64 {
+	return r.r.NormFloat64()*r.stddev + r.mean
+}
+
+func NewNormalRNG(mean float64, stddev float64) *NormalRNG {
+	return &NormalRNG{
+		mean:   mean,
+		stddev: stddev,
+		r:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
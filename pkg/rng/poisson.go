@@ -8,15 +8,17 @@ import (
 
 var _ RNG = &PoissonRNG{}
 
-// PoissonRNG generates Poisson distributed numbers using Knuth's algorithm
+// PoissonRNG generates Poisson distributed numbers using Knuth's algorithm, which multiplies uniform samples until the
+// product falls below e^-lambda.  The number of samples is one more than the Poisson sample.  It takes O(lambda) uniform
+// samples per value, so it is suited to the small rates used in calibration.  For lambda larger than about 700, e^-lambda
+// underflows and the algorithm does not terminate correctly.
 type PoissonRNG struct {
 	lambda float64
 	r      *rand.Rand
 }
 
 func (r *PoissonRNG) Rand() float64 {
-	// Knuth's algorithm
-	L := math.Pow(math.E, -r.lambda)
+	L := math.Exp(-r.lambda)
 	var k int64 = 0
 	var p float64 = 1.0
 
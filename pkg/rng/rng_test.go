@@ -0,0 +1,45 @@
+package rng
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistributions(t *testing.T) {
+	tt := []struct {
+		name     string
+		rng      RNG
+		mean     float64
+		variance float64
+		min      float64
+	}{
+		{name: "normal", rng: NewNormalRNG(10.0, 2.0), mean: 10.0, variance: 4.0, min: math.Inf(-1)},
+		{name: "poisson", rng: NewPoissonRNG(20.0), mean: 20.0, variance: 20.0, min: 0.0},
+		{name: "poisson small lambda", rng: NewPoissonRNG(0.5), mean: 0.5, variance: 0.5, min: 0.0},
+		{name: "exponential", rng: NewExponentialRNG(0.5), mean: 2.0, variance: 4.0, min: 0.0},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			n := 50000
+			val := make([]float64, n)
+			sum := 0.0
+			for i := 0; i < n; i++ {
+				val[i] = tc.rng.Rand()
+				sum += val[i]
+				assert.True(t, val[i] >= tc.min, "value %f below minimum %f", val[i], tc.min)
+			}
+			mean := sum / float64(n)
+			variance := 0.0
+			for _, v := range val {
+				variance += math.Pow(v-mean, 2.0)
+			}
+			variance = variance / float64(n-1)
+
+			// within 5% of the expected moments
+			assert.InDelta(t, tc.mean, mean, 0.05*tc.mean)
+			assert.InDelta(t, tc.variance, variance, 0.05*tc.variance)
+		})
+	}
+}
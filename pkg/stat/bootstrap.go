@@ -0,0 +1,153 @@
+package stat
+
+import (
+	"fmt"
+	"math"
+)
+
+// stallFactor is the number of full series of observations a statistic may record while bootstrapping before the
+// bootstrap is considered stalled
+const stallFactor = 3
+
+// BootstrapWarning describes a statistic that has not established a baseline after many observations, such as a log
+// normal statistic recording zeros.  A statistic in this condition never alarms.
+type BootstrapWarning struct {
+	Statistic string
+	// Attempts is the number of observations recorded while bootstrapping, of which Invalid could not be transformed
+	Attempts int
+	Invalid  int
+	// Mean and Variance are the last baseline candidates that were rejected
+	Mean     float64
+	Variance float64
+	// Relaxed is true if the statistic will retry the bootstrap with relaxed criteria
+	Relaxed bool
+}
+
+func (w BootstrapWarning) String() string {
+	return fmt.Sprintf("statistic %s has not established a baseline after %d observations (%d invalid, mean %g, variance %g)", w.Statistic, w.Attempts, w.Invalid, w.Mean, w.Variance)
+}
+
+// bootstrap tracks the progress of a statistic towards establishing a baseline
+type bootstrap struct {
+	attempts int
+	invalid  int
+	mean     float64
+	variance float64
+	stalled  bool
+
+	relax bool
+	warn  func(BootstrapWarning)
+}
+
+// OnBootstrapStalled calls fn once each time the bootstrap of the statistic stalls
+func (e *TestStatistic) OnBootstrapStalled(fn func(BootstrapWarning)) {
+	e.bootstrap.warn = fn
+}
+
+// RelaxBootstrap retries a stalled bootstrap accepting any baseline with a finite mean and positive variance.  By default
+// the baseline mean must also be positive.
+func (e *TestStatistic) RelaxBootstrap() {
+	e.bootstrap.relax = true
+}
+
+// BootstrapStalled returns true if the statistic has recorded many observations without establishing a baseline
+func (e *TestStatistic) BootstrapStalled() bool {
+	return e.bootstrap.stalled
+}
+
+// bootstrapping returns true while the statistic is collecting observations to establish a baseline
+func (e *TestStatistic) bootstrapping() bool {
+	switch e.fsm.State() {
+	case Reset, UCLInitial, LCLInitial:
+		return true
+	default:
+		return false
+	}
+}
+
+// recordAttempt counts an observation recorded while bootstrapping and checks whether the bootstrap has stalled
+func (e *TestStatistic) recordAttempt(valid bool) {
+	b := &e.bootstrap
+	b.attempts++
+	if !valid {
+		b.invalid++
+	}
+	if b.stalled || b.attempts < stallFactor*e.series.Capacity() {
+		return
+	}
+	b.stalled = true
+	if b.warn != nil {
+		b.warn(BootstrapWarning{
+			Statistic: e.name,
+			Attempts:  b.attempts,
+			Invalid:   b.invalid,
+			Mean:      b.mean,
+			Variance:  b.variance,
+			Relaxed:   b.relax,
+		})
+	}
+}
+
+// acceptBaseline returns true if the mean and variance can be used as the baseline.  Rejected values are kept for
+// reporting a stalled bootstrap.
+func (e *TestStatistic) acceptBaseline(mean float64, variance float64) bool {
+	b := &e.bootstrap
+	ok := mean > 0.0 && variance > 0.0
+	if !ok && b.stalled && b.relax {
+		ok = !math.IsNaN(mean) && !math.IsInf(mean, 0) && variance > 0.0 && !math.IsInf(variance, 0)
+	}
+	if !ok {
+		b.mean, b.variance = mean, variance
+		return false
+	}
+	e.bootstrap = bootstrap{relax: b.relax, warn: b.warn}
+	return true
+}
+
+// resetBootstrap starts counting bootstrap attempts over when the statistic is transitioned to collect a new baseline
+func (e *TestStatistic) resetBootstrap() {
+	e.bootstrap = bootstrap{relax: e.bootstrap.relax, warn: e.bootstrap.warn}
+}
+
+// WithBootstrapWarning calls fn when any statistic in the test records many observations without establishing a
+// baseline, which usually means the test is misconfigured for the observations it receives
+func WithBootstrapWarning(fn func(BootstrapWarning)) TestOption {
+	return func(t *Test) error {
+		if fn == nil {
+			return fmt.Errorf("bootstrap warning function must not be nil")
+		}
+		t.bootstrapWarn = fn
+		return nil
+	}
+}
+
+// WithRelaxedBootstrap retries a stalled bootstrap for each statistic in the test with relaxed criteria.  See
+// TestStatistic.RelaxBootstrap.
+func WithRelaxedBootstrap() TestOption {
+	return func(t *Test) error {
+		t.relaxBootstrap = true
+		return nil
+	}
+}
+
+// configureBootstrap applies the bootstrap options of the test to each statistic
+func (t *Test) configureBootstrap() {
+	for _, s := range t.sub {
+		if t.bootstrapWarn != nil {
+			s.OnBootstrapStalled(t.bootstrapWarn)
+		}
+		if t.relaxBootstrap {
+			s.RelaxBootstrap()
+		}
+	}
+}
+
+// BootstrapStalled returns true if any statistic in the test has stalled while establishing a baseline
+func (t *Test) BootstrapStalled() bool {
+	for _, s := range t.sub {
+		if s.BootstrapStalled() {
+			return true
+		}
+	}
+	return false
+}
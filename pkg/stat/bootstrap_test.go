@@ -0,0 +1,57 @@
+package stat
+
+import (
+	"testing"
+
+	"github.com/BTBurke/monny/pkg/fsm"
+	"github.com/BTBurke/monny/pkg/metric"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBootstrapStalled(t *testing.T) {
+	var warnings []BootstrapWarning
+	test, err := NewLogNormalTest(metric.NewName("test", nil), WithStatistic(DefaultLogNormalEWMA()), WithBootstrapWarning(func(w BootstrapWarning) {
+		warnings = append(warnings, w)
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error creating test: %v", err)
+	}
+	// zeros can not be log transformed, so the baseline is never established
+	for i := 0; i < 200; i++ {
+		assert.Error(t, test.Record(0.0))
+	}
+	assert.True(t, test.BootstrapStalled())
+	assert.Equal(t, []fsm.State{UCLInitial}, test.State())
+	if assert.Len(t, warnings, 1) {
+		assert.Equal(t, "ewma", warnings[0].Statistic)
+		assert.Equal(t, 150, warnings[0].Attempts)
+		assert.Equal(t, 150, warnings[0].Invalid)
+		assert.False(t, warnings[0].Relaxed)
+	}
+
+	// transitioning to collect a new baseline starts counting over
+	assert.NoError(t, test.Transition(Reset, true))
+	assert.False(t, test.BootstrapStalled())
+}
+
+func TestRelaxedBootstrap(t *testing.T) {
+	// values below one have a negative mean in log space and are rejected by the default criteria
+	obs := randNorm(200, -2.0, 0.5, logNormalTransform)
+
+	strict, err := NewLogNormalTest(metric.NewName("test", nil), WithStatistic(DefaultLogNormalEWMA()))
+	if err != nil {
+		t.Fatalf("unexpected error creating test: %v", err)
+	}
+	relaxed, err := NewLogNormalTest(metric.NewName("test", nil), WithStatistic(DefaultLogNormalEWMA()), WithRelaxedBootstrap())
+	if err != nil {
+		t.Fatalf("unexpected error creating test: %v", err)
+	}
+	for _, o := range obs {
+		assert.NoError(t, strict.Record(o))
+		assert.NoError(t, relaxed.Record(o))
+	}
+	assert.True(t, strict.BootstrapStalled())
+	assert.Equal(t, []fsm.State{UCLInitial}, strict.State())
+	assert.False(t, relaxed.BootstrapStalled())
+	assert.Equal(t, []fsm.State{TestingUCL}, relaxed.State())
+}
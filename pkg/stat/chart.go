@@ -0,0 +1,115 @@
+package stat
+
+import (
+	"fmt"
+	"time"
+)
+
+// ChartPoint is a single point on a control chart.  Observation is the value after the PDF transform is applied so that it is
+// on the same scale as the test statistic and control limits.
+type ChartPoint struct {
+	Statistic   string
+	Time        time.Time
+	Observation float64
+	Current     float64
+	UCL         float64
+	LCL         float64
+}
+
+// chart retains a bounded history of control chart points, overwriting the oldest point when full
+type chart struct {
+	points []ChartPoint
+	next   int
+	full   bool
+}
+
+func newChart(history int) *chart {
+	return &chart{points: make([]ChartPoint, history)}
+}
+
+func (c *chart) add(p ChartPoint) {
+	c.points[c.next] = p
+	c.next = (c.next + 1) % len(c.points)
+	if c.next == 0 {
+		c.full = true
+	}
+}
+
+// values returns the retained points from oldest to newest
+func (c *chart) values() []ChartPoint {
+	if !c.full {
+		out := make([]ChartPoint, c.next)
+		copy(out, c.points[0:c.next])
+		return out
+	}
+	out := make([]ChartPoint, 0, len(c.points))
+	out = append(out, c.points[c.next:]...)
+	return append(out, c.points[0:c.next]...)
+}
+
+// EnableChartData retains up to history points of control chart data as observations are recorded.  Points are only
+// retained after a baseline has been established and control limits are calculated.
+func (e *TestStatistic) EnableChartData(history int) error {
+	if history < 1 {
+		return fmt.Errorf("chart history must be at least 1, got %d", history)
+	}
+	e.chart = newChart(history)
+	return nil
+}
+
+// ChartData returns the retained control chart points from oldest to newest.  Returns nil if chart data is not enabled.
+func (e *TestStatistic) ChartData() []ChartPoint {
+	if e.chart == nil {
+		return nil
+	}
+	return e.chart.values()
+}
+
+// recordChart adds the current value of the statistic and its control limits to the chart history
+func (e *TestStatistic) recordChart(o float64) {
+	if e.chart == nil || !e.baseline {
+		return
+	}
+	e.chart.add(ChartPoint{
+		Statistic:   e.name,
+		Time:        time.Now(),
+		Observation: o,
+		Current:     e.current,
+		UCL:         e.ucl,
+		LCL:         e.lcl,
+	})
+}
+
+// WithChartData retains up to history points of control chart data for each statistic in the test.  See Test.ChartData.
+func WithChartData(history int) TestOption {
+	return func(t *Test) error {
+		if history < 1 {
+			return fmt.Errorf("chart history must be at least 1, got %d", history)
+		}
+		t.chartHistory = history
+		return nil
+	}
+}
+
+// ChartData returns the control chart points for all statistics in the test.  Points for each statistic are ordered from
+// oldest to newest and identified by ChartPoint.Statistic.
+func (t *Test) ChartData() []ChartPoint {
+	var out []ChartPoint
+	for _, s := range t.sub {
+		out = append(out, s.ChartData()...)
+	}
+	return out
+}
+
+// enableChartData turns on chart history for each statistic when configured with WithChartData
+func (t *Test) enableChartData() error {
+	if t.chartHistory == 0 {
+		return nil
+	}
+	for _, s := range t.sub {
+		if err := s.EnableChartData(t.chartHistory); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package stat
+
+import (
+	"testing"
+
+	"github.com/BTBurke/monny/pkg/metric"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChartHistory(t *testing.T) {
+	c := newChart(3)
+	assert.Len(t, c.values(), 0)
+	for i := 1; i <= 2; i++ {
+		c.add(ChartPoint{Observation: float64(i)})
+	}
+	assert.Equal(t, []ChartPoint{{Observation: 1}, {Observation: 2}}, c.values())
+	for i := 3; i <= 5; i++ {
+		c.add(ChartPoint{Observation: float64(i)})
+	}
+	assert.Equal(t, []ChartPoint{{Observation: 3}, {Observation: 4}, {Observation: 5}}, c.values())
+}
+
+func TestChartData(t *testing.T) {
+	test, err := NewLogNormalTest(metric.NewName("test", nil), WithStatistic(DefaultLogNormalEWMA()), WithChartData(100))
+	if err != nil {
+		t.Fatalf("unexpected error creating test: %v", err)
+	}
+	// 50 observations establish the baseline, only points recorded after the baseline are retained
+	for _, obs := range randNorm(120, 5.2983, 1.0, logNormalTransform) {
+		assert.NoError(t, test.Record(obs))
+	}
+	points := test.ChartData()
+	assert.Len(t, points, 71)
+	for _, p := range points {
+		assert.Equal(t, "ewma", p.Statistic)
+		assert.True(t, p.LCL < p.UCL)
+		assert.True(t, p.LCL < 5.2983 && 5.2983 < p.UCL, "baseline mean outside limits [%f, %f]", p.LCL, p.UCL)
+		assert.True(t, p.Current > 0.0)
+	}
+	for i := 1; i < len(points); i++ {
+		assert.False(t, points[i].Time.Before(points[i-1].Time))
+	}
+
+	for _, obs := range randNorm(100, 5.2983, 1.0, logNormalTransform) {
+		assert.NoError(t, test.Record(obs))
+	}
+	assert.Len(t, test.ChartData(), 100)
+
+	// limits are not valid while collecting a new baseline
+	assert.NoError(t, test.Transition(Reset, true))
+	assert.NoError(t, test.Transition(UCLInitial, false))
+	assert.NoError(t, test.Record(200.0))
+	assert.Len(t, test.ChartData(), 100)
+}
+
+func TestChartDataDisabled(t *testing.T) {
+	test, _ := NewLogNormalTest(metric.NewName("test", nil))
+	for _, obs := range randNorm(60, 5.2983, 1.0, logNormalTransform) {
+		assert.NoError(t, test.Record(obs))
+	}
+	assert.Nil(t, test.ChartData())
+	_, err := NewLogNormalTest(metric.NewName("test", nil), WithChartData(0))
+	assert.Error(t, err)
+}
@@ -0,0 +1,51 @@
+package stat
+
+import (
+	"fmt"
+	"math"
+)
+
+// SetDeadband requires the current value of the statistic to pass the control limit by more than width before it
+// alarms, so that a statistic hovering at the limit does not alarm on noise.  Width is on the same scale as the statistic
+// after the PDF transform is applied.  Alarms are held until the statistic is transitioned, so the deadband only
+// applies to entering an alarm.
+func (e *TestStatistic) SetDeadband(width float64) error {
+	if width < 0.0 || math.IsNaN(width) || math.IsInf(width, 0) {
+		return fmt.Errorf("deadband width must be a non-negative number, got %f", width)
+	}
+	e.deadband = width
+	return nil
+}
+
+// tripped returns true if the current value has passed the control limit by more than the deadband
+func (e *TestStatistic) tripped() bool {
+	if e.lower {
+		return e.current <= e.limit-e.deadband
+	}
+	return e.current >= e.limit+e.deadband
+}
+
+// WithDeadband sets a deadband of width around the control limits of each statistic in the test.  See
+// TestStatistic.SetDeadband.
+func WithDeadband(width float64) TestOption {
+	return func(t *Test) error {
+		if width < 0.0 || math.IsNaN(width) || math.IsInf(width, 0) {
+			return fmt.Errorf("deadband width must be a non-negative number, got %f", width)
+		}
+		t.deadband = width
+		return nil
+	}
+}
+
+// setDeadband sets the deadband of each statistic when configured with WithDeadband
+func (t *Test) setDeadband() error {
+	if t.deadband == 0.0 {
+		return nil
+	}
+	for _, s := range t.sub {
+		if err := s.SetDeadband(t.deadband); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package stat
+
+import (
+	"math"
+	"testing"
+
+	"github.com/BTBurke/monny/pkg/metric"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadband(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Side     Side
+		Deadband float64
+		// Offsets are added to the limit in log space for each observation
+		Offsets []float64
+		Alarm   bool
+	}{
+		{Name: "oscillating without deadband", Side: Upper, Offsets: []float64{-0.05, 0.05, -0.05, 0.05}, Alarm: true},
+		{Name: "oscillating within deadband", Side: Upper, Deadband: 0.1, Offsets: []float64{-0.05, 0.05, -0.05, 0.05, -0.09, 0.09}, Alarm: false},
+		{Name: "beyond deadband", Side: Upper, Deadband: 0.1, Offsets: []float64{-0.05, 0.05, 0.15}, Alarm: true},
+		{Name: "lower oscillating within deadband", Side: Lower, Deadband: 0.1, Offsets: []float64{0.05, -0.05, 0.09, -0.09}, Alarm: false},
+		{Name: "lower beyond deadband", Side: Lower, Deadband: 0.1, Offsets: []float64{0.05, -0.05, -0.15}, Alarm: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			pdf, err := LogNormalFromUnits(50, 50.0, 20.0, KFixed(3.0))
+			if err != nil {
+				t.Fatalf("unexpected error creating log normal: %v", err)
+			}
+			shewart, err := NewEWMAStatistic("shewart", 1.0, pdf)
+			if err != nil {
+				t.Fatalf("unexpected error creating statistic: %v", err)
+			}
+			test, err := NewLogNormalTest(metric.NewName("test", nil), WithStatistic(shewart), WithTwoSided(), WithDeadband(tc.Deadband))
+			if err != nil {
+				t.Fatalf("unexpected error creating test: %v", err)
+			}
+			var limit float64
+			for _, s := range test.sub {
+				if s.Side() == tc.Side {
+					limit = s.Limit()
+				}
+			}
+			for _, o := range tc.Offsets {
+				assert.NoError(t, test.Record(math.Exp(limit+o)))
+			}
+			upper, lower := test.Tripped()
+			assert.Equal(t, tc.Alarm, upper || lower)
+			if tc.Alarm {
+				assert.Equal(t, tc.Side == Upper, upper)
+				assert.Equal(t, tc.Side == Lower, lower)
+			}
+		})
+	}
+
+	_, err := NewLogNormalTest(metric.NewName("test", nil), WithDeadband(-1.0))
+	assert.Error(t, err)
+	assert.Error(t, DefaultLogNormalEWMA().SetDeadband(math.NaN()))
+}
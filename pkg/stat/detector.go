@@ -0,0 +1,109 @@
+package stat
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/metric"
+)
+
+// Detector is a single EWMA test statistic that manages its own lifecycle.  It collects observations to establish a
+// baseline, tests each following observation against the upper control limit, and remains alarmed until it is reset.
+// Use a Test when the statistic states need to be managed directly.
+type Detector struct {
+	stat *TestStatistic
+}
+
+// NewLogNormalDetector returns a detector for log normally distributed observations, such as latency, that establishes
+// a baseline from capacity observations.  Lambda is the weight of each new observation in the EWMA statistic and
+// errorRate is the approximate Type I error rate of the control limit.
+func NewLogNormalDetector(capacity int, lambda float64, errorRate float64) (*Detector, error) {
+	if err := validateDetector(capacity, lambda, errorRate); err != nil {
+		return nil, fmt.Errorf("failed to create log normal detector: %v", err)
+	}
+	return newDetector("log-normal", lambda, NewLogNormal(capacity, KErrorRate(errorRate)))
+}
+
+// NewPoissonDetector returns a detector for countable observations, such as errors per minute, that are summed over
+// each sampleWindow.  The baseline is established from capacity samples.  Lambda is the weight of each new observation
+// in the EWMA statistic and errorRate is the approximate Type I error rate of the control limit.  Done must be called
+// when the detector is no longer used.
+func NewPoissonDetector(capacity int, sampleWindow time.Duration, lambda float64, errorRate float64) (*Detector, error) {
+	if err := validateDetector(capacity, lambda, errorRate); err != nil {
+		return nil, fmt.Errorf("failed to create poisson detector: %v", err)
+	}
+	if sampleWindow <= 0 {
+		return nil, fmt.Errorf("failed to create poisson detector: sample window must be positive, got %s", sampleWindow)
+	}
+	return newDetector("poisson", lambda, NewPoisson(capacity, sampleWindow, metric.SampleSum, KErrorRate(errorRate)))
+}
+
+func newDetector(name string, lambda float64, pdf PDF) (*Detector, error) {
+	stat, err := NewEWMAStatistic(name, lambda, pdf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s detector: %v", name, err)
+	}
+	return &Detector{stat: stat}, nil
+}
+
+// validateDetector checks the parameters common to all detectors
+func validateDetector(capacity int, lambda float64, errorRate float64) error {
+	switch {
+	case capacity < 1:
+		return fmt.Errorf("capacity must be at least 1, got %d", capacity)
+	case lambda <= 0.0 || lambda > 1.0:
+		return fmt.Errorf("lambda must be greater than 0 and at most 1, got %g", lambda)
+	case errorRate <= 0.0 || errorRate >= 1.0:
+		return fmt.Errorf("error rate must be between 0 and 1, got %g", errorRate)
+	}
+	return nil
+}
+
+// Record tests an observation and returns true if the detector has alarmed.  Observations are used to establish the
+// baseline until it is full.  Once alarmed, the detector ignores observations and returns true until it is reset.
+func (d *Detector) Record(obs float64) (bool, error) {
+	if d.stat.HasAlarmed() {
+		return true, nil
+	}
+	if err := d.stat.Record(obs); err != nil {
+		return false, err
+	}
+	return d.stat.HasAlarmed(), nil
+}
+
+// Reset clears the alarm and the baseline so that the detector establishes a new baseline from the following
+// observations
+func (d *Detector) Reset() error {
+	if d.stat.State() != Reset {
+		if err := d.stat.Transition(Reset, true); err != nil {
+			return fmt.Errorf("failed to reset detector: %v", err)
+		}
+	}
+	if err := d.stat.Transition(UCLInitial, false); err != nil {
+		return fmt.Errorf("failed to reset detector: %v", err)
+	}
+	d.stat.current = 0.0
+	d.stat.limit = 0.0
+	return nil
+}
+
+// CurrentValue returns the current value of the statistic, in the units of the transformed observations.  It is zero
+// until the baseline is full.
+func (d *Detector) CurrentValue() float64 {
+	return d.stat.Value()
+}
+
+// Limit returns the upper control limit above which the detector alarms.  It is zero until the baseline is full.
+func (d *Detector) Limit() float64 {
+	return d.stat.Limit()
+}
+
+// BaselineFull returns true once the detector has established a baseline and is testing observations
+func (d *Detector) BaselineFull() bool {
+	return d.stat.baseline
+}
+
+// Done stops the detector from sampling observations
+func (d *Detector) Done() {
+	d.stat.Done()
+}
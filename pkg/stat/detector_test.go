@@ -0,0 +1,92 @@
+package stat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectorLifecycle(t *testing.T) {
+	d, err := NewLogNormalDetector(50, 0.25, 0.05)
+	if err != nil {
+		t.Fatalf("unexpected error creating detector: %v", err)
+	}
+	defer d.Done()
+
+	for _, obs := range randNorm(50, 5.2983, 1.0, logNormalTransform) {
+		assert.False(t, d.BaselineFull())
+		alarmed, err := d.Record(obs)
+		assert.NoError(t, err)
+		assert.False(t, alarmed)
+	}
+	assert.True(t, d.BaselineFull())
+	assert.True(t, d.Limit() > d.CurrentValue())
+
+	var alarmed bool
+	for _, obs := range randNorm(2000, 8.0, 1.0, logNormalTransform) {
+		if alarmed, err = d.Record(obs); alarmed {
+			break
+		}
+	}
+	assert.NoError(t, err)
+	assert.True(t, alarmed)
+	// remains alarmed until reset
+	alarmed, err = d.Record(1.0)
+	assert.NoError(t, err)
+	assert.True(t, alarmed)
+
+	assert.NoError(t, d.Reset())
+	assert.False(t, d.BaselineFull())
+	assert.Equal(t, 0.0, d.CurrentValue())
+	assert.Equal(t, 0.0, d.Limit())
+	for _, obs := range randNorm(50, 8.0, 1.0, logNormalTransform) {
+		alarmed, err := d.Record(obs)
+		assert.NoError(t, err)
+		assert.False(t, alarmed)
+	}
+	assert.True(t, d.BaselineFull())
+	assert.InDelta(t, 8.0, d.CurrentValue(), 1.0)
+
+	// resetting while collecting the baseline starts over
+	assert.NoError(t, d.Reset())
+	assert.NoError(t, d.Reset())
+	assert.False(t, d.BaselineFull())
+}
+
+func TestDetectorConstruction(t *testing.T) {
+	tt := []struct {
+		Name      string
+		Capacity  int
+		Window    time.Duration
+		Lambda    float64
+		ErrorRate float64
+		Err       bool
+	}{
+		{Name: "valid", Capacity: 50, Window: time.Second, Lambda: 0.25, ErrorRate: 0.05},
+		{Name: "shewart", Capacity: 50, Window: time.Second, Lambda: 1.0, ErrorRate: 0.05},
+		{Name: "zero capacity", Capacity: 0, Window: time.Second, Lambda: 0.25, ErrorRate: 0.05, Err: true},
+		{Name: "zero lambda", Capacity: 50, Window: time.Second, Lambda: 0.0, ErrorRate: 0.05, Err: true},
+		{Name: "lambda above one", Capacity: 50, Window: time.Second, Lambda: 1.5, ErrorRate: 0.05, Err: true},
+		{Name: "error rate of one", Capacity: 50, Window: time.Second, Lambda: 0.25, ErrorRate: 1.0, Err: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			ln, err := NewLogNormalDetector(tc.Capacity, tc.Lambda, tc.ErrorRate)
+			p, perr := NewPoissonDetector(tc.Capacity, tc.Window, tc.Lambda, tc.ErrorRate)
+			if tc.Err {
+				assert.Error(t, err)
+				assert.Error(t, perr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NoError(t, perr)
+			assert.False(t, ln.BaselineFull())
+			assert.False(t, p.BaselineFull())
+			p.Done()
+		})
+	}
+
+	_, err := NewPoissonDetector(50, 0, 0.25, 0.05)
+	assert.Error(t, err)
+}
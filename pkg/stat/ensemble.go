@@ -0,0 +1,123 @@
+package stat
+
+import (
+	"fmt"
+
+	"github.com/BTBurke/monny/pkg/fsm"
+	"github.com/BTBurke/monny/pkg/metric"
+)
+
+// VotingStrategy determines when an ensemble is in an alarm condition based on the tests it holds
+type VotingStrategy int
+
+const (
+	// AnyAlarm alarms when either test alarms, the same as the statistics within a test
+	AnyAlarm VotingStrategy = iota
+	// AllAlarm alarms only when both tests agree, which reduces false positives at the cost of detecting fewer changes
+	AllAlarm
+)
+
+func (v VotingStrategy) String() string {
+	switch v {
+	case AnyAlarm:
+		return "any"
+	case AllAlarm:
+		return "all"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(v))
+	}
+}
+
+// Ensemble combines a log normal test and a poisson test of the same service, such as request latency and error counts,
+// into a single alarm condition determined by the voting strategy.  Observations are recorded to each test separately
+// using RecordLogNormal and RecordPoisson.  Otherwise an ensemble has the same methods as a Test.
+type Ensemble struct {
+	name      metric.Name
+	logNormal *Test
+	poisson   *Test
+	strategy  VotingStrategy
+}
+
+// NewEnsemble returns an ensemble of a log normal test and a poisson test.  The tests must have different names so that
+// their metrics and alarm sources can be distinguished.
+func NewEnsemble(name metric.Name, logNormal *Test, poisson *Test, strategy VotingStrategy) (*Ensemble, error) {
+	if logNormal == nil || poisson == nil {
+		return nil, fmt.Errorf("ensemble %s requires both a log normal and a poisson test", name.String())
+	}
+	if logNormal.Name() == poisson.Name() {
+		return nil, fmt.Errorf("ensemble %s requires tests with different names, got %s for both", name.String(), logNormal.Name())
+	}
+	switch strategy {
+	case AnyAlarm, AllAlarm:
+	default:
+		return nil, fmt.Errorf("ensemble %s has unknown voting strategy %s", name.String(), strategy)
+	}
+	return &Ensemble{
+		name:      name,
+		logNormal: logNormal,
+		poisson:   poisson,
+		strategy:  strategy,
+	}, nil
+}
+
+func (e *Ensemble) Name() string {
+	return e.name.String()
+}
+
+// RecordLogNormal records an observation to the log normal test, such as a request latency
+func (e *Ensemble) RecordLogNormal(obs float64) error {
+	return e.logNormal.Record(obs)
+}
+
+// RecordPoisson records an observation to the poisson test, such as a count of errors
+func (e *Ensemble) RecordPoisson(obs float64) error {
+	return e.poisson.Record(obs)
+}
+
+// State returns the states of the log normal statistics followed by the poisson statistics
+func (e *Ensemble) State() []fsm.State {
+	return append(e.logNormal.State(), e.poisson.State()...)
+}
+
+// Transition attempts to transition both tests to the desired state
+func (e *Ensemble) Transition(state fsm.State, reset bool) error {
+	if err := e.logNormal.Transition(state, reset); err != nil {
+		return err
+	}
+	return e.poisson.Transition(state, reset)
+}
+
+// HasAlarmed returns true if the tests have alarmed according to the voting strategy
+func (e *Ensemble) HasAlarmed() bool {
+	switch e.strategy {
+	case AllAlarm:
+		return e.logNormal.HasAlarmed() && e.poisson.HasAlarmed()
+	default:
+		return e.logNormal.HasAlarmed() || e.poisson.HasAlarmed()
+	}
+}
+
+// AlarmSources returns the names of the tests that have alarmed, regardless of the voting strategy
+func (e *Ensemble) AlarmSources() []string {
+	var out []string
+	for _, t := range []*Test{e.logNormal, e.poisson} {
+		if t.HasAlarmed() {
+			out = append(out, t.Name())
+		}
+	}
+	return out
+}
+
+// Metric returns the metrics of both tests.  See Test.Metric.
+func (e *Ensemble) Metric() map[string]float64 {
+	out := e.logNormal.Metric()
+	for k, v := range e.poisson.Metric() {
+		out[k] = v
+	}
+	return out
+}
+
+func (e *Ensemble) Done() {
+	e.logNormal.Done()
+	e.poisson.Done()
+}
@@ -0,0 +1,80 @@
+package stat
+
+import (
+	"testing"
+
+	"github.com/BTBurke/monny/pkg/metric"
+	"github.com/stretchr/testify/assert"
+)
+
+// trip forces each statistic in the test into an alarm condition
+func trip(t *testing.T, test *Test) {
+	for _, s := range test.sub {
+		assert.NoError(t, s.fsm.Transition(TestingUCL))
+		assert.NoError(t, s.fsm.Transition(UCLTrip))
+	}
+}
+
+func TestEnsemble(t *testing.T) {
+	tt := []struct {
+		Name      string
+		Strategy  VotingStrategy
+		LogNormal bool
+		Poisson   bool
+		Alarm     bool
+		Sources   []string
+	}{
+		{Name: "any none", Strategy: AnyAlarm},
+		{Name: "any one", Strategy: AnyAlarm, Poisson: true, Alarm: true, Sources: []string{"errors"}},
+		{Name: "any both", Strategy: AnyAlarm, LogNormal: true, Poisson: true, Alarm: true, Sources: []string{"latency", "errors"}},
+		{Name: "all none", Strategy: AllAlarm},
+		{Name: "all one", Strategy: AllAlarm, LogNormal: true, Sources: []string{"latency"}},
+		{Name: "all both", Strategy: AllAlarm, LogNormal: true, Poisson: true, Alarm: true, Sources: []string{"latency", "errors"}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			ln, _ := NewLogNormalTest(metric.NewName("latency", nil), WithStatistic(DefaultLogNormalEWMA()))
+			p, _ := NewPoissonTest(metric.NewName("errors", nil), WithStatistic(DefaultPoissonEWMA()))
+			e, err := NewEnsemble(metric.NewName("api", nil), ln, p, tc.Strategy)
+			if err != nil {
+				t.Fatalf("unexpected error creating ensemble: %v", err)
+			}
+			defer e.Done()
+			if tc.LogNormal {
+				trip(t, ln)
+			}
+			if tc.Poisson {
+				trip(t, p)
+			}
+			assert.Equal(t, tc.Alarm, e.HasAlarmed())
+			assert.Equal(t, tc.Sources, e.AlarmSources())
+
+			// transitioning the ensemble resets both tests
+			assert.NoError(t, e.Transition(Reset, true))
+			assert.False(t, e.HasAlarmed())
+			assert.Len(t, e.State(), 2)
+		})
+	}
+}
+
+func TestEnsembleConstruction(t *testing.T) {
+	ln, _ := NewLogNormalTest(metric.NewName("latency", nil), WithStatistic(DefaultLogNormalEWMA()))
+	p, _ := NewPoissonTest(metric.NewName("latency", nil), WithStatistic(DefaultPoissonEWMA()))
+	defer p.Done()
+
+	_, err := NewEnsemble(metric.NewName("api", nil), ln, nil, AnyAlarm)
+	assert.Error(t, err)
+	_, err = NewEnsemble(metric.NewName("api", nil), ln, p, AnyAlarm)
+	assert.Error(t, err)
+
+	p2, _ := NewPoissonTest(metric.NewName("errors", nil), WithStatistic(DefaultPoissonEWMA()))
+	defer p2.Done()
+	_, err = NewEnsemble(metric.NewName("api", nil), ln, p2, VotingStrategy(5))
+	assert.Error(t, err)
+
+	e, err := NewEnsemble(metric.NewName("api", nil), ln, p2, AllAlarm)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "api", e.Name())
+		assert.Len(t, e.Metric(), 4)
+	}
+}
@@ -16,6 +16,24 @@ type TestStatistic struct {
 	fsm     *fsm.Machine
 	current float64
 	pdf     PDF
+
+	baseline bool
+	ucl      float64
+	lcl      float64
+	chart    *chart
+
+	bootstrap bootstrap
+
+	// lower is true for statistics that test the lower control limit for a decrease from the baseline
+	lower bool
+	// movingRange is true for Shewhart statistics that estimate the baseline variance from the moving range
+	movingRange bool
+	// deadband is how far the current value must pass the limit to alarm
+	deadband float64
+	// sigma is the standard deviation of the statistic from the baseline
+	sigma float64
+	// runLength is the number of observations tested against the current limits
+	runLength int
 }
 
 func (e *TestStatistic) Name() string {
@@ -36,35 +54,53 @@ func (e *TestStatistic) Done() {
 
 func (e *TestStatistic) Record(o float64) error {
 	o = e.pdf.Transform(o)
-	if math.IsNaN(o) || math.IsInf(o, 1) || math.IsInf(o, -1) {
+	valid := !math.IsNaN(o) && !math.IsInf(o, 1) && !math.IsInf(o, -1)
+	if e.bootstrapping() {
+		defer func() {
+			if e.bootstrapping() {
+				e.recordAttempt(valid)
+			}
+		}()
+	}
+	if !valid {
 		return fmt.Errorf("transform(value) is not defined")
 	}
+	// a series with no capacity discards every observation, so the baseline would never be established
+	if e.series.Capacity() == 0 {
+		return fmt.Errorf("could not record observation for %s: %w", e.name, metric.ErrZeroCapacity)
+	}
 
 	e.series.Record(o)
 	switch e.fsm.State() {
 	case Reset:
 		// reset assumes that estimator should be restarted from steady state non-alarmed condition
-		// and next transition should be to testing the UCL
-		if err := e.fsm.Transition(UCLInitial); err != nil {
+		// and next transition should be to testing the UCL, or the LCL for a lower side statistic
+		if err := e.fsm.Transition(e.initialState()); err != nil {
 			return err
 		}
+		e.baseline = false
 		// if forced into reset with existing observations, start series recording over again
 		if e.series.Count() > 0 {
 			e.series.Reset()
 			// re-record the current observation after reseting the series
 			e.series.Record(o)
 		}
+		if e.lower {
+			break
+		}
 		fallthrough
 	case TestingUCL:
 		e.calculateCurrent(o)
-		if e.current >= e.limit {
+		e.runLength++
+		if e.tripped() {
 			if err := e.fsm.Transition(UCLTrip); err != nil {
 				return err
 			}
 		}
 	case TestingLCL:
 		e.calculateCurrent(o)
-		if e.current <= e.limit {
+		e.runLength++
+		if e.tripped() {
 			if err := e.fsm.Transition(LCLTrip); err != nil {
 				return err
 			}
@@ -73,32 +109,45 @@ func (e *TestStatistic) Record(o float64) error {
 		if e.series.Count() >= e.series.Capacity() {
 			values := e.series.Values()
 			mean := e.pdf.Mean(values)
-			variance := e.pdf.Variance(values, mean)
-			if mean > 0.0 && variance > 0.0 {
+			variance := e.baselineVariance(values, mean)
+			if e.acceptBaseline(mean, variance) {
 				if err := e.fsm.Transition(TestingUCL); err != nil {
 					return err
 				}
 				e.current = mean
-				e.limit = calculateLimit(mean, variance, e.lambda, e.pdf, 1)
+				e.setLimits(mean, variance)
+				e.limit = e.ucl
 			}
 		}
 	case LCLInitial:
 		if e.series.Count() >= e.series.Capacity() {
 			values := e.series.Values()
 			mean := e.pdf.Mean(values)
-			variance := e.pdf.Variance(values, mean)
-			if mean > 0.0 && variance > 0.0 {
+			variance := e.baselineVariance(values, mean)
+			if e.acceptBaseline(mean, variance) {
 				if err := e.fsm.Transition(TestingLCL); err != nil {
 					return err
 				}
 				e.current = mean
-				e.limit = calculateLimit(mean, variance, e.lambda, e.pdf, -1)
+				e.setLimits(mean, variance)
+				e.limit = e.lcl
 			}
 		}
 	}
+	e.recordChart(o)
 	return nil
 }
 
+// setLimits calculates both control limits from the baseline mean and variance.  The run length starts over from the
+// new limits.
+func (e *TestStatistic) setLimits(mean float64, variance float64) {
+	e.ucl = calculateLimit(mean, variance, e.lambda, e.pdf, 1)
+	e.lcl = calculateLimit(mean, variance, e.lambda, e.pdf, -1)
+	e.sigma = math.Sqrt((e.lambda / (2.0 - e.lambda)) * variance)
+	e.runLength = 0
+	e.baseline = true
+}
+
 // HasAlarmed returns true if the estimator has detected that the current value of the statistic has exceeded either
 // the UCL or LCL.  This will continue to return true until the estimator is manually transitioned to a new state.
 func (e *TestStatistic) HasAlarmed() bool {
@@ -115,6 +164,35 @@ func (e *TestStatistic) State() fsm.State {
 	return e.fsm.State()
 }
 
+// FSMHistory returns the most recent state transitions of the statistic from oldest to newest, such as the path
+// taken to an alarm
+func (e *TestStatistic) FSMHistory() []fsm.StateTransition {
+	return e.fsm.History()
+}
+
+// MergeSeries combines the observations recorded by other into the series of this statistic, such as when combining
+// baselines recorded by parallel workers.  The statistic state is not changed.  See metric.Series.Merge for merge options.
+func (e *TestStatistic) MergeSeries(other *TestStatistic, opts ...metric.MergeOption) error {
+	m, ok := e.series.(merger)
+	if !ok {
+		return fmt.Errorf("series for statistic %s does not support merging", e.name)
+	}
+	values := other.series.Values()
+	if n := other.series.Count(); n < len(values) {
+		values = values[0:n]
+	}
+	s, err := metric.NewSeries(other.series.Capacity(), metric.WithValues(values))
+	if err != nil {
+		return fmt.Errorf("unable to merge series from statistic %s: %v", other.name, err)
+	}
+	return m.Merge(s, opts...)
+}
+
+// merger is implemented by series that can merge observations from another series
+type merger interface {
+	Merge(other *metric.Series, opts ...metric.MergeOption) error
+}
+
 // caluculate the current value of the test statistic
 func (e *TestStatistic) calculateCurrent(o float64) {
 	e.current = (e.lambda * o) + ((1.0 - e.lambda) * e.current)
@@ -126,7 +204,17 @@ func (e *TestStatistic) Transition(state fsm.State, resetSeries bool) error {
 	if resetSeries {
 		e.series.Reset()
 	}
-	return e.fsm.Transition(state)
+	if err := e.fsm.Transition(state); err != nil {
+		return err
+	}
+	switch state {
+	case Reset, UCLInitial, LCLInitial:
+		// limits are no longer valid until a new baseline is established
+		e.baseline = false
+		e.runLength = 0
+		e.resetBootstrap()
+	}
+	return nil
 }
 
 // calculateLimit will determine the UCL or LCL limit (UCL => direction +1, LCL => direction -1)
@@ -152,19 +240,57 @@ func calculateLimit(mean float64, variance float64, lambda float64, pdf PDF, dir
 // NewEWMAStatistic returns a new EWMA test statistic.  Transform can be used to apply a function to each raw observation before
 // it is tested by the statistic.  e.g., for log-normally distributed observations, the transform would be math.Log(observation)
 func NewEWMAStatistic(name string, lambda float64, pdf PDF) (*TestStatistic, error) {
+	return newEWMAStatistic(name, lambda, pdf, false)
+}
+
+// newEWMAStatistic returns a new EWMA test statistic that tests the UCL, or the LCL if lower is true
+func newEWMAStatistic(name string, lambda float64, pdf PDF, lower bool) (*TestStatistic, error) {
 	series, err := pdf.NewSeries()
 	if err != nil {
 		return nil, fmt.Errorf("unable to create EWMA test statistic for %s: %v", pdf.String(), err)
 	}
-	machine, err := newMachine(UCLInitial)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create estimator FSM: %v", err)
-	}
-	return &TestStatistic{
+	e := &TestStatistic{
 		name:   name,
 		lambda: lambda,
 		series: series,
-		fsm:    machine,
 		pdf:    pdf,
-	}, nil
+		lower:  lower,
+	}
+	e.fsm, err = newMachine(e.initialState())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create estimator FSM: %v", err)
+	}
+	// start testing immediately when the baseline is already known
+	if b, ok := pdf.(baseliner); ok {
+		if mean, variance, seeded := b.Baseline(); seeded {
+			testing := TestingUCL
+			if lower {
+				testing = TestingLCL
+			}
+			if err := e.fsm.Transition(testing); err != nil {
+				return nil, fmt.Errorf("failed to start estimator from seeded baseline: %v", err)
+			}
+			e.current = mean
+			e.setLimits(mean, variance)
+			e.limit = e.ucl
+			if lower {
+				e.limit = e.lcl
+			}
+		}
+	}
+	return e, nil
+}
+
+// initialState is the state in which the statistic collects observations to establish a baseline
+func (e *TestStatistic) initialState() fsm.State {
+	if e.lower {
+		return LCLInitial
+	}
+	return UCLInitial
+}
+
+// baseliner is implemented by distributions that can provide a baseline mean and variance of transformed observations
+// without bootstrapping from observations
+type baseliner interface {
+	Baseline() (mean float64, variance float64, seeded bool)
 }
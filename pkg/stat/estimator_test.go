@@ -1,6 +1,7 @@
 package stat
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
@@ -8,41 +9,32 @@ import (
 	"time"
 
 	"github.com/BTBurke/monny/pkg/metric"
+	"github.com/BTBurke/monny/pkg/rng"
 	"github.com/stretchr/testify/assert"
 )
 
 // randNorm returns a []float64 array of normally distributed numbers with mean and stddev
 // optional transform function can be used to return log-normally distributed numbers
 func randNorm(length int, mean float64, stddev float64, transform func(float64) float64) []float64 {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	r := rng.NewNormalRNG(mean, stddev)
 	out := make([]float64, length)
 	for i := 0; i < length; i++ {
 		switch transform {
 		case nil:
-			out[i] = r.NormFloat64()*stddev + mean
+			out[i] = r.Rand()
 		default:
-			out[i] = transform(r.NormFloat64()*stddev + mean)
+			out[i] = transform(r.Rand())
 		}
 	}
 	return out
 }
 
-// randPoisson returns a []float64 array of poisson distributed values with the given lambda using
-// Knuth's algorithm
+// randPoisson returns a []float64 array of poisson distributed values with the given lambda
 func randPoisson(length int, lambda float64) []float64 {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	r := rng.NewPoissonRNG(lambda)
 	out := make([]float64, length)
 	for i := 0; i < length; i++ {
-		// algorithm by Knuth
-		L := math.Pow(math.E, -lambda)
-		var k int64 = 0
-		var p float64 = 1.0
-
-		for p > L {
-			k++
-			p = p * r.Float64()
-		}
-		out[i] = float64(k - 1)
+		out[i] = r.Rand()
 	}
 	return out
 }
@@ -58,8 +50,29 @@ func TestMean(t *testing.T) {
 
 func TestVariance(t *testing.T) {
 	values := []float64{1.0, 1.0, 1.0, 2.0, 2.0, 2.0}
-	assert.Equal(t, varianceNormal(values, 1.5), 0.3)
+	assert.InDelta(t, 0.3, varianceNormal(values, 1.5), 1e-12)
 	assert.Equal(t, variancePoisson(values, 1.5), 1.5)
+
+	// precision is maintained for small differences between large values
+	large := make([]float64, len(values))
+	for i, v := range values {
+		large[i] = 1e9 + v
+	}
+	assert.InDelta(t, 0.3, varianceNormal(large, meanNormal(large)), 1e-6)
+}
+
+func TestLogNormalSeriesVariance(t *testing.T) {
+	values := randNorm(200, 5.0, 0.5, logNormalTransform)
+	pdf := NewLogNormal(50, KFixed(3.0))
+	series, err := pdf.NewSeries()
+	if err != nil {
+		t.Fatalf("unexpected error creating series: %v", err)
+	}
+	for _, v := range values {
+		series.Record(pdf.Transform(v))
+	}
+	window := series.Values()
+	assert.InDelta(t, varianceNormal(window, meanNormal(window)), pdf.Variance(window, meanNormal(window)), 1e-9)
 }
 
 func TestLimitCalc(t *testing.T) {
@@ -106,6 +119,24 @@ func TestPMetric(t *testing.T) {
 	assert.Equal(t, exp, out)
 }
 
+func TestMergeSeries(t *testing.T) {
+	s1, _ := NewEWMAStatistic("ewma", 0.25, NewLogNormal(4, KFixed(3.0)))
+	s2, _ := NewEWMAStatistic("ewma", 0.25, NewLogNormal(4, KFixed(3.0)))
+	s3, _ := NewEWMAStatistic("ewma", 0.25, NewLogNormal(6, KFixed(3.0)))
+	for _, o := range []float64{1.0, math.E} {
+		assert.NoError(t, s1.Record(o))
+	}
+	for _, o := range []float64{math.E, 1.0} {
+		assert.NoError(t, s2.Record(o))
+	}
+
+	assert.NoError(t, s1.MergeSeries(s2))
+	assert.Equal(t, []float64{0.0, 1.0, 1.0, 0.0}, s1.series.Values())
+	assert.Error(t, s1.MergeSeries(s3))
+	assert.NoError(t, s1.MergeSeries(s3, metric.MergeForceCapacity()))
+	assert.Equal(t, 6, s1.series.Capacity())
+}
+
 func TestLogNormalEWMAEstimator(t *testing.T) {
 	gen := func(length int, mean float64) []float64 {
 		return randNorm(length, mean, 1.0, logNormalTransform)
@@ -124,6 +155,52 @@ func TestLogNormalEWMAEstimator(t *testing.T) {
 		}
 	}
 	assert.Equal(t, UCLTrip, ewma.State())
+	history := ewma.FSMHistory()
+	if assert.NotEmpty(t, history) {
+		assert.Equal(t, UCLInitial, history[0].From)
+		assert.Equal(t, UCLTrip, history[len(history)-1].To)
+	}
+}
+
+func TestLogNormalFromUnits(t *testing.T) {
+	// typical latency of 50ms with a spread of 20ms alarms on a single observation above ~148ms
+	sigma2 := math.Log(1.0 + (20.0*20.0)/(50.0*50.0))
+	threshold := math.Exp(math.Log(50.0) - sigma2/2.0 + 3.0*math.Sqrt(sigma2))
+	assert.InDelta(t, 147.5, threshold, 0.5)
+
+	tt := []struct {
+		Name  string
+		Obs   []float64
+		Alarm bool
+	}{
+		{Name: "typical", Obs: []float64{50.0, 35.0, 70.0, 45.0}, Alarm: false},
+		{Name: "below threshold", Obs: []float64{0.95 * threshold}, Alarm: false},
+		{Name: "above threshold", Obs: []float64{1.05 * threshold}, Alarm: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			pdf, err := LogNormalFromUnits(50, 50.0, 20.0, KFixed(3.0))
+			if err != nil {
+				t.Fatalf("unexpected error creating log normal: %v", err)
+			}
+			shewart, err := NewEWMAStatistic("shewart", 1.0, pdf)
+			if err != nil {
+				t.Fatalf("unexpected error creating statistic: %v", err)
+			}
+			// testing starts from the seeded baseline without bootstrapping
+			assert.Equal(t, TestingUCL, shewart.State())
+			assert.InDelta(t, threshold, math.Exp(shewart.Limit()), 1e-6)
+			for _, o := range tc.Obs {
+				assert.NoError(t, shewart.Record(o))
+			}
+			assert.Equal(t, tc.Alarm, shewart.HasAlarmed())
+		})
+	}
+
+	_, err := LogNormalFromUnits(50, 0.0, 20.0, KFixed(3.0))
+	assert.Error(t, err)
+	_, err = LogNormalFromUnits(50, 50.0, -1.0, KFixed(3.0))
+	assert.Error(t, err)
 }
 
 func TestPoissonEWMAEstimator(t *testing.T) {
@@ -133,14 +210,17 @@ func TestPoissonEWMAEstimator(t *testing.T) {
 	series := make([]float64, 0)
 	series = append(append(series, gen(100, 5)...), gen(600, 10)...)
 
-	testStat, _ := NewEWMAStatistic("ewma", 0.25, NewPoisson(50, 10*time.Millisecond, metric.SampleMax, KErrorRate(0.05)))
+	ticker := metric.NewManualTicker()
+	pdf := NewPoisson(50, 10*time.Millisecond, metric.SampleMax, KErrorRate(0.05))
+	pdf.ticker = ticker
+	testStat, _ := NewEWMAStatistic("ewma", 0.25, pdf)
 	est, _ := NewPoissonTest(metric.NewName("test", nil), WithStatistic(testStat))
 	ewma := est.sub[0]
 	for i, s := range series {
 		if err := ewma.Record(s); err != nil {
 			t.Fail()
 		}
-		time.Sleep(10 * time.Millisecond)
+		ticker.Tick()
 		if i == 51 {
 			assert.Equal(t, TestingUCL, ewma.State())
 		}
@@ -148,6 +228,37 @@ func TestPoissonEWMAEstimator(t *testing.T) {
 	assert.Equal(t, UCLTrip, ewma.State())
 }
 
+func TestPoissonWindowTransitions(t *testing.T) {
+	ticker := metric.NewManualTicker()
+	pdf := NewPoisson(50, time.Minute, metric.SampleSum, KErrorRate(0.05))
+	pdf.ticker = ticker
+	shewart, err := NewEWMAStatistic("shewart", 1.0, pdf)
+	if err != nil {
+		t.Fatalf("unexpected error creating statistic: %v", err)
+	}
+	defer shewart.Done()
+
+	// each window closes with a count of 4 or 6 so the baseline mean is exactly 5
+	for i := 0; i < 50; i++ {
+		assert.NoError(t, shewart.Record(float64(4+2*(i%2))))
+		assert.Equal(t, UCLInitial, shewart.State())
+		ticker.Tick()
+	}
+	// the baseline is established on the first observation after the 50th window closes
+	assert.NoError(t, shewart.Record(5))
+	assert.Equal(t, TestingUCL, shewart.State())
+	assert.Equal(t, 5.0, shewart.Value())
+	ticker.Tick()
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, shewart.Record(6))
+		assert.Equal(t, TestingUCL, shewart.State())
+		ticker.Tick()
+	}
+	assert.NoError(t, shewart.Record(shewart.Limit()))
+	assert.Equal(t, UCLTrip, shewart.State())
+}
+
 // Measures the average number of samples to detect shifts in the mean of a log normal process. Test cases are represented as an increase
 // in the mean as a multiple of the standard deviation.
 func BenchmarkLogNormalEWMA(b *testing.B) {
@@ -341,3 +452,28 @@ func BenchmarkPoissonEWMA(b *testing.B) {
 // })
 // }
 // }
+
+func TestStateSummary(t *testing.T) {
+	ln, _ := NewLogNormalTest(metric.NewName("test", nil))
+	assert.Equal(t, map[string]string{"ewma": "ucl_initial", "shewart": "ucl_initial"}, ln.StateSummary())
+	assert.NoError(t, ln.sub[1].fsm.Transition(TestingUCL))
+	assert.NoError(t, ln.sub[1].fsm.Transition(UCLTrip))
+	assert.Equal(t, map[string]string{"ewma": "ucl_initial", "shewart": "ucl_trip"}, ln.StateSummary())
+
+	p, _ := NewPoissonTest(metric.NewName("test", nil), WithStatistic(DefaultPoissonEWMA()), WithTwoSided())
+	defer p.Done()
+	assert.Equal(t, map[string]string{"ewma:upper": "ucl_initial", "ewma:lower": "lcl_initial"}, p.StateSummary())
+}
+
+func TestZeroCapacitySeries(t *testing.T) {
+	est, _ := NewLogNormalTest(metric.NewName("test", nil), WithStatistic(DefaultLogNormalEWMA()))
+	defer est.Done()
+	ewma := est.sub[0]
+	// the zero value of a series bypasses the capacity check of NewSeries
+	ewma.series = &metric.Series{}
+	err := ewma.Record(100.0)
+	if assert.Error(t, err) {
+		assert.True(t, errors.Is(err, metric.ErrZeroCapacity))
+	}
+	assert.Equal(t, UCLInitial, ewma.State())
+}
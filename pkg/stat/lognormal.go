@@ -18,6 +18,16 @@ func NewLogNormalTest(name metric.Name, opts ...TestOption) (*Test, error) {
 	if len(e.sub) == 0 {
 		e.sub = append(e.sub, DefaultLogNormalEWMA(), DefaultLogNormalShewart())
 	}
+	if err := e.addLowerSide(); err != nil {
+		return nil, fmt.Errorf("failed to apply option to log normal test: %v", err)
+	}
+	if err := e.setDeadband(); err != nil {
+		return nil, fmt.Errorf("failed to apply option to log normal test: %v", err)
+	}
+	e.configureBootstrap()
+	if err := e.enableChartData(); err != nil {
+		return nil, fmt.Errorf("failed to apply option to log normal test: %v", err)
+	}
 	return e, nil
 }
 
@@ -39,6 +39,9 @@ type Poisson struct {
 	strategy func([]float64) float64
 	done     func()
 	k        K
+
+	// ticker ends each sample window instead of the wall clock when set, for tests
+	ticker metric.Ticker
 }
 
 func (p *Poisson) Mean(obs []float64) float64 {
@@ -50,7 +53,15 @@ func (p *Poisson) Variance(obs []float64, mean float64) float64 {
 }
 
 func (p *Poisson) NewSeries() (metric.SeriesRecorder, error) {
-	series, done, err := metric.NewSampledSeries(p.capacity, p.window, p.strategy)
+	var series *metric.SampledSeries
+	var done func()
+	var err error
+	switch {
+	case p.ticker != nil:
+		series, done, err = metric.NewSampledSeriesWithTicker(p.capacity, p.ticker, p.strategy)
+	default:
+		series, done, err = metric.NewSampledSeries(p.capacity, p.window, p.strategy)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Poisson PDF estimator: %v", err)
 	}
@@ -90,18 +101,33 @@ func NewPoisson(capacity int, sampleWindow time.Duration, strategy func([]float6
 type LogNormal struct {
 	capacity int
 	k        K
+	series   *metric.ConcurrentSeries
+
+	// seeded baseline in log space, when constructed from original units
+	seeded   bool
+	mean     float64
+	variance float64
 }
 
 func (p *LogNormal) Mean(obs []float64) float64 {
 	return meanNormal(obs)
 }
 
+// Variance returns the running variance of the series when it holds the observations, otherwise the variance of obs
 func (p *LogNormal) Variance(obs []float64, mean float64) float64 {
+	if p.series != nil && p.series.Count() >= len(obs) {
+		return p.series.Variance()
+	}
 	return varianceNormal(obs, mean)
 }
 
 func (p *LogNormal) NewSeries() (metric.SeriesRecorder, error) {
-	return metric.NewSeries(p.capacity)
+	series, err := metric.NewConcurrentSeries(p.capacity)
+	if err != nil {
+		return nil, err
+	}
+	p.series = series
+	return series, nil
 }
 
 func (p *LogNormal) Transform(obs float64) float64 {
@@ -127,6 +153,30 @@ func NewLogNormal(capacity int, k K) *LogNormal {
 	}
 }
 
+// LogNormalFromUnits returns a log normal estimator with a baseline seeded from the expected mean and standard deviation
+// of observations in original units (e.g., latency of ~50ms with a spread of 20ms), so that testing starts without
+// waiting for capacity observations.  Capacity observations are used to establish a new baseline if the estimator is
+// reset.
+func LogNormalFromUnits(capacity int, typicalValue, spread float64, k K) (*LogNormal, error) {
+	if typicalValue <= 0.0 || spread <= 0.0 {
+		return nil, fmt.Errorf("log normal typical value and spread must be positive, got %f and %f", typicalValue, spread)
+	}
+	// match the moments of the log normal distribution to the mean and variance in original units
+	variance := math.Log(1.0 + (spread*spread)/(typicalValue*typicalValue))
+	return &LogNormal{
+		capacity: capacity,
+		k:        k,
+		seeded:   true,
+		mean:     math.Log(typicalValue) - variance/2.0,
+		variance: variance,
+	}, nil
+}
+
+// Baseline returns the seeded mean and variance of the transformed observations, if any
+func (p *LogNormal) Baseline() (float64, float64, bool) {
+	return p.mean, p.variance, p.seeded
+}
+
 func meanNormal(values []float64) float64 {
 	if len(values) == 0 {
 		return 0.0
@@ -138,12 +188,17 @@ func meanNormal(values []float64) float64 {
 	return s / float64(len(values))
 }
 
+// varianceNormal returns the sample variance of values using Welford's online algorithm, which is numerically stable
+// for large samples and values of very different magnitudes.  The mean is accumulated with the variance.
 func varianceNormal(values []float64, mean float64) float64 {
-	s := 0.0
+	var n, m, m2 float64
 	for _, v := range values {
-		s = s + math.Pow(v-mean, 2)
+		n++
+		delta := v - m
+		m += delta / n
+		m2 += delta * (v - m)
 	}
-	return s / float64(len(values)-1)
+	return m2 / (n - 1)
 }
 
 func meanPoisson(values []float64) float64 {
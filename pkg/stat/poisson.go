@@ -19,6 +19,16 @@ func NewPoissonTest(name metric.Name, opts ...TestOption) (*Test, error) {
 	if len(e.sub) == 0 {
 		e.sub = append(e.sub, DefaultPoissonEWMA(), DefaultPoissonShewart())
 	}
+	if err := e.addLowerSide(); err != nil {
+		return nil, fmt.Errorf("failed to apply option to poisson test: %v", err)
+	}
+	if err := e.setDeadband(); err != nil {
+		return nil, fmt.Errorf("failed to apply option to poisson test: %v", err)
+	}
+	e.configureBootstrap()
+	if err := e.enableChartData(); err != nil {
+		return nil, fmt.Errorf("failed to apply option to poisson test: %v", err)
+	}
 	return e, nil
 }
 
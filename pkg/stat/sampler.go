@@ -0,0 +1,98 @@
+package stat
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// sampler limits the observations that are recorded by a test to protect the estimators when the input
+// rate is high (e.g., a metric extracted from thousands of log lines per second).  Observations are randomly
+// sampled so that periodic patterns in the input do not bias the series, and then limited to a maximum number
+// of observations per period.
+type sampler struct {
+	mu       sync.Mutex
+	r        *rand.Rand
+	every    int
+	limit    int
+	period   time.Duration
+	window   time.Time
+	inWindow int
+	recorded uint64
+	dropped  uint64
+	now      func() time.Time
+}
+
+func newSampler() *sampler {
+	return &sampler{
+		r:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		now: time.Now,
+	}
+}
+
+// allow returns true if the observation should be recorded
+func (s *sampler) allow() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.every > 1 && s.r.Intn(s.every) != 0 {
+		s.dropped++
+		return false
+	}
+	if s.limit > 0 {
+		now := s.now()
+		if now.Sub(s.window) >= s.period {
+			s.window = now
+			s.inWindow = 0
+		}
+		if s.inWindow >= s.limit {
+			s.dropped++
+			return false
+		}
+		s.inWindow++
+	}
+	s.recorded++
+	return true
+}
+
+// WithSampling records on average 1 in every k observations.  Each observation is recorded with probability 1/k
+// so that the sampled series has the same distribution as the input.
+func WithSampling(k int) TestOption {
+	return func(t *Test) error {
+		if k < 1 {
+			return fmt.Errorf("sampling rate must be at least 1, got %d", k)
+		}
+		if t.sampler == nil {
+			t.sampler = newSampler()
+		}
+		t.sampler.every = k
+		return nil
+	}
+}
+
+// WithRateLimit records at most n observations per period.  Observations in excess of the limit are dropped
+// until the next period begins.  When used with WithSampling, the limit applies to the sampled observations.
+func WithRateLimit(n int, period time.Duration) TestOption {
+	return func(t *Test) error {
+		if n < 1 || period <= 0 {
+			return fmt.Errorf("rate limit must be at least 1 observation over a positive period, got %d per %s", n, period)
+		}
+		if t.sampler == nil {
+			t.sampler = newSampler()
+		}
+		t.sampler.limit = n
+		t.sampler.period = period
+		return nil
+	}
+}
+
+// Sampled returns the number of observations recorded and dropped when sampling or rate limiting is enabled
+func (t *Test) Sampled() (recorded uint64, dropped uint64) {
+	if t.sampler == nil {
+		return 0, 0
+	}
+	t.sampler.mu.Lock()
+	defer t.sampler.mu.Unlock()
+	return t.sampler.recorded, t.sampler.dropped
+}
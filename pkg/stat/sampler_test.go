@@ -0,0 +1,125 @@
+package stat
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/BTBurke/monny/pkg/metric"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSamplerOptions(t *testing.T) {
+	tt := []struct {
+		name string
+		opt  TestOption
+		err  bool
+	}{
+		{name: "sampling", opt: WithSampling(10)},
+		{name: "sampling every observation", opt: WithSampling(1)},
+		{name: "sampling invalid", opt: WithSampling(0), err: true},
+		{name: "rate limit", opt: WithRateLimit(100, time.Second)},
+		{name: "rate limit invalid quantity", opt: WithRateLimit(0, time.Second), err: true},
+		{name: "rate limit invalid period", opt: WithRateLimit(100, 0), err: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewLogNormalTest(metric.NewName("test", nil), tc.opt)
+			switch tc.err {
+			case true:
+				assert.Error(t, err)
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSampling(t *testing.T) {
+	n := 100000
+	test, err := NewLogNormalTest(metric.NewName("test", nil), WithStatistic(DefaultLogNormalEWMA()), WithSampling(100))
+	if err != nil {
+		t.Fatalf("unexpected error creating test: %v", err)
+	}
+	for _, obs := range randNorm(n, 5.2983, 1.0, logNormalTransform) {
+		assert.NoError(t, test.Record(obs))
+	}
+	recorded, dropped := test.Sampled()
+	assert.Equal(t, uint64(n), recorded+dropped)
+	assert.InDelta(t, 1000, recorded, 150)
+}
+
+func TestRateLimit(t *testing.T) {
+	test, err := NewLogNormalTest(metric.NewName("test", nil), WithStatistic(DefaultLogNormalEWMA()), WithRateLimit(10, time.Second))
+	if err != nil {
+		t.Fatalf("unexpected error creating test: %v", err)
+	}
+	now := time.Now()
+	test.sampler.now = func() time.Time { return now }
+
+	for _, obs := range randNorm(50, 5.2983, 1.0, logNormalTransform) {
+		assert.NoError(t, test.Record(obs))
+	}
+	recorded, dropped := test.Sampled()
+	assert.Equal(t, uint64(10), recorded)
+	assert.Equal(t, uint64(40), dropped)
+	assert.Equal(t, 10, test.sub[0].series.Count())
+
+	now = now.Add(time.Second)
+	for _, obs := range randNorm(50, 5.2983, 1.0, logNormalTransform) {
+		assert.NoError(t, test.Record(obs))
+	}
+	recorded, dropped = test.Sampled()
+	assert.Equal(t, uint64(20), recorded)
+	assert.Equal(t, uint64(80), dropped)
+}
+
+// Estimator should keep up with a flood of observations when sampling is enabled and still establish a baseline
+// from the sampled observations
+func TestSampledHighRateInput(t *testing.T) {
+	n := 1000000
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	test, err := NewLogNormalTest(metric.NewName("test", nil), WithStatistic(DefaultLogNormalEWMA()), WithSampling(1000))
+	if err != nil {
+		t.Fatalf("unexpected error creating test: %v", err)
+	}
+
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		if err := test.Record(math.Exp(r.NormFloat64() + 5.2983)); err != nil {
+			t.Fatalf("unexpected error recording observation: %v", err)
+		}
+	}
+	elapsed := time.Since(start)
+
+	recorded, dropped := test.Sampled()
+	assert.Equal(t, uint64(n), recorded+dropped)
+	assert.True(t, recorded > 50, "expected enough sampled observations to establish a baseline, got %d", recorded)
+	assert.Equal(t, TestingUCL, test.sub[0].State())
+	assert.True(t, elapsed < 10*time.Second, "sampled test took %s to record %d observations", elapsed, n)
+}
+
+func BenchmarkRecordSampled(b *testing.B) {
+	tt := []struct {
+		name string
+		opts []TestOption
+	}{
+		{name: "no sampling"},
+		{name: "1 in 100", opts: []TestOption{WithSampling(100)}},
+		{name: "1000/s", opts: []TestOption{WithRateLimit(1000, time.Second)}},
+	}
+	for _, tc := range tt {
+		b.Run(tc.name, func(b *testing.B) {
+			opts := append(tc.opts, WithStatistic(DefaultLogNormalEWMA()))
+			test, _ := NewLogNormalTest(metric.NewName("benchmark", nil), opts...)
+			obs := randNorm(1000, 5.2983, 1.0, logNormalTransform)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := test.Record(obs[i%len(obs)]); err != nil {
+					b.Fail()
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package stat
+
+import (
+	"fmt"
+	"time"
+)
+
+// Seasonality divides time into buckets that repeat, such as each hour of the day, so that observations are tested
+// against a baseline established at the same time in earlier periods.  Buckets are selected in the location of the
+// timestamp of the observation.
+type Seasonality struct {
+	name    string
+	buckets int
+	bucket  func(t time.Time) int
+}
+
+// HourOfDay keeps a baseline for each hour of the day, for metrics with a daily pattern such as request rate
+func HourOfDay() Seasonality {
+	return Seasonality{
+		name:    "hour-of-day",
+		buckets: 24,
+		bucket:  func(t time.Time) int { return t.Hour() },
+	}
+}
+
+// DayOfWeek keeps a baseline for each day of the week, for metrics that differ on weekends
+func DayOfWeek() Seasonality {
+	return Seasonality{
+		name:    "day-of-week",
+		buckets: 7,
+		bucket:  func(t time.Time) int { return int(t.Weekday()) },
+	}
+}
+
+// HourOfWeek keeps a baseline for each hour of each day of the week, for metrics with both a daily and weekly pattern.
+// It takes a week of observations to establish every baseline.
+func HourOfWeek() Seasonality {
+	return Seasonality{
+		name:    "hour-of-week",
+		buckets: 7 * 24,
+		bucket:  func(t time.Time) int { return int(t.Weekday())*24 + t.Hour() },
+	}
+}
+
+// Buckets returns the number of buckets in each period
+func (s Seasonality) Buckets() int {
+	return s.buckets
+}
+
+// Bucket returns the bucket of an observation made at t
+func (s Seasonality) Bucket(t time.Time) int {
+	return s.bucket(t)
+}
+
+func (s Seasonality) String() string {
+	return s.name
+}
+
+// SeasonalDetector tests observations against the baseline of their bucket instead of a single baseline, so that
+// predictable peaks such as the busiest hour of the day do not alarm.  Each bucket is a Detector that establishes its
+// own baseline from the observations made during that bucket and remains alarmed until it is reset.
+type SeasonalDetector struct {
+	season    Seasonality
+	detectors []*Detector
+}
+
+// NewSeasonalLogNormalDetector returns a seasonal detector for log normally distributed observations, such as latency
+// or request rate.  The baseline of each bucket is established from capacity observations.  Lambda is the weight of
+// each new observation in the EWMA statistic and errorRate is the approximate Type I error rate of the control limit.
+func NewSeasonalLogNormalDetector(season Seasonality, capacity int, lambda float64, errorRate float64) (*SeasonalDetector, error) {
+	if season.buckets < 1 || season.bucket == nil {
+		return nil, fmt.Errorf("failed to create seasonal log normal detector: seasonality has no buckets")
+	}
+	if err := validateDetector(capacity, lambda, errorRate); err != nil {
+		return nil, fmt.Errorf("failed to create seasonal log normal detector: %v", err)
+	}
+	d := &SeasonalDetector{season: season, detectors: make([]*Detector, season.buckets)}
+	for i := range d.detectors {
+		detector, err := newDetector("log-normal", lambda, NewLogNormal(capacity, KErrorRate(errorRate)))
+		if err != nil {
+			return nil, err
+		}
+		d.detectors[i] = detector
+	}
+	return d, nil
+}
+
+// Record tests an observation made at t against the baseline of its bucket and returns true if that bucket has
+// alarmed.  Observations are used to establish the baseline of the bucket until it is full.
+func (d *SeasonalDetector) Record(t time.Time, obs float64) (bool, error) {
+	detector, err := d.Bucket(t)
+	if err != nil {
+		return false, err
+	}
+	return detector.Record(obs)
+}
+
+// Bucket returns the detector for the bucket of an observation made at t, such as to read its current value and limit
+func (d *SeasonalDetector) Bucket(t time.Time) (*Detector, error) {
+	b := d.season.Bucket(t)
+	if b < 0 || b >= len(d.detectors) {
+		return nil, fmt.Errorf("bucket %d of %s at %s is out of range", b, d.season, t)
+	}
+	return d.detectors[b], nil
+}
+
+// HasAlarmed returns true if any bucket has alarmed
+func (d *SeasonalDetector) HasAlarmed() bool {
+	for _, detector := range d.detectors {
+		if detector.stat.HasAlarmed() {
+			return true
+		}
+	}
+	return false
+}
+
+// BaselineFull returns true once every bucket has established a baseline
+func (d *SeasonalDetector) BaselineFull() bool {
+	for _, detector := range d.detectors {
+		if !detector.BaselineFull() {
+			return false
+		}
+	}
+	return true
+}
+
+// Reset clears the alarm and baseline of every bucket
+func (d *SeasonalDetector) Reset() error {
+	for i, detector := range d.detectors {
+		if err := detector.Reset(); err != nil {
+			return fmt.Errorf("failed to reset bucket %d of %s: %v", i, d.season, err)
+		}
+	}
+	return nil
+}
+
+// Done stops the detector of every bucket
+func (d *SeasonalDetector) Done() {
+	for _, detector := range d.detectors {
+		detector.Done()
+	}
+}
@@ -0,0 +1,99 @@
+package stat
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeasonalDetector(t *testing.T) {
+	seasonal, err := NewSeasonalLogNormalDetector(HourOfDay(), 50, 0.25, 0.001)
+	if err != nil {
+		t.Fatalf("unexpected error creating detector: %v", err)
+	}
+	defer seasonal.Done()
+	single, err := NewLogNormalDetector(50, 0.25, 0.001)
+	if err != nil {
+		t.Fatalf("unexpected error creating detector: %v", err)
+	}
+	defer single.Done()
+
+	// the log of the request rate follows a daily sine wave from a trough of 4 after midnight to a peak of 6 at noon,
+	// with one observation each minute
+	start := time.Date(2020, time.March, 2, 0, 0, 0, 0, time.UTC)
+	level := func(hour int) float64 {
+		return 5.0 - math.Cos(2.0*math.Pi*(float64(hour)+0.5)/24.0)
+	}
+	var singleAlarmed bool
+	for day := 0; day < 2; day++ {
+		for hour := 0; hour < 24; hour++ {
+			for minute, obs := range randNorm(60, level(hour), 0.1, logNormalTransform) {
+				ts := start.Add(time.Duration(day*24+hour)*time.Hour + time.Duration(minute)*time.Minute)
+				alarmed, err := seasonal.Record(ts, obs)
+				assert.NoError(t, err)
+				if alarmed {
+					t.Fatalf("false alarm on day %d at %s", day+1, ts.Format("15:04"))
+				}
+				if a, _ := single.Record(obs); a {
+					singleAlarmed = true
+				}
+			}
+		}
+		assert.True(t, seasonal.BaselineFull())
+	}
+	assert.False(t, seasonal.HasAlarmed())
+	assert.True(t, singleAlarmed, "a single baseline should alarm at the daily peak")
+
+	peak, err := seasonal.Bucket(start.Add(12 * time.Hour))
+	assert.NoError(t, err)
+	trough, err := seasonal.Bucket(start)
+	assert.NoError(t, err)
+	assert.True(t, peak.Limit() > trough.Limit())
+
+	// a spike during the night is anomalous even though it is below the daily peak
+	spikeAt := start.Add(50 * time.Hour)
+	var alarmed bool
+	for minute, obs := range randNorm(60, level(2)+1.0, 0.1, logNormalTransform) {
+		if alarmed, err = seasonal.Record(spikeAt.Add(time.Duration(minute)*time.Minute), obs); alarmed {
+			break
+		}
+	}
+	assert.NoError(t, err)
+	assert.True(t, alarmed)
+	assert.True(t, seasonal.HasAlarmed())
+	bucket, _ := seasonal.Bucket(spikeAt)
+	assert.True(t, bucket.stat.HasAlarmed())
+	assert.False(t, peak.stat.HasAlarmed(), "only the bucket of the spike should alarm")
+
+	assert.NoError(t, seasonal.Reset())
+	assert.False(t, seasonal.HasAlarmed())
+	assert.False(t, seasonal.BaselineFull())
+}
+
+func TestSeasonality(t *testing.T) {
+	// Monday
+	ts := time.Date(2020, time.March, 2, 13, 30, 0, 0, time.UTC)
+	tt := []struct {
+		Name    string
+		Season  Seasonality
+		Buckets int
+		Bucket  int
+	}{
+		{Name: "hour of day", Season: HourOfDay(), Buckets: 24, Bucket: 13},
+		{Name: "day of week", Season: DayOfWeek(), Buckets: 7, Bucket: 1},
+		{Name: "hour of week", Season: HourOfWeek(), Buckets: 168, Bucket: 37},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			assert.Equal(t, tc.Buckets, tc.Season.Buckets())
+			assert.Equal(t, tc.Bucket, tc.Season.Bucket(ts))
+		})
+	}
+
+	_, err := NewSeasonalLogNormalDetector(Seasonality{}, 30, 0.25, 0.05)
+	assert.Error(t, err)
+	_, err = NewSeasonalLogNormalDetector(HourOfDay(), 0, 0.25, 0.05)
+	assert.Error(t, err)
+}
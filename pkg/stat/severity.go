@@ -0,0 +1,37 @@
+package stat
+
+import "math"
+
+// Severity returns the number of observations tested since the statistic started testing against its current limits,
+// which includes the observation that alarmed, and the distance of the current value beyond the limit in standard
+// deviations of the statistic.  The distance is negative while the current value is within the limit.  Both are zero
+// until a baseline is established.  After an alarm they are held at their values when the statistic alarmed.
+func (e *TestStatistic) Severity() (runLength int, distance float64) {
+	if !e.baseline || e.sigma == 0.0 {
+		return e.runLength, 0.0
+	}
+	if e.lower {
+		return e.runLength, (e.limit - e.current) / e.sigma
+	}
+	return e.runLength, (e.current - e.limit) / e.sigma
+}
+
+// Severity returns the run length and distance of the alarmed statistic furthest beyond its limit, so that marginal
+// alarms can be told apart from extreme ones.  Alarmed is false if no statistic has alarmed.  See
+// TestStatistic.Severity.
+func (t *Test) Severity() (runLength int, distance float64, alarmed bool) {
+	distance = math.Inf(-1)
+	for _, s := range t.sub {
+		if !s.HasAlarmed() {
+			continue
+		}
+		n, d := s.Severity()
+		if d > distance {
+			runLength, distance, alarmed = n, d, true
+		}
+	}
+	if !alarmed {
+		return 0, 0.0, false
+	}
+	return runLength, distance, true
+}
@@ -0,0 +1,81 @@
+package stat
+
+import (
+	"math"
+	"testing"
+
+	"github.com/BTBurke/monny/pkg/metric"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeverity(t *testing.T) {
+	pdf, err := LogNormalFromUnits(50, 50.0, 20.0, KFixed(3.0))
+	if err != nil {
+		t.Fatalf("unexpected error creating log normal: %v", err)
+	}
+	shewart, err := NewEWMAStatistic("shewart", 1.0, pdf)
+	if err != nil {
+		t.Fatalf("unexpected error creating statistic: %v", err)
+	}
+	test, err := NewLogNormalTest(metric.NewName("latency", nil), WithStatistic(shewart))
+	if err != nil {
+		t.Fatalf("unexpected error creating test: %v", err)
+	}
+	_, _, alarmed := test.Severity()
+	assert.False(t, alarmed)
+
+	// the standard deviation of a Shewhart statistic is the baseline standard deviation
+	_, variance, _ := pdf.Baseline()
+	sigma := math.Sqrt(variance)
+	for _, obs := range []float64{50.0, 45.0, 60.0} {
+		assert.NoError(t, test.Record(obs))
+	}
+	n, d := shewart.Severity()
+	assert.Equal(t, 3, n)
+	assert.InDelta(t, (math.Log(60.0)-shewart.Limit())/sigma, d, 1e-9)
+	assert.True(t, d < 0.0)
+	assert.Len(t, test.Metric(), 2, "severity is only reported after an alarm")
+
+	// an observation two standard deviations beyond the limit
+	assert.NoError(t, test.Record(math.Exp(shewart.Limit()+2.0*sigma)))
+	runLength, distance, alarmed := test.Severity()
+	assert.True(t, alarmed)
+	assert.Equal(t, 4, runLength)
+	assert.InDelta(t, 2.0, distance, 1e-9)
+
+	// severity is held once alarmed
+	assert.NoError(t, test.Record(50.0))
+	runLength, distance, _ = test.Severity()
+	assert.Equal(t, 4, runLength)
+	assert.InDelta(t, 2.0, distance, 1e-9)
+
+	out := test.Metric()
+	assert.Equal(t, 4.0, out["latency[strategy=shewart type=estimator value=run_length]"])
+	assert.InDelta(t, 2.0, out["latency[strategy=shewart type=estimator value=distance]"], 1e-9)
+
+	assert.NoError(t, test.Transition(LCLInitial, true))
+	n, d = shewart.Severity()
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 0.0, d)
+}
+
+func TestSeverityLowerSide(t *testing.T) {
+	pdf, err := LogNormalFromUnits(50, 50.0, 20.0, KFixed(3.0))
+	if err != nil {
+		t.Fatalf("unexpected error creating log normal: %v", err)
+	}
+	shewart, err := NewEWMAStatistic("shewart", 1.0, pdf)
+	if err != nil {
+		t.Fatalf("unexpected error creating statistic: %v", err)
+	}
+	lower, err := shewart.LowerSide()
+	if err != nil {
+		t.Fatalf("unexpected error creating lower side: %v", err)
+	}
+	_, variance, _ := pdf.Baseline()
+	assert.NoError(t, lower.Record(math.Exp(lower.Limit()-math.Sqrt(variance))))
+	assert.True(t, lower.HasAlarmed())
+	n, d := lower.Severity()
+	assert.Equal(t, 1, n)
+	assert.InDelta(t, 1.0, d, 1e-9)
+}
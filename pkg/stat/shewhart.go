@@ -0,0 +1,46 @@
+package stat
+
+import (
+	"fmt"
+	"math"
+)
+
+// d2 is the bias correction constant for estimating the standard deviation from the average moving range of two
+// consecutive observations
+const d2 = 1.128
+
+// NewShewhartStatistic returns a new Shewhart individuals statistic.  Each observation is tested against limits set at k
+// standard deviations from the baseline mean, where the standard deviation is estimated from the average moving range
+// of consecutive baseline observations instead of the baseline variance.  The moving range is less inflated than the
+// variance by a drift or step change during the baseline.  It differs from an EWMA statistic with lambda 1.0 only in how
+// the limits are estimated.
+func NewShewhartStatistic(name string, pdf PDF) (*TestStatistic, error) {
+	e, err := newEWMAStatistic(name, 1.0, pdf, false)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create Shewhart test statistic: %v", err)
+	}
+	e.movingRange = true
+	return e, nil
+}
+
+// baselineVariance returns the variance of the baseline observations used to set the control limits
+func (e *TestStatistic) baselineVariance(values []float64, mean float64) float64 {
+	if e.movingRange {
+		return movingRangeVariance(values)
+	}
+	return e.pdf.Variance(values, mean)
+}
+
+// movingRangeVariance estimates the variance from the average absolute difference of consecutive observations, ordered
+// from oldest to newest
+func movingRangeVariance(values []float64) float64 {
+	if len(values) < 2 {
+		return 0.0
+	}
+	var sum float64
+	for i := 1; i < len(values); i++ {
+		sum += math.Abs(values[i] - values[i-1])
+	}
+	sigma := sum / float64(len(values)-1) / d2
+	return sigma * sigma
+}
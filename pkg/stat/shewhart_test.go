@@ -0,0 +1,100 @@
+package stat
+
+import (
+	"math"
+	"testing"
+
+	"github.com/BTBurke/monny/pkg/metric"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMovingRangeVariance(t *testing.T) {
+	assert.Equal(t, 0.0, movingRangeVariance(nil))
+	assert.Equal(t, 0.0, movingRangeVariance([]float64{1.0}))
+	assert.InDelta(t, math.Pow(2.0/d2, 2), movingRangeVariance([]float64{1.0, 3.0, 1.0, 3.0}), 1e-9)
+}
+
+// shewhartTrial records the same observations into a Shewhart statistic and an EWMA statistic with lambda 1.0 and
+// returns whether each alarmed
+func shewhartTrial(t *testing.T, obs []float64) (shewhart bool, ewma bool) {
+	s, err := NewShewhartStatistic("shewhart", NewLogNormal(50, KFixed(3.0)))
+	if err != nil {
+		t.Fatalf("unexpected error creating statistic: %v", err)
+	}
+	e, err := NewEWMAStatistic("ewma", 1.0, NewLogNormal(50, KFixed(3.0)))
+	if err != nil {
+		t.Fatalf("unexpected error creating statistic: %v", err)
+	}
+	for _, o := range obs {
+		assert.NoError(t, s.Record(o))
+		assert.NoError(t, e.Record(o))
+	}
+	return s.HasAlarmed(), e.HasAlarmed()
+}
+
+func TestShewhartCompareEWMA(t *testing.T) {
+	tt := []struct {
+		Name string
+		// Shift of the mean after the baseline in standard deviations
+		Shift float64
+		// Min and Max fraction of trials that should alarm for both statistics
+		Min float64
+		Max float64
+	}{
+		// each point has a 0.00135 chance of exceeding the UCL, so about 13% of trials of 100 points false alarm
+		{Name: "false alarms", Shift: 0.0, Min: 0.02, Max: 0.30},
+		// a shift of 3 standard deviations exceeds the UCL half of the time, so nearly every trial of 20 points alarms
+		{Name: "detection", Shift: 3.0, Min: 0.95, Max: 1.0},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			trials := 300
+			var shewhart, ewma int
+			for i := 0; i < trials; i++ {
+				n := 100
+				if tc.Shift > 0 {
+					n = 20
+				}
+				obs := append(randNorm(50, 5.0, 0.1, logNormalTransform), randNorm(n, 5.0+tc.Shift*0.1, 0.1, logNormalTransform)...)
+				s, e := shewhartTrial(t, obs)
+				if s {
+					shewhart++
+				}
+				if e {
+					ewma++
+				}
+			}
+			// the moving range estimates the same standard deviation as the variance for independent observations
+			sRate, eRate := float64(shewhart)/float64(trials), float64(ewma)/float64(trials)
+			assert.True(t, sRate >= tc.Min && sRate <= tc.Max, "shewhart alarm rate %g", sRate)
+			assert.True(t, eRate >= tc.Min && eRate <= tc.Max, "ewma alarm rate %g", eRate)
+			assert.InDelta(t, eRate, sRate, 0.1)
+		})
+	}
+}
+
+func TestShewhartBaselineShift(t *testing.T) {
+	s, _ := NewShewhartStatistic("shewhart", NewLogNormal(50, KFixed(3.0)))
+	e, _ := NewEWMAStatistic("ewma", 1.0, NewLogNormal(50, KFixed(3.0)))
+
+	// a step change during the baseline inflates the variance but only one moving range
+	obs := append(randNorm(25, 5.0, 0.1, logNormalTransform), randNorm(25, 6.0, 0.1, logNormalTransform)...)
+	for _, o := range obs {
+		assert.NoError(t, s.Record(o))
+		assert.NoError(t, e.Record(o))
+	}
+	assert.Equal(t, TestingUCL, s.State())
+	assert.Equal(t, TestingUCL, e.State())
+	assert.True(t, s.Limit() < e.Limit(), "shewhart limit %g should be less than ewma limit %g", s.Limit(), e.Limit())
+}
+
+func TestShewhartLowerSide(t *testing.T) {
+	s, _ := NewShewhartStatistic("shewhart", NewLogNormal(50, KFixed(3.0)))
+	test, err := NewLogNormalTest(metric.NewName("test", nil), WithStatistic(s), WithTwoSided())
+	if err != nil {
+		t.Fatalf("unexpected error creating test: %v", err)
+	}
+	for _, sub := range test.sub {
+		assert.True(t, sub.movingRange)
+	}
+}
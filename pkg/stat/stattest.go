@@ -8,10 +8,19 @@ import (
 // Test applies one or more test statistics to a series of observations that is assumed to be log-normally
 // distributed.  It initially looks for changes from background in the positive direction (increasing latencies, etc.)
 // Once in an alarm condition, you must manually transition it to a new state to start testing for changes in the other direction (e.g., self correcting
-// temporary changes in latencies, etc.)
+// temporary changes in latencies, etc.)  Use WithTwoSided to test for changes in both directions at once.
 type Test struct {
-	name metric.Name
-	sub  []*TestStatistic
+	name    metric.Name
+	sub     []*TestStatistic
+	sampler *sampler
+
+	chartHistory int
+
+	bootstrapWarn  func(BootstrapWarning)
+	relaxBootstrap bool
+
+	twoSided bool
+	deadband float64
 }
 
 // LogNormalOption applies options to construct a custom estimator
@@ -22,6 +31,9 @@ func (t *Test) Name() string {
 }
 
 func (t *Test) Record(obs float64) error {
+	if t.sampler != nil && !t.sampler.allow() {
+		return nil
+	}
 	for _, s := range t.sub {
 		if err := s.Record(obs); err != nil {
 			return err
@@ -39,6 +51,7 @@ func (t *Test) Transition(state fsm.State, reset bool) error {
 	return nil
 }
 
+// HasAlarmed returns true if any statistic in the test has alarmed.  See Tripped for which side alarmed.
 func (t *Test) HasAlarmed() bool {
 	for _, s := range t.sub {
 		if s.HasAlarmed() {
@@ -56,6 +69,20 @@ func (t *Test) State() []fsm.State {
 	return out
 }
 
+// StateSummary returns the current state of each statistic by name, such as ewma=testing_ucl, for logging.  Statistics
+// of a two sided test are named with their side, such as ewma:lower.  Use State for programmatic checks.
+func (t *Test) StateSummary() map[string]string {
+	out := make(map[string]string, len(t.sub))
+	for _, s := range t.sub {
+		name := s.Name()
+		if t.twoSided {
+			name = name + ":" + string(s.Side())
+		}
+		out[name] = string(s.State())
+	}
+	return out
+}
+
 func (t *Test) Done() {
 	for _, s := range t.sub {
 		s.Done()
@@ -73,6 +100,10 @@ func WithStatistic(e *TestStatistic) TestOption {
 // Metric will return current values from all sub estimators.  It defines the following metrics identified by metadata:
 // <log field>[strategy=<(ewma|shewart)> type=estimator value=<(current|limit>]
 //
+// Two sided tests add side=<(upper|lower)> to identify the control limit tested by each statistic.
+//
+// Statistics that have alarmed add value=run_length and value=distance with their severity.  See TestStatistic.Severity.
+//
 // This gives the current value of the estimator and the testing limit.  This can be plotted as a spark line with the current
 // testing limit.
 //
@@ -81,14 +112,35 @@ func WithStatistic(e *TestStatistic) TestOption {
 func (e *Test) Metric() map[string]float64 {
 	out := make(map[string]float64)
 	for _, est := range e.sub {
+		md := map[string]string{"strategy": est.Name(), "type": "estimator"}
+		if e.twoSided {
+			md["side"] = string(est.Side())
+		}
+
 		nameValue := metric.NewNameFrom(e.name)
-		nameValue.AddMetadata(map[string]string{"strategy": est.Name(), "type": "estimator", "value": "current"})
+		nameValue.AddMetadata(md)
+		nameValue.AddMetadata(map[string]string{"value": "current"})
 
 		nameLimit := metric.NewNameFrom(e.name)
-		nameLimit.AddMetadata(map[string]string{"strategy": est.Name(), "type": "estimator", "value": "limit"})
+		nameLimit.AddMetadata(md)
+		nameLimit.AddMetadata(map[string]string{"value": "limit"})
 
 		out[nameValue.String()] = est.Value()
 		out[nameLimit.String()] = est.Limit()
+
+		if est.HasAlarmed() {
+			runLength, distance := est.Severity()
+			nameRunLength := metric.NewNameFrom(e.name)
+			nameRunLength.AddMetadata(md)
+			nameRunLength.AddMetadata(map[string]string{"value": "run_length"})
+
+			nameDistance := metric.NewNameFrom(e.name)
+			nameDistance.AddMetadata(md)
+			nameDistance.AddMetadata(map[string]string{"value": "distance"})
+
+			out[nameRunLength.String()] = float64(runLength)
+			out[nameDistance.String()] = distance
+		}
 	}
 	return out
 }
@@ -0,0 +1,99 @@
+package stat
+
+import "fmt"
+
+// Side identifies the control limit tested by a statistic
+type Side string
+
+const (
+	// Upper statistics alarm on an increase from the baseline by exceeding the UCL
+	Upper Side = "upper"
+	// Lower statistics alarm on a decrease from the baseline by falling below the LCL
+	Lower Side = "lower"
+)
+
+// Side returns the control limit tested by the statistic
+func (e *TestStatistic) Side() Side {
+	if e.lower {
+		return Lower
+	}
+	return Upper
+}
+
+// LowerSide returns a new statistic with the same name, lambda, distribution, and limit estimation that tests the LCL instead of the UCL.
+// The new statistic records its own series and establishes its own baseline.
+func (e *TestStatistic) LowerSide() (*TestStatistic, error) {
+	c, ok := e.pdf.(cloner)
+	if !ok {
+		return nil, fmt.Errorf("distribution %s of statistic %s does not support lower side testing", e.pdf.String(), e.name)
+	}
+	lower, err := newEWMAStatistic(e.name, e.lambda, c.clone(), true)
+	if err != nil {
+		return nil, err
+	}
+	lower.movingRange = e.movingRange
+	lower.deadband = e.deadband
+	return lower, nil
+}
+
+// cloner is implemented by distributions that can return an unused copy of themselves with the same parameters
+type cloner interface {
+	clone() PDF
+}
+
+func (p *LogNormal) clone() PDF {
+	c := *p
+	c.series = nil
+	return &c
+}
+
+func (p *Poisson) clone() PDF {
+	c := *p
+	c.done = nil
+	return &c
+}
+
+// WithTwoSided tests for decreases as well as increases from the baseline by adding a lower side statistic in parallel
+// to each statistic in the test.  Metrics are identified by side=(upper|lower) in their metadata.  See Test.Tripped.
+func WithTwoSided() TestOption {
+	return func(t *Test) error {
+		t.twoSided = true
+		return nil
+	}
+}
+
+// addLowerSide adds a lower side statistic for each statistic when configured with WithTwoSided
+func (t *Test) addLowerSide() error {
+	if !t.twoSided {
+		return nil
+	}
+	upper := t.sub
+	for _, s := range upper {
+		if s.lower {
+			continue
+		}
+		l, err := s.LowerSide()
+		if err != nil {
+			return err
+		}
+		t.sub = append(t.sub, l)
+	}
+	return nil
+}
+
+// Tripped returns which sides of the test have alarmed.  Only the upper side is tested unless the test is constructed
+// with WithTwoSided.
+func (t *Test) Tripped() (upper bool, lower bool) {
+	for _, s := range t.sub {
+		if !s.HasAlarmed() {
+			continue
+		}
+		switch s.Side() {
+		case Lower:
+			lower = true
+		default:
+			upper = true
+		}
+	}
+	return upper, lower
+}
@@ -0,0 +1,99 @@
+package stat
+
+import (
+	"testing"
+
+	"github.com/BTBurke/monny/pkg/fsm"
+	"github.com/BTBurke/monny/pkg/metric"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTwoSided(t *testing.T) {
+	tt := []struct {
+		Name  string
+		Mean  float64
+		Upper bool
+		Lower bool
+	}{
+		{Name: "no change", Mean: 5.0},
+		{Name: "increase", Mean: 8.0, Upper: true},
+		{Name: "decrease", Mean: 2.0, Lower: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			// a wide limit avoids false alarms when there is no change
+			ewma, _ := NewEWMAStatistic("ewma", 0.25, NewLogNormal(50, KFixed(10.0)))
+			test, err := NewLogNormalTest(metric.NewName("test", nil), WithStatistic(ewma), WithTwoSided())
+			if err != nil {
+				t.Fatalf("unexpected error creating test: %v", err)
+			}
+			assert.Equal(t, []fsm.State{UCLInitial, LCLInitial}, test.State())
+
+			obs := append(randNorm(100, 5.0, 0.1, logNormalTransform), randNorm(500, tc.Mean, 0.1, logNormalTransform)...)
+			for _, o := range obs {
+				assert.NoError(t, test.Record(o))
+			}
+			upper, lower := test.Tripped()
+			assert.Equal(t, tc.Upper, upper)
+			assert.Equal(t, tc.Lower, lower)
+			assert.Equal(t, tc.Upper || tc.Lower, test.HasAlarmed())
+		})
+	}
+}
+
+func TestTwoSidedReset(t *testing.T) {
+	test, err := NewLogNormalTest(metric.NewName("test", nil), WithStatistic(DefaultLogNormalShewart()), WithTwoSided())
+	if err != nil {
+		t.Fatalf("unexpected error creating test: %v", err)
+	}
+	for _, o := range randNorm(60, 5.0, 0.1, logNormalTransform) {
+		assert.NoError(t, test.Record(o))
+	}
+	assert.Equal(t, []fsm.State{TestingUCL, TestingLCL}, test.State())
+
+	// each side collects a new baseline for its own control limit after a reset
+	assert.NoError(t, test.Transition(Reset, true))
+	assert.NoError(t, test.Record(logNormalTransform(5.0)))
+	assert.Equal(t, []fsm.State{UCLInitial, LCLInitial}, test.State())
+}
+
+func TestTwoSidedSeeded(t *testing.T) {
+	pdf, err := LogNormalFromUnits(50, 50.0, 20.0, KFixed(3.0))
+	if err != nil {
+		t.Fatalf("unexpected error creating log normal: %v", err)
+	}
+	shewart, err := NewEWMAStatistic("shewart", 1.0, pdf)
+	if err != nil {
+		t.Fatalf("unexpected error creating statistic: %v", err)
+	}
+	lower, err := shewart.LowerSide()
+	if err != nil {
+		t.Fatalf("unexpected error creating lower side: %v", err)
+	}
+	assert.Equal(t, Lower, lower.Side())
+	assert.Equal(t, TestingLCL, lower.State())
+	assert.Equal(t, shewart.lcl, lower.Limit())
+	assert.NoError(t, lower.Record(5.0))
+	assert.True(t, lower.HasAlarmed())
+	assert.False(t, shewart.HasAlarmed())
+}
+
+func TestTwoSidedMetric(t *testing.T) {
+	n, err := NewPoissonTest(metric.NewName("test_error_rate", nil), WithStatistic(DefaultPoissonEWMA()), WithTwoSided())
+	if err != nil {
+		t.Fatalf("unexpected error creating test: %v", err)
+	}
+	defer n.Done()
+	if !assert.Len(t, n.sub, 2) {
+		return
+	}
+	n.sub[0].current, n.sub[0].limit = 3.0, 4.0
+	n.sub[1].current, n.sub[1].limit = 3.0, 2.0
+	exp := map[string]float64{
+		"test_error_rate[side=upper strategy=ewma type=estimator value=current]": 3.0,
+		"test_error_rate[side=upper strategy=ewma type=estimator value=limit]":   4.0,
+		"test_error_rate[side=lower strategy=ewma type=estimator value=current]": 3.0,
+		"test_error_rate[side=lower strategy=ewma type=estimator value=limit]":   2.0,
+	}
+	assert.Equal(t, exp, n.Metric())
+}
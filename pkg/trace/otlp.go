@@ -0,0 +1,222 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var _ SpanExporter = &OTLPExporter{}
+
+// otlpTracesPath is the default path of the traces endpoint of an OTLP/HTTP collector
+const otlpTracesPath = "/v1/traces"
+
+// otlpSpanKindInternal is the span kind of spans that do not represent a remote call
+const otlpSpanKindInternal = 1
+
+// OTLPExporter exports spans to an OpenTelemetry collector using OTLP over HTTP with the JSON encoding
+type OTLPExporter struct {
+	url     string
+	service string
+	client  *http.Client
+}
+
+// NewOTLPExporter returns an exporter that sends spans to the OTLP/HTTP endpoint of a collector, such as
+// localhost:4318 or https://collector.example.com/v1/traces.  Endpoints without a scheme use http, and the
+// /v1/traces path is added to endpoints without a path.  Spans are exported with the resource service.name
+// set to service.
+func NewOTLPExporter(endpoint string, service string) (*OTLPExporter, error) {
+	raw := endpoint
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTLP endpoint %s: %v", endpoint, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return nil, fmt.Errorf("invalid OTLP endpoint %s, should be a host:port or an http or https URL", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = otlpTracesPath
+	}
+	return &OTLPExporter{
+		url:     u.String(),
+		service: service,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// URL returns the URL to which spans are exported
+func (e *OTLPExporter) URL() string {
+	return e.url
+}
+
+func (e *OTLPExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	body, err := json.Marshal(otlpEncode(e.service, spans))
+	if err != nil {
+		return fmt.Errorf("could not encode spans: %v", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("could not create OTLP request: %v", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not export spans to %s: %v", e.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("could not export spans to %s: %s %s", e.url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+// otlpStatus codes are the same as StatusCode
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+// otlpAnyValue sets one of its fields.  64 bit integers are encoded as strings in OTLP JSON.
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+// otlpEncode groups spans by the tracer that started them in a single resource
+func otlpEncode(service string, spans []SpanData) otlpRequest {
+	var scopes []otlpScopeSpans
+	index := make(map[string]int)
+	for _, s := range spans {
+		i, ok := index[s.Scope]
+		if !ok {
+			i = len(scopes)
+			index[s.Scope] = i
+			scopes = append(scopes, otlpScopeSpans{Scope: otlpScope{Name: s.Scope}})
+		}
+		scopes[i].Spans = append(scopes[i].Spans, otlpEncodeSpan(s))
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes([]Attribute{String("service.name", service)})},
+		ScopeSpans: scopes,
+	}}}
+}
+
+func otlpEncodeSpan(s SpanData) otlpSpan {
+	out := otlpSpan{
+		TraceID:           s.SpanContext.TraceID.String(),
+		SpanID:            s.SpanContext.SpanID.String(),
+		Name:              s.Name,
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: otlpTime(s.StartTime),
+		EndTimeUnixNano:   otlpTime(s.EndTime),
+		Attributes:        otlpAttributes(s.Attributes),
+		Status:            otlpStatus{Code: int(s.Status), Message: s.StatusDescription},
+	}
+	if s.Parent.IsValid() {
+		out.ParentSpanID = s.Parent.SpanID.String()
+	}
+	for _, e := range s.Events {
+		out.Events = append(out.Events, otlpEvent{
+			TimeUnixNano: otlpTime(e.Time),
+			Name:         e.Name,
+			Attributes:   otlpAttributes(e.Attributes),
+		})
+	}
+	return out
+}
+
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func otlpAttributes(attrs []Attribute) []otlpKeyValue {
+	out := make([]otlpKeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		var v otlpAnyValue
+		switch value := attr.Value.(type) {
+		case string:
+			v.StringValue = &value
+		case int64:
+			s := strconv.FormatInt(value, 10)
+			v.IntValue = &s
+		case int:
+			s := strconv.Itoa(value)
+			v.IntValue = &s
+		case float64:
+			v.DoubleValue = &value
+		case bool:
+			v.BoolValue = &value
+		default:
+			s := fmt.Sprint(value)
+			v.StringValue = &s
+		}
+		out = append(out, otlpKeyValue{Key: attr.Key, Value: v})
+	}
+	return out
+}
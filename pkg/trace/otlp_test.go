@@ -0,0 +1,110 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOTLPExporter(t *testing.T) {
+	tt := []struct {
+		name     string
+		endpoint string
+		url      string
+		err      bool
+	}{
+		{name: "host port", endpoint: "localhost:4318", url: "http://localhost:4318/v1/traces"},
+		{name: "url", endpoint: "https://collector.example.com", url: "https://collector.example.com/v1/traces"},
+		{name: "url with path", endpoint: "http://collector:4318/custom/traces", url: "http://collector:4318/custom/traces"},
+		{name: "unsupported scheme", endpoint: "grpc://collector:4317", err: true},
+		{name: "no host", endpoint: "http://", err: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := NewOTLPExporter(tc.endpoint, "test")
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.url, e.URL())
+		})
+	}
+}
+
+func TestOTLPExport(t *testing.T) {
+	var body map[string]interface{}
+	var path, contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, contentType = r.URL.Path, r.Header.Get("Content-Type")
+		data, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+	}))
+	defer srv.Close()
+
+	e, err := NewOTLPExporter(srv.URL, "monny")
+	if err != nil {
+		t.Fatalf("unexpected error creating exporter: %v", err)
+	}
+	start := time.Unix(1, 0)
+	span := SpanData{
+		Scope:       "test",
+		Name:        "span",
+		SpanContext: SpanContext{TraceID: TraceID{1}, SpanID: SpanID{2}, TraceFlags: FlagsSampled},
+		Parent:      SpanContext{TraceID: TraceID{1}, SpanID: SpanID{3}},
+		StartTime:   start,
+		EndTime:     start.Add(time.Second),
+		Attributes:  []Attribute{String("s", "v"), Int64("i", 3), Float64("f", 1.5), Bool("b", true)},
+		Events:      []Event{{Name: "event", Time: start}},
+		Status:      StatusError,
+	}
+	assert.NoError(t, e.ExportSpans(context.Background(), []SpanData{span}))
+	assert.NoError(t, e.Shutdown(context.Background()))
+	assert.Equal(t, "/v1/traces", path)
+	assert.Equal(t, "application/json", contentType)
+
+	var expected map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"resourceSpans": [{
+		"resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "monny"}}]},
+		"scopeSpans": [{"scope": {"name": "test"}, "spans": [{
+			"traceId": "01000000000000000000000000000000",
+			"spanId": "0200000000000000",
+			"parentSpanId": "0300000000000000",
+			"name": "span",
+			"kind": 1,
+			"startTimeUnixNano": "1000000000",
+			"endTimeUnixNano": "2000000000",
+			"attributes": [
+				{"key": "s", "value": {"stringValue": "v"}},
+				{"key": "i", "value": {"intValue": "3"}},
+				{"key": "f", "value": {"doubleValue": 1.5}},
+				{"key": "b", "value": {"boolValue": true}}
+			],
+			"events": [{"timeUnixNano": "1000000000", "name": "event"}],
+			"status": {"code": 2}
+		}]}]
+	}]}`), &expected))
+	assert.Equal(t, expected, body)
+}
+
+func TestOTLPExportError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "collector unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	e, err := NewOTLPExporter(srv.URL, "monny")
+	if err != nil {
+		t.Fatalf("unexpected error creating exporter: %v", err)
+	}
+	err = e.ExportSpans(context.Background(), []SpanData{{Name: "span"}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "collector unavailable")
+	}
+}
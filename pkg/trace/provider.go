@@ -0,0 +1,201 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+	"time"
+)
+
+var _ TracerProvider = &Provider{}
+
+// SpanData is a span that has ended, as passed to an exporter
+type SpanData struct {
+	// Scope is the name of the tracer that started the span
+	Scope             string
+	Name              string
+	SpanContext       SpanContext
+	Parent            SpanContext
+	StartTime         time.Time
+	EndTime           time.Time
+	Attributes        []Attribute
+	Events            []Event
+	Status            StatusCode
+	StatusDescription string
+}
+
+// Event is a named point in time during a span
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes []Attribute
+}
+
+// SpanExporter sends ended spans to a tracing backend
+type SpanExporter interface {
+	ExportSpans(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// Provider is a TracerProvider that records spans and passes them to an exporter when they end.  Ended spans are held
+// until Shutdown so that a short-lived process exports all of its spans together.
+type Provider struct {
+	exporter SpanExporter
+
+	mutex sync.Mutex
+	ended []SpanData
+}
+
+// NewProvider returns a provider that exports spans with exporter
+func NewProvider(exporter SpanExporter) *Provider {
+	return &Provider{exporter: exporter}
+}
+
+func (p *Provider) Tracer(name string) Tracer {
+	return &tracer{name: name, provider: p}
+}
+
+// ForceFlush exports the spans that have ended
+func (p *Provider) ForceFlush(ctx context.Context) error {
+	p.mutex.Lock()
+	spans := p.ended
+	p.ended = nil
+	p.mutex.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+	return p.exporter.ExportSpans(ctx, spans)
+}
+
+// Shutdown exports the spans that have ended and shuts down the exporter
+func (p *Provider) Shutdown(ctx context.Context) error {
+	err := p.ForceFlush(ctx)
+	if serr := p.exporter.Shutdown(ctx); err == nil {
+		err = serr
+	}
+	return err
+}
+
+func (p *Provider) end(s SpanData) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.ended = append(p.ended, s)
+}
+
+type tracer struct {
+	name     string
+	provider *Provider
+}
+
+func (t *tracer) Start(ctx context.Context, name string, opts ...SpanOption) (context.Context, Span) {
+	parent := SpanContextFromContext(ctx)
+	sc := SpanContext{TraceID: parent.TraceID, TraceFlags: FlagsSampled}
+	if !parent.IsValid() {
+		rand.Read(sc.TraceID[:])
+	} else {
+		sc.TraceFlags = parent.TraceFlags
+	}
+	rand.Read(sc.SpanID[:])
+
+	s := &span{
+		provider: t.provider,
+		data: SpanData{
+			Scope:       t.name,
+			Name:        name,
+			SpanContext: sc,
+			Parent:      parent,
+			StartTime:   time.Now(),
+			Attributes:  NewSpanConfig(opts...).Attributes,
+		},
+	}
+	return ContextWithSpan(ctx, s), s
+}
+
+// span records changes until it is ended, after which changes are ignored
+type span struct {
+	provider *Provider
+
+	mutex sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+func (s *span) SpanContext() SpanContext {
+	return s.data.SpanContext
+}
+
+func (s *span) AddEvent(name string, attrs ...Attribute) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ended {
+		return
+	}
+	s.data.Events = append(s.data.Events, Event{Name: name, Time: time.Now(), Attributes: attrs})
+}
+
+func (s *span) SetAttributes(attrs ...Attribute) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ended {
+		return
+	}
+	s.data.Attributes = append(s.data.Attributes, attrs...)
+}
+
+func (s *span) SetStatus(code StatusCode, description string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ended {
+		return
+	}
+	s.data.Status = code
+	s.data.StatusDescription = description
+}
+
+func (s *span) End() {
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.EndTime = time.Now()
+	data := s.data
+	s.mutex.Unlock()
+	s.provider.end(data)
+}
+
+// InMemoryExporter retains exported spans, for tests
+type InMemoryExporter struct {
+	mutex sync.Mutex
+	spans []SpanData
+}
+
+func (e *InMemoryExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *InMemoryExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// Spans returns the spans that have been exported
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]SpanData(nil), e.spans...)
+}
+
+// Attribute returns the value of the last attribute of the span with key, or nil if it is not set
+func (s SpanData) Attribute(key string) interface{} {
+	var v interface{}
+	for _, attr := range s.Attributes {
+		if attr.Key == key {
+			v = attr.Value
+		}
+	}
+	return v
+}
@@ -0,0 +1,62 @@
+package trace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider(t *testing.T) {
+	exporter := &InMemoryExporter{}
+	p := NewProvider(exporter)
+	tr := p.Tracer("test")
+
+	ctx, parent := tr.Start(context.Background(), "parent", WithAttributes(String("a", "b")))
+	_, child := tr.Start(ctx, "child")
+	child.AddEvent("match", Int64("line", 3))
+	child.End()
+	parent.SetAttributes(Int64("exit", 1))
+	parent.SetStatus(StatusError, "failed")
+	parent.End()
+
+	// changes after a span ends are ignored
+	parent.SetAttributes(String("late", "value"))
+	parent.End()
+
+	// spans are exported on shutdown
+	assert.Empty(t, exporter.Spans())
+	assert.NoError(t, p.Shutdown(context.Background()))
+	spans := exporter.Spans()
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+	c, s := spans[0], spans[1]
+	assert.Equal(t, "child", c.Name)
+	assert.Equal(t, "test", c.Scope)
+	assert.Equal(t, s.SpanContext, c.Parent)
+	assert.Equal(t, s.SpanContext.TraceID, c.SpanContext.TraceID)
+	assert.Equal(t, []Event{{Name: "match", Time: c.Events[0].Time, Attributes: []Attribute{Int64("line", 3)}}}, c.Events)
+
+	assert.Equal(t, "parent", s.Name)
+	assert.False(t, s.Parent.IsValid())
+	assert.True(t, s.SpanContext.IsValid())
+	assert.True(t, s.SpanContext.IsSampled())
+	assert.Equal(t, "b", s.Attribute("a"))
+	assert.Equal(t, int64(1), s.Attribute("exit"))
+	assert.Nil(t, s.Attribute("late"))
+	assert.Equal(t, StatusError, s.Status)
+	assert.Equal(t, "failed", s.StatusDescription)
+	assert.False(t, s.EndTime.Before(s.StartTime))
+}
+
+func TestProviderRemoteParent(t *testing.T) {
+	sc, err := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	if err != nil {
+		t.Fatalf("unexpected error parsing traceparent: %v", err)
+	}
+	_, span := NewProvider(&InMemoryExporter{}).Tracer("test").Start(ContextWithRemoteSpanContext(context.Background(), sc), "span")
+	assert.Equal(t, sc.TraceID, span.SpanContext().TraceID)
+	assert.NotEqual(t, sc.SpanID, span.SpanContext().SpanID)
+	assert.False(t, span.SpanContext().IsSampled())
+}
@@ -0,0 +1,238 @@
+package trace
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TraceParentEnv is the environment variable used to propagate a W3C trace context to and from a process
+const TraceParentEnv = "TRACEPARENT"
+
+// FlagsSampled is set in the trace flags when the trace is sampled
+const FlagsSampled byte = 0x01
+
+// TracerProvider provides tracers for instrumenting a library or application.  It follows the OpenTelemetry API so
+// that an adapter to an OpenTelemetry SDK only needs to convert between types.
+type TracerProvider interface {
+	Tracer(name string) Tracer
+}
+
+// Tracer creates spans
+type Tracer interface {
+	// Start creates a span that is a child of the span or remote span context in ctx, if any.  The returned context
+	// contains the new span.
+	Start(ctx context.Context, name string, opts ...SpanOption) (context.Context, Span)
+}
+
+// Span is a single operation within a trace
+type Span interface {
+	SpanContext() SpanContext
+	AddEvent(name string, attrs ...Attribute)
+	SetAttributes(attrs ...Attribute)
+	SetStatus(code StatusCode, description string)
+	End()
+}
+
+// StatusCode is the status of a span when it ends
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = iota
+	StatusOK
+	StatusError
+)
+
+func (s StatusCode) String() string {
+	switch s {
+	case StatusOK:
+		return "Ok"
+	case StatusError:
+		return "Error"
+	default:
+		return "Unset"
+	}
+}
+
+// Attribute is a key value pair that describes a span or event
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a string attribute
+func String(key string, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int64 returns an integer attribute
+func Int64(key string, value int64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Float64 returns a float attribute
+func Float64(key string, value float64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Bool returns a boolean attribute
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// SpanConfig holds options applied when a span is started
+type SpanConfig struct {
+	Attributes []Attribute
+}
+
+// SpanOption configures a span when it is started
+type SpanOption func(*SpanConfig)
+
+// WithAttributes sets attributes on the span when it is started
+func WithAttributes(attrs ...Attribute) SpanOption {
+	return func(c *SpanConfig) {
+		c.Attributes = append(c.Attributes, attrs...)
+	}
+}
+
+// NewSpanConfig applies options to a span configuration.  This is used by tracer implementations.
+func NewSpanConfig(opts ...SpanOption) SpanConfig {
+	c := SpanConfig{}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// IsValid returns true if the trace ID is not all zeros
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+// IsValid returns true if the span ID is not all zeros
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// SpanContext identifies a span and is propagated across process boundaries
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	TraceFlags byte
+	Remote     bool
+}
+
+// IsValid returns true if both the trace and span IDs are valid
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// IsSampled returns true if the sampled flag is set
+func (sc SpanContext) IsSampled() bool {
+	return sc.TraceFlags&FlagsSampled == FlagsSampled
+}
+
+// TraceParent formats the span context as a W3C traceparent header value
+func (sc SpanContext) TraceParent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.TraceFlags)
+}
+
+// ParseTraceParent parses a W3C traceparent header value (version-traceid-spanid-flags).  The returned span context
+// is marked as remote.
+func ParseTraceParent(traceparent string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 {
+		return SpanContext{}, fmt.Errorf("invalid traceparent: %s", traceparent)
+	}
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || len(version) != 1 || version[0] == 0xff || (version[0] == 0 && len(parts) != 4) {
+		return SpanContext{}, fmt.Errorf("invalid traceparent version: %s", traceparent)
+	}
+
+	sc := SpanContext{Remote: true}
+	if err := decodeID(parts[1], sc.TraceID[:]); err != nil {
+		return SpanContext{}, fmt.Errorf("invalid traceparent trace id: %s", traceparent)
+	}
+	if err := decodeID(parts[2], sc.SpanID[:]); err != nil {
+		return SpanContext{}, fmt.Errorf("invalid traceparent span id: %s", traceparent)
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || len(flags) != 1 {
+		return SpanContext{}, fmt.Errorf("invalid traceparent flags: %s", traceparent)
+	}
+	sc.TraceFlags = flags[0]
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("invalid traceparent, trace and span id must not be zero: %s", traceparent)
+	}
+	return sc, nil
+}
+
+// decodeID decodes lowercase hex into id, which must be exactly the length of id
+func decodeID(s string, id []byte) error {
+	if len(s) != 2*len(id) || strings.ToLower(s) != s {
+		return fmt.Errorf("invalid id length")
+	}
+	_, err := hex.Decode(id, []byte(s))
+	return err
+}
+
+type spanContextKey struct{}
+type spanKey struct{}
+
+// ContextWithRemoteSpanContext returns a context with a span context received from another process to be used as
+// the parent of the next span started
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// ContextWithSpan returns a context containing span
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the current span in ctx or nil if there is none
+func SpanFromContext(ctx context.Context) Span {
+	span, _ := ctx.Value(spanKey{}).(Span)
+	return span
+}
+
+// SpanContextFromContext returns the span context of the current span in ctx, or the remote span context if there
+// is no current span.  Returns an invalid span context if neither exist.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext()
+	}
+	sc, _ := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc
+}
+
+// ContextFromEnvironment returns a context with the remote span context from the TRACEPARENT environment variable,
+// if it is set and valid.  Otherwise ctx is returned unchanged.
+func ContextFromEnvironment(ctx context.Context) context.Context {
+	tp, ok := os.LookupEnv(TraceParentEnv)
+	if !ok {
+		return ctx
+	}
+	sc, err := ParseTraceParent(tp)
+	if err != nil {
+		return ctx
+	}
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
@@ -0,0 +1,72 @@
+package trace
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	tt := []struct {
+		name  string
+		tp    string
+		trace string
+		span  string
+		flags byte
+		err   bool
+	}{
+		{name: "sampled", tp: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", trace: "4bf92f3577b34da6a3ce929d0e0e4736", span: "00f067aa0ba902b7", flags: 0x01},
+		{name: "not sampled", tp: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", trace: "4bf92f3577b34da6a3ce929d0e0e4736", span: "00f067aa0ba902b7", flags: 0x00},
+		{name: "future version", tp: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", trace: "4bf92f3577b34da6a3ce929d0e0e4736", span: "00f067aa0ba902b7", flags: 0x01},
+		{name: "version 00 extra fields", tp: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", err: true},
+		{name: "invalid version", tp: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", err: true},
+		{name: "zero trace id", tp: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", err: true},
+		{name: "zero span id", tp: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", err: true},
+		{name: "short trace id", tp: "00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01", err: true},
+		{name: "uppercase", tp: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", err: true},
+		{name: "not hex", tp: "00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01", err: true},
+		{name: "missing fields", tp: "00-4bf92f3577b34da6a3ce929d0e0e4736", err: true},
+		{name: "empty", tp: "", err: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			sc, err := ParseTraceParent(tc.tp)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.trace, sc.TraceID.String())
+			assert.Equal(t, tc.span, sc.SpanID.String())
+			assert.Equal(t, tc.flags, sc.TraceFlags)
+			assert.True(t, sc.Remote)
+			assert.Equal(t, tc.flags == FlagsSampled, sc.IsSampled())
+		})
+	}
+}
+
+func TestTraceParentRoundTrip(t *testing.T) {
+	tp := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceParent(tp)
+	assert.NoError(t, err)
+	assert.Equal(t, tp, sc.TraceParent())
+}
+
+func TestContextFromEnvironment(t *testing.T) {
+	tp := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	defer os.Unsetenv(TraceParentEnv)
+
+	os.Unsetenv(TraceParentEnv)
+	assert.False(t, SpanContextFromContext(ContextFromEnvironment(context.Background())).IsValid())
+
+	os.Setenv(TraceParentEnv, "invalid")
+	assert.False(t, SpanContextFromContext(ContextFromEnvironment(context.Background())).IsValid())
+
+	os.Setenv(TraceParentEnv, tp)
+	sc := SpanContextFromContext(ContextFromEnvironment(context.Background()))
+	assert.True(t, sc.IsValid())
+	assert.True(t, sc.Remote)
+	assert.Equal(t, tp, sc.TraceParent())
+}
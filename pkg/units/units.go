@@ -0,0 +1,86 @@
+// Package units parses the human-friendly byte sizes and durations accepted in configuration
+package units
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// byteUnits maps lower case suffixes to their size in bytes.  Suffixes without an i are decimal (1K = 1000) and
+// suffixes with an i are binary (1Ki = 1024).
+var byteUnits = map[string]uint64{
+	"":    1,
+	"b":   1,
+	"k":   1e3,
+	"kb":  1e3,
+	"m":   1e6,
+	"mb":  1e6,
+	"g":   1e9,
+	"gb":  1e9,
+	"t":   1e12,
+	"tb":  1e12,
+	"ki":  1 << 10,
+	"kib": 1 << 10,
+	"mi":  1 << 20,
+	"mib": 1 << 20,
+	"gi":  1 << 30,
+	"gib": 1 << 30,
+	"ti":  1 << 40,
+	"tib": 1 << 40,
+}
+
+// ParseBytes returns the number of bytes in a size such as 512, 100MB, 1.5G, or 2GiB.  Suffixes are case-insensitive.
+// Decimal suffixes (K, M, G, T) are powers of 1000 and binary suffixes (Ki, Mi, Gi, Ti) are powers of 1024, with or
+// without a trailing B.  Fractional sizes are rounded down to a whole byte.
+func ParseBytes(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+	number, suffix := s[:i], strings.ToLower(strings.TrimSpace(s[i:]))
+	unit, ok := byteUnits[suffix]
+	if len(number) == 0 || !ok {
+		return 0, fmt.Errorf("invalid size %q, should be a number with an optional unit such as K, M, G, KiB, MiB, or GiB", s)
+	}
+
+	// whole numbers are parsed exactly, fractions as floating point
+	if n, err := strconv.ParseUint(number, 10, 64); err == nil {
+		if n > math.MaxUint64/unit {
+			return 0, fmt.Errorf("size %q is too large", s)
+		}
+		return n * unit, nil
+	}
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %v", s, err)
+	}
+	size := f * float64(unit)
+	if size >= math.MaxUint64 {
+		return 0, fmt.Errorf("size %q is too large", s)
+	}
+	return uint64(size), nil
+}
+
+// ParseDuration returns the duration of a Go duration string such as 1h30m or 250ms, or of a number without a unit,
+// which is a number of seconds.  Numbers allow YAML values such as timeout-warn: 30.
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if n > math.MaxInt64/int64(time.Second) || n < math.MinInt64/int64(time.Second) {
+			return 0, fmt.Errorf("duration %q is too large", s)
+		}
+		return time.Duration(n) * time.Second, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		d := f * float64(time.Second)
+		if math.IsNaN(d) || math.Abs(d) >= math.MaxInt64 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(d), nil
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,82 @@
+package units
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBytes(t *testing.T) {
+	tt := []struct {
+		Name   string
+		Value  string
+		Expect uint64
+		Error  bool
+	}{
+		{Name: "bytes", Value: "512", Expect: 512},
+		{Name: "bytes suffix", Value: "512B", Expect: 512},
+		{Name: "kilobytes", Value: "2K", Expect: 2000},
+		{Name: "kilobytes KB", Value: "2KB", Expect: 2000},
+		{Name: "megabytes lower case", Value: "100mb", Expect: 100e6},
+		{Name: "gigabytes lower case", Value: "2g", Expect: 2e9},
+		{Name: "terabytes", Value: "1T", Expect: 1e12},
+		{Name: "fractional gigabytes", Value: "1.5G", Expect: 1.5e9},
+		{Name: "kibibytes", Value: "512Ki", Expect: 512 * 1024},
+		{Name: "mebibytes", Value: "100MiB", Expect: 100 * 1024 * 1024},
+		{Name: "gibibytes mixed case", Value: "2gIb", Expect: 2 * 1024 * 1024 * 1024},
+		{Name: "fractional mebibytes", Value: "0.5MiB", Expect: 512 * 1024},
+		{Name: "space before unit", Value: " 3 M ", Expect: 3e6},
+		{Name: "fraction rounded down", Value: "1.5", Expect: 1},
+		{Name: "unknown unit", Value: "2X", Error: true},
+		{Name: "unit only", Value: "MB", Error: true},
+		{Name: "empty", Value: "", Error: true},
+		{Name: "negative", Value: "-1K", Error: true},
+		{Name: "two decimal points", Value: "1.2.3M", Error: true},
+		{Name: "too large", Value: "20000000TiB", Error: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			b, err := ParseBytes(tc.Value)
+			switch tc.Error {
+			case true:
+				assert.Error(t, err)
+			default:
+				assert.NoError(t, err)
+				assert.Equal(t, tc.Expect, b)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tt := []struct {
+		Name   string
+		Value  string
+		Expect time.Duration
+		Error  bool
+	}{
+		{Name: "seconds", Value: "30", Expect: 30 * time.Second},
+		{Name: "zero", Value: "0", Expect: 0},
+		{Name: "fractional seconds", Value: "1.5", Expect: 1500 * time.Millisecond},
+		{Name: "go duration", Value: "1h30m", Expect: 90 * time.Minute},
+		{Name: "milliseconds", Value: "250ms", Expect: 250 * time.Millisecond},
+		{Name: "negative", Value: "-5s", Expect: -5 * time.Second},
+		{Name: "spaces", Value: " 10m ", Expect: 10 * time.Minute},
+		{Name: "unknown unit", Value: "5T", Error: true},
+		{Name: "empty", Value: "", Error: true},
+		{Name: "too large", Value: "100000000000", Error: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			d, err := ParseDuration(tc.Value)
+			switch tc.Error {
+			case true:
+				assert.Error(t, err)
+			default:
+				assert.NoError(t, err)
+				assert.Equal(t, tc.Expect, d)
+			}
+		})
+	}
+}